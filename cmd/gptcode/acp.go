@@ -13,12 +13,14 @@ import (
 	"gptcode/internal/config"
 	"gptcode/internal/llm"
 	"gptcode/internal/maestro"
+	"gptcode/internal/telemetry"
 	"gptcode/internal/tools"
 )
 
 var (
-	acpHTTP bool
-	acpPort int
+	acpHTTP        bool
+	acpPort        int
+	acpMetricsAddr string
 )
 
 var acpCmd = &cobra.Command{
@@ -30,6 +32,8 @@ By default, uses stdio transport (stdin/stdout). Any ACP-compatible editor
 (Zed, JetBrains, Neovim, VS Code) can launch this as a subprocess.
 
 With --http, starts an HTTP server for remote clients (e.g., Live dashboard).
+It also serves Prometheus metrics at /metrics; over stdio, set --metrics-addr
+to serve them.
 
 For more information, see: https://agentclientprotocol.com`,
 	RunE: runACP,
@@ -38,6 +42,7 @@ For more information, see: https://agentclientprotocol.com`,
 func init() {
 	acpCmd.Flags().BoolVar(&acpHTTP, "http", false, "Use HTTP transport instead of stdio")
 	acpCmd.Flags().IntVar(&acpPort, "port", 8080, "HTTP port (only with --http)")
+	acpCmd.Flags().StringVar(&acpMetricsAddr, "metrics-addr", "", "Serve Prometheus metrics at /metrics on this address (e.g. :9090)")
 	rootCmd.AddCommand(acpCmd)
 }
 
@@ -61,10 +66,19 @@ func runACP(cmd *cobra.Command, args []string) error {
 	server := acp.NewServer(handler)
 	handler.server = server
 
+	if acpMetricsAddr != "" {
+		stop, err := serveMetrics(acpMetricsAddr)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
 	if acpHTTP {
 		// HTTP transport for remote clients (Live dashboard, Fly.io)
 		fmt.Fprintf(os.Stderr, "[ACP] Starting HTTP transport on :%d\n", acpPort)
 		transport := acp.NewHTTPTransport(server, acpPort)
+		transport.Handle(telemetry.MetricsPath, telemetry.MetricsHandler())
 		return transport.ListenAndServe(ctx)
 	}
 
@@ -10,7 +10,9 @@ import (
 	"github.com/spf13/cobra"
 
 	"gptcode/internal/config"
+	"gptcode/internal/events"
 	"gptcode/internal/intelligence"
+	"gptcode/internal/ledger"
 	"gptcode/internal/live"
 	"gptcode/internal/llm"
 	"gptcode/internal/modes"
@@ -59,6 +61,16 @@ func init() {
 	doCmd.Flags().BoolP("interactive", "i", false, "Prompt for model selection when multiple options are similar")
 }
 
+// doResult is what gt do reports as its result in json and ndjson output
+type doResult struct {
+	Task     string `json:"task"`
+	Backend  string `json:"backend,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Attempts int    `json:"attempts"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+	Analysis string `json:"analysis,omitempty"`
+}
+
 func runDoAnalysis(task string, verbose bool) error {
 	setup, err := config.LoadSetup()
 	if err != nil {
@@ -74,6 +86,7 @@ func runDoAnalysis(task string, verbose bool) error {
 	} else {
 		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
 	}
+	provider = llm.ReplayFromEnv(provider)
 
 	queryModel := backendCfg.GetModelForAgent("query")
 
@@ -100,6 +113,8 @@ Provide a brief analysis.`, task)
 		return fmt.Errorf("analysis failed: %w", err)
 	}
 
+	events.SetResult(doResult{Task: task, DryRun: true, Analysis: resp.Text})
+
 	fmt.Println(resp.Text)
 	fmt.Println("\n=== Execution Plan ===")
 	fmt.Println("This would create a detailed plan and execute using guided mode.")
@@ -145,6 +160,13 @@ func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervi
 		}
 	}
 
+	if !setup.AllowsModel("editor", editorBackend, editorModel) {
+		return fmt.Errorf("editor model %s/%s is not allowed by %s", editorBackend, editorModel, config.ProjectConfigFile)
+	}
+	if !setup.AllowsModel("query", queryBackend, queryModel) {
+		return fmt.Errorf("query model %s/%s is not allowed by %s", queryBackend, queryModel, config.ProjectConfigFile)
+	}
+
 	if verbose {
 		fmt.Fprintf(os.Stderr, "Auto-selected models:\n")
 		fmt.Fprintf(os.Stderr, "   Editor: %s/%s - %s\n", editorBackend, editorModel, editorReason)
@@ -154,12 +176,16 @@ func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervi
 
 	currentBackend := editorBackend
 	currentEditorModel := editorModel
+	totalAttempts := 0
 
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		if attempt > 1 && verbose {
 			fmt.Fprintf(os.Stderr, "\n=== Attempt %d/%d ===\n", attempt, maxAttempts)
 		}
 
+		totalAttempts++
+		events.SetResult(doResult{Task: task, Backend: currentBackend, Model: currentEditorModel, Attempts: totalAttempts})
+
 		startTime := time.Now()
 		err := runDoExecution(task, verbose, supervised, setup, currentBackend, currentEditorModel)
 		elapsed := time.Since(startTime).Milliseconds()
@@ -202,7 +228,7 @@ func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervi
 
 		if attempt >= maxAttempts {
 			// If we hit max attempts with API errors, offer to try a different backend
-			if looksLikeAPIError {
+			if looksLikeAPIError && !events.Headless() {
 				fmt.Fprintf(os.Stderr, "\n  All %d attempts failed with API/rate limit errors.\n", maxAttempts)
 				fmt.Fprintf(os.Stderr, "\nAvailable backends:\n")
 				var backends []string
@@ -244,8 +270,11 @@ func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervi
 			fmt.Fprintf(os.Stderr, "Asking intelligence system for alternative model...\n")
 		}
 
-		recommendations, err := intelligence.RecommendModelForRetry(setup, "editor", currentBackend, currentEditorModel, task)
-		if err != nil || len(recommendations) == 0 {
+		recommendations, recErr := intelligence.RecommendModelForRetry(setup, "editor", currentBackend, currentEditorModel, task)
+		if recErr != nil || len(recommendations) == 0 {
+			if events.Headless() {
+				return fmt.Errorf("task failed and no other model is available: %w", err)
+			}
 			// No automatic recommendations - ask user
 			fmt.Fprintf(os.Stderr, "\n  No suitable models found automatically.\n")
 			fmt.Fprintf(os.Stderr, "\nAvailable backends:\n")
@@ -308,6 +337,7 @@ func runDoExecution(task string, verbose bool, supervised bool, setup *config.Se
 	backendCfg := setup.Backend[backendName]
 
 	cwd, _ := os.Getwd()
+	ctx := ledger.WithTask(context.Background(), task)
 
 	var provider llm.Provider
 	if backendCfg.Type == "ollama" {
@@ -315,6 +345,7 @@ func runDoExecution(task string, verbose bool, supervised bool, setup *config.Se
 	} else {
 		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
 	}
+	provider = llm.ReplayFromEnv(provider)
 
 	// Use same backend for all agents (query, research, editor) for consistency
 	// This ensures retry switches all models together
@@ -362,7 +393,7 @@ func runDoExecution(task string, verbose bool, supervised bool, setup *config.Se
 		}
 
 		executor := modes.NewAutonomousExecutorWithLive(queryProvider, cwd, queryModel, language, liveClient, reportConfig, backendName)
-		return executor.Execute(context.Background(), task)
+		return executor.Execute(ctx, task)
 	}
 
 	// Supervised mode: use guided workflow
@@ -373,7 +404,7 @@ func runDoExecution(task string, verbose bool, supervised bool, setup *config.Se
 			fmt.Fprintf(os.Stderr, "Creating plan...\n")
 		}
 
-		planContent, err := guided.ExecuteAndReturnPlan(context.Background(), task)
+		planContent, err := guided.ExecuteAndReturnPlan(ctx, task)
 		if err != nil {
 			return fmt.Errorf("plan creation failed: %w", err)
 		}
@@ -384,7 +415,7 @@ func runDoExecution(task string, verbose bool, supervised bool, setup *config.Se
 
 		guidedWithCustomEditor := modes.NewGuidedModeWithCustomModel(orchestrator, provider, cwd, queryModel, editorModel)
 
-		if err := guidedWithCustomEditor.Implement(context.Background(), planContent); err != nil {
+		if err := guidedWithCustomEditor.Implement(ctx, planContent); err != nil {
 			return fmt.Errorf("implementation failed: %w", err)
 		}
 	} else {
@@ -394,7 +425,7 @@ func runDoExecution(task string, verbose bool, supervised bool, setup *config.Se
 			fmt.Fprintf(os.Stderr, "Using orchestrated mode with decomposed agents...\n")
 		}
 
-		if err := orchestrated.Execute(context.Background(), task); err != nil {
+		if err := orchestrated.Execute(ctx, task); err != nil {
 			return fmt.Errorf("orchestrated execution failed: %w", err)
 		}
 	}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gptcode/internal/ledger"
+	"gptcode/internal/llm"
+)
+
+// fakeModel answers OpenAI-style chat requests: tool-using agents write
+// greet.go, reviews pass and everything else gets a short plan
+type fakeModel struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeModel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+		Tools []json.RawMessage `json:"tools"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+
+	message := map[string]interface{}{"role": "assistant"}
+	last := req.Messages[len(req.Messages)-1]
+	switch {
+	case len(req.Messages) > 1 && strings.HasPrefix(req.Messages[1].Content, "Validate if the implementation"):
+		message["content"] = "SUCCESS"
+	case len(req.Tools) > 0 && last.Role == "tool":
+		message["content"] = "Created greet.go"
+	case len(req.Tools) > 0:
+		message["content"] = ""
+		message["tool_calls"] = []map[string]interface{}{{
+			"id":   "call_1",
+			"type": "function",
+			"function": map[string]string{
+				"name":      "write_file",
+				"arguments": `{"path":"greet.go","content":"package greet\n"}`,
+			},
+		}}
+	default:
+		message["content"] = "## Plan\n\nCreate greet.go declaring package greet.\n\n## Success Criteria\n- greet.go exists"
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []map[string]interface{}{{"message": message}},
+		"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12},
+	})
+}
+
+// TestDo_Replay records gt do against a fake model server, then replays it
+// with the server gone
+func TestDo_Replay(t *testing.T) {
+	fake := &fakeModel{}
+	server := httptest.NewServer(fake)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(ledger.EnvPath, "")
+	t.Setenv("FAKE_API_KEY", "test")
+	setup := "defaults:\n  backend: fake\n  lang: go\nbackend:\n  fake:\n    type: openai\n    base_url: " + server.URL + "\n    default_model: gpt-4o-fake\n"
+	catalog := `{"fake": {"models": [{"id": "gpt-4o-fake", "name": "Fake", "context_window": 128000}]}}`
+	if err := os.MkdirAll(filepath.Join(home, ".gptcode"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"setup.yaml": setup, "models_catalog.json": catalog} {
+		if err := os.WriteFile(filepath.Join(home, ".gptcode", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+	t.Setenv(llm.EnvCassetteDir, t.TempDir())
+	task := "Create greet.go declaring package greet"
+
+	t.Setenv(llm.EnvCassetteMode, string(llm.ReplayRecord))
+	if err := runDoExecutionWithRetry(task, false, 1, false, false); err != nil {
+		t.Fatalf("recording run failed: %v", err)
+	}
+	server.Close()
+	recorded := fake.calls
+	if entries, _ := ledger.Default().Entries(time.Time{}); len(entries) != recorded {
+		t.Errorf("expected %d calls in the ledger under the test HOME, got %d", recorded, len(entries))
+	}
+	if err := os.Remove(filepath.Join(cwd, "greet.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(llm.EnvCassetteMode, string(llm.ReplayReplay))
+	if err := runDoExecutionWithRetry(task, false, 1, false, false); err != nil {
+		t.Fatalf("replayed run failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cwd, "greet.go")); string(data) != "package greet\n" {
+		t.Errorf("unexpected file content %q", data)
+	}
+	if fake.calls != recorded {
+		t.Error("replay reached the model server")
+	}
+
+	session := llm.ReplayFromEnv(nil).(*llm.ReplayProvider)
+	if d := session.Divergences(); len(d) > 0 {
+		t.Errorf("replay diverged from the recording: %+v", d)
+	}
+	if unused := session.Unused(); len(unused) > 0 {
+		t.Errorf("replay made fewer model calls than recorded: %v", unused)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gptcode/internal/config"
+	"gptcode/internal/eval"
+	"gptcode/internal/llm"
+
+	"github.com/spf13/cobra"
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Benchmark agents on task suites",
+}
+
+var evalRunCmd = &cobra.Command{
+	Use:   "run <suite.yaml>",
+	Short: "Run a task suite across backends and models",
+	Long: `Run every task of a suite against each backend/model/profile in its
+matrix and score the results.
+
+Each task copies its fixture into a temporary git repository, runs the
+agent with the task's prompt, then checks the outcome: test commands that
+must pass, files that must change and files that must not. Results report
+pass rate, tokens, cost, latency and tool calls per variant, and are
+compared against a stored baseline.
+
+Suite format:
+  name: basics
+  agent: editor            # or maestro
+  parallel: 4
+  max_cost: 2.00           # USD for the whole run
+  max_cost_per_task: 0.25
+  timeout: 10m
+  matrix:
+    - backend: openrouter
+      model: moonshotai/kimi-k2
+    - backend: groq
+      profile: speed
+  tasks:
+    - id: fix-add
+      fixture: fixtures/calc
+      prompt: Fix Add so it returns the sum of its arguments
+      checks:
+        tests: ["go test ./..."]
+        changed: ["calc.go"]
+        forbidden: ["*_test.go"]
+
+Examples:
+  gptcode eval run evals/basics.yaml
+  gptcode eval run evals/basics.yaml --parallel 8 --markdown report.md
+  gptcode eval run evals/basics.yaml --update-baseline`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEval,
+}
+
+var (
+	evalParallel       int
+	evalMaxCost        float64
+	evalJSONOut        string
+	evalMarkdownOut    string
+	evalBaselinePath   string
+	evalUpdateBaseline bool
+)
+
+func init() {
+	evalRunCmd.Flags().IntVar(&evalParallel, "parallel", 0, "Tasks to run at once (default: suite setting or 1)")
+	evalRunCmd.Flags().Float64Var(&evalMaxCost, "max-cost", 0, "Stop starting tasks once the run has spent this much in USD")
+	evalRunCmd.Flags().StringVar(&evalJSONOut, "json", "", "Write the report as JSON to this file")
+	evalRunCmd.Flags().StringVar(&evalMarkdownOut, "markdown", "", "Write the report as Markdown to this file")
+	evalRunCmd.Flags().StringVar(&evalBaselinePath, "baseline", "", "Baseline report to compare against (default: .gptcode/eval/<suite>.baseline.json)")
+	evalRunCmd.Flags().BoolVar(&evalUpdateBaseline, "update-baseline", false, "Save this run as the new baseline")
+
+	evalCmd.AddCommand(evalRunCmd)
+	rootCmd.AddCommand(evalCmd)
+}
+
+func runEval(cmd *cobra.Command, args []string) error {
+	suite, err := eval.LoadSuite(args[0])
+	if err != nil {
+		return err
+	}
+
+	setup, err := config.LoadSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	variants := suite.Matrix
+	if len(variants) == 0 {
+		variants = []eval.Variant{{Backend: setup.Defaults.Backend, Profile: setup.Defaults.Profile}}
+	}
+	var targets []eval.Target
+	for _, v := range variants {
+		target, err := evalTarget(setup, v)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+	}
+
+	runner := eval.NewRunner(suite, targets)
+	runner.Parallel = evalParallel
+	runner.MaxCost = evalMaxCost
+	runner.OnResult = func(r eval.Result) {
+		icon := "✅"
+		switch {
+		case r.Skipped:
+			icon = "⏭️ "
+		case !r.Passed:
+			icon = "❌"
+		}
+		fmt.Printf("%s %s on %s (%s, $%.4f, %d tool calls)\n", icon, r.Task, r.Variant, r.Duration.Round(100*time.Millisecond), r.Cost, r.ToolCalls)
+	}
+
+	fmt.Printf("🧪 Running %d task(s) on %d variant(s)...\n\n", len(suite.Tasks), len(targets))
+	report := runner.Run(context.Background())
+
+	baselinePath := evalBaselinePath
+	if baselinePath == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		baselinePath = filepath.Join(cwd, ".gptcode", "eval", suite.Name+".baseline.json")
+	}
+	baseline, err := eval.LoadReport(baselinePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	fmt.Println()
+	for _, s := range report.Summaries {
+		fmt.Printf("📊 %s: %.0f%% passed (%d/%d), %d tokens, $%.4f, avg %s\n",
+			s.Variant, 100*s.PassRate, s.Passed, s.Runs, s.Tokens, s.Cost, s.AvgDuration.Round(100*time.Millisecond))
+	}
+
+	var regressions []string
+	if baseline != nil {
+		fmt.Println("\n📈 Compared to baseline:")
+		for _, c := range eval.Compare(report, baseline) {
+			fmt.Printf("   %s: %.0f%% → %.0f%%, cost %+.4f\n", c.Variant, 100*c.BaselinePassRate, 100*c.PassRate, c.CostDelta)
+			for _, task := range c.Regressions {
+				regressions = append(regressions, fmt.Sprintf("%s on %s", task, c.Variant))
+			}
+			if len(c.Fixed) > 0 {
+				fmt.Printf("      fixed: %s\n", strings.Join(c.Fixed, ", "))
+			}
+		}
+	}
+
+	if evalJSONOut != "" {
+		if err := report.Save(evalJSONOut); err != nil {
+			return err
+		}
+		fmt.Printf("\n💾 JSON report: %s\n", evalJSONOut)
+	}
+	if evalMarkdownOut != "" {
+		if err := os.WriteFile(evalMarkdownOut, []byte(report.Markdown(baseline)), 0644); err != nil {
+			return fmt.Errorf("failed to write markdown report: %w", err)
+		}
+		fmt.Printf("💾 Markdown report: %s\n", evalMarkdownOut)
+	}
+	if evalUpdateBaseline {
+		if err := report.Save(baselinePath); err != nil {
+			return err
+		}
+		fmt.Printf("💾 Baseline updated: %s\n", baselinePath)
+	}
+
+	if len(regressions) > 0 {
+		return fmt.Errorf("%d regression(s) against baseline: %s", len(regressions), strings.Join(regressions, "; "))
+	}
+	return nil
+}
+
+// evalTarget creates the provider for a variant and resolves the models
+// its agents use
+func evalTarget(setup *config.Setup, v eval.Variant) (eval.Target, error) {
+	backendCfg, ok := setup.Backend[v.Backend]
+	if !ok {
+		return eval.Target{}, fmt.Errorf("backend %s not configured", v.Backend)
+	}
+
+	var provider llm.Provider
+	if backendCfg.Type == "ollama" {
+		provider = llm.NewOllama(backendCfg.BaseURL)
+	} else {
+		provider = llm.NewChatCompletion(backendCfg.BaseURL, v.Backend)
+	}
+	provider = llm.ReplayFromEnv(provider)
+
+	target := eval.Target{Variant: v, Provider: provider, EditorModel: v.Model, QueryModel: v.Model}
+	if target.EditorModel == "" {
+		target.EditorModel = backendCfg.GetModelForAgentWithProfile("editor", v.Profile)
+	}
+	if target.QueryModel == "" {
+		target.QueryModel = backendCfg.GetModelForAgentWithProfile("query", v.Profile)
+	}
+	if target.EditorModel == "" {
+		return eval.Target{}, fmt.Errorf("no model configured for %s", v.Name())
+	}
+	return target, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -24,8 +25,27 @@ var genCmd = &cobra.Command{
 var genTestCmd = &cobra.Command{
 	Use:   "test <file>",
 	Short: "Generate unit tests for a source file",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runGenTest,
+	Long: `Generate unit tests for a source file.
+
+With --mutation-score, the generated tests are scored by mutation testing:
+small changes (swapped operators, negated conditions, altered return values)
+are applied to the source one at a time, and each change the tests don't
+catch is fed back to the model to strengthen the assertions. This repeats
+until the score is reached or --mutation-rounds runs out. Mutation testing
+is currently available for Go.
+
+With --until-coverage, tests are generated for the largest uncovered blocks
+of the file, with the exact uncovered lines in the prompt. New tests are kept
+only if they pass 3 runs in a row and cover something new; this repeats until
+the file reaches the coverage target or --max-cost is spent. Go only.
+
+Examples:
+  gptcode gen test internal/calc/calc.go
+  gptcode gen test internal/calc/calc.go --mutation-score 0.7
+  gptcode gen test internal/calc/calc.go --mutation-score 0.9 --mutation-rounds 5
+  gptcode gen test internal/calc/calc.go --until-coverage 80 --max-cost 0.50`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGenTest,
 }
 
 var genChangelogCmd = &cobra.Command{
@@ -91,7 +111,35 @@ Examples:
 	RunE: runGenSnapshot,
 }
 
-var genModel string
+var genFuzzCmd = &cobra.Command{
+	Use:   "fuzz <file>",
+	Short: "Generate Go fuzz targets and property tests for a source file",
+	Long: `Generate native Go fuzz targets (func FuzzX(f *testing.F)) for the functions
+in a file that take strings, []byte, bools or numbers, plus round-trip targets
+for encode/decode style pairs. Seeds come from literal arguments in existing
+table tests. Pure functions also get testing/quick property tests from the model.
+
+Each target is fuzzed with go test -fuzz for --fuzztime; failing inputs are kept
+in testdata/fuzz and replayed by generated regression tests. Functions that do
+I/O, directly or through the package's own calls, are never fuzzed.
+
+Examples:
+  gptcode gen fuzz internal/parser/lexer.go
+  gptcode gen fuzz internal/codec/codec.go --fuzztime 1m
+  gptcode gen fuzz internal/codec/codec.go --fuzztime 0 --properties=false`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGenFuzz,
+}
+
+var (
+	genModel          string
+	genMutationScore  float64
+	genMutationRounds int
+	genUntilCoverage  float64
+	genMaxCost        float64
+	genFuzzTime       time.Duration
+	genFuzzProperties bool
+)
 
 func init() {
 	rootCmd.AddCommand(genCmd)
@@ -101,8 +149,15 @@ func init() {
 	genCmd.AddCommand(genIntegrationCmd)
 	genCmd.AddCommand(genMigrationCmd)
 	genCmd.AddCommand(genSnapshotCmd)
+	genCmd.AddCommand(genFuzzCmd)
 
 	genCmd.PersistentFlags().StringVar(&genModel, "model", "", "LLM model to use (default: from config)")
+	genTestCmd.Flags().Float64Var(&genMutationScore, "mutation-score", 0, "Strengthen tests until this mutation score (0-1) is reached")
+	genTestCmd.Flags().IntVar(&genMutationRounds, "mutation-rounds", 3, "Maximum rounds of strengthening with --mutation-score")
+	genTestCmd.Flags().Float64Var(&genUntilCoverage, "until-coverage", 0, "Generate targeted tests until the file reaches this coverage percent")
+	genTestCmd.Flags().Float64Var(&genMaxCost, "max-cost", 0.50, "Stop --until-coverage once model calls cost this much (USD, 0 for no limit)")
+	genFuzzCmd.Flags().DurationVar(&genFuzzTime, "fuzztime", 10*time.Second, "How long to fuzz each target (0 to only generate)")
+	genFuzzCmd.Flags().BoolVar(&genFuzzProperties, "properties", true, "Generate testing/quick property tests for pure functions")
 }
 
 func runGenTest(cmd *cobra.Command, args []string) error {
@@ -128,12 +183,37 @@ func runGenTest(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create test generator: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	if genMutationScore < 0 || genMutationScore > 1 {
+		return fmt.Errorf("--mutation-score must be between 0 and 1")
+	}
+	if genUntilCoverage > 0 {
+		if genMutationScore > 0 {
+			return fmt.Errorf("--until-coverage and --mutation-score can't be combined")
+		}
+		return runGenTestUntilCoverage(generator, sourceFile, setup.Defaults.Backend)
+	}
+
+	timeout := 3 * time.Minute
+	if genMutationScore > 0 {
+		timeout = time.Duration(genMutationRounds+1) * 5 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	fmt.Printf("🧪 Generating unit tests for: %s\n", sourceFile)
 
-	result, err := generator.GenerateUnitTests(ctx, sourceFile)
+	var result *testgen.GenerateResult
+	if genMutationScore > 0 {
+		result, err = generator.GenerateWithMutationScore(ctx, sourceFile, testgen.MutationOptions{
+			Threshold: genMutationScore,
+			MaxRounds: genMutationRounds,
+			OnRound: func(round int, report *testgen.MutationReport) {
+				fmt.Printf("🧬 Round %d mutation score: %s\n", round, report.Summary())
+			},
+		})
+	} else {
+		result, err = generator.GenerateUnitTests(ctx, sourceFile)
+	}
 	if err != nil && result == nil {
 		return fmt.Errorf("failed to generate tests: %w", err)
 	}
@@ -147,6 +227,19 @@ func runGenTest(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if report := result.Mutation; report != nil {
+		if report.Score() >= genMutationScore {
+			fmt.Printf("✅ Mutation score %s meets %.0f%%\n", report.Summary(), genMutationScore*100)
+		} else {
+			fmt.Printf("⚠️  Mutation score %s is below %.0f%%; surviving mutants:\n", report.Summary(), genMutationScore*100)
+			for _, m := range report.Survivors() {
+				fmt.Printf("   %s\n", m)
+			}
+		}
+	} else if genMutationScore > 0 && result.Valid && result.Error != nil {
+		fmt.Printf("⚠️  %v\n", result.Error)
+	}
+
 	return nil
 }
 
@@ -413,3 +506,108 @@ func runGenSnapshot(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runGenFuzz(cmd *cobra.Command, args []string) error {
+	sourceFile := args[0]
+
+	setup, err := config.LoadSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	provider, model, err := getGenProvider(setup)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	generator := testgen.NewFuzzGenerator(provider, model, workDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute+20*genFuzzTime)
+	defer cancel()
+
+	fmt.Printf("🎲 Generating fuzz targets for: %s\n", sourceFile)
+
+	result, err := generator.Generate(ctx, sourceFile, testgen.FuzzOptions{
+		FuzzTime:   genFuzzTime,
+		Properties: genFuzzProperties,
+		OnTarget: func(target string) {
+			fmt.Printf("   fuzzing %s for %s...\n", target, genFuzzTime)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate fuzz tests: %w", err)
+	}
+	if !result.Valid {
+		fmt.Printf("⚠️  Generated fuzz tests did not compile, so %s was left as it was\n", result.TestFile)
+		if result.Error != nil {
+			fmt.Printf("   Error: %v\n", result.Error)
+		}
+		return nil
+	}
+
+	fmt.Printf("✅ Generated %s with %d fuzz target(s)\n", result.TestFile, len(result.Targets))
+	if result.PropertyKept != "" {
+		fmt.Printf("💡 Kept the existing %s; remove it to generate property tests\n", result.PropertyKept)
+	}
+	if result.PropertyFile != "" {
+		fmt.Printf("✅ Generated %s\n", result.PropertyFile)
+		if result.PropertyFailure != "" {
+			fmt.Printf("⚠️  Property tests fail; check whether the code or the property is wrong:\n%s\n", result.PropertyFailure)
+		}
+	}
+
+	if len(result.Crashers) == 0 {
+		if genFuzzTime > 0 {
+			fmt.Println("✅ No crashers found")
+		}
+		return nil
+	}
+	fmt.Printf("\n💥 %d target(s) found failing inputs:\n", len(result.Crashers))
+	for _, c := range result.Crashers {
+		if len(c.Inputs) > 0 {
+			fmt.Printf("   %s(%s)\n", c.Target, strings.Join(c.Inputs, ", "))
+		} else {
+			fmt.Printf("   %s\n%s\n", c.Target, c.Output)
+		}
+	}
+	fmt.Println("\n💡 Regression tests replaying them were added; fix the code until they pass.")
+	return nil
+}
+
+func runGenTestUntilCoverage(generator *testgen.TestGenerator, sourceFile, backend string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	fmt.Printf("📈 Generating tests for %s until %.0f%% coverage\n", sourceFile, genUntilCoverage)
+
+	result, err := generator.GenerateUntilCoverage(ctx, sourceFile, testgen.CoverageOptions{
+		Target:  genUntilCoverage,
+		MaxCost: genMaxCost,
+		Backend: backend,
+		OnRound: func(r testgen.CoverageRound) {
+			fmt.Printf("\n🔁 Round %d: %.1f%% → %.1f%% ($%.4f)\n", r.Round, r.Before, r.After, r.Cost)
+			if r.File != "" {
+				fmt.Printf("   ✅ Kept %d test(s) in %s: %s\n", len(r.Kept), r.File, strings.Join(r.Kept, ", "))
+			}
+			for name, reason := range r.Dropped {
+				fmt.Printf("   ✗ Dropped %s: %s\n", name, reason)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate tests: %w", err)
+	}
+
+	icon := "✅"
+	if result.Final < genUntilCoverage {
+		icon = "⚠️ "
+	}
+	fmt.Printf("\n%s Coverage of %s: %.1f%% → %.1f%% (%s, $%.4f spent)\n",
+		icon, sourceFile, result.Start, result.Final, result.StopReason, result.Cost)
+	return nil
+}
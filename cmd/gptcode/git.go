@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"gptcode/internal/bisect"
 	"gptcode/internal/config"
 	"gptcode/internal/llm"
 )
@@ -24,9 +25,17 @@ var gitBisectCmd = &cobra.Command{
 	Short: "AI-assisted binary search for bug introduction",
 	Long: `Automatically find which commit introduced a bug using binary search.
 
+Runs "git bisect run" with a reproduction script: either a test command
+(--run) or a script generated from a natural-language symptom (--symptom).
+Each commit is tested --runs times; commits with inconsistent results are
+reported as flaky and skipped. The first bad commit is then analyzed to
+produce a root-cause hypothesis and a suggested fix patch.
+
 Examples:
   gptcode git bisect v1.0.0 HEAD
-  gptcode git bisect abc123 def456`,
+  gptcode git bisect abc123 def456 --run "go test ./internal/parser/..."
+  gptcode git bisect v1.2.0 HEAD --symptom "gt --version prints an empty string"
+  gptcode git bisect v1.0.0 HEAD --patch-out fix.patch`,
 	Args: cobra.ExactArgs(2),
 	RunE: runGitBisect,
 }
@@ -81,6 +90,13 @@ Examples:
 var gitModel string
 var gitInteractive bool
 
+var (
+	gitBisectRun      string
+	gitBisectSymptom  string
+	gitBisectRuns     int
+	gitBisectPatchOut string
+)
+
 func init() {
 	rootCmd.AddCommand(gitCmd)
 	gitCmd.AddCommand(gitBisectCmd)
@@ -91,12 +107,21 @@ func init() {
 
 	gitCmd.PersistentFlags().StringVar(&gitModel, "model", "", "LLM model to use")
 	gitRebaseCmd.Flags().BoolVar(&gitInteractive, "interactive", false, "Interactive rebase")
+
+	gitBisectCmd.Flags().StringVar(&gitBisectRun, "run", "", "Command that fails when the bug is present (default: go test -count=1 ./...)")
+	gitBisectCmd.Flags().StringVar(&gitBisectSymptom, "symptom", "", "Describe the bug; a reproduction script is generated")
+	gitBisectCmd.Flags().IntVar(&gitBisectRuns, "runs", 3, "Runs per commit; commits with mixed results are skipped as flaky")
+	gitBisectCmd.Flags().StringVar(&gitBisectPatchOut, "patch-out", "", "Write the suggested fix patch to this file")
 }
 
 func runGitBisect(cmd *cobra.Command, args []string) error {
 	goodCommit := args[0]
 	badCommit := args[1]
 
+	if gitBisectRun != "" && gitBisectSymptom != "" {
+		return fmt.Errorf("use either --run or --symptom, not both")
+	}
+
 	setup, err := config.LoadSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
@@ -107,56 +132,79 @@ func runGitBisect(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Printf("🔍 Starting bisect: %s (good) ... %s (bad)\n", goodCommit, badCommit)
+	workDir, _ := os.Getwd()
+	ctx := context.Background()
 
-	bisectCmd := exec.Command("git", "bisect", "start", badCommit, goodCommit)
-	if err := bisectCmd.Run(); err != nil {
-		return fmt.Errorf("failed to start bisect: %w", err)
-	}
-
-	defer exec.Command("git", "bisect", "reset").Run()
-
-	iteration := 0
-	for {
-		iteration++
-		fmt.Printf("\n📍 Iteration %d: Testing commit...\n", iteration)
-
-		currentCmd := exec.Command("git", "rev-parse", "HEAD")
-		currentHash, err := currentCmd.Output()
+	var script string
+	switch {
+	case gitBisectSymptom != "":
+		fmt.Println("🧪 Generating reproduction script...")
+		genCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+		script, err = bisect.GenerateScript(genCtx, provider, model, workDir, gitBisectSymptom)
+		cancel()
 		if err != nil {
-			return fmt.Errorf("failed to get current commit: %w", err)
+			return err
 		}
+		fmt.Printf("\n%s\n", script)
+	case gitBisectRun != "":
+		script = bisect.CommandScript(gitBisectRun)
+	default:
+		// -count=1 so repeated runs aren't answered from the test cache
+		script = bisect.CommandScript("go test -count=1 ./...")
+	}
+
+	fmt.Printf("🔍 Bisecting: %s (good) ... %s (bad), %d run(s) per commit\n", goodCommit, badCommit, gitBisectRuns)
+
+	bisector := bisect.NewBisector(bisect.Options{
+		Dir:    workDir,
+		Good:   goodCommit,
+		Bad:    badCommit,
+		Script: script,
+		Runs:   gitBisectRuns,
+	})
+	result, err := bisector.Run(ctx)
+	if err != nil {
+		return err
+	}
 
-		testCmd := exec.Command("go", "test", "./...")
-		testOutput, testErr := testCmd.CombinedOutput()
+	if len(result.Flaky) > 0 {
+		fmt.Printf("\n⚠️  Skipped %d flaky commit(s) with inconsistent results:\n", len(result.Flaky))
+		for _, sha := range result.Flaky {
+			fmt.Printf("   %s\n", sha)
+		}
+	}
 
-		var status string
-		if testErr == nil {
-			status = "good"
-			fmt.Println("✅ Tests passed - marking as good")
-		} else {
-			status = "bad"
-			fmt.Println("❌ Tests failed - marking as bad")
+	if result.FirstBad == "" {
+		fmt.Println("\n🤷 Could not narrow down to one commit. The first bad commit is one of:")
+		for _, sha := range result.Candidates {
+			fmt.Printf("   %s\n", sha)
 		}
+		return nil
+	}
 
-		markCmd := exec.Command("git", "bisect", status)
-		output, err := markCmd.CombinedOutput()
+	fmt.Printf("\n🎯 First bad commit: %s\n", result.FirstBad)
 
-		if strings.Contains(string(output), "is the first bad commit") {
-			fmt.Println("\n🎯 Found the culprit!")
+	analyzeCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	analysis, err := analyzeCommit(analyzeCtx, provider, model, result.FirstBad, truncate(result.TestOutput, 8000))
+	if err != nil {
+		return fmt.Errorf("failed to analyze commit: %w", err)
+	}
 
-			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-			defer cancel()
+	fmt.Println("\n📊 Analysis:")
+	fmt.Println(analysis)
 
-			analysis, _ := analyzeCommit(ctx, provider, model, string(currentHash), string(testOutput))
-			fmt.Println("\n📊 Analysis:")
-			fmt.Println(analysis)
-			break
+	if gitBisectPatchOut != "" {
+		patch := bisect.ExtractPatch(analysis)
+		if patch == "" {
+			fmt.Println("\n⚠️  Analysis did not include a patch")
+			return nil
 		}
-
-		if err != nil {
-			break
+		if err := os.WriteFile(gitBisectPatchOut, []byte(patch), 0644); err != nil {
+			return fmt.Errorf("failed to write patch: %w", err)
 		}
+		fmt.Printf("\n💾 Suggested fix written to %s (apply with: git apply %s)\n", gitBisectPatchOut, gitBisectPatchOut)
 	}
 
 	return nil
@@ -277,15 +325,17 @@ Commit: %s
 Diff:
 %s
 
-Test failure:
+Reproduction output at this commit:
 %s
 
-Provide:
-1. What the commit changed
-2. Why it likely broke tests
-3. Suggested fix
+Reply in markdown with these sections:
+## What changed
+## Root cause hypothesis
+Why this change most likely causes the failure, citing the relevant lines.
+## Suggested fix
+A unified diff against the current tree in a single diff code block.
 
-Be concise.`, commit, string(diff), testOutput)
+Be concise.`, commit, truncate(string(diff), 12000), testOutput)
 
 	resp, err := provider.Chat(ctx, llm.ChatRequest{
 		SystemPrompt: "You are a helpful assistant that analyzes git commits and identifies bugs.",
@@ -26,7 +26,15 @@ Examples:
   gptcode implement plan.md
   gptcode implement plan.md --auto
   gptcode implement plan.md --auto --lint
-  gptcode implement plan.md --auto --max-retries 5`,
+  gptcode implement plan.md --auto --max-retries 5
+  gptcode implement plan.md --auto --parallel 4
+
+Plans with a structured step graph (YAML front-matter or a fenced json
+block with "steps", each with id, depends_on, files and acceptance) run
+independent steps with disjoint files concurrently. Each step's acceptance
+criteria are checked after it builds and passes tests: "http:", "cli:" and
+"grep:" criteria are executed, the rest are judged by a reviewer model
+(--judge-model, defaulting to the query model) against the diff.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		planPath := args[0]
@@ -45,12 +53,16 @@ func init() {
 	implementCmd.Flags().Int("max-retries", 3, "Maximum retry attempts per step (only with --auto)")
 	implementCmd.Flags().Bool("lint", false, "Enable lint verification (only with --auto)")
 	implementCmd.Flags().Bool("resume", false, "Resume from last checkpoint (only with --auto)")
+	implementCmd.Flags().Int("parallel", 3, "Maximum independent plan steps to run concurrently (only with --auto)")
+	implementCmd.Flags().String("judge-model", "", "Model that reviews acceptance criteria (only with --auto, default: query model)")
 }
 
 func runAutonomousImplement(cmd *cobra.Command, planPath string) error {
 	maxRetries, _ := cmd.Flags().GetInt("max-retries")
 	lint, _ := cmd.Flags().GetBool("lint")
 	resume, _ := cmd.Flags().GetBool("resume")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	judgeModel, _ := cmd.Flags().GetString("judge-model")
 
 	planContent, err := os.ReadFile(planPath)
 	if err != nil {
@@ -79,6 +91,11 @@ func runAutonomousImplement(cmd *cobra.Command, planPath string) error {
 	if maxRetries > 0 {
 		m.MaxRetries = maxRetries
 	}
+	m.MaxParallel = parallel
+	if judgeModel == "" {
+		judgeModel = backendCfg.GetModelForAgent("query")
+	}
+	m.JudgeModel = judgeModel
 
 	if lint {
 		m.Verifiers = append(m.Verifiers, maestro.NewLintVerifier(cwd))
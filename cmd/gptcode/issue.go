@@ -18,6 +18,7 @@ import (
 	"gptcode/internal/langdetect"
 	"gptcode/internal/llm"
 	"gptcode/internal/modes"
+	"gptcode/internal/prreview"
 	"gptcode/internal/recovery"
 	"gptcode/internal/validation"
 )
@@ -623,20 +624,36 @@ func attemptLintFix(workDir string, lintResults []*validation.LintResult) error
 }
 
 var issueReviewCmd = &cobra.Command{
-	Use:   "review <pr-number>",
-	Short: "Address review comments on a PR",
-	Long: `Fetch review comments from a pull request and autonomously address them.
+	Use:   "review <pr-number>...",
+	Short: "Address review comments on one or more PRs",
+	Long: `Fetch unresolved review threads from pull requests and autonomously address them.
+
+For each PR this will:
+1. Check out the PR branch and fetch unresolved review threads
+2. Group threads by file and apply a fix per thread with the editor agent
+3. Validate each fix with build and test verifiers, retrying once on failure
+4. Push one commit per file batch
+5. Reply in-thread with what changed and resolve the thread
+
+Threads the agent declines (wrong, unclear or already satisfied) get an
+explanation reply and are left open for the reviewer. Threads whose last
+comment is our own reply are skipped until the reviewer answers. With
+--no-push, commits stay local and threads are neither replied to nor
+resolved.
 
-This will:
-1. Fetch all unresolved review comments
-2. Analyze each comment
-3. Implement requested changes
-4. Commit and push updates`,
-	Args: cobra.ExactArgs(1),
+Examples:
+  gt issue review 42
+  gt issue review 42 57 --no-resolve
+  gt issue review 42 --no-push`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		prNumber, err := strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("invalid PR number: %s", args[0])
+		var prNumbers []int
+		for _, arg := range args {
+			prNumber, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("invalid PR number: %s", arg)
+			}
+			prNumbers = append(prNumbers, prNumber)
 		}
 
 		repo, _ := cmd.Flags().GetString("repo")
@@ -646,29 +663,14 @@ This will:
 				return fmt.Errorf("could not detect GitHub repository. Use --repo flag")
 			}
 		}
-
-		fmt.Printf("🔍 Fetching review comments for PR #%d...\n", prNumber)
+		noPush, _ := cmd.Flags().GetBool("no-push")
+		noResolve, _ := cmd.Flags().GetBool("no-resolve")
+		model, _ := cmd.Flags().GetString("model")
 
 		client := github.NewClient(repo)
 		workDir, _ := os.Getwd()
 		client.SetWorkDir(workDir)
 
-		comments, err := client.GetUnresolvedComments(prNumber)
-		if err != nil {
-			return fmt.Errorf("failed to fetch comments: %w", err)
-		}
-
-		if len(comments) == 0 {
-			fmt.Println("✅ No unresolved comments")
-			return nil
-		}
-
-		fmt.Printf("\n📝 Found %d unresolved comment(s):\n\n", len(comments))
-		for i, comment := range comments {
-			fmt.Printf("%d. [@%s] %s:%d\n", i+1, comment.Author, comment.Path, comment.Line)
-			fmt.Printf("   %s\n\n", comment.Body)
-		}
-
 		setup, err := config.LoadSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
@@ -682,68 +684,58 @@ This will:
 		} else {
 			provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
 		}
-		queryModel := backendCfg.GetModelForAgent("query")
-		if queryModel == "" {
-			queryModel = backendCfg.DefaultModel
+		if model == "" {
+			model = backendCfg.GetModelForAgent("editor")
 		}
-		language := string(langdetect.DetectLanguage(workDir))
-		if language == "" || language == "unknown" {
-			language = setup.Defaults.Lang
-			if language == "" {
-				language = "go"
-			}
+		if model == "" {
+			model = backendCfg.DefaultModel
 		}
 
-		fmt.Println("🔧 Addressing review comments...")
-
-		for i, comment := range comments {
-			fmt.Printf("\n[%d/%d] Processing comment from @%s on %s\n", i+1, len(comments), comment.Author, comment.Path)
-
-			task := fmt.Sprintf(`Address review comment:
-File: %s (line %d)
-Comment: %s
+		self, err := github.CurrentUser()
+		if err != nil {
+			fmt.Printf("⚠️  Could not look up the GitHub login, threads we already answered will be handled again: %v\n", err)
+		}
 
-Please read the file, understand the context, and implement the requested change.`,
-				comment.Path, comment.Line, comment.Body)
+		var failed []int
+		for _, prNumber := range prNumbers {
+			fmt.Printf("\n🔍 PR #%d: checking out and fetching review threads...\n", prNumber)
 
-			exec := modes.NewAutonomousExecutorWithLive(provider, workDir, queryModel, language, nil, nil, backendName)
-			if err := exec.Execute(context.Background(), task); err != nil {
-				fmt.Printf("⚠️  Failed to address comment: %v\n", err)
+			branch, err := client.CheckoutPR(prNumber)
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				failed = append(failed, prNumber)
 				continue
 			}
 
-			fmt.Println("✅ Comment addressed")
-		}
-
-		fmt.Println("\n📦 Committing changes...")
+			responder := prreview.NewResponder(provider, model, workDir, client)
+			responder.Push = !noPush
+			responder.Resolve = !noResolve
+			responder.Self = self
 
-		err = client.CommitChanges(github.CommitOptions{
-			Message:  fmt.Sprintf("Address review comments on PR #%d", prNumber),
-			AllFiles: true,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to commit: %w", err)
-		}
+			report, err := responder.Respond(context.Background(), prNumber, branch)
+			if err != nil {
+				fmt.Printf("❌ PR #%d: %v\n", prNumber, err)
+				failed = append(failed, prNumber)
+				continue
+			}
 
-		fmt.Println("✅ Changes committed")
+			if len(report.Outcomes) == 0 {
+				fmt.Println("✅ No unresolved review threads")
+				continue
+			}
 
-		currentBranch := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-		currentBranch.Dir = workDir
-		branchOutput, err := currentBranch.Output()
-		if err != nil {
-			return fmt.Errorf("failed to get current branch: %w", err)
+			fmt.Printf("\n✨ PR #%d: %d applied, %d declined, %d failed in %d commit(s)\n",
+				prNumber,
+				report.Count(prreview.OutcomeApplied),
+				report.Count(prreview.OutcomeDeclined),
+				report.Count(prreview.OutcomeFailed),
+				len(report.Commits))
+			fmt.Printf("   View PR: https://github.com/%s/pull/%d\n", repo, prNumber)
 		}
 
-		branchName := strings.TrimSpace(string(branchOutput))
-		fmt.Printf("🚀 Pushing %s...\n", branchName)
-
-		if err := client.PushBranch(branchName); err != nil {
-			return fmt.Errorf("failed to push: %w", err)
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to process PR(s): %v", failed)
 		}
-
-		fmt.Printf("\n✨ Successfully addressed %d review comment(s)\n", len(comments))
-		fmt.Printf("   View PR: https://github.com/%s/pull/%d\n", repo, prNumber)
-
 		return nil
 	},
 }
@@ -915,6 +907,9 @@ func init() {
 	issuePushCmd.Flags().Bool("draft", false, "Create draft pull request")
 
 	issueReviewCmd.Flags().String("repo", "", "GitHub repository (owner/repo)")
+	issueReviewCmd.Flags().Bool("no-push", false, "Commit locally without pushing, replying or resolving")
+	issueReviewCmd.Flags().Bool("no-resolve", false, "Reply to threads without resolving them")
+	issueReviewCmd.Flags().String("model", "", "LLM model to use (default: editor model from config)")
 
 	issueCICmd.Flags().String("repo", "", "GitHub repository (owner/repo)")
 }
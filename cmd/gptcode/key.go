@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+
+	"gptcode/internal/config"
+	"gptcode/internal/secrets"
+
+	"github.com/spf13/cobra"
+)
+
+var keyMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move plaintext API keys into the secret store",
+	Long: `Move API keys saved in plaintext into the secret store.
+
+Keys are read from ~/.gptcode/keys.yaml and from the "# GPTCode API key"
+exports older versions appended to ~/.zshrc, ~/.bashrc and ~/.bash_profile.
+Once every key is stored, the plaintext copies are removed. Exports you
+wrote yourself are left alone.
+
+Keys go to the system keyring (Secret Service over D-Bus, through
+secret-tool) when it is available, otherwise to ~/.gptcode/secrets.enc,
+encrypted with a passphrase. Set GPTCODE_SECRETS_PASSPHRASE to unlock the
+file without a prompt, and GPTCODE_SECRET_STORE=file or keyring to pick
+a store.
+
+Keys can also be referenced from setup.yaml instead of stored:
+
+  keys:
+    openrouter: "cmd: pass show openrouter"
+    groq: "env:MY_GROQ_KEY"
+
+Examples:
+  gptcode key migrate --dry-run
+  gptcode key migrate
+  gptcode key migrate --store file`,
+	Args: cobra.NoArgs,
+	RunE: runKeyMigrate,
+}
+
+var (
+	keyMigrateDryRun bool
+	keyMigrateStore  string
+)
+
+func init() {
+	keyMigrateCmd.Flags().BoolVar(&keyMigrateDryRun, "dry-run", false, "List the keys that would move without changing anything")
+	keyMigrateCmd.Flags().StringVar(&keyMigrateStore, "store", "", "Store to move keys to: keyring or file (default: keyring when available)")
+	keyCmd.AddCommand(keyMigrateCmd)
+}
+
+func runKeyMigrate(cmd *cobra.Command, args []string) error {
+	var store secrets.Store
+	switch keyMigrateStore {
+	case "":
+		store = secrets.Default()
+	case "keyring":
+		k := secrets.NewKeyring()
+		if !k.Available() {
+			return fmt.Errorf("no Secret Service keyring available (needs secret-tool and a D-Bus session)")
+		}
+		store = k
+	case "file":
+		store = secrets.DefaultFile()
+	default:
+		return fmt.Errorf("unknown store %q (expected keyring or file)", keyMigrateStore)
+	}
+
+	migrated, err := config.MigrateAPIKeys(store, keyMigrateDryRun)
+	for _, m := range migrated {
+		if keyMigrateDryRun {
+			fmt.Printf("  %s (from %s)\n", m.Name, m.From)
+		} else {
+			fmt.Printf("✓ %s (from %s)\n", m.Name, m.From)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(migrated) == 0:
+		fmt.Println("No plaintext API keys found")
+	case keyMigrateDryRun:
+		fmt.Printf("\n%d key(s) would move to %s\n", len(migrated), store.Name())
+	default:
+		fmt.Printf("\n🔐 Moved %d key(s) to %s\n", len(migrated), store.Name())
+		fmt.Println("   Open a new shell so removed exports no longer apply")
+	}
+	return nil
+}
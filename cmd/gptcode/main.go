@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,9 +16,11 @@ import (
 
 	"gptcode/internal/repl"
 
+	"gptcode/internal/budget"
 	"gptcode/internal/catalog"
 	"gptcode/internal/config"
 	"gptcode/internal/elixir"
+	"gptcode/internal/events"
 	"gptcode/internal/feedback"
 	"gptcode/internal/langdetect"
 	"gptcode/internal/live"
@@ -26,14 +30,82 @@ import (
 	"gptcode/internal/modes"
 	"gptcode/internal/ollama"
 	"gptcode/internal/prompt"
+	"gptcode/internal/telemetry"
 )
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	// The format is read before cobra parses the arguments, so a mistyped
+	// command or flag still ends with a machine-readable result
+	if format, err := events.ParseFormat(outputFromArgs(os.Args[1:])); err == nil {
+		events.SetFormat(format)
+	}
+	stopTracing := startTracing()
+	cmd, err := rootCmd.ExecuteC()
+	stopTracing()
+	if cmd != nil {
+		_ = events.Finish(strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" "), err)
+	}
+	if err != nil {
+		if errors.Is(err, budget.ErrExceeded) {
+			fmt.Fprintln(os.Stderr, "\n💸 Stopped to stay within budget. See what was spent with 'gptcode usage', or raise the limits under budget: and defaults: in ~/.gptcode/setup.yaml")
+		}
 		os.Exit(1)
 	}
 }
 
+// outputFromArgs returns the last --output value in args, as the flag
+// parser would, or "text" when there is none
+func outputFromArgs(args []string) string {
+	format := "text"
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return format
+		case arg == "--output" && i+1 < len(args):
+			format = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--output="):
+			format = strings.TrimPrefix(arg, "--output=")
+		}
+	}
+	return format
+}
+
+// startTracing exports spans when a collector is configured and returns
+// the function that flushes them before exit
+func startTracing() func() {
+	setup, _ := config.LoadSetup()
+	shutdown, err := telemetry.StartTracing(context.Background(), setup.Tracing, version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Tracing disabled: %v\n", err)
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = shutdown(ctx)
+	}
+}
+
+// serveMetrics serves Prometheus metrics on addr, such as ":9090", until
+// the returned function is called
+func serveMetrics(addr string) (func(), error) {
+	shutdown, err := telemetry.ServeMetrics(addr)
+	if err != nil {
+		return nil, err
+	}
+	host := addr
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
+	fmt.Fprintf(os.Stderr, "📈 Metrics at http://%s%s\n", host, telemetry.MetricsPath)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = shutdown(ctx)
+	}, nil
+}
+
 var version = "dev"
 
 var versionCmd = &cobra.Command{
@@ -126,10 +198,21 @@ $0-5/month vs $20-30/month subscriptions.
   gptcode detect-language      - Detect project language`,
 }
 
+var outputFormat string
+
 func init() {
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "Output format: text, json (final result) or ndjson (streamed events and result)")
+
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// main has already switched to the format; this only rejects
+		// unknown values
+		if _, err := events.ParseFormat(outputFormat); err != nil {
+			return err
+		}
+
 		// Skip for setup and key commands
-		if cmd.Name() == "setup" || cmd.Name() == "key" || cmd.Name() == "completion" {
+		if cmd.Name() == "setup" || cmd.Name() == "key" || cmd.Name() == "completion" ||
+			(cmd.HasParent() && cmd.Parent().Name() == "key") {
 			return nil
 		}
 
@@ -348,7 +431,15 @@ var setupCmd = &cobra.Command{
 var keyCmd = &cobra.Command{
 	Use:   "key [backend]",
 	Short: "Add or update API key for a backend (e.g., gptcode key openrouter)",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Add or update the API key for a backend. Keys are saved to the system
+keyring when available, otherwise to ~/.gptcode/secrets.enc, encrypted with
+a passphrase.
+
+Examples:
+  gptcode key openrouter
+  gptcode key tavily
+  gptcode key migrate`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		backendName := args[0]
 		return config.UpdateAPIKey(backendName)
@@ -520,7 +611,8 @@ var configCmd = &cobra.Command{
 var configGetCmd = &cobra.Command{
 	Use:   "get <key>",
 	Short: "Get configuration value",
-	Long: `Get a configuration value from ~/.gptcode/setup.yaml
+	Long: `Get a configuration value from ~/.gptcode/setup.yaml, merged with the
+repository's .gptcode/config.yaml when there is one
 
 Examples:
   gptcode config get defaults.backend
@@ -541,7 +633,8 @@ Examples:
 var configSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Set configuration value",
-	Long: `Set a configuration value in ~/.gptcode/setup.yaml
+	Long: `Set a configuration value in ~/.gptcode/setup.yaml. Settings in the
+repository's .gptcode/config.yaml are edited by hand and committed.
 
 Examples:
   gptcode config set defaults.backend groq
@@ -559,6 +652,48 @@ Examples:
 	},
 }
 
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration",
+	Long: `Show every setting in effect for the current directory: the user's
+~/.gptcode/setup.yaml merged with the repository's .gptcode/config.yaml.
+
+Project settings replace the user's commands and agent allow-lists, add to
+skills and protected paths, and can only lower budget caps.
+
+Examples:
+  gptcode config show
+  gptcode config show --origin`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		showOrigin, _ := cmd.Flags().GetBool("origin")
+
+		// Without a user setup there are still defaults and project values
+		setup, err := config.LoadSetup()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		settings, err := setup.Settings()
+		if err != nil {
+			return err
+		}
+		projectFile := filepath.Join(setup.ProjectRoot, config.ProjectConfigFile)
+		for _, kv := range settings {
+			if !showOrigin {
+				fmt.Printf("%s = %s\n", kv[0], kv[1])
+				continue
+			}
+			origin := setup.Origin(kv[0])
+			if strings.Contains(origin, config.OriginProject) && setup.ProjectRoot != "" {
+				origin += " (" + projectFile + ")"
+			}
+			fmt.Printf("%s = %s  [%s]\n", kv[0], kv[1], origin)
+		}
+		return nil
+	},
+}
+
 var detectLanguageCmd = &cobra.Command{
 	Use:     "detect-language [path]",
 	Aliases: []string{"detect"},
@@ -1300,6 +1435,8 @@ func init() {
 
 	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configShowCmd)
+	configShowCmd.Flags().Bool("origin", false, "Show whether each value comes from the user setup, the project config or the defaults")
 
 	rootCmd.AddCommand(profilesCmd)
 	profilesCmd.AddCommand(profilesListCmd)
@@ -1830,17 +1967,21 @@ Tasks are markdown checkboxes: - [ ] task description
 Completed tasks are marked: - [x] task description
 
 The agent stays connected to the Live Dashboard between tasks,
-allowing real-time monitoring from the /live page.
+allowing real-time monitoring from the /live page. With --metrics-addr,
+request, token, tool-call and verification metrics are served for
+Prometheus.
 
 Examples:
   gt watch
   gt watch --file my-tasks.md
   gt watch --interval 60s
-  gt watch --max 5`,
+  gt watch --max 5
+  gt watch --metrics-addr :9090`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		roadmapFile, _ := cmd.Flags().GetString("file")
 		intervalStr, _ := cmd.Flags().GetString("interval")
 		maxTasks, _ := cmd.Flags().GetInt("max")
+		metricsAddr, _ := cmd.Flags().GetString("metrics-addr")
 
 		interval := 30 * time.Second
 		if intervalStr != "" {
@@ -1856,6 +1997,14 @@ Examples:
 			return err
 		}
 
+		if metricsAddr != "" {
+			stop, err := serveMetrics(metricsAddr)
+			if err != nil {
+				return err
+			}
+			defer stop()
+		}
+
 		return modes.WatchExecute(builder, provider, model, modes.WatchConfig{
 			RoadmapFile: roadmapFile,
 			Interval:    interval,
@@ -1891,6 +2040,7 @@ func init() {
 	watchCmd.Flags().String("file", "", "Path to roadmap file (default: auto-detect)")
 	watchCmd.Flags().String("interval", "30s", "Wait duration between tasks")
 	watchCmd.Flags().Int("max", 0, "Maximum number of tasks to execute (0 = unlimited)")
+	watchCmd.Flags().String("metrics-addr", "", "Serve Prometheus metrics at /metrics on this address (e.g. :9090)")
 }
 
 var featureCmd = &cobra.Command{
@@ -2088,7 +2238,11 @@ Review a directory:
 Focus on specific aspects:
   gptcode review main.go --focus security
   gptcode review . --focus performance
-  gptcode review src/ --focus "error handling"`,
+  gptcode review src/ --focus "error handling"
+
+Export findings as SARIF for GitHub code scanning or IDE viewers:
+  gptcode review . --sarif review.sarif
+  gptcode review . --sarif review.sarif --baseline review.sarif`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		target := "."
 		if len(args) > 0 {
@@ -2096,16 +2250,41 @@ Focus on specific aspects:
 		}
 
 		focus, _ := cmd.Flags().GetString("focus")
+		sarifPath, _ := cmd.Flags().GetString("sarif")
+		baseline, _ := cmd.Flags().GetString("baseline")
+		keepAbsent, _ := cmd.Flags().GetBool("include-absent")
+		if baseline != "" && sarifPath == "" {
+			return fmt.Errorf("--baseline requires --sarif")
+		}
+		if keepAbsent && baseline == "" {
+			return fmt.Errorf("--include-absent requires --baseline")
+		}
 
-		return modes.RunReview(modes.ReviewOptions{
+		report, err := modes.RunReview(modes.ReviewOptions{
 			Target: target,
 			Focus:  focus,
 		})
+		if err != nil {
+			return err
+		}
+		events.SetResult(report)
+
+		if sarifPath != "" {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get working directory: %w", err)
+			}
+			return writeSARIF(report.SARIF(version, cwd), sarifPath, baseline, keepAbsent)
+		}
+		return nil
 	},
 }
 
 func init() {
 	reviewCmd.Flags().StringP("focus", "f", "", "Focus area for review (e.g., security, performance, error handling)")
+	reviewCmd.Flags().String("sarif", "", "Also write the findings as SARIF 2.1.0 to this file")
+	reviewCmd.Flags().String("baseline", "", "SARIF file of an earlier review to compare findings with")
+	reviewCmd.Flags().Bool("include-absent", false, "Also write findings fixed since the baseline as absent results (not for code scanning uploads)")
 }
 
 func detectLanguage() string {
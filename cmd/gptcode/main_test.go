@@ -189,3 +189,21 @@ func TestDemoFeedbackCreateFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestOutputFromArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "text"},
+		{[]string{"--output", "json", "badcmd"}, "json"},
+		{[]string{"do", "--bogus", "--output=ndjson"}, "ndjson"},
+		{[]string{"--output", "json", "do", "--output", "ndjson"}, "ndjson"},
+		{[]string{"run", "--", "--output", "json"}, "text"},
+	}
+	for _, tt := range tests {
+		if got := outputFromArgs(tt.args); got != tt.want {
+			t.Errorf("outputFromArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -22,6 +22,14 @@ var mergeResolveCmd = &cobra.Command{
 	Short: "Resolve all merge conflicts",
 	Long: `Detect and resolve all merge conflicts in the working directory.
 
+Go files are merged declaration by declaration using the AST, and Python,
+JS/TS, Ruby, Rust and Elixir files at the level of top-level declarations
+and the members of classes, modules and impls.
+Declarations and imports changed on only one side are merged without the
+LLM; only declarations changed on both sides are sent to it, along with the
+commits involved. Other files are resolved by the LLM as a whole. The build
+is verified once all resolutions are staged.
+
 Examples:
   gptcode merge resolve
   gptcode merge resolve --model claude-3-5-sonnet-20241022`,
@@ -83,7 +91,22 @@ func runMergeResolve(cmd *cobra.Command, args []string) error {
 			continue
 		}
 
-		fmt.Printf("   ✅ Resolved and staged\n")
+		if cf.Strategy == merge.StrategyStructured {
+			fmt.Printf("   ✅ Resolved and staged (structured: %d merged deterministically, %d by LLM)\n", cf.AutoResolved, cf.LLMResolved)
+		} else {
+			fmt.Printf("   ✅ Resolved and staged\n")
+		}
+	}
+
+	fmt.Println("\n🔨 Verifying build...")
+	result, err := resolver.Verify(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify build: %w", err)
+	}
+	if !result.Success {
+		fmt.Println("❌ Build fails after resolution:")
+		fmt.Println(truncate(result.Output, 2000))
+		return fmt.Errorf("merged result does not build")
 	}
 
 	fmt.Println("\n✅ All conflicts resolved")
@@ -272,7 +272,7 @@ Examples:
 }
 
 func setDefaultModel(modelName string) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadUserSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load setup: %w", err)
 	}
@@ -426,8 +426,8 @@ func updateCatalogFromAllProviders() error {
 
 	apiKeys := make(map[string]string)
 	// Get API keys using GetAPIKey which checks:
-	// 1. Environment variables (BACKEND_API_KEY)
-	// 2. keys.yaml
+	// 1. keys in setup.yaml, the secret store and the legacy keys.yaml
+	// 2. Environment variables (BACKEND_API_KEY)
 	for backendName := range setup.Backend {
 		if key := config.GetAPIKey(backendName); key != "" {
 			apiKeys[backendName] = key
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"gptcode/internal/sarif"
+)
+
+// writeSARIF writes log to path. With a baseline from an earlier run,
+// results are marked new or unchanged; fixed ones are only counted unless
+// keepAbsent adds them as absent. A baseline that doesn't exist yet makes
+// every result new.
+func writeSARIF(log *sarif.Log, path, baseline string, keepAbsent bool) error {
+	fixed := 0
+	if baseline != "" {
+		prev, err := sarif.ReadFile(baseline)
+		if errors.Is(err, fs.ErrNotExist) {
+			prev = &sarif.Log{}
+		} else if err != nil {
+			return err
+		}
+		fixed = log.ApplyBaseline(prev, keepAbsent)
+	}
+
+	if err := log.WriteFile(path); err != nil {
+		return fmt.Errorf("failed to write SARIF: %w", err)
+	}
+
+	if baseline != "" {
+		fmt.Printf("📄 SARIF written to %s: %d new, %d unchanged, %d fixed\n", path,
+			log.Count(sarif.BaselineNew), log.Count(sarif.BaselineUnchanged), fixed)
+	} else {
+		fmt.Printf("📄 SARIF written to %s: %d result(s)\n", path, len(log.Results()))
+	}
+	return nil
+}
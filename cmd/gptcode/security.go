@@ -8,6 +8,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"gptcode/internal/config"
+	"gptcode/internal/events"
 	"gptcode/internal/llm"
 	"gptcode/internal/security"
 )
@@ -31,22 +32,39 @@ Supported tools:
 
 Examples:
   gptcode security scan           # Scan only
-  gptcode security scan --fix     # Scan and auto-fix`,
+  gptcode security scan --fix     # Scan and auto-fix
+
+Export findings as SARIF for GitHub code scanning or IDE viewers:
+  gptcode security scan --sarif security.sarif
+  gptcode security scan --sarif security.sarif --baseline security.sarif`,
 	RunE: runSecurityScan,
 }
 
 var securityFix bool
 var securityModel string
+var securitySARIF string
+var securityBaseline string
+var securityIncludeAbsent bool
 
 func init() {
 	rootCmd.AddCommand(securityCmd)
 	securityCmd.AddCommand(securityScanCmd)
 
 	securityScanCmd.Flags().BoolVar(&securityFix, "fix", false, "Automatically fix vulnerabilities")
+	securityScanCmd.Flags().StringVar(&securitySARIF, "sarif", "", "Also write the findings as SARIF 2.1.0 to this file")
+	securityScanCmd.Flags().StringVar(&securityBaseline, "baseline", "", "SARIF file of an earlier scan to compare findings with")
+	securityScanCmd.Flags().BoolVar(&securityIncludeAbsent, "include-absent", false, "Also write findings fixed since the baseline as absent results (not for code scanning uploads)")
 	securityCmd.PersistentFlags().StringVar(&securityModel, "model", "", "LLM model to use (default: from config)")
 }
 
 func runSecurityScan(cmd *cobra.Command, args []string) error {
+	if securityBaseline != "" && securitySARIF == "" {
+		return fmt.Errorf("--baseline requires --sarif")
+	}
+	if securityIncludeAbsent && securityBaseline == "" {
+		return fmt.Errorf("--include-absent requires --baseline")
+	}
+
 	setup, err := config.LoadSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
@@ -73,6 +91,13 @@ func runSecurityScan(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("scan failed: %w", err)
 	}
+	events.SetResult(report)
+
+	if securitySARIF != "" {
+		if err := writeSARIF(report.SARIF(version, workDir), securitySARIF, securityBaseline, securityIncludeAbsent); err != nil {
+			return err
+		}
+	}
 
 	if len(report.Vulnerabilities) == 0 {
 		fmt.Println("✅ No vulnerabilities detected")
@@ -104,6 +129,9 @@ func runSecurityScan(cmd *cobra.Command, args []string) error {
 		if vuln.Package != "" {
 			fmt.Printf(" in %s", vuln.Package)
 		}
+		if vuln.Fixed {
+			fmt.Print(" ✅ fixed")
+		}
 		fmt.Println()
 
 		if vuln.Description != "" {
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/langdetect"
+	"gptcode/internal/llm"
+	"gptcode/internal/modes"
+	"gptcode/internal/webhook"
+)
+
+var (
+	serveWebhooksPort        int
+	serveWebhooksConcurrency int
+	serveWebhooksQueueSize   int
+	serveWebhooksLabel       string
+	serveWebhooksModel       string
+	serveWebhooksTimeout     time.Duration
+	serveWebhooksWorkDir     string
+	serveWebhooksBotLogins   []string
+	serveWebhooksMetricsAddr string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run long-lived server modes",
+}
+
+var serveWebhooksCmd = &cobra.Command{
+	Use:   "webhooks",
+	Short: "Self-hosted autofix server driven by GitHub/GitLab webhooks",
+	Long: `Start an HTTP server that receives GitHub and GitLab webhooks and runs
+the autofix agent for each actionable event.
+
+Handled events:
+  GitHub: check_run (failed), issues (labeled with --label), pull_request_review_comment
+  GitLab: Pipeline Hook (failed), Issue Hook (label added), Note Hook (MR diff notes)
+
+Each job runs in an isolated temporary clone. Results are reported back as
+commit statuses (context "gptcode/autofix") and comments; fixes for issues and
+failed checks are proposed as a new PR/MR, review fixes are pushed to the PR branch.
+With --metrics-addr, Prometheus metrics are served at /metrics on a separate
+address, so they stay off the port that is open to the forges.
+
+Only trusted people can start jobs, because the agent runs commands in a
+clone that can push. GitHub review comments must come from an owner, member
+or collaborator, and GitLab events from a Developer or above. Events from the
+runner's own account (the token's user, plus any --bot-login) and failures on
+its own gptcode/ branches are ignored, and a finished job isn't run again for
+redeliveries of the same event within 24 hours.
+
+Environment:
+  GPTCODE_GITHUB_WEBHOOK_SECRET   Secret configured on the GitHub webhook
+  GPTCODE_GITLAB_WEBHOOK_SECRET   Secret token configured on the GitLab webhook
+  GH_TOKEN / GITHUB_TOKEN         Token used to clone, push and report on GitHub
+  GITLAB_TOKEN                    Token used to clone, push and report on GitLab
+
+Examples:
+  gt serve webhooks --port 8090
+  gt serve webhooks --concurrency 4 --label autofix
+  gt serve webhooks --metrics-addr 127.0.0.1:9090`,
+	RunE: runServeWebhooks,
+}
+
+func init() {
+	serveWebhooksCmd.Flags().IntVar(&serveWebhooksPort, "port", 8090, "HTTP port")
+	serveWebhooksCmd.Flags().IntVar(&serveWebhooksConcurrency, "concurrency", 2, "Maximum jobs running at once")
+	serveWebhooksCmd.Flags().IntVar(&serveWebhooksQueueSize, "queue-size", 100, "Maximum queued jobs before deliveries are rejected")
+	serveWebhooksCmd.Flags().StringVar(&serveWebhooksLabel, "label", webhook.DefaultTriggerLabel, "Issue label that triggers autofix")
+	serveWebhooksCmd.Flags().StringVar(&serveWebhooksModel, "model", "", "LLM model to use (default: from config)")
+	serveWebhooksCmd.Flags().DurationVar(&serveWebhooksTimeout, "timeout", 25*time.Minute, "Per-job timeout")
+	serveWebhooksCmd.Flags().StringVar(&serveWebhooksWorkDir, "workspace-root", "", "Directory for job workspaces (default: system temp)")
+	serveWebhooksCmd.Flags().StringSliceVar(&serveWebhooksBotLogins, "bot-login", nil, "Other accounts the runner acts as, whose events are ignored (repeatable)")
+	serveWebhooksCmd.Flags().StringVar(&serveWebhooksMetricsAddr, "metrics-addr", "", "Serve Prometheus metrics at /metrics on this address (e.g. 127.0.0.1:9090)")
+
+	serveCmd.AddCommand(serveWebhooksCmd)
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServeWebhooks(cmd *cobra.Command, args []string) error {
+	githubSecret := os.Getenv("GPTCODE_GITHUB_WEBHOOK_SECRET")
+	gitlabSecret := os.Getenv("GPTCODE_GITLAB_WEBHOOK_SECRET")
+	if githubSecret == "" && gitlabSecret == "" {
+		return fmt.Errorf("set GPTCODE_GITHUB_WEBHOOK_SECRET and/or GPTCODE_GITLAB_WEBHOOK_SECRET")
+	}
+
+	githubToken := os.Getenv("GH_TOKEN")
+	if githubToken == "" {
+		githubToken = os.Getenv("GITHUB_TOKEN")
+		// gh reads GH_TOKEN; keep reporting and cloning on the same credentials
+		if githubToken != "" {
+			os.Setenv("GH_TOKEN", githubToken)
+		}
+	}
+	gitlabToken := os.Getenv("GITLAB_TOKEN")
+
+	setup, err := config.LoadSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	backendName := setup.Defaults.Backend
+	backendCfg, ok := setup.Backend[backendName]
+	if !ok {
+		return fmt.Errorf("backend %s not configured", backendName)
+	}
+	var provider llm.Provider
+	if backendCfg.Type == "ollama" {
+		provider = llm.NewOllama(backendCfg.BaseURL)
+	} else {
+		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
+	}
+	model := serveWebhooksModel
+	if model == "" {
+		model = backendCfg.GetModelForAgent("query")
+	}
+
+	runner := &webhook.Runner{
+		Forges: map[webhook.Provider]webhook.Forge{
+			webhook.ProviderGitHub: webhook.GitHubForge{},
+			webhook.ProviderGitLab: webhook.NewGitLabForge(gitlabToken),
+		},
+		Tokens: map[webhook.Provider]string{
+			webhook.ProviderGitHub: githubToken,
+			webhook.ProviderGitLab: gitlabToken,
+		},
+		WorkspaceRoot: serveWebhooksWorkDir,
+		Timeout:       serveWebhooksTimeout,
+		BotLogins:     serveWebhooksBotLogins,
+		Execute: func(ctx context.Context, job *webhook.Job, workDir string) (string, error) {
+			language := string(langdetect.DetectLanguage(workDir))
+			if language == "" || language == "unknown" {
+				language = setup.Defaults.Lang
+			}
+			executor := modes.NewAutonomousExecutorWithLive(provider, workDir, model, language, nil, nil, backendName)
+			if err := executor.Execute(ctx, webhook.BuildPrompt(job)); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Model: `%s` · Job: `%s`", model, job.ID), nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		fmt.Fprintln(os.Stderr, "[WEBHOOK] Signal received, shutting down")
+		cancel()
+	}()
+
+	if serveWebhooksMetricsAddr != "" {
+		stop, err := serveMetrics(serveWebhooksMetricsAddr)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	queue := webhook.NewQueue(serveWebhooksConcurrency, serveWebhooksQueueSize, runner.Handle)
+	queue.Start(ctx)
+
+	server := webhook.NewServer(webhook.ServerConfig{
+		Port:         serveWebhooksPort,
+		GitHubSecret: githubSecret,
+		GitLabSecret: gitlabSecret,
+		TriggerLabel: serveWebhooksLabel,
+	}, queue)
+
+	fmt.Fprintf(os.Stderr, "[WEBHOOK] Listening on :%d (concurrency %d, model %s)\n", serveWebhooksPort, serveWebhooksConcurrency, model)
+	if githubSecret != "" {
+		fmt.Fprintf(os.Stderr, "[WEBHOOK]   GitHub: POST /webhooks/github\n")
+	}
+	if gitlabSecret != "" {
+		fmt.Fprintf(os.Stderr, "[WEBHOOK]   GitLab: POST /webhooks/gitlab\n")
+	}
+
+	err = server.ListenAndServe(ctx)
+	cancel()
+	queue.Wait()
+	return err
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/github"
+	"gptcode/internal/llm"
+	"gptcode/internal/split"
+)
+
+var (
+	splitDryRun   bool
+	splitNoVerify bool
+	splitPR       bool
+	splitBase     string
+	splitPrefix   string
+	splitDraft    bool
+	splitRepo     string
+	splitModel    string
+)
+
+var splitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Split working-tree changes into a series of reviewable commits",
+	Long: `Cluster the uncommitted changes into logical steps and commit them in order.
+
+Changes are grouped by unit (a Go package, or a source file and its test),
+ordered with the import graph so dependencies land first, with dependency
+manifests first and docs last. Whitespace-only edits become their own commit.
+
+Each commit is built and tested in an isolated worktree; a step that doesn't
+pass on its own is folded into the next one. Commit messages are generated
+from each step's diff.
+
+With --pr, every commit gets its own branch and PR, each based on the
+previous one (a stacked diff).
+
+Examples:
+  gt split --dry-run
+  gt split
+  gt split --pr --base main --draft`,
+	Args: cobra.NoArgs,
+	RunE: runSplit,
+}
+
+func init() {
+	splitCmd.Flags().BoolVar(&splitDryRun, "dry-run", false, "Show the planned commits without creating them")
+	splitCmd.Flags().BoolVar(&splitNoVerify, "no-verify", false, "Skip building and testing each commit")
+	splitCmd.Flags().BoolVar(&splitPR, "pr", false, "Open stacked pull requests, one per commit")
+	splitCmd.Flags().StringVar(&splitBase, "base", "", "Base branch of the first PR (default: repository default branch)")
+	splitCmd.Flags().StringVar(&splitPrefix, "branch-prefix", "", "Prefix for stacked branches (default: split/<current-branch>)")
+	splitCmd.Flags().BoolVar(&splitDraft, "draft", false, "Open PRs as drafts")
+	splitCmd.Flags().StringVar(&splitRepo, "repo", "", "GitHub repository (owner/repo)")
+	splitCmd.Flags().StringVar(&splitModel, "model", "", "LLM model for commit messages (default: from config)")
+
+	rootCmd.AddCommand(splitCmd)
+}
+
+func runSplit(cmd *cobra.Command, args []string) error {
+	topCmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	top, err := topCmd.Output()
+	if err != nil {
+		return fmt.Errorf("not a git repository")
+	}
+	workDir := strings.TrimSpace(string(top))
+
+	provider, model := splitProvider()
+	splitter := split.NewSplitter(provider, model, workDir)
+	splitter.Verify = !splitNoVerify
+
+	clusters, err := splitter.Plan()
+	if err != nil {
+		return fmt.Errorf("failed to plan split: %w", err)
+	}
+	if len(clusters) == 0 {
+		fmt.Println("✅ No changes to split")
+		return nil
+	}
+
+	fmt.Printf("🧩 Planned %d step(s):\n\n", len(clusters))
+	for i, c := range clusters {
+		fmt.Printf("%d. %s (%s)\n", i+1, c.Name, c.Kind)
+		for _, f := range c.Files() {
+			fmt.Printf("     %s\n", f)
+		}
+	}
+	if splitDryRun {
+		return nil
+	}
+
+	fmt.Println()
+	commits, err := splitter.Apply(context.Background(), clusters)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✨ Created %d commit(s)\n", len(commits))
+	for _, c := range commits {
+		mark := "✅"
+		if !c.Verified {
+			mark = "⚠️ "
+		}
+		fmt.Printf("   %s %s %s\n", mark, c.SHA[:7], c.Subject())
+	}
+
+	if !splitPR {
+		return nil
+	}
+
+	repo := splitRepo
+	if repo == "" {
+		repo = detectGitHubRepo()
+		if repo == "" {
+			return fmt.Errorf("could not detect GitHub repository. Use --repo flag")
+		}
+	}
+	client := github.NewClient(repo)
+	client.SetWorkDir(workDir)
+
+	base := splitBase
+	if base == "" {
+		base = client.DetectDefaultBranch()
+	}
+	prefix := splitPrefix
+	if prefix == "" {
+		branch, err := getCurrentBranch()
+		if err != nil {
+			return fmt.Errorf("failed to get branch: %w", err)
+		}
+		prefix = "split/" + branch
+	}
+
+	fmt.Printf("\n🚀 Opening %d stacked PR(s) on %s...\n", len(commits), base)
+	prs, err := split.OpenStack(client, workDir, commits, split.StackOptions{
+		Base:   base,
+		Prefix: prefix,
+		Draft:  splitDraft,
+	})
+	for _, pr := range prs {
+		fmt.Printf("   #%d %s ← %s\n", pr.Number, pr.URL, pr.HeadBranch)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open stacked PRs: %w", err)
+	}
+	return nil
+}
+
+// splitProvider returns the configured LLM, or nil to fall back to
+// heuristic commit messages when no backend is set up.
+func splitProvider() (llm.Provider, string) {
+	setup, err := config.LoadSetup()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  No config loaded, using generated commit messages: %v\n", err)
+		return nil, ""
+	}
+
+	backendName := setup.Defaults.Backend
+	backendCfg, ok := setup.Backend[backendName]
+	if !ok {
+		return nil, ""
+	}
+
+	var provider llm.Provider
+	if backendCfg.Type == "ollama" {
+		provider = llm.NewOllama(backendCfg.BaseURL)
+	} else {
+		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
+	}
+
+	model := splitModel
+	if model == "" {
+		model = backendCfg.GetModelForAgent("query")
+	}
+	if model == "" {
+		model = backendCfg.DefaultModel
+	}
+	return provider, model
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"gptcode/internal/config"
+	"gptcode/internal/live"
+	"gptcode/internal/llm"
+	"gptcode/internal/maestro"
 	"gptcode/internal/testrunner"
+	"gptcode/internal/validation"
 
 	"github.com/spf13/cobra"
 )
@@ -41,7 +51,55 @@ Examples:
 	RunE: runE2ETests,
 }
 
+var testRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the project's tests and report per-test results",
+	Long: `Run the project's test suite in machine-readable mode and summarize it.
+
+Uses go test -json, pytest --junitxml, jest --json, rspec --format json or
+mix test --trace depending on the project, then lists each failing test with
+its file:line and message. The report is also sent to Live when connected.
+
+Examples:
+  gptcode test run
+  gptcode test run --slowest 10`,
+	RunE: runProjectTests,
+}
+
+var testFlakyCmd = &cobra.Command{
+	Use:   "flaky",
+	Short: "List flaky tests and generate fixes for them",
+	Long: `List the tests recorded as flaky in .gptcode/flaky.json.
+
+When verification fails, each failing test is re-run in isolation. Tests
+that pass on a re-run are recorded as flaky and reported separately instead
+of triggering a code fix. This command shows their failure rates and, with
+--fix, asks the model for a fix for each one.
+
+Examples:
+  gptcode test flaky
+  gptcode test flaky --all
+  gptcode test flaky --fix
+  gptcode test flaky --forget "example.com/pkg › TestRetry"`,
+	RunE: runFlakyTests,
+}
+
+var (
+	testSlowestFlag int
+	flakyAllFlag    bool
+	flakyFixFlag    bool
+	flakyForgetFlag string
+)
+
 func init() {
+	testRunCmd.Flags().IntVar(&testSlowestFlag, "slowest", 5, "Show the N slowest tests (0 to disable)")
+	testCmd.AddCommand(testRunCmd)
+
+	testFlakyCmd.Flags().BoolVar(&flakyAllFlag, "all", false, "Also list tracked tests that have never passed")
+	testFlakyCmd.Flags().BoolVar(&flakyFixFlag, "fix", false, "Offer to generate a fix for each flaky test")
+	testFlakyCmd.Flags().StringVar(&flakyForgetFlag, "forget", "", "Drop a test's history, e.g. once its flake is fixed")
+	testCmd.AddCommand(testFlakyCmd)
+
 	testE2ECmd.Flags().StringVarP(&profileFlag, "profile", "p", "", "Profile to use for tests")
 	testE2ECmd.Flags().BoolVarP(&interactiveFlag, "interactive", "i", false, "Select profile interactively")
 	testE2ECmd.Flags().StringVarP(&backendFlag, "backend", "b", "", "Override backend (default: from config)")
@@ -209,7 +267,7 @@ func describeProfile(backend, profile string, setup *config.Setup) {
 }
 
 func saveDefaultE2EProfile(profile string) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadUserSetup()
 	if err != nil {
 		return err
 	}
@@ -217,3 +275,186 @@ func saveDefaultE2EProfile(profile string) error {
 	setup.E2E.DefaultProfile = profile
 	return config.SaveSetup(setup)
 }
+
+func runProjectTests(cmd *cobra.Command, args []string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("🧪 Running tests...")
+	result, err := validation.NewTestExecutor(cwd).RunTests()
+	if err != nil {
+		return err
+	}
+
+	report := result.Report
+	if report == nil {
+		// Runner without a machine-readable mode: fall back to counts
+		if result.Success {
+			fmt.Printf("✅ Tests passed (%d passed) in %s\n", result.Passed, result.Duration)
+			return nil
+		}
+		fmt.Println(result.Output)
+		return fmt.Errorf("tests failed (%d passed, %d failed)", result.Passed, result.Failed)
+	}
+
+	_ = live.GetClient().SendTestReport(report)
+
+	for _, tc := range report.Failures() {
+		fmt.Printf("\n❌ %s", tc.FullName())
+		if loc := tc.Location(); loc != "" {
+			fmt.Printf(" (%s)", loc)
+		}
+		fmt.Println()
+		for _, line := range strings.Split(truncate(tc.Message, 600), "\n") {
+			fmt.Printf("   %s\n", line)
+		}
+	}
+
+	if testSlowestFlag > 0 && len(report.Tests) > 0 {
+		fmt.Println("\n🐢 Slowest tests:")
+		for _, tc := range report.Slowest(testSlowestFlag) {
+			fmt.Printf("   %8s  %s\n", tc.Duration.Round(time.Millisecond), tc.FullName())
+		}
+	}
+
+	fmt.Println()
+	if !result.Success {
+		return fmt.Errorf("tests failed: %s", report.Summary())
+	}
+	fmt.Printf("✅ %s (%s, %s)\n", report.Summary(), report.Runner, result.Duration)
+	return nil
+}
+
+func runFlakyTests(cmd *cobra.Command, args []string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	store, err := maestro.LoadFlakyStore(cwd)
+	if err != nil {
+		return err
+	}
+
+	if flakyForgetFlag != "" {
+		if !store.Forget(flakyForgetFlag) {
+			return fmt.Errorf("no history for test %q", flakyForgetFlag)
+		}
+		if err := store.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  Forgot %s\n", flakyForgetFlag)
+		return nil
+	}
+
+	tests := store.List(!flakyAllFlag)
+	if len(tests) == 0 {
+		fmt.Println("✅ No flaky tests recorded")
+		return nil
+	}
+
+	label := "flaky"
+	if flakyAllFlag {
+		label = "tracked"
+	}
+	fmt.Printf("🎲 %d %s test(s):\n\n", len(tests), label)
+	for _, t := range tests {
+		marker := "🎲"
+		if !t.Flaky {
+			marker = "❌"
+		}
+		fmt.Printf("%s %5.1f%%  %s (%d/%d runs failed, last seen %s)\n",
+			marker, 100*t.FailureRate(), t.Name, t.Failures, t.Passes+t.Failures, t.LastSeen.Format("2006-01-02 15:04"))
+		if t.File != "" {
+			loc := t.File
+			if t.Line > 0 {
+				loc = fmt.Sprintf("%s:%d", t.File, t.Line)
+			}
+			fmt.Printf("          %s\n", loc)
+		}
+	}
+
+	if !flakyFixFlag {
+		return nil
+	}
+
+	setup, err := config.LoadSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	provider, model, err := getGenProvider(setup)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for _, t := range tests {
+		if !t.Flaky {
+			continue
+		}
+		fmt.Printf("\nGenerate a fix for %s? [y/N]: ", t.Name)
+		response, _ := reader.ReadString('\n')
+		response = strings.ToLower(strings.TrimSpace(response))
+		if response != "y" && response != "yes" {
+			continue
+		}
+
+		fmt.Println("🤖 Generating fix...")
+		resp, err := provider.Chat(context.Background(), llm.ChatRequest{
+			SystemPrompt: "You fix flaky tests. Find the source of nondeterminism (timing, ordering, shared state, randomness, external resources) and make the test deterministic without weakening what it checks.",
+			UserPrompt:   flakyFixPrompt(cwd, t),
+			Model:        model,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to generate fix: %w", err)
+		}
+		fmt.Printf("\n%s\n", strings.TrimSpace(resp.Text))
+	}
+	return nil
+}
+
+// flakyFixPrompt describes a flaky test and includes its source file
+func flakyFixPrompt(cwd string, t maestro.FlakyTest) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The test %s is flaky: counting its failures and the isolated re-runs of each on the same code, it failed %d of %d runs.\n\n", t.Name, t.Failures, t.Passes+t.Failures)
+	if t.LastFailure != "" {
+		fmt.Fprintf(&sb, "Last failure:\n%s\n\n", truncate(t.LastFailure, 2000))
+	}
+	if path := flakySourceFile(cwd, t); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			rel, _ := filepath.Rel(cwd, path)
+			fmt.Fprintf(&sb, "Source of %s:\n```\n%s\n```\n\n", rel, truncate(string(data), 12000))
+		}
+	}
+	sb.WriteString("Explain the cause of the flakiness in a few sentences, then give the corrected test code.")
+	return sb.String()
+}
+
+// flakySourceFile finds the file a flaky test lives in. Go reports file
+// names relative to the package, so they are resolved with go list.
+func flakySourceFile(cwd string, t maestro.FlakyTest) string {
+	if t.File == "" {
+		return ""
+	}
+	if path := filepath.Join(cwd, t.File); fileExistsAt(path) {
+		return path
+	}
+	if t.Suite == "" {
+		return ""
+	}
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", t.Suite).Output()
+	if err != nil {
+		return ""
+	}
+	if path := filepath.Join(strings.TrimSpace(string(out)), filepath.Base(t.File)); fileExistsAt(path) {
+		return path
+	}
+	return ""
+}
+
+func fileExistsAt(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
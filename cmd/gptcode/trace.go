@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/events"
+	"gptcode/internal/observability"
+	"gptcode/internal/tools"
+
+	"github.com/spf13/cobra"
+)
+
+var traceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Inspect, compare and replay session traces",
+	Long: `Inspect, compare and replay the traces of autonomous runs.
+
+Each run writes a session trace to ~/.gptcode/traces (set
+GPTCODE_TRACES_DIR to move it) with its decisions, tool calls, LLM calls,
+tokens and cost. Traces left in the current directory by older versions
+are read too.
+
+Traces are named by session ID; any unique prefix of one works, as does
+a path to a trace file.
+
+Examples:
+  gptcode trace list
+  gptcode trace show 5c227220
+  gptcode trace show 5c227220 --html trace.html
+  gptcode trace diff 5c227220 b03e6f8c
+  gptcode trace replay 5c227220`,
+}
+
+var traceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded session traces, newest first",
+	Args:  cobra.NoArgs,
+	RunE:  runTraceList,
+}
+
+var traceShowCmd = &cobra.Command{
+	Use:   "show <session>",
+	Short: "Show a trace as a timeline",
+	Long: `Show a trace as a timeline of decisions, tool calls and LLM calls with
+their tokens and cost.
+
+With --html the timeline is written as a self-contained HTML page.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTraceShow,
+}
+
+var traceDiffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Compare two runs of the same task",
+	Long: `Compare two runs of the same task: decisions made differently, tool calls
+only one run made, and the change in cost, tokens and time from a to b.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runTraceDiff,
+}
+
+var traceReplayCmd = &cobra.Command{
+	Use:   "replay <session>",
+	Short: "Re-run a trace's tool calls on a fresh checkout",
+	Long: `Re-run a trace's tool calls, in order, on a fresh git worktree of the
+commit the run started from, to reproduce a failure without calling a model.
+
+Each call is reported with whether it failed as it did in the recorded run.
+Secrets are masked when a trace is saved, so calls whose arguments held one
+aren't run; they're reported as diverged.
+The worktree is removed afterwards unless --keep is set.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTraceReplay,
+}
+
+var (
+	traceLimit     int
+	traceTask      string
+	traceJSON      bool
+	traceHTML      string
+	traceReplayDir string
+	traceKeep      bool
+	traceDryRun    bool
+)
+
+func init() {
+	traceListCmd.Flags().IntVar(&traceLimit, "limit", 20, "Show at most this many traces (0 for all)")
+	traceListCmd.Flags().StringVar(&traceTask, "task", "", "Only list runs whose task contains this text")
+	traceListCmd.Flags().BoolVar(&traceJSON, "json", false, "Output as JSON")
+	traceShowCmd.Flags().StringVar(&traceHTML, "html", "", "Write the timeline as HTML to this file")
+	traceShowCmd.Flags().BoolVar(&traceJSON, "json", false, "Output the timeline as JSON")
+	traceDiffCmd.Flags().BoolVar(&traceJSON, "json", false, "Output as JSON")
+	traceReplayCmd.Flags().StringVar(&traceReplayDir, "dir", "", "Where to check out the commit (default: a new temporary directory)")
+	traceReplayCmd.Flags().BoolVar(&traceKeep, "keep", false, "Keep the worktree after replaying")
+	traceReplayCmd.Flags().BoolVar(&traceDryRun, "dry-run", false, "List the tool calls without running them")
+
+	traceCmd.AddCommand(traceListCmd, traceShowCmd, traceDiffCmd, traceReplayCmd)
+	rootCmd.AddCommand(traceCmd)
+}
+
+// traceDirs are searched for traces: the traces directory, then the
+// current directory where older versions wrote them
+func traceDirs() []string {
+	return []string{observability.TracesDir(), "."}
+}
+
+func runTraceList(cmd *cobra.Command, args []string) error {
+	traces, err := observability.ListTraces(traceDirs()...)
+	if err != nil {
+		return err
+	}
+	if traceTask != "" {
+		var filtered []observability.TraceFile
+		for _, t := range traces {
+			if strings.Contains(strings.ToLower(t.Command), strings.ToLower(traceTask)) {
+				filtered = append(filtered, t)
+			}
+		}
+		traces = filtered
+	}
+	if traceLimit > 0 && len(traces) > traceLimit {
+		traces = traces[:traceLimit]
+	}
+	events.SetResult(traces)
+
+	if traceJSON {
+		return printJSON(traces)
+	}
+	if len(traces) == 0 {
+		fmt.Println("No traces recorded yet")
+		return nil
+	}
+	fmt.Printf("%-10s %-16s %8s %9s %5s  %-8s %s\n", "SESSION", "STARTED", "TIME", "COST", "TOOLS", "STATUS", "TASK")
+	for _, t := range traces {
+		totals := observability.Totals(t.SessionTrace)
+		status := "✓ ok"
+		if !t.Success {
+			status = "✗ failed"
+		}
+		task, _, _ := strings.Cut(strings.TrimSpace(t.Command), "\n")
+		if r := []rune(task); len(r) > 60 {
+			task = string(r[:59]) + "…"
+		}
+		fmt.Printf("%-10.8s %-16s %7.1fs %9s %5d  %-8s %s\n",
+			t.SessionID, t.StartTime.Format("2006-01-02 15:04"), float64(t.TotalTimeMs)/1000,
+			fmt.Sprintf("$%.4f", totals.Cost), totals.ToolCalls, status, task)
+	}
+	return nil
+}
+
+func runTraceShow(cmd *cobra.Command, args []string) error {
+	t, err := observability.FindTrace(args[0], traceDirs()...)
+	if err != nil {
+		return err
+	}
+
+	detail := struct {
+		observability.TraceFile
+		Timeline []observability.TimelineEntry `json:"timeline"`
+		Totals   observability.TraceTotals     `json:"totals"`
+	}{*t, observability.Timeline(t.SessionTrace), observability.Totals(t.SessionTrace)}
+	events.SetResult(detail)
+
+	if traceHTML != "" {
+		f, err := os.Create(traceHTML)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", traceHTML, err)
+		}
+		defer f.Close()
+		if err := observability.RenderHTML(f, t.SessionTrace); err != nil {
+			return fmt.Errorf("failed to render trace: %w", err)
+		}
+		fmt.Printf("📄 Wrote %s\n", traceHTML)
+		return nil
+	}
+	if traceJSON {
+		return printJSON(detail)
+	}
+	observability.RenderTimeline(os.Stdout, t.SessionTrace)
+	return nil
+}
+
+func runTraceDiff(cmd *cobra.Command, args []string) error {
+	a, err := observability.FindTrace(args[0], traceDirs()...)
+	if err != nil {
+		return err
+	}
+	b, err := observability.FindTrace(args[1], traceDirs()...)
+	if err != nil {
+		return err
+	}
+	d := observability.DiffTraces(a.SessionTrace, b.SessionTrace)
+	events.SetResult(d)
+	if traceJSON {
+		return printJSON(d)
+	}
+	observability.RenderDiff(os.Stdout, d)
+	return nil
+}
+
+func runTraceReplay(cmd *cobra.Command, args []string) error {
+	t, err := observability.FindTrace(args[0], traceDirs()...)
+	if err != nil {
+		return err
+	}
+	calls := observability.ToolCalls(t.SessionTrace)
+	if len(calls) == 0 {
+		return fmt.Errorf("trace %s has no recorded tool calls to replay", t.SessionID)
+	}
+
+	if traceDryRun {
+		for i, c := range calls {
+			fmt.Printf("%3d. %s %s\n", i+1, c.Name, c.Arguments)
+		}
+		return nil
+	}
+
+	repo := t.WorkDir
+	if repo == "" {
+		repo, _ = os.Getwd()
+	}
+	dir, tempDir := traceReplayDir, ""
+	if dir == "" {
+		tempDir, err = os.MkdirTemp("", "gptcode-replay-")
+		if err != nil {
+			return fmt.Errorf("failed to create replay directory: %w", err)
+		}
+		dir = filepath.Join(tempDir, "checkout")
+	}
+	if err := observability.Checkout(t.SessionTrace, repo, dir); err != nil {
+		if tempDir != "" {
+			os.RemoveAll(tempDir)
+		}
+		return err
+	}
+	fmt.Printf("🔁 Replaying %d tool call(s) from %.8s on %.12s in %s\n\n", len(calls), t.SessionID, t.Commit, dir)
+
+	results := observability.Replay(t.SessionTrace, dir, func(call observability.ToolCallTrace, dir string) (string, string) {
+		r := tools.ExecuteToolFromLLM(tools.LLMToolCall{Name: call.Name, Arguments: call.Arguments}, dir)
+		return r.Result, r.Error
+	})
+
+	diverged, reproduced, masked := 0, 0, 0
+	for i, r := range results {
+		mark := "✓"
+		switch {
+		case r.Masked:
+			mark = "🔒"
+			diverged++
+			masked++
+		case r.Diverged:
+			mark = "≠"
+			diverged++
+		case r.Error != "":
+			mark = "✗"
+			reproduced++
+		}
+		fmt.Printf("%3d. %s %s\n", i+1, mark, r.Call.Name)
+		if r.Error != "" {
+			fmt.Printf("       error: %s\n", firstLine(r.Error))
+		}
+		if r.Diverged && !r.Masked && r.Call.Error != "" {
+			fmt.Printf("       recorded error: %s\n", firstLine(r.Call.Error))
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case diverged > 0:
+		fmt.Printf("⚠️  %d call(s) went differently than recorded\n", diverged)
+		if masked > 0 {
+			fmt.Printf("   %d of them weren't run because their arguments were redacted when the trace was saved\n", masked)
+		}
+	case reproduced > 0:
+		fmt.Printf("✅ Reproduced %d failing call(s)\n", reproduced)
+	default:
+		fmt.Println("✅ Every call went as recorded")
+	}
+
+	if traceKeep {
+		fmt.Printf("   Worktree kept at %s; remove it with: git worktree remove --force %s\n", dir, dir)
+		return nil
+	}
+	if err := observability.RemoveCheckout(repo, dir); err != nil {
+		return err
+	}
+	if tempDir != "" {
+		return os.RemoveAll(tempDir)
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
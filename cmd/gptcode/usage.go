@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gptcode/internal/ledger"
+
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show LLM spend from the cost ledger",
+	Long: `Show what LLM calls have cost, grouped by day, model, agent or repo.
+
+Every call made through a provider is priced and appended to the cost
+ledger at ~/.gptcode/usage.jsonl (set GPTCODE_LEDGER to move it, or to
+"off" to stop recording). Prices come from the model catalog; override
+them per backend in setup.yaml:
+
+  backend:
+    groq:
+      pricing:
+        llama-3.3-70b-versatile: {prompt: 0.59, completion: 0.79}
+        "*": {prompt: 0.10, completion: 0.10}   # any other model
+
+Examples:
+  gptcode usage
+  gptcode usage --since 7d --by model
+  gptcode usage --since 2025-01-01 --by day,agent
+  gptcode usage --repo gptcode --json`,
+	RunE: runUsage,
+}
+
+var (
+	usageSince string
+	usageBy    string
+	usageRepo  string
+	usageJSON  bool
+)
+
+func init() {
+	usageCmd.Flags().StringVar(&usageSince, "since", "30d", "Window to report: 30d, 12h, 2w or a YYYY-MM-DD date")
+	usageCmd.Flags().StringVar(&usageBy, "by", "day,model,agent,repo", "Comma-separated groupings: "+strings.Join(ledger.Dimensions, ", "))
+	usageCmd.Flags().StringVar(&usageRepo, "repo", "", "Only count calls made in this repo")
+	usageCmd.Flags().BoolVar(&usageJSON, "json", false, "Output as JSON")
+	rootCmd.AddCommand(usageCmd)
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	since, err := ledger.ParseSince(usageSince, time.Now())
+	if err != nil {
+		return err
+	}
+
+	l := ledger.Default()
+	if l == nil {
+		return fmt.Errorf("cost ledger is disabled (GPTCODE_LEDGER=off)")
+	}
+	entries, err := l.Entries(since)
+	if err != nil {
+		return err
+	}
+	if usageRepo != "" {
+		var filtered []ledger.Entry
+		for _, e := range entries {
+			if e.Repo == usageRepo {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+
+	type group struct {
+		By   string       `json:"by"`
+		Rows []ledger.Row `json:"rows"`
+	}
+	var groups []group
+	total := 0.0
+	for _, e := range entries {
+		total += e.Cost
+	}
+	for _, by := range strings.Split(usageBy, ",") {
+		by = strings.TrimSpace(by)
+		rows, err := ledger.Summarize(entries, by)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, group{By: by, Rows: rows})
+	}
+
+	if usageJSON {
+		data, _ := json.MarshalIndent(map[string]interface{}{
+			"since":  since,
+			"calls":  len(entries),
+			"cost":   total,
+			"groups": groups,
+		}, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("💰 $%.4f across %d call(s) since %s\n", total, len(entries), since.Format("2006-01-02 15:04"))
+	if len(entries) == 0 {
+		return nil
+	}
+	for _, g := range groups {
+		fmt.Printf("\nBy %s:\n", g.By)
+		fmt.Printf("  %-40s %7s %12s %12s %10s\n", strings.ToUpper(g.By), "CALLS", "PROMPT", "COMPLETION", "COST")
+		for _, r := range g.Rows {
+			fmt.Printf("  %-40s %7d %12d %12d %10s\n", truncateUsageKey(r.Key, 40), r.Calls, r.PromptTokens, r.CompletionTokens, fmt.Sprintf("$%.4f", r.Cost))
+		}
+	}
+	return nil
+}
+
+func truncateUsageKey(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
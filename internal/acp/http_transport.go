@@ -18,10 +18,13 @@ import (
 //   - POST /acp       — JSON-RPC request → response
 //   - GET  /acp/events — SSE stream of session/update notifications
 //   - GET  /health     — health check
+//
+// Further endpoints, such as /metrics, can be added with Handle.
 type HTTPTransport struct {
 	server     *Server
 	httpServer *http.Server
 	port       int
+	handlers   map[string]http.Handler
 
 	// SSE subscribers: sessionID → list of channels
 	subs   map[string][]chan []byte
@@ -37,12 +40,24 @@ func NewHTTPTransport(server *Server, port int) *HTTPTransport {
 	}
 }
 
+// Handle serves handler at pattern next to the ACP endpoints. It must be
+// called before ListenAndServe.
+func (h *HTTPTransport) Handle(pattern string, handler http.Handler) {
+	if h.handlers == nil {
+		h.handlers = make(map[string]http.Handler)
+	}
+	h.handlers[pattern] = handler
+}
+
 // ListenAndServe starts the HTTP server. Blocks until ctx is cancelled.
 func (h *HTTPTransport) ListenAndServe(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/acp", h.handleRPC)
 	mux.HandleFunc("/acp/events", h.handleSSE)
 	mux.HandleFunc("/health", h.handleHealth)
+	for pattern, handler := range h.handlers {
+		mux.Handle(pattern, handler)
+	}
 
 	h.httpServer = &http.Server{
 		Addr:         fmt.Sprintf(":%d", h.port),
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"os"
 
+	"gptcode/internal/ledger"
 	"gptcode/internal/llm"
+	"gptcode/internal/telemetry"
 	"gptcode/internal/tools"
 )
 
@@ -68,6 +70,9 @@ Analysis:
 Focus on understanding the codebase.`
 
 func (a *AnalyzerAgent) Analyze(ctx context.Context, task string, statusCallback StatusCallback) (string, error) {
+	ctx = ledger.WithAgent(ctx, "analyzer")
+	ctx, span := telemetry.StartAgent(ctx, "analyzer")
+	defer span.End()
 	if statusCallback != nil {
 		statusCallback("Analyzer: Understanding codebase...")
 	}
@@ -167,7 +172,7 @@ Do NOT suggest changes. Just report what exists.`, task)
 					}
 				}
 			}
-			result := tools.ExecuteToolFromLLM(llmCall, a.cwd)
+			result := tools.ExecuteToolFromLLMContext(ctx, llmCall, a.cwd)
 
 			content := result.Result
 			if result.Error != "" {
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 
+	"gptcode/internal/ledger"
 	"gptcode/internal/llm"
+	"gptcode/internal/telemetry"
 )
 
 type Coordinator struct {
@@ -35,6 +37,9 @@ func NewCoordinator(
 }
 
 func (c *Coordinator) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, error) {
+	ctx = ledger.WithAgent(ctx, "coordinator")
+	ctx, span := telemetry.StartAgent(ctx, "coordinator")
+	defer span.End()
 	// Use the last user message for intent classification
 	lastMessage := ""
 	for i := len(history) - 1; i >= 0; i-- {
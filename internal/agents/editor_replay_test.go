@@ -0,0 +1,55 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gptcode/internal/llm"
+)
+
+// TestEditor_Replay runs the editor against recorded model responses, so a
+// change to its prompts or tool definitions shows up as a divergence. After
+// an intentional change, delete the cassettes and re-record with
+// GPTCODE_CASSETTE_MODE=record.
+func TestEditor_Replay(t *testing.T) {
+	tmpDir := t.TempDir()
+	cassettes := filepath.Join("testdata", "cassettes", "editor_write_file")
+
+	mode := llm.ReplayReplay
+	if os.Getenv(llm.EnvCassetteMode) == string(llm.ReplayRecord) {
+		mode = llm.ReplayRecord
+	}
+	script := &mockProvider{
+		responses: []llm.ChatResponse{
+			{
+				ToolCalls: []llm.ChatToolCall{
+					{ID: "call_1", Name: "write_file", Arguments: `{"path":"greet.go","content":"package greet\n"}`},
+				},
+			},
+			{Text: "Created greet.go"},
+		},
+	}
+	provider := llm.NewReplayProvider(script, cassettes, mode)
+	provider.Substitutions[tmpDir] = "{{workdir}}"
+
+	editor := NewEditor(provider, tmpDir, "test-model")
+	history := []llm.ChatMessage{{Role: "user", Content: "Create greet.go declaring package greet"}}
+	result, modified, err := editor.Execute(context.Background(), history, nil)
+	if d := provider.Divergences(); len(d) > 0 {
+		t.Fatalf("editor requests no longer match the cassettes: %+v", d)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "Created greet.go" || len(modified) != 1 || modified[0] != "greet.go" {
+		t.Errorf("unexpected result %q, modified %v", result, modified)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "greet.go")); string(data) != "package greet\n" {
+		t.Errorf("unexpected file content %q", data)
+	}
+	if unused := provider.Unused(); len(unused) > 0 {
+		t.Errorf("editor made fewer model calls than recorded: %v", unused)
+	}
+}
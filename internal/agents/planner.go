@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 
+	"gptcode/internal/ledger"
 	"gptcode/internal/llm"
+	"gptcode/internal/telemetry"
 )
 
 type PlannerAgent struct {
@@ -148,7 +150,27 @@ Use run_command to display explanation about slot entries:
 
 Create minimal, direct plans.`
 
+// planSchemaInstructions asks for the machine-readable step DAG that maestro
+// uses to run independent steps in parallel.
+const planSchemaInstructions = "Finally, end the plan with the steps as a dependency graph in a fenced json block:\n\n" +
+	"```json\n" +
+	`{"steps": [
+  {"id": "store", "title": "Add Save to store", "description": "What to change and how",
+   "depends_on": [], "files": ["internal/store/store.go"], "acceptance": ["cli: go build ./...", "Save rejects empty keys"]},
+  {"id": "api", "title": "Expose save endpoint", "description": "...",
+   "depends_on": ["store"], "files": ["internal/api/save.go"], "acceptance": ["http: POST /save -> 201"]}
+]}` + "\n```\n\n" +
+	`- "depends_on" lists step ids that must be finished first; omit dependencies that aren't real
+- "files" lists EVERY file the step creates or modifies; steps with disjoint files may run in parallel
+- "acceptance" holds the step's verifiable success criteria. Write checkable ones as
+  "http: METHOD /path -> STATUS [contains \"text\"]", "cli: command [=> expected output]" or
+  "grep: regexp in glob" ("!grep:" when it must not match); anything else is reviewed against the diff
+- A single-step plan is fine`
+
 func (p *PlannerAgent) CreatePlan(ctx context.Context, task string, analysis string, statusCallback StatusCallback) (string, error) {
+	ctx = ledger.WithAgent(ctx, "planner")
+	ctx, span := telemetry.StartAgent(ctx, "planner")
+	defer span.End()
 	if statusCallback != nil {
 		statusCallback("Planner: Creating minimal plan...")
 	}
@@ -212,7 +234,9 @@ REMEMBER:
 - NO automation unless explicitly requested
 - NO files for explanations - use command output instead
 - Solve the task DIRECTLY in the simplest way
-- Keep it MINIMAL. NO extra features.`, task, analysis)
+- Keep it MINIMAL. NO extra features.
+
+`+planSchemaInstructions, task, analysis)
 
 	resp, err := p.provider.Chat(ctx, llm.ChatRequest{
 		SystemPrompt: plannerPrompt,
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 
+	"gptcode/internal/ledger"
 	"gptcode/internal/llm"
+	"gptcode/internal/telemetry"
 	"gptcode/internal/tools"
 )
 
@@ -34,6 +36,9 @@ You can:
 You CANNOT modify files. Be concise and direct in your explanations.`
 
 func (q *QueryAgent) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, error) {
+	ctx = ledger.WithAgent(ctx, "query")
+	ctx, span := telemetry.StartAgent(ctx, "query")
+	defer span.End()
 	toolDefs := []interface{}{
 		map[string]interface{}{
 			"type": "function",
@@ -153,7 +158,7 @@ func (q *QueryAgent) Execute(ctx context.Context, history []llm.ChatMessage, sta
 			if statusCallback != nil {
 				statusCallback(fmt.Sprintf("Query: Executing %s...", tc.Name))
 			}
-			result := tools.ExecuteToolFromLLM(llmCall, q.cwd)
+			result := tools.ExecuteToolFromLLMContext(ctx, llmCall, q.cwd)
 
 			content := result.Result
 			if result.Error != "" {
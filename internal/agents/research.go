@@ -3,7 +3,9 @@ package agents
 import (
 	"context"
 
+	"gptcode/internal/ledger"
 	"gptcode/internal/llm"
+	"gptcode/internal/telemetry"
 )
 
 type ResearchAgent struct {
@@ -23,6 +25,9 @@ You can use web_search to find current information, documentation, and answers t
 Be concise and cite sources when possible.`
 
 func (r *ResearchAgent) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, error) {
+	ctx = ledger.WithAgent(ctx, "research")
+	ctx, span := telemetry.StartAgent(ctx, "research")
+	defer span.End()
 	if statusCallback != nil {
 		statusCallback("Research: Searching/Summarizing...")
 	}
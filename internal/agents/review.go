@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 
+	"gptcode/internal/ledger"
 	"gptcode/internal/llm"
+	"gptcode/internal/telemetry"
 	"gptcode/internal/tools"
 )
 
@@ -82,6 +84,9 @@ Be concise but thorough. If the code is good, say so.`
 }
 
 func (r *ReviewAgent) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, error) {
+	ctx = ledger.WithAgent(ctx, "review")
+	ctx, span := telemetry.StartAgent(ctx, "review")
+	defer span.End()
 	reviewPrompt := buildReviewPrompt()
 
 	toolDefs := []interface{}{
@@ -185,7 +190,7 @@ func (r *ReviewAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 			if statusCallback != nil {
 				statusCallback(fmt.Sprintf("Review: Executing %s...", tc.Name))
 			}
-			result := tools.ExecuteToolFromLLM(llmCall, r.cwd)
+			result := tools.ExecuteToolFromLLMContext(ctx, llmCall, r.cwd)
 
 			content := result.Result
 			if result.Error != "" {
@@ -6,7 +6,9 @@ import (
 	"os"
 	"strings"
 
+	"gptcode/internal/ledger"
 	"gptcode/internal/llm"
+	"gptcode/internal/telemetry"
 	"gptcode/internal/tools"
 )
 
@@ -174,6 +176,9 @@ GOOD:
 Be direct and precise.`
 
 func (v *ReviewerAgent) Review(ctx context.Context, plan string, modifiedFiles []string, statusCallback StatusCallback) (*ReviewResult, error) {
+	ctx = ledger.WithAgent(ctx, "reviewer")
+	ctx, span := telemetry.StartAgent(ctx, "reviewer")
+	defer span.End()
 	if statusCallback != nil {
 		statusCallback("Reviewer: Analyzing changes...")
 	}
@@ -297,7 +302,7 @@ Be precise and specific.`, plan, filesStr)
 				Name:      tc.Name,
 				Arguments: tc.Arguments,
 			}
-			result := tools.ExecuteToolFromLLM(llmCall, v.cwd)
+			result := tools.ExecuteToolFromLLMContext(ctx, llmCall, v.cwd)
 
 			content := result.Result
 			if result.Error != "" {
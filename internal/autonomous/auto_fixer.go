@@ -1,10 +1,15 @@
 package autonomous
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"gptcode/internal/maestro"
+	"gptcode/internal/merge"
 )
 
 type AutoFixer struct {
@@ -336,8 +341,22 @@ func (mr *MergeResolver) AutoResolve() *MergeResult {
 		return result
 	}
 
-	// Simple auto-resolve: accept ours for all
+	// Structured merge first: disjoint declarations and imports merge cleanly
+	structured := 0
 	for _, file := range conflicts {
+		merged, _, err := merge.MergeDeclarations(file, merge.LoadVersions(mr.cwd, file), nil)
+		if err != nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(mr.cwd, file), []byte(merged), 0644); err != nil {
+			continue
+		}
+		mr.run("git add " + file)
+		structured++
+	}
+
+	// Simple auto-resolve: accept ours for all
+	for _, file := range mr.GetConflicts() {
 		// Check if conflict is simple (no complex merges needed)
 		content, err := os.ReadFile(mr.cwd + "/" + file)
 		if err != nil {
@@ -362,6 +381,17 @@ func (mr *MergeResolver) AutoResolve() *MergeResult {
 		return result
 	}
 
+	if structured > 0 {
+		verification, err := maestro.NewBuildVerifier(mr.cwd).Verify(context.Background())
+		if err != nil || !verification.Success {
+			result.Error = "build fails after structured merge"
+			if verification != nil {
+				result.Output = verification.Output
+			}
+			return result
+		}
+	}
+
 	result.Resolved = true
 	result.Output = "All conflicts resolved"
 	return result
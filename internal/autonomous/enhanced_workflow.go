@@ -2,12 +2,12 @@ package autonomous
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sync"
 	"time"
+
+	"gptcode/internal/ledger"
 )
 
 type EnhancedWorkflow struct {
@@ -21,6 +21,8 @@ type EnhancedWorkflow struct {
 	cwd           string
 }
 
+// BudgetManager enforces daily and per-session spending limits. Spend is
+// read from the cost ledger, so it covers every LLM call the process made.
 type BudgetManager struct {
 	mu           sync.Mutex
 	dailyLimit   float64
@@ -28,7 +30,8 @@ type BudgetManager struct {
 	spentToday   float64
 	spentSession float64
 	alerts       []BudgetAlert
-	budgetFile   string
+	ledger       *ledger.Ledger
+	started      time.Time
 }
 
 type BudgetAlert struct {
@@ -83,48 +86,73 @@ func NewEnhancedWorkflow(cwd string) *EnhancedWorkflow {
 }
 
 func NewBudgetManager(cwd string) *BudgetManager {
-	bm := &BudgetManager{
+	return &BudgetManager{
 		dailyLimit:   10.0,
 		sessionLimit: 2.0,
-		budgetFile:   filepath.Join(cwd, ".gptcode", "budget.json"),
+		ledger:       ledger.Default(),
+		started:      time.Now(),
 	}
-	bm.load()
-	return bm
 }
 
-func (bm *BudgetManager) RecordCost(cost float64, task string) {
-	bm.mu.Lock()
-	defer bm.mu.Unlock()
-
-	bm.spentSession += cost
-	bm.spentToday += cost
+// refresh reloads spend from the ledger and raises alerts as the session
+// limit gets close
+func (bm *BudgetManager) refresh() {
+	if bm.ledger == nil {
+		return
+	}
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := midnight
+	if bm.started.Before(since) {
+		since = bm.started
+	}
+	entries, err := bm.ledger.Entries(since)
+	if err != nil {
+		return
+	}
+	bm.spentToday, bm.spentSession = 0, 0
+	for _, e := range entries {
+		if !e.Time.Before(midnight) {
+			bm.spentToday += e.Cost
+		}
+		if !e.Time.Before(bm.started) {
+			bm.spentSession += e.Cost
+		}
+	}
 
-	if cost > bm.sessionLimit*0.5 && len(bm.alerts) == 0 {
+	if bm.spentSession >= bm.sessionLimit*0.5 && !bm.alerted("warning") {
 		bm.alerts = append(bm.alerts, BudgetAlert{
 			Level:     "warning",
 			Threshold: bm.sessionLimit * 0.5,
 			Current:   bm.spentSession,
 			Message:   fmt.Sprintf("Half of session budget used ($%.2f/$%.2f)", bm.spentSession, bm.sessionLimit),
-			Timestamp: time.Now(),
+			Timestamp: now,
 		})
 	}
-
-	if bm.spentSession >= bm.sessionLimit {
+	if bm.spentSession >= bm.sessionLimit && !bm.alerted("critical") {
 		bm.alerts = append(bm.alerts, BudgetAlert{
 			Level:     "critical",
 			Threshold: bm.sessionLimit,
 			Current:   bm.spentSession,
 			Message:   fmt.Sprintf("Session budget exceeded! ($%.2f/$%.2f)", bm.spentSession, bm.sessionLimit),
-			Timestamp: time.Now(),
+			Timestamp: now,
 		})
 	}
+}
 
-	bm.save()
+func (bm *BudgetManager) alerted(level string) bool {
+	for _, a := range bm.alerts {
+		if a.Level == level {
+			return true
+		}
+	}
+	return false
 }
 
 func (bm *BudgetManager) CanProceed() (bool, string) {
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
+	bm.refresh()
 
 	if bm.spentSession >= bm.sessionLimit {
 		return false, fmt.Sprintf("Session budget exceeded (%.2f/%.2f)", bm.spentSession, bm.sessionLimit)
@@ -156,42 +184,12 @@ func (bm *BudgetManager) ClearAlerts() {
 	bm.alerts = []BudgetAlert{}
 }
 
-func (bm *BudgetManager) save() {
-	data := struct {
-		SpentToday   float64 `json:"spent_today"`
-		SpentSession float64 `json:"spent_session"`
-		Date         string  `json:"date"`
-	}{
-		SpentToday:   bm.spentToday,
-		SpentSession: bm.spentSession,
-		Date:         time.Now().Format("2006-01-02"),
-	}
-
-	jsonData, _ := json.MarshalIndent(data, "", "  ")
-	os.WriteFile(bm.budgetFile, jsonData, 0644)
-}
-
-func (bm *BudgetManager) load() {
-	data, err := os.ReadFile(bm.budgetFile)
-	if err != nil {
-		return
-	}
-
-	var loaded struct {
-		SpentToday   float64 `json:"spent_today"`
-		SpentSession float64 `json:"spent_session"`
-		Date         string  `json:"date"`
-	}
-
-	if err := json.Unmarshal(data, &loaded); err != nil {
-		return
-	}
-
-	today := time.Now().Format("2006-01-02")
-	if loaded.Date == today {
-		bm.spentToday = loaded.SpentToday
-	}
-	bm.spentSession = loaded.SpentSession
+// Summary describes spend against both limits
+func (bm *BudgetManager) Summary() string {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.refresh()
+	return fmt.Sprintf("$%.2f/$%.2f session, $%.2f/$%.2f daily", bm.spentSession, bm.sessionLimit, bm.spentToday, bm.dailyLimit)
 }
 
 func NewLoopDetector(maxHistory, windowSize int) *LoopDetector {
@@ -471,7 +469,7 @@ func (ew *EnhancedWorkflow) RefactorFiles(ctx context.Context, files []string, t
 func (ew *EnhancedWorkflow) GetStats() map[string]interface{} {
 	return map[string]interface{}{
 		"learning":     ew.learning.GetStats(),
-		"budget":       fmt.Sprintf("$%.2f/$%.2f session, $%.2f/$%.2f daily", ew.budgetManager.spentSession, ew.budgetManager.sessionLimit, ew.budgetManager.spentToday, ew.budgetManager.dailyLimit),
+		"budget":       ew.budgetManager.Summary(),
 		"telemetry":    ew.telemetry.Summary(),
 		"loop_history": len(ew.loopDetector.GetHistory()),
 	}
@@ -0,0 +1,198 @@
+package bisect
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Exit codes understood by `git bisect run`
+const (
+	ExitGood = 0
+	ExitBad  = 1
+	ExitSkip = 125
+)
+
+// Options configures an automated bisect session
+type Options struct {
+	Dir  string
+	Good string
+	Bad  string
+	// Script is a POSIX shell script that exits 0 when the bug is absent and
+	// non-zero when it reproduces (125 to skip an untestable commit).
+	Script string
+	// Runs is how many times each commit is tested. Commits whose runs
+	// disagree are reported as flaky and skipped.
+	Runs int
+}
+
+// Result is the outcome of a bisect session
+type Result struct {
+	FirstBad   string
+	Candidates []string // set when skipped commits made the result ambiguous
+	Flaky      []string
+	TestOutput string // reproduction output at the first bad commit
+	Log        string
+}
+
+// Bisector drives `git bisect run` with a reproduction script
+type Bisector struct {
+	opts Options
+}
+
+func NewBisector(opts Options) *Bisector {
+	if opts.Runs < 1 {
+		opts.Runs = 1
+	}
+	return &Bisector{opts: opts}
+}
+
+// Run executes the bisect and always resets the repository afterwards
+func (b *Bisector) Run(ctx context.Context) (*Result, error) {
+	if dirty, err := b.isDirty(); err != nil {
+		return nil, err
+	} else if dirty {
+		return nil, fmt.Errorf("working tree has uncommitted changes; commit or stash them before bisecting")
+	}
+
+	workDir, err := os.MkdirTemp("", "gptcode-bisect-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bisect workspace: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	// Scripts live outside the repository so checkouts can't touch them
+	scriptPath := filepath.Join(workDir, "repro.sh")
+	if err := os.WriteFile(scriptPath, []byte(b.opts.Script), 0755); err != nil {
+		return nil, fmt.Errorf("failed to write reproduction script: %w", err)
+	}
+	logDir := filepath.Join(workDir, "logs")
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log dir: %w", err)
+	}
+	runnerPath := filepath.Join(workDir, "run.sh")
+	if err := os.WriteFile(runnerPath, []byte(RunnerScript(scriptPath, logDir, b.opts.Runs)), 0755); err != nil {
+		return nil, fmt.Errorf("failed to write runner script: %w", err)
+	}
+
+	if out, err := b.git(ctx, "bisect", "start", b.opts.Bad, b.opts.Good); err != nil {
+		return nil, fmt.Errorf("failed to start bisect: %w\nOutput: %s", err, out)
+	}
+	defer b.git(context.Background(), "bisect", "reset")
+
+	out, runErr := b.git(ctx, "bisect", "run", runnerPath)
+	result := &Result{Log: out}
+	result.FirstBad, result.Candidates = ParseOutput(out)
+	result.Flaky = readLines(filepath.Join(logDir, "flaky"))
+
+	if result.FirstBad == "" && len(result.Candidates) == 0 {
+		if runErr != nil {
+			return result, fmt.Errorf("bisect run failed: %w\nOutput: %s", runErr, out)
+		}
+		return result, fmt.Errorf("bisect did not identify a first bad commit")
+	}
+
+	if result.FirstBad != "" {
+		if data, err := os.ReadFile(filepath.Join(logDir, result.FirstBad+".log")); err == nil {
+			result.TestOutput = string(data)
+		}
+	}
+	return result, nil
+}
+
+// RunnerScript wraps the reproduction script so each commit is tested runs
+// times. The script is executed directly so its shebang picks the shell. Output is kept per commit; disagreeing runs mark the commit flaky
+// and skip it rather than feeding a wrong verdict to git bisect.
+func RunnerScript(scriptPath, logDir string, runs int) string {
+	return fmt.Sprintf(`#!/bin/sh
+sha=$(git rev-parse HEAD)
+log=%[2]q/$sha.log
+pass=0
+fail=0
+i=0
+while [ $i -lt %[3]d ]; do
+  %[1]q >"$log" 2>&1
+  code=$?
+  if [ $code -eq %[4]d ]; then
+    exit %[4]d
+  elif [ $code -eq 0 ]; then
+    pass=$((pass+1))
+  else
+    fail=$((fail+1))
+    cp "$log" "$log.fail"
+  fi
+  i=$((i+1))
+done
+if [ -f "$log.fail" ]; then
+  mv "$log.fail" "$log"
+fi
+if [ $pass -gt 0 ] && [ $fail -gt 0 ]; then
+  echo "$sha" >>%[2]q/flaky
+  exit %[4]d
+fi
+if [ $fail -gt 0 ]; then
+  exit %[5]d
+fi
+exit %[6]d
+`, scriptPath, logDir, runs, ExitSkip, ExitBad, ExitGood)
+}
+
+var (
+	firstBadPattern  = regexp.MustCompile(`(?m)^([0-9a-f]{7,40}) is the first bad commit`)
+	candidatePattern = regexp.MustCompile(`(?m)^([0-9a-f]{40})\b`)
+)
+
+// ParseOutput extracts the first bad commit from `git bisect run` output, or
+// the list of candidates when only skipped commits were left.
+func ParseOutput(out string) (string, []string) {
+	if m := firstBadPattern.FindStringSubmatch(out); m != nil {
+		return m[1], nil
+	}
+
+	idx := strings.Index(out, "The first bad commit could be any of:")
+	if idx < 0 {
+		return "", nil
+	}
+	var candidates []string
+	for _, m := range candidatePattern.FindAllStringSubmatch(out[idx:], -1) {
+		candidates = append(candidates, m[1])
+	}
+	return "", candidates
+}
+
+func (b *Bisector) isDirty() (bool, error) {
+	out, err := b.git(context.Background(), "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return false, fmt.Errorf("failed to check git status: %w\nOutput: %s", err, out)
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
+func (b *Bisector) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = b.opts.Dir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func readLines(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,114 @@
+package bisect
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunFindsFirstBadCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	git(t, dir, "config", "user.email", "test@example.com")
+	git(t, dir, "config", "user.name", "Test")
+
+	var shas []string
+	contents := []string{"ok 1", "ok 2", "BUG 3", "BUG 4", "BUG 5"}
+	for i, content := range contents {
+		if err := os.WriteFile(filepath.Join(dir, "state.txt"), []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git(t, dir, "add", ".")
+		git(t, dir, "commit", "-q", "-m", "commit "+string(rune('1'+i)))
+		shas = append(shas, strings.TrimSpace(git(t, dir, "rev-parse", "HEAD")))
+	}
+
+	// A bash script keeps working where sh is another shell
+	if _, err := exec.LookPath("bash"); err == nil {
+		b := NewBisector(Options{
+			Dir:    dir,
+			Good:   shas[0],
+			Bad:    shas[4],
+			Script: "#!/usr/bin/env bash\nif [[ $(cat state.txt) == BUG* ]]; then exit 1; fi\n",
+			Runs:   1,
+		})
+		result, err := b.Run(context.Background())
+		if err != nil {
+			t.Fatalf("bisect with a bash script failed: %v", err)
+		}
+		if result.FirstBad != shas[2] {
+			t.Errorf("expected the bash script to find %s, got %s", shas[2], result.FirstBad)
+		}
+	}
+
+	b := NewBisector(Options{
+		Dir:    dir,
+		Good:   shas[0],
+		Bad:    shas[4],
+		Script: CommandScript("cat state.txt; ! grep -q BUG state.txt"),
+		Runs:   2,
+	})
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("bisect failed: %v", err)
+	}
+	if result.FirstBad != shas[2] {
+		t.Errorf("expected first bad %s, got %s", shas[2], result.FirstBad)
+	}
+	if !strings.Contains(result.TestOutput, "BUG 3") {
+		t.Errorf("expected reproduction output of the culprit, got %q", result.TestOutput)
+	}
+	if len(result.Flaky) != 0 {
+		t.Errorf("expected no flaky commits, got %v", result.Flaky)
+	}
+
+	if out := git(t, dir, "rev-parse", "HEAD"); strings.TrimSpace(out) != shas[4] {
+		t.Error("expected bisect to be reset to the original HEAD")
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	first, _ := ParseOutput("Bisecting: 0 revisions left\n1234567890abcdef1234567890abcdef12345678 is the first bad commit\ncommit 1234567\n")
+	if first != "1234567890abcdef1234567890abcdef12345678" {
+		t.Errorf("unexpected first bad commit: %q", first)
+	}
+
+	out := `There are only 'skip'ped commits left to test.
+The first bad commit could be any of:
+aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa
+bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb
+We cannot bisect more!`
+	first, candidates := ParseOutput(out)
+	if first != "" || len(candidates) != 2 {
+		t.Errorf("expected 2 candidates, got first=%q candidates=%v", first, candidates)
+	}
+}
+
+func TestExtractPatch(t *testing.T) {
+	analysis := "## Suggested fix\n```diff\n--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-a\n+b\n```\n"
+	patch := ExtractPatch(analysis)
+	if !strings.HasPrefix(patch, "--- a/x.go") || !strings.HasSuffix(patch, "+b\n") {
+		t.Errorf("unexpected patch: %q", patch)
+	}
+	if ExtractPatch("no code here") != "" {
+		t.Error("expected empty patch when none is present")
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
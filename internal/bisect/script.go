@@ -0,0 +1,95 @@
+package bisect
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"gptcode/internal/langdetect"
+	"gptcode/internal/llm"
+)
+
+// CommandScript turns a test command into a reproduction script
+func CommandScript(command string) string {
+	return "#!/bin/sh\n" + command + "\n"
+}
+
+// GenerateScript asks the LLM for a reproduction script for a natural-language
+// symptom, grounded on the repository layout.
+func GenerateScript(ctx context.Context, provider llm.Provider, model, dir, symptom string) (string, error) {
+	lang := langdetect.DetectLanguage(dir)
+
+	filesCmd := exec.Command("git", "ls-files")
+	filesCmd.Dir = dir
+	files, _ := filesCmd.Output()
+
+	prompt := fmt.Sprintf(`Write a POSIX shell script that reproduces this bug in a repository:
+
+Symptom: %s
+
+Language: %s
+
+Tracked files (truncated):
+%s
+
+The script is run by "git bisect run" from the repository root at many
+historical commits. Requirements:
+- exit 0 when the bug is ABSENT
+- exit 1 when the bug REPRODUCES
+- exit 125 when the commit cannot be tested (e.g. it does not build)
+- build or run only what is needed to check the symptom; keep it fast
+- do not modify tracked files and do not use git commands
+
+Reply with only the script in a single sh code block.`, symptom, lang, headLines(string(files), 200))
+
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You are an expert at writing minimal, deterministic bug reproduction scripts.",
+		UserPrompt:   prompt,
+		Model:        model,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate reproduction script: %w", err)
+	}
+
+	script := extractCodeBlock(resp.Text, "sh", "bash", "shell")
+	if strings.TrimSpace(script) == "" {
+		return "", fmt.Errorf("LLM did not return a reproduction script")
+	}
+	if !strings.HasPrefix(script, "#!") {
+		script = "#!/bin/sh\n" + script
+	}
+	return script, nil
+}
+
+// ExtractPatch returns the first unified diff block of an analysis, if any
+func ExtractPatch(analysis string) string {
+	return extractCodeBlock(analysis, "diff", "patch")
+}
+
+var codeBlockPattern = regexp.MustCompile("(?s)```([a-zA-Z]*)\\s*\\n(.*?)```")
+
+func extractCodeBlock(text string, langs ...string) string {
+	matches := codeBlockPattern.FindAllStringSubmatch(text, -1)
+	for _, m := range matches {
+		for _, lang := range langs {
+			if strings.EqualFold(m[1], lang) {
+				return strings.TrimRight(m[2], " \n") + "\n"
+			}
+		}
+	}
+	// A lone untagged block is assumed to be the requested content
+	if len(matches) == 1 && matches[0][1] == "" {
+		return strings.TrimRight(matches[0][2], " \n") + "\n"
+	}
+	return ""
+}
+
+func headLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n... (%d more)", len(lines)-n)
+}
@@ -0,0 +1,398 @@
+// Package budget stops LLM requests that would go over a spending limit
+package budget
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"gptcode/internal/config"
+	"gptcode/internal/ledger"
+	"gptcode/internal/notifications"
+)
+
+// Scopes a limit applies to
+const (
+	ScopeTask    = "task"
+	ScopeSession = "session"
+	ScopeDaily   = "daily"
+	ScopeMonthly = "monthly"
+)
+
+// What to do when a request would go over a limit
+const (
+	ActionStop      = "stop"
+	ActionDowngrade = "downgrade"
+	ActionPause     = "pause"
+)
+
+// DefaultAlertAt is the share of a limit that triggers a warning
+const DefaultAlertAt = 0.8
+
+// Limits are spending caps in USD; zero means no limit
+type Limits struct {
+	Task    float64
+	Session float64
+	Daily   float64
+	Monthly float64
+}
+
+// ErrExceeded is wrapped by every error a guard stops a request with
+var ErrExceeded = errors.New("budget exceeded")
+
+// ExceededError says which limit a request would have gone over
+type ExceededError struct {
+	Scope    string
+	Limit    float64
+	Spent    float64
+	Estimate float64
+	Model    string
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s budget exceeded: $%.4f spent + $%.4f estimated for %s would pass the $%.2f limit",
+		e.Scope, e.Spent, e.Estimate, e.Model, e.Limit)
+}
+
+func (e *ExceededError) Unwrap() error {
+	return ErrExceeded
+}
+
+// Alert is a warning or stop raised by a guard
+type Alert struct {
+	Level   string // warning or critical
+	Scope   string
+	Spent   float64
+	Limit   float64
+	Model   string
+	Message string
+}
+
+// Guard checks each request's estimated cost against spend recorded in the
+// ledger before it is sent
+type Guard struct {
+	Limits   Limits
+	OnExceed string
+	// Downgrade maps a backend to the cheaper model to switch to
+	Downgrade map[string]string
+	AlertAt   float64
+	Ledger    *ledger.Ledger
+	Pricer    *ledger.Pricer
+	// Notify receives warnings and stops; nil drops them
+	Notify func(Alert)
+	// Confirm asks whether to go over a limit when OnExceed is pause; nil
+	// declines
+	Confirm func(*ExceededError) bool
+
+	mu       sync.Mutex
+	alerted  map[string]bool
+	approved map[string]bool
+	now      func() time.Time
+	// spend holds this month's totals so each check only reads what was
+	// appended to the ledger since the last one
+	spend *monthSpend
+}
+
+// monthSpend is one month of ledger spend, summed by scope
+type monthSpend struct {
+	month    time.Time
+	offset   int64
+	total    float64
+	days     map[string]float64
+	sessions map[string]float64
+	tasks    map[string]float64
+}
+
+func newMonthSpend(month time.Time) *monthSpend {
+	return &monthSpend{
+		month:    month,
+		days:     map[string]float64{},
+		sessions: map[string]float64{},
+		tasks:    map[string]float64{},
+	}
+}
+
+func (m *monthSpend) add(e ledger.Entry) {
+	if e.Time.Before(m.month) {
+		return
+	}
+	m.total += e.Cost
+	m.days[e.Time.In(m.month.Location()).Format("2006-01-02")] += e.Cost
+	m.sessions[e.Session] += e.Cost
+	m.tasks[e.Session+"\x00"+e.Task] += e.Cost
+}
+
+// NewGuard reads limits from the setup. The per-task and monthly limits in
+// defaults only apply in budget mode.
+func NewGuard(setup *config.Setup, l *ledger.Ledger) *Guard {
+	g := &Guard{
+		OnExceed:  setup.Budget.OnExceed,
+		Downgrade: setup.Budget.Downgrade,
+		AlertAt:   setup.Budget.AlertAt,
+		Ledger:    l,
+		Limits: Limits{
+			Session: setup.Budget.Session,
+			Daily:   setup.Budget.Daily,
+		},
+	}
+	if setup.Defaults.BudgetMode {
+		g.Limits.Task = setup.Defaults.MaxCostPerTask
+		g.Limits.Monthly = setup.Defaults.MonthlyBudget
+	}
+	return g
+}
+
+// Enabled reports whether any limit is set
+func (g *Guard) Enabled() bool {
+	return g != nil && (g.Limits.Task > 0 || g.Limits.Session > 0 || g.Limits.Daily > 0 || g.Limits.Monthly > 0)
+}
+
+type scope struct {
+	name  string
+	key   string
+	limit float64
+	spent float64
+}
+
+// Check estimates a request and returns the model to send it to, which is
+// a cheaper one when the guard downgrades. It returns an *ExceededError
+// when the request must not be sent.
+func (g *Guard) Check(ctx context.Context, backend, model string, promptTokens, completionTokens int) (string, error) {
+	if !g.Enabled() {
+		return model, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	scopes, err := g.scopes(ctx)
+	if err != nil {
+		// An unreadable ledger shouldn't take the tool down with it
+		if os.Getenv("GPTCODE_DEBUG") == "1" {
+			fmt.Fprintf(os.Stderr, "[BUDGET] %v\n", err)
+		}
+		return model, nil
+	}
+
+	estimate := g.estimate(backend, model, promptTokens, completionTokens)
+	g.warn(scopes, estimate, model)
+	exceeded := over(scopes, estimate, model)
+	if exceeded == nil {
+		return model, nil
+	}
+
+	switch g.OnExceed {
+	case ActionDowngrade:
+		if cheaper := g.Downgrade[backend]; cheaper != "" && cheaper != model {
+			if over(scopes, g.estimate(backend, cheaper, promptTokens, completionTokens), cheaper) == nil {
+				g.notify(Alert{
+					Level:   "warning",
+					Scope:   exceeded.Scope,
+					Spent:   exceeded.Spent,
+					Limit:   exceeded.Limit,
+					Model:   cheaper,
+					Message: fmt.Sprintf("%s budget nearly spent ($%.2f/$%.2f), switching from %s to %s", exceeded.Scope, exceeded.Spent, exceeded.Limit, model, cheaper),
+				})
+				return cheaper, nil
+			}
+		}
+	case ActionPause:
+		key := scopeKey(scopes, exceeded.Scope)
+		if g.approved[key] {
+			return model, nil
+		}
+		if g.Confirm != nil && g.Confirm(exceeded) {
+			if g.approved == nil {
+				g.approved = map[string]bool{}
+			}
+			g.approved[key] = true
+			return model, nil
+		}
+	}
+
+	g.notify(Alert{
+		Level:   "critical",
+		Scope:   exceeded.Scope,
+		Spent:   exceeded.Spent,
+		Limit:   exceeded.Limit,
+		Model:   model,
+		Message: exceeded.Error(),
+	})
+	return "", exceeded
+}
+
+// scopes sums recorded spend for every limit that is set
+func (g *Guard) scopes(ctx context.Context) ([]scope, error) {
+	now := time.Now()
+	if g.now != nil {
+		now = g.now()
+	}
+	session, task := ledger.Scope(ctx)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	scopes := []scope{
+		{name: ScopeTask, key: "task:" + session + "\x00" + task, limit: g.Limits.Task},
+		{name: ScopeSession, key: "session:" + session, limit: g.Limits.Session},
+		{name: ScopeDaily, key: "daily:" + day.Format("2006-01-02"), limit: g.Limits.Daily},
+		{name: ScopeMonthly, key: "monthly:" + month.Format("2006-01"), limit: g.Limits.Monthly},
+	}
+	if g.Ledger == nil {
+		return scopes, nil
+	}
+
+	if g.spend == nil || !g.spend.month.Equal(month) {
+		g.spend = newMonthSpend(month)
+	}
+	entries, next, err := g.Ledger.Tail(g.spend.offset)
+	if err != nil {
+		return nil, err
+	}
+	if next < g.spend.offset {
+		// The ledger was replaced, so the totals start over
+		g.spend = newMonthSpend(month)
+	}
+	g.spend.offset = next
+	for _, e := range entries {
+		g.spend.add(e)
+	}
+
+	scopes[0].spent = g.spend.tasks[session+"\x00"+task]
+	scopes[1].spent = g.spend.sessions[session]
+	scopes[2].spent = g.spend.days[day.Format("2006-01-02")]
+	scopes[3].spent = g.spend.total
+	return scopes, nil
+}
+
+func (g *Guard) estimate(backend, model string, promptTokens, completionTokens int) float64 {
+	pricer := g.Pricer
+	if pricer == nil {
+		pricer = ledger.DefaultPricer()
+	}
+	cost, _ := pricer.Cost(backend, model, promptTokens, completionTokens, 0)
+	return cost
+}
+
+// warn raises one warning per scope once spend crosses the alert share
+func (g *Guard) warn(scopes []scope, estimate float64, model string) {
+	share := g.AlertAt
+	if share <= 0 || share >= 1 {
+		share = DefaultAlertAt
+	}
+	for _, s := range scopes {
+		if s.limit <= 0 || s.spent+estimate < share*s.limit || g.alerted[s.key] {
+			continue
+		}
+		if g.alerted == nil {
+			g.alerted = map[string]bool{}
+		}
+		g.alerted[s.key] = true
+		g.notify(Alert{
+			Level:   "warning",
+			Scope:   s.name,
+			Spent:   s.spent,
+			Limit:   s.limit,
+			Model:   model,
+			Message: fmt.Sprintf("%s budget %.0f%% used ($%.2f/$%.2f)", s.name, 100*s.spent/s.limit, s.spent, s.limit),
+		})
+	}
+}
+
+func (g *Guard) notify(a Alert) {
+	if g.Notify != nil {
+		g.Notify(a)
+	}
+}
+
+// over returns the first limit the request would pass
+func over(scopes []scope, estimate float64, model string) *ExceededError {
+	for _, s := range scopes {
+		if s.limit > 0 && s.spent+estimate > s.limit {
+			return &ExceededError{Scope: s.name, Limit: s.limit, Spent: s.spent, Estimate: estimate, Model: model}
+		}
+	}
+	return nil
+}
+
+func scopeKey(scopes []scope, name string) string {
+	for _, s := range scopes {
+		if s.name == name {
+			return s.key
+		}
+	}
+	return name
+}
+
+var (
+	defaultGuard     *Guard
+	defaultGuardOnce sync.Once
+)
+
+// Default returns the guard for the user's setup, or nil when no limit is
+// set
+func Default() *Guard {
+	defaultGuardOnce.Do(func() {
+		setup, err := config.LoadSetup()
+		if err != nil {
+			return
+		}
+		g := NewGuard(setup, ledger.Default())
+		if !g.Enabled() {
+			return
+		}
+		g.Notify = cliNotifier(setup)
+		g.Confirm = confirmOnTerminal
+		defaultGuard = g
+	})
+	return defaultGuard
+}
+
+// Preflight checks a request with the default guard
+func Preflight(ctx context.Context, backend, model string, promptTokens, completionTokens int) (string, error) {
+	return Default().Check(ctx, backend, model, promptTokens, completionTokens)
+}
+
+// cliNotifier prints alerts and forwards them to the notification channels
+// configured under notifications.budget
+func cliNotifier(setup *config.Setup) func(Alert) {
+	var manager *notifications.Manager
+	return func(a Alert) {
+		icon := "⚠️ "
+		if a.Level == "critical" {
+			icon = "🛑"
+		}
+		fmt.Fprintf(os.Stderr, "\n%s Budget: %s\n", icon, a.Message)
+
+		if !setup.Notifications.Budget.Enabled {
+			return
+		}
+		if manager == nil {
+			manager = notifications.NewManager(setup)
+		}
+		_ = manager.SendBudgetAlert(context.Background(), notifications.BudgetNotification{
+			Level:   a.Level,
+			Scope:   a.Scope,
+			Spent:   a.Spent,
+			Limit:   a.Limit,
+			Model:   a.Model,
+			Message: a.Message,
+		})
+	}
+}
+
+// confirmOnTerminal asks before going over a limit; without a terminal to
+// ask on, the request is stopped
+func confirmOnTerminal(e *ExceededError) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "\n⏸️  Paused: %s\n   Continue anyway? [y/N]: ", e.Error())
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y")
+}
@@ -0,0 +1,170 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gptcode/internal/config"
+	"gptcode/internal/ledger"
+)
+
+// newTestGuard prices every "work" model at $1 per million tokens except
+// "cheap", and seeds the ledger with spend
+func newTestGuard(t *testing.T, limits Limits, spent ...ledger.Entry) (*Guard, *[]Alert) {
+	t.Helper()
+	setup := &config.Setup{Backend: map[string]config.BackendConfig{
+		"work": {Pricing: map[string]config.ModelPricing{
+			"*":     {Prompt: 1, Completion: 1},
+			"cheap": {Prompt: 0.01, Completion: 0.01},
+		}},
+	}}
+	l := ledger.Open(filepath.Join(t.TempDir(), "usage.jsonl"))
+	for _, e := range spent {
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var alerts []Alert
+	g := &Guard{
+		Limits:    limits,
+		Downgrade: map[string]string{"work": "cheap"},
+		Ledger:    l,
+		Pricer:    ledger.NewPricer(setup, nil),
+		Notify:    func(a Alert) { alerts = append(alerts, a) },
+		now:       func() time.Time { return time.Date(2025, 3, 15, 12, 0, 0, 0, time.Local) },
+	}
+	return g, &alerts
+}
+
+func TestGuardStopsOverLimit(t *testing.T) {
+	ctx := ledger.WithSession(context.Background(), "s1")
+	g, alerts := newTestGuard(t, Limits{Daily: 1},
+		ledger.Entry{Time: time.Date(2025, 3, 15, 9, 0, 0, 0, time.Local), Session: "old", Cost: 0.9},
+		ledger.Entry{Time: time.Date(2025, 3, 14, 9, 0, 0, 0, time.Local), Session: "old", Cost: 5},
+	)
+
+	model, err := g.Check(ctx, "work", "big", 50_000, 0)
+	if err != nil || model != "big" {
+		t.Fatalf("expected a request within the limit to pass, got %q, %v", model, err)
+	}
+	if len(*alerts) != 1 || (*alerts)[0].Level != "warning" || (*alerts)[0].Scope != ScopeDaily {
+		t.Errorf("expected a daily warning past 80%%, got %+v", *alerts)
+	}
+
+	_, err = g.Check(ctx, "work", "big", 200_000, 0)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || !errors.Is(err, ErrExceeded) {
+		t.Fatalf("expected an exceeded error, got %v", err)
+	}
+	if exceeded.Scope != ScopeDaily || exceeded.Spent != 0.9 || exceeded.Estimate != 0.2 {
+		t.Errorf("unexpected breach: %+v", exceeded)
+	}
+	if last := (*alerts)[len(*alerts)-1]; last.Level != "critical" {
+		t.Errorf("expected a critical alert on stop, got %+v", last)
+	}
+}
+
+func TestGuardScopesTaskAndSession(t *testing.T) {
+	ctx := ledger.WithTask(ledger.WithSession(context.Background(), "s1"), "fix bug")
+	now := time.Date(2025, 3, 15, 10, 0, 0, 0, time.Local)
+	g, _ := newTestGuard(t, Limits{Task: 0.5, Session: 2.2},
+		ledger.Entry{Time: now, Session: "s1", Task: "fix bug", Cost: 0.45},
+		ledger.Entry{Time: now, Session: "s1", Task: "other", Cost: 1.5},
+		ledger.Entry{Time: now, Session: "s2", Task: "fix bug", Cost: 10},
+	)
+
+	_, err := g.Check(ctx, "work", "big", 100_000, 0)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Scope != ScopeTask {
+		t.Fatalf("expected the task limit to stop the request, got %v", err)
+	}
+
+	other := ledger.WithTask(ctx, "new task")
+	if _, err := g.Check(other, "work", "big", 100_000, 0); err != nil {
+		t.Errorf("expected a new task in the session to have room, got %v", err)
+	}
+	if _, err := g.Check(other, "work", "big", 400_000, 0); !errors.As(err, &exceeded) || exceeded.Scope != ScopeSession {
+		t.Errorf("expected the session limit to stop the request, got %v", err)
+	}
+}
+
+func TestGuardCountsSpendRecordedBetweenChecks(t *testing.T) {
+	ctx := ledger.WithSession(context.Background(), "s1")
+	now := time.Date(2025, 3, 15, 10, 0, 0, 0, time.Local)
+	g, _ := newTestGuard(t, Limits{Monthly: 1},
+		ledger.Entry{Time: now.AddDate(0, -1, 0), Session: "old", Cost: 5},
+		ledger.Entry{Time: now, Session: "s1", Cost: 0.5},
+	)
+
+	if _, err := g.Check(ctx, "work", "big", 100_000, 0); err != nil {
+		t.Fatalf("expected room under the monthly limit, got %v", err)
+	}
+	if err := g.Ledger.Append(ledger.Entry{Time: now, Session: "s1", Cost: 0.4}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := g.Check(ctx, "work", "big", 200_000, 0)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Scope != ScopeMonthly || exceeded.Spent != 0.9 {
+		t.Fatalf("expected the new entry to count toward the month, got %v", err)
+	}
+
+	// A replaced ledger starts the totals over
+	if err := os.Remove(g.Ledger.Path()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Check(ctx, "work", "big", 200_000, 0); err != nil {
+		t.Errorf("expected an emptied ledger to clear the spend, got %v", err)
+	}
+}
+
+func TestGuardDowngradesAndPauses(t *testing.T) {
+	ctx := context.Background()
+	g, _ := newTestGuard(t, Limits{Monthly: 1},
+		ledger.Entry{Time: time.Date(2025, 3, 2, 0, 0, 0, 0, time.Local), Cost: 0.95},
+	)
+
+	g.OnExceed = ActionDowngrade
+	model, err := g.Check(ctx, "work", "big", 100_000, 0)
+	if err != nil || model != "cheap" {
+		t.Errorf("expected a downgrade to the cheap model, got %q, %v", model, err)
+	}
+	if _, err := g.Check(ctx, "other", "big", 100_000, 0); !errors.Is(err, ErrExceeded) {
+		t.Errorf("expected a stop without a model to downgrade to, got %v", err)
+	}
+
+	g.OnExceed = ActionPause
+	asked := 0
+	g.Confirm = func(*ExceededError) bool { asked++; return true }
+	for i := 0; i < 2; i++ {
+		if model, err := g.Check(ctx, "work", "big", 100_000, 0); err != nil || model != "big" {
+			t.Errorf("expected the confirmed request to go ahead, got %q, %v", model, err)
+		}
+	}
+	if asked != 1 {
+		t.Errorf("expected to ask once per limit, asked %d times", asked)
+	}
+}
+
+func TestNewGuardReadsSetup(t *testing.T) {
+	setup := &config.Setup{}
+	setup.Defaults.MaxCostPerTask = 0.5
+	setup.Defaults.MonthlyBudget = 20
+	setup.Budget.Daily = 3
+
+	g := NewGuard(setup, nil)
+	if g.Limits.Task != 0 || g.Limits.Monthly != 0 || g.Limits.Daily != 3 {
+		t.Errorf("expected defaults limits to need budget mode, got %+v", g.Limits)
+	}
+	setup.Defaults.BudgetMode = true
+	if g := NewGuard(setup, nil); g.Limits.Task != 0.5 || g.Limits.Monthly != 20 {
+		t.Errorf("expected budget mode limits, got %+v", g.Limits)
+	}
+	if (&Guard{}).Enabled() {
+		t.Error("a guard without limits should be disabled")
+	}
+}
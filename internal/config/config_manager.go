@@ -23,7 +23,7 @@ func GetConfig(key string) (string, error) {
 }
 
 func SetConfig(key, value string) error {
-	setup, err := LoadSetup()
+	setup, err := LoadUserSetup()
 	if err != nil {
 		return err
 	}
@@ -101,6 +101,23 @@ func getNestedValue(setup *Setup, key string) (interface{}, error) {
 			return nil, fmt.Errorf("unknown backend field: %s", parts[2])
 		}
 
+	case "budget":
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("budget key requires subfield (e.g., budget.daily)")
+		}
+		switch parts[1] {
+		case "daily":
+			return setup.Budget.Daily, nil
+		case "session":
+			return setup.Budget.Session, nil
+		case "on_exceed":
+			return setup.Budget.OnExceed, nil
+		case "alert_at":
+			return setup.Budget.AlertAt, nil
+		default:
+			return nil, fmt.Errorf("unknown budget field: %s", parts[1])
+		}
+
 	default:
 		return nil, fmt.Errorf("unknown config section: %s", parts[0])
 	}
@@ -210,6 +227,36 @@ func setNestedValue(setup *Setup, key, value string) error {
 
 		setup.Backend[backendName] = backend
 
+	case "budget":
+		if len(parts) < 2 {
+			return fmt.Errorf("budget key requires subfield (e.g., budget.daily)")
+		}
+		switch parts[1] {
+		case "daily", "session", "alert_at":
+			var f float64
+			if _, err := fmt.Sscan(value, &f); err != nil {
+				return fmt.Errorf("invalid float value for %s: %s", parts[1], value)
+			}
+			if f < 0 {
+				return fmt.Errorf("%s must be non-negative", parts[1])
+			}
+			switch parts[1] {
+			case "daily":
+				setup.Budget.Daily = f
+			case "session":
+				setup.Budget.Session = f
+			case "alert_at":
+				setup.Budget.AlertAt = f
+			}
+		case "on_exceed":
+			if value != "stop" && value != "downgrade" && value != "pause" {
+				return fmt.Errorf("on_exceed must be 'stop', 'downgrade' or 'pause'")
+			}
+			setup.Budget.OnExceed = value
+		default:
+			return fmt.Errorf("unknown budget field: %s", parts[1])
+		}
+
 	default:
 		return fmt.Errorf("unknown config section: %s", parts[0])
 	}
@@ -218,7 +265,7 @@ func setNestedValue(setup *Setup, key, value string) error {
 }
 
 func CreateBackend(name, backendType, baseURL string) error {
-	setup, err := LoadSetup()
+	setup, err := LoadUserSetup()
 	if err != nil {
 		return err
 	}
@@ -237,7 +284,7 @@ func CreateBackend(name, backendType, baseURL string) error {
 }
 
 func DeleteBackend(name string) error {
-	setup, err := LoadSetup()
+	setup, err := LoadUserSetup()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"gptcode/internal/secrets"
+)
+
+// legacyKeysFile is where keys used to be saved in plaintext
+func legacyKeysFile() string {
+	return filepath.Join(configDir(), "keys.yaml")
+}
+
+// lookupAPIKey tries each name in the keys section of setup.yaml, then in
+// the secret store, then in the legacy keys.yaml
+func lookupAPIKey(names ...string) string {
+	debug := os.Getenv("GPTCODE_DEBUG") == "1"
+
+	if setup, err := LoadUserSetup(); err == nil {
+		for _, name := range names {
+			ref, ok := setup.Keys[name]
+			if !ok || ref == "" {
+				continue
+			}
+			value, err := secrets.Resolve(ref)
+			if err == nil {
+				return value
+			}
+			if debug {
+				fmt.Fprintf(os.Stderr, "[KEYS] keys.%s: %v\n", name, err)
+			}
+		}
+	}
+
+	for _, name := range names {
+		value, err := secrets.Lookup(name)
+		if err == nil {
+			return value
+		}
+		if debug && !errors.Is(err, secrets.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "[KEYS] %v\n", err)
+		}
+	}
+
+	keys, _ := readLegacyKeys()
+	for _, name := range names {
+		if value := keys[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func readLegacyKeys() (map[string]string, error) {
+	data, err := os.ReadFile(legacyKeysFile())
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]string{}
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", legacyKeysFile(), err)
+	}
+	return keys, nil
+}
+
+// saveAPIKey stores a key in the secret store and says where it went
+func saveAPIKey(name, apiKey string) (string, error) {
+	store := secrets.Default()
+	if err := store.Set(name, apiKey); err != nil {
+		return "", err
+	}
+	return store.Name(), nil
+}
+
+// KeyMigration is one plaintext key moved into the secret store
+type KeyMigration struct {
+	Name string
+	From string
+}
+
+// profileExport matches the lines gptcode used to append to shell profiles
+var profileExport = regexp.MustCompile(`^export ([A-Z0-9_]+_API_KEY)=(".*")$`)
+
+const profileMarker = "# GPTCode API key"
+
+// MigrateAPIKeys moves plaintext keys from ~/.gptcode/keys.yaml and from
+// the exports gptcode added to shell profiles into a secret store, then
+// removes the plaintext copies. With dryRun nothing is changed.
+func MigrateAPIKeys(store secrets.Store, dryRun bool) ([]KeyMigration, error) {
+	var migrated []KeyMigration
+
+	keys, err := readLegacyKeys()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if keys[name] == "" {
+			continue
+		}
+		if !dryRun {
+			if err := store.Set(name, keys[name]); err != nil {
+				return migrated, fmt.Errorf("failed to migrate %s: %w", name, err)
+			}
+		}
+		migrated = append(migrated, KeyMigration{Name: name, From: legacyKeysFile()})
+	}
+	if len(keys) > 0 && !dryRun {
+		if err := os.Remove(legacyKeysFile()); err != nil {
+			return migrated, fmt.Errorf("failed to remove %s: %w", legacyKeysFile(), err)
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return migrated, nil
+	}
+	for _, profile := range []string{".zshrc", ".bashrc", ".bash_profile"} {
+		moved, err := migrateProfile(filepath.Join(home, profile), store, dryRun)
+		migrated = append(migrated, moved...)
+		if err != nil {
+			return migrated, err
+		}
+	}
+	return migrated, nil
+}
+
+// migrateProfile moves the exports under the "# GPTCode API key" marker,
+// leaving the rest of the profile alone
+func migrateProfile(path string, store secrets.Store, dryRun bool) ([]KeyMigration, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var migrated []KeyMigration
+	var kept []string
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == profileMarker && i+1 < len(lines) {
+			if m := profileExport.FindStringSubmatch(strings.TrimSpace(lines[i+1])); m != nil {
+				value, err := strconv.Unquote(m[2])
+				if err == nil && value != "" {
+					if !dryRun {
+						if err := store.Set(m[1], value); err != nil {
+							return migrated, fmt.Errorf("failed to migrate %s: %w", m[1], err)
+						}
+					}
+					migrated = append(migrated, KeyMigration{Name: m[1], From: path})
+					// Drop the blank line written before the marker too
+					if n := len(kept); n > 0 && kept[n-1] == "" {
+						kept = kept[:n-1]
+					}
+					i++
+					continue
+				}
+			}
+		}
+		kept = append(kept, lines[i])
+	}
+	if len(migrated) == 0 || dryRun {
+		return migrated, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return migrated, err
+	}
+	out := strings.Join(kept, "\n")
+	if strings.HasSuffix(string(data), "\n") {
+		out += "\n"
+	}
+	if err := os.WriteFile(path, []byte(out), info.Mode().Perm()); err != nil {
+		return migrated, fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	return migrated, nil
+}
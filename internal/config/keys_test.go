@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/secrets"
+)
+
+func TestMigrateAPIKeys(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTestFile(t, filepath.Join(home, ".gptcode", "keys.yaml"), "openrouter: sk-or-1\nTAVILY_API_KEY: tv-1\n")
+	bashrc := "alias ll='ls -l'\nexport MY_API_KEY=\"mine\"\n\n# GPTCode API key\nexport GROQ_API_KEY=\"gsk-1\"\n"
+	writeTestFile(t, filepath.Join(home, ".bashrc"), bashrc)
+
+	store := secrets.NewFileStore(filepath.Join(home, ".gptcode", "secrets.enc"))
+	store.Passphrase = func(bool) (string, error) { return "test", nil }
+
+	planned, err := MigrateAPIKeys(store, true)
+	if err != nil || len(planned) != 3 {
+		t.Fatalf("expected 3 keys to move, got %+v, %v", planned, err)
+	}
+	if store.Exists() {
+		t.Error("a dry run shouldn't write the store")
+	}
+
+	migrated, err := MigrateAPIKeys(store, false)
+	if err != nil || len(migrated) != 3 {
+		t.Fatalf("expected 3 keys moved, got %+v, %v", migrated, err)
+	}
+	for name, want := range map[string]string{"openrouter": "sk-or-1", "TAVILY_API_KEY": "tv-1", "GROQ_API_KEY": "gsk-1"} {
+		if got, err := store.Get(name); err != nil || got != want {
+			t.Errorf("expected %s = %q in the store, got %q, %v", name, want, got, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(home, ".gptcode", "keys.yaml")); !os.IsNotExist(err) {
+		t.Error("expected keys.yaml to be removed")
+	}
+	data, _ := os.ReadFile(filepath.Join(home, ".bashrc"))
+	if string(data) != "alias ll='ls -l'\nexport MY_API_KEY=\"mine\"\n" {
+		t.Errorf("expected only the gptcode export removed, got %q", data)
+	}
+
+	if again, err := MigrateAPIKeys(store, false); err != nil || len(again) != 0 {
+		t.Errorf("expected nothing left to migrate, got %+v, %v", again, err)
+	}
+}
+
+func TestGetAPIKeyResolvesReferences(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(secrets.StoreEnv, "keyring")
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+	t.Setenv("MY_GROQ_KEY", "gsk-env")
+	t.Setenv("OPENAI_API_KEY", "sk-env")
+	writeTestFile(t, filepath.Join(home, ".gptcode", "setup.yaml"), `keys:
+    groq: env:MY_GROQ_KEY
+    openrouter: "cmd: echo sk-or-cmd"
+`)
+	writeTestFile(t, filepath.Join(home, ".gptcode", "keys.yaml"), "openrouter: sk-or-legacy\nanthropic: sk-ant-legacy\n")
+
+	tests := map[string]string{
+		"groq":       "gsk-env",
+		"openrouter": "sk-or-cmd",
+		"anthropic":  "sk-ant-legacy",
+		"openai":     "sk-env",
+	}
+	for backend, want := range tests {
+		if got := GetAPIKey(backend); got != want {
+			t.Errorf("GetAPIKey(%q) = %q, want %q", backend, got, want)
+		}
+	}
+	if got := LoadAPIKey("ANTHROPIC_API_KEY"); !strings.HasPrefix(got, "sk-ant") {
+		t.Errorf("expected LoadAPIKey to find the key without its suffix, got %q", got)
+	}
+}
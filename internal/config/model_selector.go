@@ -426,6 +426,9 @@ func (ms *ModelSelector) SelectModel(action ActionType, language string, complex
 		}
 
 		for _, modelInfo := range models {
+			if !ms.allows(action, backend, modelInfo.ID) {
+				continue
+			}
 			score := ms.scoreModel(modelInfo, action, language, complexity)
 			if score > 0 {
 				// Boost score for default backend to prioritize it
@@ -505,6 +508,9 @@ func (ms *ModelSelector) trySelectApprovedModel(modelID string, action ActionTyp
 						return "", "", fmt.Errorf("model %s does not support tools", modelID)
 					}
 				}
+				if !ms.allows(action, backend, modelID) {
+					continue
+				}
 				return backend, modelID, nil
 			}
 		}
@@ -513,6 +519,25 @@ func (ms *ModelSelector) trySelectApprovedModel(modelID string, action ActionTyp
 	return "", "", fmt.Errorf("model %s not found in catalog", modelID)
 }
 
+// actionAgents maps selector actions to the agents a project config can
+// restrict models for
+var actionAgents = map[ActionType]string{
+	ActionEdit:     "editor",
+	ActionReview:   "reviewer",
+	ActionPlan:     "planner",
+	ActionResearch: "research",
+	ActionRoute:    "router",
+}
+
+// allows reports whether the project config lets the action's agent use a
+// model
+func (ms *ModelSelector) allows(action ActionType, backend, model string) bool {
+	if ms.setup == nil {
+		return true
+	}
+	return ms.setup.AllowsModel(actionAgents[action], backend, model)
+}
+
 // logBlockedNotification logs when all models fail
 func (ms *ModelSelector) logBlockedNotification(action, language string) {
 	if ms.setup == nil || !ms.setup.IsBlockedNotificationEnabled() {
@@ -35,9 +35,66 @@ type Setup struct {
 		Notify         bool   `yaml:"notify,omitempty"`
 		Parallel       int    `yaml:"parallel,omitempty"`
 	} `yaml:"e2e,omitempty"`
-	Backend        map[string]BackendConfig `yaml:"backend"`
-	ApprovedModels []ApprovedModel          `yaml:"approved_models,omitempty"`
-	Notifications  NotificationConfig       `yaml:"notifications,omitempty"`
+	Backend        map[string]BackendConfig  `yaml:"backend"`
+	ApprovedModels []ApprovedModel           `yaml:"approved_models,omitempty"`
+	Notifications  NotificationConfig        `yaml:"notifications,omitempty"`
+	Budget         BudgetConfig              `yaml:"budget,omitempty"`
+	Agents         map[string]AgentAllowList `yaml:"agents,omitempty"`
+	Commands       Commands                  `yaml:"commands,omitempty"`
+	Policy         Policy                    `yaml:"policy,omitempty"`
+	Skills         []string                  `yaml:"skills,omitempty"`
+	// Keys maps backends and search providers to API key references such
+	// as "env:OPENROUTER_KEY" or "cmd: pass show openrouter"
+	Keys map[string]string `yaml:"keys,omitempty"`
+	// Redaction controls how secrets are masked before content leaves the
+	// machine
+	Redaction RedactionConfig `yaml:"redaction,omitempty"`
+	// Tracing exports OpenTelemetry spans of agent runs to a collector
+	Tracing TracingConfig `yaml:"tracing,omitempty"`
+
+	// ProjectRoot is the repository whose .gptcode/config.yaml was merged in
+	ProjectRoot string `yaml:"-"`
+	// Origins maps dotted keys to where their values came from
+	Origins map[string]string `yaml:"-"`
+}
+
+// BudgetConfig sets spending limits on top of defaults.max_cost_per_task
+// and defaults.monthly_budget. Limits are in USD; zero means no limit.
+type BudgetConfig struct {
+	Daily   float64 `yaml:"daily,omitempty"`
+	Session float64 `yaml:"session,omitempty"`
+	// OnExceed is what happens when a request would go over a limit:
+	// stop (default), downgrade to a cheaper model, or pause and ask
+	OnExceed string `yaml:"on_exceed,omitempty"`
+	// Downgrade maps a backend to the cheaper model to switch to
+	Downgrade map[string]string `yaml:"downgrade,omitempty"`
+	// AlertAt is the share of a limit that triggers a warning (default 0.8)
+	AlertAt float64 `yaml:"alert_at,omitempty"`
+}
+
+// RedactionConfig tunes secret masking. Masking is on unless disabled.
+type RedactionConfig struct {
+	Disabled bool `yaml:"disabled,omitempty"`
+	// MinEntropy is the bits per character above which a long token is
+	// masked (default 4.0); a negative value turns entropy detection off
+	MinEntropy float64 `yaml:"min_entropy,omitempty"`
+	// Patterns are extra regular expressions to mask; when a pattern has a
+	// group, only the first group is masked
+	Patterns []string `yaml:"patterns,omitempty"`
+	// Allow lists values that are never masked
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// TracingConfig sends traces to an OTLP/HTTP collector. Tracing is off
+// unless an endpoint is set here or in OTEL_EXPORTER_OTLP_ENDPOINT.
+type TracingConfig struct {
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Endpoint is the collector's base URL, e.g. http://localhost:4318;
+	// spans are posted to /v1/traces unless the URL has a path
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// Headers are sent with every export. Values may be key references
+	// such as "env:HONEYCOMB_API_KEY".
+	Headers map[string]string `yaml:"headers,omitempty"`
 }
 
 type ApprovedModel struct {
@@ -48,6 +105,12 @@ type ApprovedModel struct {
 
 type NotificationConfig struct {
 	Blocked BlockedNotificationConfig `yaml:"blocked"`
+	Budget  BudgetNotificationConfig  `yaml:"budget,omitempty"`
+}
+
+type BudgetNotificationConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Channels []string `yaml:"channels"` // telegram, live
 }
 
 type BlockedNotificationConfig struct {
@@ -63,6 +126,16 @@ type BackendConfig struct {
 	Models       map[string]string        `yaml:"models"`
 	AgentModels  AgentModels              `yaml:"agent_models,omitempty"`
 	Profiles     map[string]ProfileConfig `yaml:"profiles,omitempty"`
+	// Pricing overrides catalog prices per model; "*" applies to every
+	// model of the backend
+	Pricing map[string]ModelPricing `yaml:"pricing,omitempty"`
+}
+
+// ModelPricing is a price in USD per million tokens
+type ModelPricing struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+	Cached     float64 `yaml:"cached,omitempty"`
 }
 
 type ProfileConfig struct {
@@ -0,0 +1,408 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is where a repository keeps its committed settings,
+// relative to the repository root
+const ProjectConfigFile = ".gptcode/config.yaml"
+
+// KnownAgents are the agents a project can restrict models for
+var KnownAgents = []string{"router", "query", "editor", "research", "planner", "reviewer"}
+
+// ProjectConfig is the schema of .gptcode/config.yaml. Unknown keys are
+// rejected so typos don't silently do nothing.
+type ProjectConfig struct {
+	Agents   map[string]AgentAllowList `yaml:"agents,omitempty"`
+	Commands Commands                  `yaml:"commands,omitempty"`
+	Policy   Policy                    `yaml:"policy,omitempty"`
+	Skills   []string                  `yaml:"skills,omitempty"`
+	Budget   ProjectBudget             `yaml:"budget,omitempty"`
+}
+
+// AgentAllowList restricts the backends and models an agent may use.
+// Models may be globs ("openai/*"); empty lists allow anything.
+type AgentAllowList struct {
+	Backends []string `yaml:"backends,omitempty"`
+	Models   []string `yaml:"models,omitempty"`
+}
+
+// Commands replace the test, lint and build commands maestro detects from
+// the project's language
+type Commands struct {
+	Test  string `yaml:"test,omitempty"`
+	Lint  string `yaml:"lint,omitempty"`
+	Build string `yaml:"build,omitempty"`
+}
+
+// Policy limits what agents may do to a repository
+type Policy struct {
+	// ProtectedPaths are files agents must not write. A pattern without a
+	// slash matches file names anywhere; one ending in / matches a
+	// directory and everything under it.
+	ProtectedPaths []string `yaml:"protected_paths,omitempty"`
+}
+
+// ProjectBudget caps spend for work in the repository. Caps only tighten
+// the user's limits, never loosen them.
+type ProjectBudget struct {
+	MaxCostPerTask float64 `yaml:"max_cost_per_task,omitempty"`
+	Monthly        float64 `yaml:"monthly,omitempty"`
+	Daily          float64 `yaml:"daily,omitempty"`
+	Session        float64 `yaml:"session,omitempty"`
+	OnExceed       string  `yaml:"on_exceed,omitempty"`
+}
+
+// Where a setting came from
+const (
+	OriginDefault = "default"
+	OriginUser    = "user"
+	OriginProject = "project"
+)
+
+// FindProjectConfig walks up from dir to the repository root looking for
+// .gptcode/config.yaml. The home directory is never searched, since
+// ~/.gptcode holds the user's own setup.
+func FindProjectConfig(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	home, _ := os.UserHomeDir()
+	for {
+		if dir != home {
+			path := filepath.Join(dir, ProjectConfigFile)
+			if _, err := os.Stat(path); err == nil {
+				return path, true
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig reads and validates a project config file
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pc ProjectConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&pc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if err := pc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &pc, nil
+}
+
+// Validate checks values the YAML schema can't express
+func (pc *ProjectConfig) Validate() error {
+	var errs []error
+	for agent, allow := range pc.Agents {
+		if !slices.Contains(KnownAgents, agent) {
+			errs = append(errs, fmt.Errorf("agents.%s: unknown agent (expected one of %s)", agent, strings.Join(KnownAgents, ", ")))
+		}
+		for _, m := range allow.Models {
+			if _, err := filepath.Match(m, ""); err != nil {
+				errs = append(errs, fmt.Errorf("agents.%s.models: bad pattern %q", agent, m))
+			}
+		}
+	}
+	for _, p := range pc.Policy.ProtectedPaths {
+		if _, err := filepath.Match(strings.TrimSuffix(p, "/"), ""); err != nil || p == "" {
+			errs = append(errs, fmt.Errorf("policy.protected_paths: bad pattern %q", p))
+		}
+	}
+	for _, s := range pc.Skills {
+		if strings.TrimSpace(s) == "" {
+			errs = append(errs, errors.New("skills: empty skill name"))
+		}
+	}
+	b := pc.Budget
+	for name, v := range map[string]float64{"max_cost_per_task": b.MaxCostPerTask, "monthly": b.Monthly, "daily": b.Daily, "session": b.Session} {
+		if v < 0 {
+			errs = append(errs, fmt.Errorf("budget.%s: must be non-negative", name))
+		}
+	}
+	if b.OnExceed != "" && b.OnExceed != "stop" && b.OnExceed != "downgrade" && b.OnExceed != "pause" {
+		errs = append(errs, fmt.Errorf("budget.on_exceed: must be stop, downgrade or pause, got %q", b.OnExceed))
+	}
+	return errors.Join(errs...)
+}
+
+// applyProject merges a project config into the user's setup:
+//   - commands and agent allow-lists from the project replace the user's
+//   - skills and protected paths are combined
+//   - budget caps take the stricter of the two; on_exceed comes from the
+//     project
+func (s *Setup) applyProject(pc *ProjectConfig, path string) {
+	root := filepath.Dir(filepath.Dir(path))
+	s.ProjectRoot = root
+	from := OriginProject
+
+	for agent, allow := range pc.Agents {
+		if s.Agents == nil {
+			s.Agents = map[string]AgentAllowList{}
+		}
+		s.Agents[agent] = allow
+		s.setOrigin("agents."+agent, from)
+	}
+
+	if pc.Commands.Test != "" {
+		s.Commands.Test = pc.Commands.Test
+		s.setOrigin("commands.test", from)
+	}
+	if pc.Commands.Lint != "" {
+		s.Commands.Lint = pc.Commands.Lint
+		s.setOrigin("commands.lint", from)
+	}
+	if pc.Commands.Build != "" {
+		s.Commands.Build = pc.Commands.Build
+		s.setOrigin("commands.build", from)
+	}
+
+	if len(pc.Policy.ProtectedPaths) > 0 {
+		s.Policy.ProtectedPaths = s.combine("policy.protected_paths", s.Policy.ProtectedPaths, pc.Policy.ProtectedPaths)
+	}
+
+	if len(pc.Skills) > 0 {
+		var skills []string
+		for _, name := range pc.Skills {
+			// Skill files in the repository are relative to its root
+			if strings.HasSuffix(name, ".md") && !filepath.IsAbs(name) {
+				name = filepath.Join(root, name)
+			}
+			skills = append(skills, name)
+		}
+		s.Skills = s.combine("skills", s.Skills, skills)
+	}
+
+	b := pc.Budget
+	if b.MaxCostPerTask > 0 {
+		userCap := 0.0
+		if s.Defaults.BudgetMode {
+			userCap = s.Defaults.MaxCostPerTask
+		}
+		if s.tighten(&userCap, b.MaxCostPerTask, "defaults.max_cost_per_task") {
+			s.Defaults.MaxCostPerTask = userCap
+		}
+	}
+	if b.Monthly > 0 {
+		userCap := 0.0
+		if s.Defaults.BudgetMode {
+			userCap = s.Defaults.MonthlyBudget
+		}
+		if s.tighten(&userCap, b.Monthly, "defaults.monthly_budget") {
+			s.Defaults.MonthlyBudget = userCap
+		}
+	}
+	if b.MaxCostPerTask > 0 || b.Monthly > 0 {
+		if !s.Defaults.BudgetMode {
+			// The user's own caps don't apply outside budget mode
+			if b.MaxCostPerTask == 0 {
+				s.Defaults.MaxCostPerTask = 0
+			}
+			if b.Monthly == 0 {
+				s.Defaults.MonthlyBudget = 0
+			}
+			s.Defaults.BudgetMode = true
+			s.setOrigin("defaults.budget_mode", from)
+		}
+	}
+	if b.Daily > 0 {
+		s.tighten(&s.Budget.Daily, b.Daily, "budget.daily")
+	}
+	if b.Session > 0 {
+		s.tighten(&s.Budget.Session, b.Session, "budget.session")
+	}
+	if b.OnExceed != "" {
+		s.Budget.OnExceed = b.OnExceed
+		s.setOrigin("budget.on_exceed", from)
+	}
+}
+
+// tighten lowers *current to the project's cap unless the user already set
+// a lower one. It reports whether the project's cap won.
+func (s *Setup) tighten(current *float64, project float64, key string) bool {
+	if *current > 0 && *current <= project {
+		return false
+	}
+	*current = project
+	s.setOrigin(key, OriginProject)
+	return true
+}
+
+// combine appends project values to the user's, skipping duplicates
+func (s *Setup) combine(key string, user, project []string) []string {
+	out := slices.Clone(user)
+	for _, v := range project {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	if len(user) > 0 {
+		s.setOrigin(key, OriginUser+" + "+OriginProject)
+	} else {
+		s.setOrigin(key, OriginProject)
+	}
+	return out
+}
+
+// setOrigin records where a key came from. Keys under it inherit it, so
+// a replaced subtree doesn't keep the origins of what it replaced.
+func (s *Setup) setOrigin(key, origin string) {
+	if s.Origins == nil {
+		s.Origins = map[string]string{}
+	}
+	for k := range s.Origins {
+		if strings.HasPrefix(k, key+".") {
+			delete(s.Origins, k)
+		}
+	}
+	s.Origins[key] = origin
+}
+
+// Origin says where a setting's value came from: user, project, both, or
+// default when neither file sets it. Keys are dotted YAML paths.
+func (s *Setup) Origin(key string) string {
+	for k := key; k != ""; {
+		if o, ok := s.Origins[k]; ok {
+			return o
+		}
+		i := strings.LastIndex(k, ".")
+		if i < 0 {
+			break
+		}
+		k = k[:i]
+	}
+	return OriginDefault
+}
+
+// AllowsModel reports whether the agent may use a backend's model under
+// the configured allow-lists
+func (s *Setup) AllowsModel(agent, backend, model string) bool {
+	allow, ok := s.Agents[agent]
+	if !ok {
+		return true
+	}
+	if len(allow.Backends) > 0 && !slices.Contains(allow.Backends, backend) {
+		return false
+	}
+	if len(allow.Models) == 0 {
+		return true
+	}
+	for _, pattern := range allow.Models {
+		if ok, _ := filepath.Match(pattern, model); ok || pattern == model {
+			return true
+		}
+	}
+	return false
+}
+
+// IsProtected reports whether policy forbids agents from writing path,
+// which is relative to the project root or absolute
+func (s *Setup) IsProtected(path string) bool {
+	if len(s.Policy.ProtectedPaths) == 0 {
+		return false
+	}
+	if filepath.IsAbs(path) && s.ProjectRoot != "" {
+		if rel, err := filepath.Rel(s.ProjectRoot, path); err == nil {
+			path = rel
+		}
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	for _, pattern := range s.Policy.ProtectedPaths {
+		if dir, ok := strings.CutSuffix(pattern, "/"); ok {
+			if path == dir || strings.HasPrefix(path, dir+"/") {
+				return true
+			}
+			continue
+		}
+		target := path
+		if !strings.Contains(pattern, "/") {
+			target = filepath.Base(path)
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordUserOrigins marks every key set in the user's setup.yaml
+func (s *Setup) recordUserOrigins(data []byte) {
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 {
+		return
+	}
+	walkYAML(doc.Content[0], "", func(key string, _ *yaml.Node) {
+		s.setOrigin(key, OriginUser)
+	})
+}
+
+// walkYAML calls fn for every scalar or sequence under a mapping, with its
+// dotted path
+func walkYAML(n *yaml.Node, prefix string, fn func(key string, value *yaml.Node)) {
+	if n.Kind != yaml.MappingNode {
+		if prefix != "" {
+			fn(prefix, n)
+		}
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i].Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		walkYAML(n.Content[i+1], key, fn)
+	}
+}
+
+// Settings flattens the merged setup into dotted keys and their values, in
+// file order
+func (s *Setup) Settings() ([][2]string, error) {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal setup: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to read setup: %w", err)
+	}
+	var out [][2]string
+	if len(doc.Content) == 0 {
+		return out, nil
+	}
+	walkYAML(doc.Content[0], "", func(key string, v *yaml.Node) {
+		value := v.Value
+		if v.Kind != yaml.ScalarNode {
+			flow := *v
+			flow.Style = yaml.FlowStyle
+			b, _ := yaml.Marshal(&flow)
+			value = strings.TrimSpace(string(b))
+		}
+		out = append(out, [2]string{key, value})
+	})
+	return out, nil
+}
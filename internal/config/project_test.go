@@ -0,0 +1,169 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSetupAtMergesProjectConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTestFile(t, filepath.Join(home, ".gptcode", "setup.yaml"), `defaults:
+    backend: groq
+    budget_mode: true
+    max_cost_per_task: 0.2
+    monthly_budget: 50
+budget:
+    daily: 10
+commands:
+    test: go test ./...
+    lint: golangci-lint run
+skills:
+    - tdd-bug-fix
+`)
+
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(repo, ProjectConfigFile), `agents:
+  editor:
+    backends: [openrouter]
+    models: ["anthropic/*"]
+commands:
+  test: make test
+policy:
+  protected_paths: [migrations/, "*.lock", config/prod.yaml]
+skills: [tdd-bug-fix, docs/style.md]
+budget:
+  max_cost_per_task: 0.5
+  monthly: 20
+  on_exceed: downgrade
+`)
+	sub := filepath.Join(repo, "internal", "pkg")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadSetupAt(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ProjectRoot != repo {
+		t.Errorf("expected the project root %s, got %s", repo, s.ProjectRoot)
+	}
+	if s.Commands.Test != "make test" || s.Commands.Lint != "golangci-lint run" {
+		t.Errorf("expected the project test command over the user's, got %+v", s.Commands)
+	}
+	if s.Defaults.MaxCostPerTask != 0.2 || s.Defaults.MonthlyBudget != 20 || s.Budget.OnExceed != "downgrade" {
+		t.Errorf("expected the stricter caps, got task %v monthly %v on_exceed %q",
+			s.Defaults.MaxCostPerTask, s.Defaults.MonthlyBudget, s.Budget.OnExceed)
+	}
+	wantSkills := []string{"tdd-bug-fix", filepath.Join(repo, "docs", "style.md")}
+	if strings.Join(s.Skills, ",") != strings.Join(wantSkills, ",") {
+		t.Errorf("expected skills %v, got %v", wantSkills, s.Skills)
+	}
+
+	origins := map[string]string{
+		"defaults.backend":           OriginUser,
+		"defaults.max_cost_per_task": OriginUser,
+		"defaults.monthly_budget":    OriginProject,
+		"commands.test":              OriginProject,
+		"commands.lint":              OriginUser,
+		"agents.editor.models":       OriginProject,
+		"skills":                     OriginUser + " + " + OriginProject,
+		"defaults.mode":              OriginDefault,
+	}
+	for key, want := range origins {
+		if got := s.Origin(key); got != want {
+			t.Errorf("Origin(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	if user, err := LoadUserSetup(); err != nil || user.Commands.Test != "go test ./..." || user.ProjectRoot != "" {
+		t.Errorf("expected the user setup alone, got %+v, %v", user.Commands, err)
+	}
+}
+
+func TestLoadProjectConfigValidates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	tests := map[string]string{
+		"comands:\n  test: make test\n":           "comands",
+		"agents:\n  editr:\n    models: [x]\n":    "unknown agent",
+		"budget:\n  daily: -1\n":                  "non-negative",
+		"budget:\n  on_exceed: panic\n":           "on_exceed",
+		"policy:\n  protected_paths: [\"[\"]\n":   "bad pattern",
+		"commands:\n  test: [make, test]\n":       "cannot unmarshal",
+		"agents:\n  editor:\n    model: gpt-4o\n": "model",
+	}
+	for content, want := range tests {
+		writeTestFile(t, path, content)
+		if _, err := LoadProjectConfig(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error mentioning %q for %q, got %v", want, content, err)
+		}
+	}
+
+	writeTestFile(t, path, "")
+	if _, err := LoadProjectConfig(path); err != nil {
+		t.Errorf("expected an empty config to be valid, got %v", err)
+	}
+}
+
+func TestAllowsModel(t *testing.T) {
+	s := &Setup{Agents: map[string]AgentAllowList{
+		"editor":   {Backends: []string{"openrouter"}, Models: []string{"anthropic/*", "openai/gpt-4o"}},
+		"reviewer": {Backends: []string{"ollama"}},
+	}}
+
+	tests := []struct {
+		agent, backend, model string
+		want                  bool
+	}{
+		{"editor", "openrouter", "anthropic/claude-sonnet-4", true},
+		{"editor", "openrouter", "openai/gpt-4o", true},
+		{"editor", "openrouter", "openai/gpt-4o-mini", false},
+		{"editor", "groq", "anthropic/claude-sonnet-4", false},
+		{"reviewer", "ollama", "qwen3-coder", true},
+		{"planner", "groq", "anything", true},
+	}
+	for _, tt := range tests {
+		if got := s.AllowsModel(tt.agent, tt.backend, tt.model); got != tt.want {
+			t.Errorf("AllowsModel(%q, %q, %q) = %v, want %v", tt.agent, tt.backend, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestIsProtected(t *testing.T) {
+	s := &Setup{ProjectRoot: "/repo"}
+	s.Policy.ProtectedPaths = []string{"migrations/", "*.lock", "config/prod.yaml"}
+
+	tests := map[string]bool{
+		"migrations":                   true,
+		"migrations/001_init.sql":      true,
+		"db/migrations/001.sql":        false,
+		"go.lock":                      true,
+		"web/package.lock":             true,
+		"config/prod.yaml":             true,
+		"config/dev.yaml":              false,
+		"/repo/migrations/002.sql":     true,
+		"/repo/internal/app/server.go": false,
+	}
+	for path, want := range tests {
+		if got := s.IsProtected(path); got != want {
+			t.Errorf("IsProtected(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -225,7 +225,36 @@ func detectTemplateDir() string {
 	return "templates"
 }
 
+// LoadSetup loads the user's setup merged with the project config of the
+// working directory's repository
 func LoadSetup() (*Setup, error) {
+	cwd, _ := os.Getwd()
+	return LoadSetupAt(cwd)
+}
+
+// LoadSetupAt loads the user's setup merged with the project config found
+// from dir. An invalid project config is reported, and the user's setup is
+// returned without it.
+func LoadSetupAt(dir string) (*Setup, error) {
+	s, err := LoadUserSetup()
+	if dir == "" {
+		return s, err
+	}
+	path, ok := FindProjectConfig(dir)
+	if !ok {
+		return s, err
+	}
+	pc, perr := LoadProjectConfig(path)
+	if perr != nil {
+		return s, perr
+	}
+	s.applyProject(pc, path)
+	return s, err
+}
+
+// LoadUserSetup loads ~/.gptcode/setup.yaml alone. Use it when the setup
+// is going to be saved, so project settings don't leak into the user's file.
+func LoadUserSetup() (*Setup, error) {
 	path := filepath.Join(configDir(), "setup.yaml")
 	b, err := os.ReadFile(path)
 	if err != nil {
@@ -235,6 +264,7 @@ func LoadSetup() (*Setup, error) {
 	if err := yaml.Unmarshal(b, &s); err != nil {
 		return &Setup{}, err
 	}
+	s.recordUserOrigins(b)
 	return &s, nil
 }
 
@@ -287,12 +317,12 @@ func interactiveSetup() *Setup {
 
 		// Save API key if provided
 		if apiKey != "" {
-			envVar := "OPENROUTER_API_KEY"
-			os.Setenv(envVar, apiKey)
-			if err := saveAPIKeyToProfile(envVar, apiKey); err != nil {
+			os.Setenv("OPENROUTER_API_KEY", apiKey)
+			if where, err := saveAPIKey("openrouter", apiKey); err != nil {
 				fmt.Fprintf(os.Stderr, "\nWarning: Could not save API key: %v\n", err)
+				fmt.Fprintln(os.Stderr, "To retry, run: gt key openrouter")
 			} else {
-				fmt.Fprintln(os.Stderr, "\n✓ API key saved!")
+				fmt.Fprintf(os.Stderr, "\n✓ API key saved to %s\n", where)
 			}
 		} else {
 			fmt.Fprintln(os.Stderr, "\n⚠️  No API key provided.")
@@ -425,12 +455,11 @@ func interactiveSetup() *Setup {
 				envVar := strings.ToUpper(backendName) + "_API_KEY"
 				os.Setenv(envVar, apiKey)
 
-				if err := saveAPIKeyToProfile(envVar, apiKey); err != nil {
-					fmt.Fprintf(os.Stderr, "\nWarning: Could not auto-save to shell profile: %v\n", err)
-					fmt.Fprintf(os.Stderr, "Manually add: export %s=%s\n", envVar, apiKey)
+				if where, err := saveAPIKey(backendName, apiKey); err != nil {
+					fmt.Fprintf(os.Stderr, "\nWarning: Could not save API key: %v\n", err)
+					fmt.Fprintf(os.Stderr, "To retry, run: gt key %s\n", backendName)
 				} else {
-					fmt.Fprintf(os.Stderr, "\n✓ API key saved to shell profile\n")
-					fmt.Fprintf(os.Stderr, "  Restart your terminal or run: source ~/.zshrc\n")
+					fmt.Fprintf(os.Stderr, "\n✓ API key saved to %s\n", where)
 				}
 			}
 		}
@@ -485,74 +514,25 @@ func saveSetup(path string, setup *Setup) error {
 	return os.WriteFile(path, data, 0o644)
 }
 
+// GetAPIKey returns a backend's key from the keys section of setup.yaml,
+// the secret store, the legacy keys.yaml, or BACKEND_API_KEY, in that order
 func GetAPIKey(backendName string) string {
-	// keys.yaml takes priority over env vars
-	home, err := os.UserHomeDir()
-	if err == nil {
-		keysPath := filepath.Join(home, ".gptcode", "keys.yaml")
-		if data, err := os.ReadFile(keysPath); err == nil {
-			var keys map[string]string
-			if err := yaml.Unmarshal(data, &keys); err == nil {
-				if val, ok := keys[backendName]; ok && val != "" {
-					return val
-				}
-			}
-		}
-	}
-
-	// Fallback to env var
 	envVar := strings.ToUpper(backendName) + "_API_KEY"
+	if key := lookupAPIKey(backendName, envVar); key != "" {
+		return key
+	}
 	return os.Getenv(envVar)
 }
 
+// LoadAPIKey returns a key by its environment variable name, e.g.
+// TAVILY_API_KEY, looking in the same places as GetAPIKey
 func LoadAPIKey(keyName string) string {
-	// keys.yaml takes priority over env vars
-	home, err := os.UserHomeDir()
-	if err == nil {
-		keysPath := filepath.Join(home, ".gptcode", "keys.yaml")
-		if data, err := os.ReadFile(keysPath); err == nil {
-			var keys map[string]string
-			if err := yaml.Unmarshal(data, &keys); err == nil {
-				// Try exact match first
-				if val, ok := keys[keyName]; ok && val != "" {
-					return val
-				}
-				// Try without _API_KEY suffix
-				cleanName := strings.TrimSuffix(keyName, "_API_KEY")
-				if val, ok := keys[cleanName]; ok && val != "" {
-					return val
-				}
-			}
-		}
+	if key := lookupAPIKey(keyName, strings.TrimSuffix(keyName, "_API_KEY")); key != "" {
+		return key
 	}
-
-	// Fallback to env var
 	return os.Getenv(keyName)
 }
 
-func saveAPIKeyToKeysFile(backendName, apiKey string) error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-
-	keysPath := filepath.Join(home, ".gptcode", "keys.yaml")
-
-	keys := make(map[string]string)
-	if data, err := os.ReadFile(keysPath); err == nil {
-		_ = yaml.Unmarshal(data, &keys)
-	}
-
-	keys[backendName] = apiKey
-
-	data, err := yaml.Marshal(keys)
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(keysPath, data, 0o600)
-}
-
 func UpdateAPIKey(backendName string) error {
 	// Handle web search API keys specially (they're not backends)
 	lowerName := strings.ToLower(backendName)
@@ -578,12 +558,12 @@ func UpdateAPIKey(backendName string) error {
 		return fmt.Errorf("API key cannot be empty")
 	}
 
-	if err := saveAPIKeyToKeysFile(backendName, apiKey); err != nil {
+	where, err := saveAPIKey(backendName, apiKey)
+	if err != nil {
 		return fmt.Errorf("failed to save API key: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "\n✓ API key saved to ~/.gptcode/keys.yaml\n")
-	fmt.Fprintf(os.Stderr, "  (with 0600 permissions for security)\n")
+	fmt.Fprintf(os.Stderr, "\n✓ API key saved to %s\n", where)
 
 	return nil
 }
@@ -600,12 +580,12 @@ func updateSearchAPIKey(name string) error {
 		return fmt.Errorf("API key cannot be empty")
 	}
 
-	if err := saveAPIKeyToKeysFile(envVar, apiKey); err != nil {
+	where, err := saveAPIKey(envVar, apiKey)
+	if err != nil {
 		return fmt.Errorf("failed to save API key: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "\n✓ API key saved to ~/.gptcode/keys.yaml\n")
-	fmt.Fprintf(os.Stderr, "  (with 0600 permissions for security)\n")
+	fmt.Fprintf(os.Stderr, "\n✓ API key saved to %s\n", where)
 	fmt.Fprintf(os.Stderr, "  Env var: %s\n", envVar)
 
 	return nil
@@ -619,52 +599,6 @@ func getBackendNames(setup *Setup) []string {
 	return names
 }
 
-func saveAPIKeyToProfile(envVar, apiKey string) error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-
-	shell := os.Getenv("SHELL")
-	var profilePath string
-
-	if strings.Contains(shell, "zsh") {
-		profilePath = filepath.Join(home, ".zshrc")
-	} else if strings.Contains(shell, "bash") {
-		profilePath = filepath.Join(home, ".bashrc")
-		if _, err := os.Stat(profilePath); os.IsNotExist(err) {
-			profilePath = filepath.Join(home, ".bash_profile")
-		}
-	} else {
-		return fmt.Errorf("unsupported shell: %s", shell)
-	}
-
-	exportLine := fmt.Sprintf("export %s=%q\n", envVar, apiKey)
-
-	if _, err := os.Stat(profilePath); err == nil {
-		content, err := os.ReadFile(profilePath)
-		if err != nil {
-			return err
-		}
-
-		if strings.Contains(string(content), envVar) {
-			return fmt.Errorf("%s already exists in %s", envVar, profilePath)
-		}
-	}
-
-	f, err := os.OpenFile(profilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = f.Close() }()
-
-	if _, err := f.WriteString("\n# GPTCode API key\n" + exportLine); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func copyIfMissing(srcDir, dstDir, file string) {
 	src := filepath.Join(srcDir, file)
 	dst := filepath.Join(dstDir, file)
@@ -0,0 +1,268 @@
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Block is one basic block from a Go coverprofile
+type Block struct {
+	File      string
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	NumStmt   int
+	Count     int
+}
+
+func (b Block) key() string {
+	return fmt.Sprintf("%s:%d.%d,%d.%d", b.File, b.StartLine, b.StartCol, b.EndLine, b.EndCol)
+}
+
+// Profile is a parsed coverprofile. Blocks reported more than once, as
+// happens when several test binaries cover the same package, are merged.
+type Profile struct {
+	Mode   string
+	Blocks []Block
+}
+
+// ParseProfile parses the output of go test -coverprofile
+func ParseProfile(data []byte) (*Profile, error) {
+	p := &Profile{}
+	index := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if mode, ok := strings.CutPrefix(line, "mode:"); ok {
+			p.Mode = strings.TrimSpace(mode)
+			continue
+		}
+		b, err := parseBlock(line)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[b.key()]; ok {
+			if p.Mode == "set" {
+				p.Blocks[i].Count = max(p.Blocks[i].Count, b.Count)
+			} else {
+				p.Blocks[i].Count += b.Count
+			}
+			continue
+		}
+		index[b.key()] = len(p.Blocks)
+		p.Blocks = append(p.Blocks, b)
+	}
+	return p, scanner.Err()
+}
+
+// parseBlock parses "file.go:10.2,12.3 2 1"
+func parseBlock(line string) (Block, error) {
+	colon := strings.LastIndex(line, ":")
+	fields := strings.Fields(line[colon+1:])
+	if colon < 0 || len(fields) != 3 {
+		return Block{}, fmt.Errorf("invalid coverprofile line %q", line)
+	}
+	b := Block{File: line[:colon]}
+	if _, err := fmt.Sscanf(fields[0], "%d.%d,%d.%d", &b.StartLine, &b.StartCol, &b.EndLine, &b.EndCol); err != nil {
+		return Block{}, fmt.Errorf("invalid coverprofile range %q: %w", fields[0], err)
+	}
+	var err error
+	if b.NumStmt, err = strconv.Atoi(fields[1]); err != nil {
+		return Block{}, fmt.Errorf("invalid statement count in %q", line)
+	}
+	if b.Count, err = strconv.Atoi(fields[2]); err != nil {
+		return Block{}, fmt.Errorf("invalid hit count in %q", line)
+	}
+	return b, nil
+}
+
+// FileBlocks returns the blocks of a source file given relative to the
+// module root. Profiles name files by import path, so the match is on the
+// path suffix.
+func (p *Profile) FileBlocks(file string) []Block {
+	file = filepath.ToSlash(file)
+	var out []Block
+	for _, b := range p.Blocks {
+		if b.File == file || strings.HasSuffix(b.File, "/"+file) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// Percent is the statement coverage of a file, or of the whole profile
+// when file is empty
+func (p *Profile) Percent(file string) float64 {
+	blocks := p.Blocks
+	if file != "" {
+		blocks = p.FileBlocks(file)
+	}
+	return percent(blocks)
+}
+
+func percent(blocks []Block) float64 {
+	total, covered := 0, 0
+	for _, b := range blocks {
+		total += b.NumStmt
+		if b.Count > 0 {
+			covered += b.NumStmt
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// Covers reports whether the profile covers a block that other leaves
+// uncovered
+func (p *Profile) Covers(other *Profile, file string) bool {
+	missed := map[string]bool{}
+	for _, b := range other.FileBlocks(file) {
+		if b.Count == 0 {
+			missed[b.key()] = true
+		}
+	}
+	for _, b := range p.FileBlocks(file) {
+		if b.Count > 0 && missed[b.key()] {
+			return true
+		}
+	}
+	return false
+}
+
+// FunctionCoverage is the coverage of one function
+type FunctionCoverage struct {
+	Name       string
+	StartLine  int
+	EndLine    int
+	Statements int
+	Covered    int
+	Uncovered  []Block
+}
+
+func (f FunctionCoverage) Percent() float64 {
+	if f.Statements == 0 {
+		return 0
+	}
+	return 100 * float64(f.Covered) / float64(f.Statements)
+}
+
+// Functions maps a file's blocks to the functions that contain them. file
+// is relative to workDir.
+func (p *Profile) Functions(workDir, file string) ([]FunctionCoverage, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filepath.Join(workDir, file), nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	var funcs []FunctionCoverage
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		name := fn.Name.Name
+		if fn.Recv != nil && len(fn.Recv.List) > 0 {
+			name = receiverName(fn.Recv.List[0].Type) + "." + name
+		}
+		funcs = append(funcs, FunctionCoverage{
+			Name:      name,
+			StartLine: fset.Position(fn.Pos()).Line,
+			EndLine:   fset.Position(fn.End()).Line,
+		})
+	}
+
+	for _, b := range p.FileBlocks(file) {
+		for i := range funcs {
+			fc := &funcs[i]
+			if b.StartLine < fc.StartLine || b.EndLine > fc.EndLine {
+				continue
+			}
+			fc.Statements += b.NumStmt
+			if b.Count > 0 {
+				fc.Covered += b.NumStmt
+			} else {
+				fc.Uncovered = append(fc.Uncovered, b)
+			}
+			break
+		}
+	}
+	return funcs, nil
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// UncoveredBlock is an uncovered block with the function it belongs to
+type UncoveredBlock struct {
+	Function string
+	Block
+}
+
+// LargestGaps returns up to n uncovered blocks with the most statements
+func LargestGaps(funcs []FunctionCoverage, n int) []UncoveredBlock {
+	var gaps []UncoveredBlock
+	for _, fc := range funcs {
+		for _, b := range fc.Uncovered {
+			gaps = append(gaps, UncoveredBlock{Function: fc.Name, Block: b})
+		}
+	}
+	sort.SliceStable(gaps, func(i, j int) bool { return gaps[i].NumStmt > gaps[j].NumStmt })
+	if len(gaps) > n {
+		gaps = gaps[:n]
+	}
+	sort.SliceStable(gaps, func(i, j int) bool { return gaps[i].StartLine < gaps[j].StartLine })
+	return gaps
+}
+
+// RunProfile runs go test with a coverprofile for pkg and parses it. Extra
+// args, such as -run, go before the package.
+func RunProfile(ctx context.Context, workDir, pkg string, args ...string) (*Profile, error) {
+	tmp, err := os.CreateTemp("", "gptcode-cover-*.out")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create coverprofile: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	cmdArgs := append([]string{"test", "-count=1", "-coverprofile=" + tmp.Name()}, args...)
+	cmd := exec.CommandContext(ctx, "go", append(cmdArgs, pkg)...)
+	cmd.Dir = workDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("go test failed: %w\nOutput: %s", err, string(output))
+	}
+
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coverprofile: %w", err)
+	}
+	return ParseProfile(data)
+}
@@ -0,0 +1,86 @@
+package coverage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const calcSource = `package calc
+
+func Classify(n int) string {
+	if n < 0 {
+		return "negative"
+	}
+	if n == 0 {
+		return "zero"
+	}
+	return "positive"
+}
+
+type Acc struct{ total int }
+
+func (a *Acc) Add(n int) {
+	a.total += n
+}
+`
+
+const calcProfile = `mode: set
+demo/calc/calc.go:3.29,4.11 1 1
+demo/calc/calc.go:4.11,6.3 1 0
+demo/calc/calc.go:7.2,7.12 1 1
+demo/calc/calc.go:7.12,9.3 1 0
+demo/calc/calc.go:10.2,10.19 1 1
+demo/calc/calc.go:15.26,17.2 1 0
+demo/calc/calc.go:4.11,6.3 1 1
+`
+
+func TestProfileFunctions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "calc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "calc", "calc.go"), []byte(calcSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ParseProfile([]byte(calcProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Blocks) != 6 {
+		t.Fatalf("expected duplicate block to be merged, got %d blocks", len(p.Blocks))
+	}
+	if got := p.Percent("calc/calc.go"); got < 66.6 || got > 66.7 {
+		t.Errorf("expected 4 of 6 statements covered, got %.1f%%", got)
+	}
+
+	funcs, err := p.Functions(dir, "calc/calc.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(funcs) != 2 || funcs[0].Name != "Classify" || funcs[1].Name != "Acc.Add" {
+		t.Fatalf("unexpected functions: %+v", funcs)
+	}
+	if funcs[0].Statements != 5 || funcs[0].Covered != 4 || len(funcs[0].Uncovered) != 1 {
+		t.Errorf("unexpected Classify coverage: %+v", funcs[0])
+	}
+
+	gaps := LargestGaps(funcs, 1)
+	if len(gaps) != 1 || gaps[0].StartLine != 7 || gaps[0].Function != "Classify" {
+		t.Errorf("expected the first largest gap in line order, got %+v", gaps)
+	}
+}
+
+func TestProfileCovers(t *testing.T) {
+	base, _ := ParseProfile([]byte("mode: set\ndemo/a.go:1.1,2.2 1 1\ndemo/a.go:3.1,4.2 2 0\n"))
+	same, _ := ParseProfile([]byte("mode: set\ndemo/a.go:1.1,2.2 1 1\ndemo/a.go:3.1,4.2 2 0\n"))
+	more, _ := ParseProfile([]byte("mode: set\ndemo/a.go:1.1,2.2 1 0\ndemo/a.go:3.1,4.2 2 1\n"))
+
+	if same.Covers(base, "a.go") {
+		t.Error("expected no new coverage")
+	}
+	if !more.Covers(base, "a.go") {
+		t.Error("expected the uncovered block to count as new coverage")
+	}
+}
@@ -0,0 +1,216 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gptcode/internal/llm"
+)
+
+type usageProvider struct{}
+
+func (usageProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	return &llm.ChatResponse{
+		ToolCalls:  []llm.ChatToolCall{{ID: "1", Name: "write_file"}},
+		TokenUsage: &llm.TokenUsage{PromptTokens: 900000, CompletionTokens: 100000, TotalTokens: 1000000},
+	}, nil
+}
+
+const suiteYAML = `name: basics
+parallel: 2
+timeout: 1m
+matrix:
+  - backend: fake
+    model: good
+  - backend: fake
+    model: sloppy
+tasks:
+  - id: fix-add
+    fixture: fixtures/calc
+    prompt: Fix Add
+    checks:
+      tests: ["go test ./..."]
+      changed: ["calc.go"]
+      forbidden: ["*_test.go"]
+`
+
+func writeSuite(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"basics.yaml":                 suiteYAML,
+		"fixtures/calc/go.mod":        "module calc\n\ngo 1.21\n",
+		"fixtures/calc/calc.go":       "package calc\n\nfunc Add(a, b int) int { return 0 }\n",
+		"fixtures/calc/calc_test.go":  "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(2, 3) != 5 {\n\t\tt.Fatal(\"wrong sum\")\n\t}\n}\n",
+		"fixtures/calc/.git/HEAD":     "ref: refs/heads/main\n",
+		"fixtures/calc/docs/notes.md": "notes\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "basics.yaml")
+}
+
+// fakeAgent fixes the bug; the sloppy model also rewrites the test so it
+// passes trivially
+func fakeAgent(ctx context.Context, target Target, dir, prompt string) error {
+	if _, err := target.Provider.Chat(ctx, llm.ChatRequest{Model: target.EditorModel, UserPrompt: prompt}); err != nil {
+		return err
+	}
+	fix := "package calc\n\nfunc Add(a, b int) int { return a + b }\n"
+	if err := os.WriteFile(filepath.Join(dir, "calc.go"), []byte(fix), 0644); err != nil {
+		return err
+	}
+	if target.EditorModel == "sloppy" {
+		return os.WriteFile(filepath.Join(dir, "calc_test.go"), []byte("package calc\n"), 0644)
+	}
+	return nil
+}
+
+func TestRunnerScoresVariants(t *testing.T) {
+	for _, tool := range []string{"go", "git"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	suite, err := LoadSuite(writeSuite(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var targets []Target
+	for _, v := range suite.Matrix {
+		targets = append(targets, Target{Variant: v, Provider: usageProvider{}, EditorModel: v.Model})
+	}
+	runner := NewRunner(suite, targets)
+	runner.Agent = fakeAgent
+	report := runner.Run(context.Background())
+
+	if len(report.Results) != 2 {
+		t.Fatalf("expected one result per variant, got %+v", report.Results)
+	}
+	good, sloppy := report.Results[0], report.Results[1]
+	if !good.Passed || good.Variant != "fake/good" {
+		t.Errorf("expected the good variant to pass, got %+v", good)
+	}
+	if strings.Join(good.Changed, ",") != "calc.go" {
+		t.Errorf("expected only calc.go to change, got %v", good.Changed)
+	}
+	if good.LLMCalls != 1 || good.ToolCalls != 1 || good.PromptTokens != 900000 || good.Cost != 1.0 {
+		t.Errorf("expected metered usage, got %+v", good)
+	}
+	if sloppy.Passed || sloppy.status() != "❌ unchanged *_test.go" {
+		t.Errorf("expected the forbidden test change to fail, got %s", sloppy.status())
+	}
+
+	if s := report.Summaries; len(s) != 2 || s[0].PassRate != 1 || s[1].PassRate != 0 {
+		t.Errorf("unexpected summaries: %+v", s)
+	}
+
+	md := report.Markdown(nil)
+	if !strings.Contains(md, "| fake/good | 100% | 1/1 |") {
+		t.Errorf("unexpected markdown:\n%s", md)
+	}
+}
+
+func TestRunnerStopsAtCostCap(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	suite, err := LoadSuite(writeSuite(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite.Parallel = 1
+	suite.Tasks[0].Checks = Checks{Changed: []string{"calc.go"}}
+
+	targets := []Target{
+		{Variant: suite.Matrix[0], Provider: usageProvider{}, EditorModel: "good"},
+		{Variant: suite.Matrix[1], Provider: usageProvider{}, EditorModel: "good"},
+	}
+	runner := NewRunner(suite, targets)
+	runner.Agent = fakeAgent
+	runner.MaxCost = 0.5
+	report := runner.Run(context.Background())
+
+	if !report.Results[0].Passed || !report.Results[1].Skipped {
+		t.Errorf("expected the second run to be skipped once the cap was spent, got %+v", report.Results)
+	}
+	if report.Summaries[1].Skipped != 1 || report.Summaries[1].Runs != 0 {
+		t.Errorf("skipped runs should not count toward the pass rate: %+v", report.Summaries[1])
+	}
+}
+
+func TestCompareAgainstBaseline(t *testing.T) {
+	baseline := &Report{Results: []Result{
+		{Task: "a", Variant: "v", Passed: true, Cost: 0.1},
+		{Task: "b", Variant: "v", Passed: false, Cost: 0.1},
+		{Task: "c", Variant: "v", Passed: true, Cost: 0.1},
+	}}
+	baseline.summarize()
+	current := &Report{Results: []Result{
+		{Task: "a", Variant: "v", Passed: false, Cost: 0.2},
+		{Task: "b", Variant: "v", Passed: true, Cost: 0.2},
+		{Task: "c", Variant: "v", Passed: true, Cost: 0.2},
+		{Task: "a", Variant: "new", Passed: true},
+	}}
+	current.summarize()
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := baseline.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comps := Compare(current, loaded)
+	if len(comps) != 1 {
+		t.Fatalf("expected only variants in the baseline to be compared, got %+v", comps)
+	}
+	c := comps[0]
+	if strings.Join(c.Regressions, ",") != "a" || strings.Join(c.Fixed, ",") != "b" {
+		t.Errorf("unexpected comparison: %+v", c)
+	}
+	if c.CostDelta < 0.29 || c.CostDelta > 0.31 {
+		t.Errorf("expected cost delta of 0.3, got %f", c.CostDelta)
+	}
+}
+
+func TestLoadSuiteValidates(t *testing.T) {
+	path := writeSuite(t)
+	data, _ := os.ReadFile(path)
+	bad := strings.Replace(string(data), "fixtures/calc", "fixtures/missing", 1)
+	if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSuite(path); err == nil || !strings.Contains(err.Error(), "fixture fixtures/missing") {
+		t.Errorf("expected a missing fixture error, got %v", err)
+	}
+}
+
+func TestRunChecksTimesOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	checks := runChecks(ctx, t.TempDir(), Checks{Tests: []string{"sleep 30"}}, nil)
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("expected the check stopped at the timeout")
+	}
+	if len(checks) != 1 || checks[0].Passed || !strings.HasPrefix(checks[0].Detail, "timed out") {
+		t.Errorf("expected a timed out check, got %+v", checks)
+	}
+}
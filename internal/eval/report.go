@@ -0,0 +1,248 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of one task on one variant
+type Result struct {
+	Task             string        `json:"task"`
+	Variant          string        `json:"variant"`
+	Passed           bool          `json:"passed"`
+	Skipped          bool          `json:"skipped,omitempty"`
+	Error            string        `json:"error,omitempty"`
+	Checks           []CheckResult `json:"checks,omitempty"`
+	Changed          []string      `json:"changed,omitempty"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Cost             float64       `json:"cost"`
+	Duration         time.Duration `json:"duration"`
+	LLMTime          time.Duration `json:"llm_time"`
+	LLMCalls         int           `json:"llm_calls"`
+	ToolCalls        int           `json:"tool_calls"`
+}
+
+// Summary aggregates a variant's results
+type Summary struct {
+	Variant     string        `json:"variant"`
+	Runs        int           `json:"runs"`
+	Passed      int           `json:"passed"`
+	Skipped     int           `json:"skipped"`
+	PassRate    float64       `json:"pass_rate"`
+	Tokens      int           `json:"tokens"`
+	Cost        float64       `json:"cost"`
+	AvgDuration time.Duration `json:"avg_duration"`
+	ToolCalls   int           `json:"tool_calls"`
+}
+
+// Report is the result of a suite run
+type Report struct {
+	Suite     string        `json:"suite"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Results   []Result      `json:"results"`
+	Summaries []Summary     `json:"summaries"`
+}
+
+// summarize computes per-variant summaries in matrix order. Skipped runs
+// don't count toward the pass rate.
+func (r *Report) summarize() {
+	index := map[string]int{}
+	r.Summaries = nil
+	for _, res := range r.Results {
+		i, ok := index[res.Variant]
+		if !ok {
+			i = len(r.Summaries)
+			index[res.Variant] = i
+			r.Summaries = append(r.Summaries, Summary{Variant: res.Variant})
+		}
+		s := &r.Summaries[i]
+		if res.Skipped {
+			s.Skipped++
+			continue
+		}
+		s.Runs++
+		if res.Passed {
+			s.Passed++
+		}
+		s.Tokens += res.PromptTokens + res.CompletionTokens
+		s.Cost += res.Cost
+		s.AvgDuration += res.Duration
+		s.ToolCalls += res.ToolCalls
+	}
+	for i := range r.Summaries {
+		s := &r.Summaries[i]
+		if s.Runs > 0 {
+			s.PassRate = float64(s.Passed) / float64(s.Runs)
+			s.AvgDuration /= time.Duration(s.Runs)
+		}
+	}
+}
+
+// TotalCost is what the whole run spent
+func (r *Report) TotalCost() float64 {
+	total := 0.0
+	for _, s := range r.Summaries {
+		total += s.Cost
+	}
+	return total
+}
+
+// Save writes the report as JSON
+func (r *Report) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// LoadReport reads a report written by Save
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Comparison is how a variant did against the baseline
+type Comparison struct {
+	Variant          string  `json:"variant"`
+	PassRate         float64 `json:"pass_rate"`
+	BaselinePassRate float64 `json:"baseline_pass_rate"`
+	CostDelta        float64 `json:"cost_delta"`
+	// Regressions are tasks that passed in the baseline and fail now
+	Regressions []string `json:"regressions,omitempty"`
+	// Fixed are tasks that failed in the baseline and pass now
+	Fixed []string `json:"fixed,omitempty"`
+}
+
+// Compare matches the report's variants with the baseline's. Variants the
+// baseline didn't run are left out.
+func Compare(current, baseline *Report) []Comparison {
+	base := map[string]Summary{}
+	for _, s := range baseline.Summaries {
+		base[s.Variant] = s
+	}
+	passed := map[string]bool{}
+	ran := map[string]bool{}
+	for _, res := range baseline.Results {
+		if res.Skipped {
+			continue
+		}
+		key := res.Variant + "\x00" + res.Task
+		ran[key] = true
+		passed[key] = res.Passed
+	}
+
+	var out []Comparison
+	for _, s := range current.Summaries {
+		b, ok := base[s.Variant]
+		if !ok {
+			continue
+		}
+		c := Comparison{
+			Variant:          s.Variant,
+			PassRate:         s.PassRate,
+			BaselinePassRate: b.PassRate,
+			CostDelta:        s.Cost - b.Cost,
+		}
+		for _, res := range current.Results {
+			key := res.Variant + "\x00" + res.Task
+			if res.Variant != s.Variant || res.Skipped || !ran[key] {
+				continue
+			}
+			switch {
+			case passed[key] && !res.Passed:
+				c.Regressions = append(c.Regressions, res.Task)
+			case !passed[key] && res.Passed:
+				c.Fixed = append(c.Fixed, res.Task)
+			}
+		}
+		sort.Strings(c.Regressions)
+		sort.Strings(c.Fixed)
+		out = append(out, c)
+	}
+	return out
+}
+
+// Markdown renders the report, with a comparison section when a baseline
+// is given
+func (r *Report) Markdown(baseline *Report) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Eval: %s\n\n", r.Suite)
+	fmt.Fprintf(&sb, "Run %s, took %s, cost $%.4f\n\n", r.StartedAt.Format("2006-01-02 15:04"), r.Duration.Round(time.Second), r.TotalCost())
+
+	sb.WriteString("| Variant | Pass rate | Passed | Tokens | Cost | Avg latency | Tool calls |\n")
+	sb.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, s := range r.Summaries {
+		passed := fmt.Sprintf("%d/%d", s.Passed, s.Runs)
+		if s.Skipped > 0 {
+			passed += fmt.Sprintf(" (%d skipped)", s.Skipped)
+		}
+		fmt.Fprintf(&sb, "| %s | %.0f%% | %s | %d | $%.4f | %s | %d |\n",
+			s.Variant, 100*s.PassRate, passed, s.Tokens, s.Cost, s.AvgDuration.Round(100*time.Millisecond), s.ToolCalls)
+	}
+
+	if baseline != nil {
+		sb.WriteString("\n## Compared to baseline\n\n")
+		sb.WriteString("| Variant | Pass rate | Baseline | Cost change | Regressions | Fixed |\n")
+		sb.WriteString("|---|---|---|---|---|---|\n")
+		for _, c := range Compare(r, baseline) {
+			fmt.Fprintf(&sb, "| %s | %.0f%% | %.0f%% | %+.4f | %s | %s |\n",
+				c.Variant, 100*c.PassRate, 100*c.BaselinePassRate, c.CostDelta, listOrDash(c.Regressions), listOrDash(c.Fixed))
+		}
+	}
+
+	sb.WriteString("\n## Tasks\n\n")
+	sb.WriteString("| Task | Variant | Result | Tokens | Cost | Latency | Tool calls |\n")
+	sb.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, res := range r.Results {
+		fmt.Fprintf(&sb, "| %s | %s | %s | %d | $%.4f | %s | %d |\n",
+			res.Task, res.Variant, res.status(), res.PromptTokens+res.CompletionTokens, res.Cost, res.Duration.Round(100*time.Millisecond), res.ToolCalls)
+	}
+	return sb.String()
+}
+
+// status is a short result label with the first failed check
+func (r Result) status() string {
+	switch {
+	case r.Skipped:
+		return "skipped"
+	case r.Passed:
+		return "✅ pass"
+	}
+	for _, c := range r.Checks {
+		if !c.Passed {
+			return "❌ " + strings.ReplaceAll(c.Name, "|", `\|`)
+		}
+	}
+	if r.Error != "" {
+		return "❌ error"
+	}
+	return "❌ no checks"
+}
+
+func listOrDash(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, ", ")
+}
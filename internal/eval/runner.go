@@ -0,0 +1,421 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gptcode/internal/agents"
+	"gptcode/internal/ledger"
+	"gptcode/internal/llm"
+	"gptcode/internal/maestro"
+)
+
+// ErrCostCap is returned to the agent once a run or the suite has spent
+// its budget
+var ErrCostCap = errors.New("eval cost cap reached")
+
+// Target is a resolved variant: the provider to call and the models each
+// agent role uses
+type Target struct {
+	Variant     Variant
+	Provider    llm.Provider
+	EditorModel string
+	QueryModel  string
+}
+
+// AgentFunc runs one task prompt in dir. The target's provider is metered,
+// so everything the agent sends through it is counted.
+type AgentFunc func(ctx context.Context, target Target, dir, prompt string) error
+
+// EditorAgent runs the prompt through a single editor tool loop
+func EditorAgent(ctx context.Context, target Target, dir, prompt string) error {
+	editor := agents.NewEditor(target.Provider, dir, target.EditorModel)
+	_, _, err := editor.Execute(ctx, []llm.ChatMessage{{Role: "user", Content: prompt}}, nil)
+	return err
+}
+
+// MaestroAgent plans the prompt and executes the plan with verification
+// and recovery
+func MaestroAgent(ctx context.Context, target Target, dir, prompt string) error {
+	plan, err := agents.NewPlanner(target.Provider, target.QueryModel).CreatePlan(ctx, prompt, "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create plan: %w", err)
+	}
+	m := maestro.NewMaestro(target.Provider, dir, target.EditorModel)
+	return m.ExecutePlan(ctx, plan)
+}
+
+// Runner runs a suite across targets
+type Runner struct {
+	Suite   *Suite
+	Targets []Target
+	Agent   AgentFunc
+	// Parallel and MaxCost override the suite's settings when set
+	Parallel int
+	MaxCost  float64
+	// OnResult is called as each run finishes
+	OnResult func(Result)
+
+	mu        sync.Mutex
+	totalCost float64
+}
+
+// NewRunner creates a runner using the agent the suite names
+func NewRunner(suite *Suite, targets []Target) *Runner {
+	agent := EditorAgent
+	if suite.Agent == "maestro" {
+		agent = MaestroAgent
+	}
+	return &Runner{Suite: suite, Targets: targets, Agent: agent}
+}
+
+// Run runs every task against every target and collects the results
+func (r *Runner) Run(ctx context.Context) *Report {
+	parallel := r.Parallel
+	if parallel <= 0 {
+		parallel = r.Suite.Parallel
+	}
+	if parallel <= 0 {
+		parallel = defaultParallel
+	}
+
+	report := &Report{Suite: r.Suite.Name, StartedAt: time.Now()}
+	type job struct {
+		task   Task
+		target Target
+	}
+	var jobs []job
+	for _, target := range r.Targets {
+		for _, task := range r.Suite.Tasks {
+			jobs = append(jobs, job{task, target})
+		}
+	}
+
+	results := make([]Result, len(jobs))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, j job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = r.runTask(ctx, j.task, j.target)
+			if r.OnResult != nil {
+				r.OnResult(results[i])
+			}
+		}(i, j)
+	}
+	wg.Wait()
+
+	report.Results = results
+	report.Duration = time.Since(report.StartedAt)
+	report.summarize()
+	return report
+}
+
+func (r *Runner) maxCost() float64 {
+	if r.MaxCost > 0 {
+		return r.MaxCost
+	}
+	return r.Suite.MaxCost
+}
+
+// overBudget reports whether the suite has spent its cap
+func (r *Runner) overBudget() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.maxCost() > 0 && r.totalCost >= r.maxCost()
+}
+
+func (r *Runner) addCost(cost float64) {
+	r.mu.Lock()
+	r.totalCost += cost
+	r.mu.Unlock()
+}
+
+// runTask runs one task against one target in an isolated copy of its
+// fixture
+func (r *Runner) runTask(ctx context.Context, task Task, target Target) Result {
+	result := Result{Task: task.ID, Variant: target.Variant.Name()}
+	if r.overBudget() {
+		result.Skipped = true
+		result.Error = ErrCostCap.Error()
+		return result
+	}
+
+	dir, err := prepareFixture(r.Suite.FixturePath(task))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer os.RemoveAll(dir)
+
+	meter := &meteredProvider{
+		inner:   target.Provider,
+		backend: target.Variant.Backend,
+		runner:  r,
+		maxCost: r.Suite.MaxCostPerTask,
+	}
+	metered := target
+	metered.Provider = meter
+
+	agentCtx, cancel := context.WithTimeout(ctx, r.Suite.taskTimeout(task))
+	defer cancel()
+
+	start := time.Now()
+	agentErr := r.Agent(agentCtx, metered, dir, task.Prompt)
+	result.Duration = time.Since(start)
+	meter.fill(&result)
+	if agentErr != nil {
+		result.Error = agentErr.Error()
+	}
+
+	changed, err := changedFiles(dir)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Changed = changed
+	// The checks get a timeout of their own, so a hanging test command
+	// can't stall the run even when the agent used up its time
+	checkCtx, cancelChecks := context.WithTimeout(ctx, r.Suite.taskTimeout(task))
+	defer cancelChecks()
+	result.Checks = runChecks(checkCtx, dir, task.Checks, changed)
+	result.Passed = len(result.Checks) > 0
+	for _, c := range result.Checks {
+		if !c.Passed {
+			result.Passed = false
+		}
+	}
+	return result
+}
+
+// CheckResult is the outcome of one success check
+type CheckResult struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func runChecks(ctx context.Context, dir string, checks Checks, changed []string) []CheckResult {
+	var results []CheckResult
+	for _, pattern := range checks.Changed {
+		c := CheckResult{Name: "changed " + pattern}
+		for _, f := range changed {
+			if matchPattern(pattern, f) {
+				c.Passed = true
+				break
+			}
+		}
+		if !c.Passed {
+			c.Detail = "no changed file matches"
+		}
+		results = append(results, c)
+	}
+	for _, pattern := range checks.Forbidden {
+		c := CheckResult{Name: "unchanged " + pattern, Passed: true}
+		var hits []string
+		for _, f := range changed {
+			if matchPattern(pattern, f) {
+				hits = append(hits, f)
+			}
+		}
+		if len(hits) > 0 {
+			c.Passed = false
+			c.Detail = "changed " + strings.Join(hits, ", ")
+		}
+		results = append(results, c)
+	}
+	for _, command := range checks.Tests {
+		c := CheckResult{Name: "test " + command}
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = dir
+		// Don't wait forever for output from children the shell left behind
+		cmd.WaitDelay = 5 * time.Second
+		out, err := cmd.CombinedOutput()
+		c.Passed = err == nil
+		switch {
+		case ctx.Err() != nil:
+			c.Detail = "timed out\n" + lastLines(string(out), 20)
+		case err != nil:
+			c.Detail = lastLines(string(out), 20)
+		}
+		results = append(results, c)
+	}
+	return results
+}
+
+// matchPattern matches a slash-separated path against a glob. Patterns
+// without a slash also match the base name, and "dir/**" matches anything
+// under dir.
+func matchPattern(pattern, file string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return strings.HasPrefix(file, prefix+"/")
+	}
+	if ok, _ := path.Match(pattern, file); ok {
+		return true
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	return false
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prepareFixture copies a fixture into a temporary git repository with
+// one baseline commit, so changes can be read back from git and the agents
+// that rely on git diff behave as in a real checkout
+func prepareFixture(fixture string) (string, error) {
+	dir, err := os.MkdirTemp("", "gptcode-eval-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create work directory: %w", err)
+	}
+	if err := copyDir(fixture, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to copy fixture: %w", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=gptcode-eval", "-c", "user.email=eval@gptcode.local", "commit", "-q", "--allow-empty", "-m", "fixture"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to set up fixture repo: %w\n%s", err, out)
+		}
+	}
+	return dir, nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// changedFiles lists files added, modified or deleted since the fixture
+// commit, ignoring gptcode's own state directory
+func changedFiles(dir string) ([]string, error) {
+	cmd := exec.Command("git", "status", "--porcelain", "--untracked-files=all", "--no-renames")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		file := strings.Trim(line[3:], `"`)
+		if strings.HasPrefix(file, ".gptcode/") {
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// meteredProvider counts calls, tokens, tool calls and cost for one run,
+// and refuses further calls once the run or the suite is over budget
+type meteredProvider struct {
+	inner   llm.Provider
+	backend string
+	runner  *Runner
+	maxCost float64
+
+	mu               sync.Mutex
+	calls            int
+	toolCalls        int
+	promptTokens     int
+	completionTokens int
+	cost             float64
+	llmTime          time.Duration
+}
+
+func (p *meteredProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.mu.Lock()
+	spent := p.cost
+	p.mu.Unlock()
+	if (p.maxCost > 0 && spent >= p.maxCost) || p.runner.overBudget() {
+		return nil, ErrCostCap
+	}
+
+	start := time.Now()
+	resp, err := p.inner.Chat(ctx, req)
+	elapsed := time.Since(start)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	p.llmTime += elapsed
+	if resp != nil {
+		p.toolCalls += len(resp.ToolCalls)
+		if u := resp.TokenUsage; u != nil {
+			p.promptTokens += u.PromptTokens
+			p.completionTokens += u.CompletionTokens
+			cost := ledger.Cost(p.backend, req.Model, u.PromptTokens, u.CompletionTokens, u.CachedTokens)
+			p.cost += cost
+			p.runner.addCost(cost)
+		}
+	}
+	return resp, err
+}
+
+func (p *meteredProvider) fill(r *Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r.LLMCalls = p.calls
+	r.ToolCalls = p.toolCalls
+	r.PromptTokens = p.promptTokens
+	r.CompletionTokens = p.completionTokens
+	r.Cost = p.cost
+	r.LLMTime = p.llmTime
+}
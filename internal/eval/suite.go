@@ -0,0 +1,157 @@
+// Package eval benchmarks agents on fixed tasks across backends and models
+package eval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a set of tasks run against every variant of a matrix
+type Suite struct {
+	Name string `yaml:"name"`
+	// Agent picks what runs each task: "editor" (default) or "maestro"
+	Agent    string        `yaml:"agent,omitempty"`
+	Parallel int           `yaml:"parallel,omitempty"`
+	Timeout  time.Duration `yaml:"timeout,omitempty"`
+	// MaxCost caps the spend of the whole run in USD; tasks not started
+	// when it is reached are skipped
+	MaxCost float64 `yaml:"max_cost,omitempty"`
+	// MaxCostPerTask stops a single run once it has spent this much
+	MaxCostPerTask float64   `yaml:"max_cost_per_task,omitempty"`
+	Matrix         []Variant `yaml:"matrix,omitempty"`
+	Tasks          []Task    `yaml:"tasks"`
+
+	// dir is where the suite file lives; fixtures are relative to it
+	dir string
+}
+
+// Variant is one backend/model/profile combination to evaluate. With no
+// model, the profile's agent models for the backend are used.
+type Variant struct {
+	Backend string `yaml:"backend"`
+	Model   string `yaml:"model,omitempty"`
+	Profile string `yaml:"profile,omitempty"`
+}
+
+// Name identifies the variant in reports
+func (v Variant) Name() string {
+	name := v.Backend
+	if v.Model != "" {
+		name += "/" + v.Model
+	}
+	if v.Profile != "" {
+		name += "@" + v.Profile
+	}
+	return name
+}
+
+// Task is one benchmark problem: a fixture repo, a prompt, and the checks
+// that decide whether the agent solved it
+type Task struct {
+	ID      string        `yaml:"id"`
+	Fixture string        `yaml:"fixture"`
+	Prompt  string        `yaml:"prompt"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	Checks  Checks        `yaml:"checks"`
+}
+
+// Checks are the success conditions of a task
+type Checks struct {
+	// Tests are shell commands that must exit 0 after the run
+	Tests []string `yaml:"tests,omitempty"`
+	// Changed are globs that must each match at least one changed file
+	Changed []string `yaml:"changed,omitempty"`
+	// Forbidden are globs no changed file may match
+	Forbidden []string `yaml:"forbidden,omitempty"`
+}
+
+const (
+	defaultTimeout  = 10 * time.Minute
+	defaultParallel = 1
+)
+
+// LoadSuite reads and validates a suite file
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite: %w", err)
+	}
+	var s Suite
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse suite: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	s.dir = filepath.Dir(abs)
+	if s.Name == "" {
+		s.Name = trimExt(filepath.Base(path))
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Suite) validate() error {
+	if len(s.Tasks) == 0 {
+		return fmt.Errorf("suite %s has no tasks", s.Name)
+	}
+	switch s.Agent {
+	case "", "editor", "maestro":
+	default:
+		return fmt.Errorf("unknown agent %q (want editor or maestro)", s.Agent)
+	}
+	seen := map[string]bool{}
+	for i, t := range s.Tasks {
+		if t.ID == "" {
+			return fmt.Errorf("task %d has no id", i+1)
+		}
+		if seen[t.ID] {
+			return fmt.Errorf("duplicate task id %q", t.ID)
+		}
+		seen[t.ID] = true
+		if t.Prompt == "" {
+			return fmt.Errorf("task %s has no prompt", t.ID)
+		}
+		if t.Fixture == "" {
+			return fmt.Errorf("task %s has no fixture", t.ID)
+		}
+		if info, err := os.Stat(s.FixturePath(t)); err != nil || !info.IsDir() {
+			return fmt.Errorf("task %s: fixture %s is not a directory", t.ID, t.Fixture)
+		}
+	}
+	for _, v := range s.Matrix {
+		if v.Backend == "" {
+			return fmt.Errorf("matrix entry %q has no backend", v.Name())
+		}
+	}
+	return nil
+}
+
+// FixturePath resolves a task's fixture against the suite file
+func (s *Suite) FixturePath(t Task) string {
+	if filepath.IsAbs(t.Fixture) {
+		return t.Fixture
+	}
+	return filepath.Join(s.dir, t.Fixture)
+}
+
+func (s *Suite) taskTimeout(t Task) time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return defaultTimeout
+}
+
+func trimExt(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))]
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"time"
 )
 
@@ -59,6 +60,7 @@ func (e *Emitter) Emit(eventType EventType, data map[string]interface{}) error {
 	if err != nil {
 		return err
 	}
+	Publish(eventType, plainData(data))
 
 	_, err = fmt.Fprintf(e.writer, "__EVENT__%s__EVENT__\n", string(jsonBytes))
 	if err != nil {
@@ -128,3 +130,17 @@ func (e *Emitter) Notify(message string, level string) error {
 		"level":   level,
 	})
 }
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// plainData strips terminal colors from string values for ndjson output
+func plainData(data map[string]interface{}) map[string]interface{} {
+	plain := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			v = ansiEscape.ReplaceAllString(s, "")
+		}
+		plain[k] = v
+	}
+	return plain
+}
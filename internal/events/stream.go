@@ -0,0 +1,274 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Format is how a command reports its progress and result
+type Format string
+
+const (
+	// FormatText is styled text for people
+	FormatText Format = "text"
+	// FormatJSON writes only the final Result to stdout, as one document
+	FormatJSON Format = "json"
+	// FormatNDJSON streams typed events to stdout, one per line, and ends
+	// with the Result
+	FormatNDJSON Format = "ndjson"
+)
+
+// SchemaVersion is raised when a field of the JSON output is removed or
+// changes meaning; new fields may appear without it
+const SchemaVersion = 1
+
+// Typed events streamed in ndjson output
+const (
+	EventStep         EventType = "step"
+	EventToolCall     EventType = "tool_call"
+	EventDiff         EventType = "diff"
+	EventVerification EventType = "verification"
+	EventCost         EventType = "cost"
+	EventResult       EventType = "result"
+)
+
+// StreamEvent is one line of ndjson output
+type StreamEvent struct {
+	Type      EventType   `json:"type"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Step is progress through a plan step or a phase of a run
+type Step struct {
+	// Index counts plan steps from 1; phases have none
+	Index int    `json:"index,omitempty"`
+	Total int    `json:"total,omitempty"`
+	Title string `json:"title"`
+	// Status is running, completed or failed
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ToolCall is a tool the model ran
+type ToolCall struct {
+	Name       string            `json:"name"`
+	Arguments  map[string]string `json:"arguments,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	DurationMs int64             `json:"duration_ms"`
+}
+
+// Diff is a change a tool made to a file
+type Diff struct {
+	Path string `json:"path"`
+	// Operation is create or modify
+	Operation string `json:"operation"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	// Patch is a unified diff of the change
+	Patch string `json:"patch,omitempty"`
+}
+
+// Verification is the outcome of checking a step's changes
+type Verification struct {
+	Passed bool `json:"passed"`
+	// ErrorType classifies a failure: syntax, build, test, lint, ...
+	ErrorType string `json:"error_type,omitempty"`
+}
+
+// Cost is the usage and price of one LLM request
+type Cost struct {
+	Backend          string  `json:"backend"`
+	Model            string  `json:"model"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CachedTokens     int     `json:"cached_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// Result is the final outcome of a command. Data holds what the command
+// produced, such as review findings, and is absent for commands without a
+// structured result.
+type Result struct {
+	SchemaVersion    int         `json:"schema_version"`
+	Command          string      `json:"command"`
+	OK               bool        `json:"ok"`
+	Error            string      `json:"error,omitempty"`
+	DurationMs       int64       `json:"duration_ms"`
+	CostUSD          float64     `json:"cost_usd"`
+	PromptTokens     int         `json:"prompt_tokens"`
+	CompletionTokens int         `json:"completion_tokens"`
+	FilesChanged     []string    `json:"files_changed"`
+	Data             interface{} `json:"data,omitempty"`
+}
+
+// stream holds the state of headless output for the process
+var stream = struct {
+	sync.Mutex
+	format     Format
+	out        io.Writer
+	start      time.Time
+	result     interface{}
+	cost       float64
+	prompt     int
+	completion int
+	files      []string
+}{format: FormatText, out: os.Stdout, start: time.Now()}
+
+// ParseFormat reads the value of --output
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatJSON, FormatNDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q: use text, json or ndjson", s)
+}
+
+// SetFormat picks the output format for the process. In json and ndjson
+// output stdout is kept for machine-readable output and everything else
+// printed there goes to stderr instead.
+func SetFormat(f Format) {
+	if f == FormatText {
+		return
+	}
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	setOutput(f, out)
+}
+
+func setOutput(f Format, w io.Writer) {
+	stream.Lock()
+	defer stream.Unlock()
+	stream.format = f
+	stream.out = w
+	stream.start = time.Now()
+	stream.result = nil
+	stream.cost, stream.prompt, stream.completion = 0, 0, 0
+	stream.files = nil
+}
+
+// CurrentFormat returns the output format in use
+func CurrentFormat() Format {
+	stream.Lock()
+	defer stream.Unlock()
+	return stream.format
+}
+
+// Headless reports whether output is for scripts rather than people
+func Headless() bool {
+	return CurrentFormat() != FormatText
+}
+
+// Streaming reports whether events are streamed, as in ndjson output
+func Streaming() bool {
+	return CurrentFormat() == FormatNDJSON
+}
+
+// Publish streams an event in ndjson output and does nothing otherwise
+func Publish(eventType EventType, data interface{}) {
+	stream.Lock()
+	defer stream.Unlock()
+	if stream.format != FormatNDJSON {
+		return
+	}
+	writeLine(StreamEvent{Type: eventType, Timestamp: time.Now().UnixMilli(), Data: data})
+}
+
+// writeLine writes one ndjson line; stream must be locked
+func writeLine(v interface{}) {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(stream.out, "%s\n", line)
+}
+
+// PublishStep streams progress through a step
+func PublishStep(s Step) {
+	Publish(EventStep, s)
+}
+
+// PublishToolCall streams a tool call
+func PublishToolCall(c ToolCall) {
+	Publish(EventToolCall, c)
+}
+
+// PublishDiff streams a file change and adds the file to the result
+func PublishDiff(d Diff) {
+	stream.Lock()
+	seen := false
+	for _, f := range stream.files {
+		seen = seen || f == d.Path
+	}
+	if !seen {
+		stream.files = append(stream.files, d.Path)
+	}
+	stream.Unlock()
+	Publish(EventDiff, d)
+}
+
+// PublishVerification streams the outcome of a verification
+func PublishVerification(v Verification) {
+	Publish(EventVerification, v)
+}
+
+// PublishCost streams an LLM request's usage and adds it to the result
+func PublishCost(c Cost) {
+	stream.Lock()
+	stream.cost += c.CostUSD
+	stream.prompt += c.PromptTokens
+	stream.completion += c.CompletionTokens
+	stream.Unlock()
+	Publish(EventCost, c)
+}
+
+// SetResult sets what the running command produced, reported as the
+// result's data
+func SetResult(data interface{}) {
+	stream.Lock()
+	defer stream.Unlock()
+	stream.result = data
+}
+
+// Finish writes the command's Result in json and ndjson output. err is the
+// error the command returned.
+func Finish(command string, err error) error {
+	stream.Lock()
+	defer stream.Unlock()
+	if stream.format == FormatText {
+		return nil
+	}
+
+	r := Result{
+		SchemaVersion:    SchemaVersion,
+		Command:          command,
+		OK:               err == nil,
+		DurationMs:       time.Since(stream.start).Milliseconds(),
+		CostUSD:          stream.cost,
+		PromptTokens:     stream.prompt,
+		CompletionTokens: stream.completion,
+		FilesChanged:     append([]string{}, stream.files...),
+		Data:             stream.result,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if stream.format == FormatNDJSON {
+		writeLine(StreamEvent{Type: EventResult, Timestamp: time.Now().UnixMilli(), Data: r})
+		return nil
+	}
+	enc := json.NewEncoder(stream.out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": FormatText, "text": FormatText, "JSON": FormatJSON, "ndjson": FormatNDJSON} {
+		got, err := ParseFormat(in)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("expected an unknown format to be refused")
+	}
+}
+
+func TestNDJSONStreamsEventsAndEndsWithResult(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var out bytes.Buffer
+	setOutput(FormatNDJSON, &out)
+	defer setOutput(FormatText, os.Stdout)
+
+	PublishStep(Step{Index: 1, Total: 2, Title: "Add handler", Status: "running"})
+	PublishToolCall(ToolCall{Name: "write_file", Arguments: map[string]string{"path": "a.go"}})
+	PublishDiff(Diff{Path: "a.go", Operation: "create", Added: 3})
+	PublishDiff(Diff{Path: "a.go", Operation: "modify", Added: 1, Removed: 1})
+	PublishVerification(Verification{Passed: false, ErrorType: "build"})
+	PublishCost(Cost{Backend: "groq", Model: "m", PromptTokens: 100, CompletionTokens: 20, CostUSD: 0.5})
+	PublishCost(Cost{Backend: "groq", Model: "m", PromptTokens: 50, CompletionTokens: 10, CostUSD: 0.25})
+	_ = NewEmitter(&bytes.Buffer{}).Status("\u001b[34mStep 1/2\u001b[0m: Add handler")
+	SetResult(map[string]string{"task": "add a handler"})
+	if err := Finish("do", errors.New("step 1 failed")); err != nil {
+		t.Fatal(err)
+	}
+
+	var types []EventType
+	var last StreamEvent
+	var status map[string]interface{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var e struct {
+			Type EventType       `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line is not JSON: %s", scanner.Text())
+		}
+		types = append(types, e.Type)
+		if e.Type == EventStatus {
+			_ = json.Unmarshal(e.Data, &status)
+		}
+		if e.Type == EventResult {
+			var r Result
+			_ = json.Unmarshal(e.Data, &r)
+			last = StreamEvent{Type: e.Type, Data: r}
+		}
+	}
+
+	want := []EventType{EventStep, EventToolCall, EventDiff, EventDiff, EventVerification, EventCost, EventCost, EventStatus, EventResult}
+	if len(types) != len(want) {
+		t.Fatalf("got events %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("got events %v, want %v", types, want)
+		}
+	}
+	if status["status"] != "Step 1/2: Add handler" {
+		t.Errorf("expected colors stripped from status, got %q", status["status"])
+	}
+
+	r := last.Data.(Result)
+	if r.OK || r.Error != "step 1 failed" || r.Command != "do" || r.SchemaVersion != SchemaVersion {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	if r.CostUSD != 0.75 || r.PromptTokens != 150 || r.CompletionTokens != 30 {
+		t.Errorf("expected usage added up, got %+v", r)
+	}
+	if len(r.FilesChanged) != 1 || r.FilesChanged[0] != "a.go" {
+		t.Errorf("expected one changed file, got %v", r.FilesChanged)
+	}
+}
+
+func TestJSONWritesOnlyTheResult(t *testing.T) {
+	var out bytes.Buffer
+	setOutput(FormatJSON, &out)
+	defer setOutput(FormatText, os.Stdout)
+
+	PublishStep(Step{Title: "planning", Status: "running"})
+	SetResult(map[string]int{"findings": 2})
+	if err := Finish("review", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var r struct {
+		Result
+		Data map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatalf("expected a single JSON document, got %s", out.String())
+	}
+	if !r.OK || r.Command != "review" || r.Data["findings"] != 2 || r.FilesChanged == nil {
+		t.Errorf("unexpected result: %s", out.String())
+	}
+}
+
+func TestTextWritesNothing(t *testing.T) {
+	var out bytes.Buffer
+	setOutput(FormatText, &out)
+
+	PublishStep(Step{Title: "planning", Status: "running"})
+	_ = Finish("do", nil)
+	if out.Len() != 0 {
+		t.Errorf("expected no output, got %q", out.String())
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"gptcode/internal/redact"
 )
 
 type Sentiment string
@@ -55,6 +57,13 @@ func Record(event Event) error {
 		event.Timestamp = time.Now()
 	}
 
+	// Feedback gets shared and exported, so it never keeps secrets
+	r := redact.Default()
+	event.Task = r.Redact(event.Task)
+	event.Context = r.Redact(event.Context)
+	event.WrongResponse = r.Redact(event.WrongResponse)
+	event.CorrectResponse = r.Redact(event.CorrectResponse)
+
 	filename := fmt.Sprintf("%s.json", event.Timestamp.Format("2006-01-02"))
 	path := filepath.Join(GetFeedbackDir(), filename)
 
@@ -441,6 +450,7 @@ func ExportAnonymized(outputPath string) error {
 		return fmt.Errorf("failed to marshal anonymized feedback: %w", err)
 	}
 
+	data = []byte(redact.Default().Redact(string(data)))
 	if err := os.WriteFile(outputPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write export file: %w", err)
 	}
@@ -253,9 +253,16 @@ func (c *Client) CreatePR(opts PRCreateOptions) (*PullRequest, error) {
 		args = append(args, "--reviewer", reviewer)
 	}
 
+	if opts.HeadBranch != "" {
+		args = append(args, "--head", opts.HeadBranch)
+	}
+
 	args = append(args, "--repo", c.repo)
 
 	cmd := exec.Command("gh", args...)
+	if c.workDir != "" {
+		cmd.Dir = c.workDir
+	}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create PR: %w\nOutput: %s", err, string(output))
@@ -416,6 +423,60 @@ func (c *Client) GetUnresolvedComments(prNumber int) ([]ReviewComment, error) {
 	return unresolved, nil
 }
 
+// SetCommitStatus sets a commit status (pending, success, failure or error) on sha
+func (c *Client) SetCommitStatus(sha, state, description, statusContext, targetURL string) error {
+	if len(description) > 140 {
+		description = description[:137] + "..."
+	}
+
+	args := []string{"api", "--method", "POST",
+		fmt.Sprintf("repos/%s/statuses/%s", c.repo, sha),
+		"-f", "state=" + state,
+		"-f", "description=" + description,
+		"-f", "context=" + statusContext,
+	}
+	if targetURL != "" {
+		args = append(args, "-f", "target_url="+targetURL)
+	}
+
+	cmd := exec.Command("gh", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set commit status on %s: %w\nOutput: %s", sha, err, string(output))
+	}
+	return nil
+}
+
+// CurrentUser returns the login the gh CLI is authenticated as
+func CurrentUser() (string, error) {
+	output, err := exec.Command("gh", "api", "user", "--jq", ".login").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the authenticated user: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// AddIssueComment posts a comment on an issue or pull request conversation
+func (c *Client) AddIssueComment(number int, body string) error {
+	cmd := exec.Command("gh", "api", "--method", "POST",
+		fmt.Sprintf("repos/%s/issues/%d/comments", c.repo, number),
+		"-f", "body="+body)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to comment on #%d: %w\nOutput: %s", number, err, string(output))
+	}
+	return nil
+}
+
+// ReplyToReviewComment replies in the thread of a pull request review comment
+func (c *Client) ReplyToReviewComment(prNumber int, commentID string, body string) error {
+	cmd := exec.Command("gh", "api", "--method", "POST",
+		fmt.Sprintf("repos/%s/pulls/%d/comments/%s/replies", c.repo, prNumber, commentID),
+		"-f", "body="+body)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to reply to comment %s on PR #%d: %w\nOutput: %s", commentID, prNumber, err, string(output))
+	}
+	return nil
+}
+
 func GeneratePRBody(issue *Issue, changes []string) string {
 	body := fmt.Sprintf("Closes #%d\n\n", issue.Number)
 	body += "## Changes\n\n"
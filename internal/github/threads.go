@@ -0,0 +1,200 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ReviewThread is a pull request review conversation anchored to a file
+type ReviewThread struct {
+	ID         string          `json:"id"` // GraphQL node ID, needed to reply/resolve
+	Path       string          `json:"path"`
+	Line       int             `json:"line"`
+	IsResolved bool            `json:"isResolved"`
+	IsOutdated bool            `json:"isOutdated"`
+	Comments   []ReviewComment `json:"comments"`
+}
+
+// Body returns the full conversation of the thread, oldest first
+func (t *ReviewThread) Body() string {
+	var sb strings.Builder
+	for _, c := range t.Comments {
+		fmt.Fprintf(&sb, "@%s: %s\n", c.Author, c.Body)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+const reviewThreadsQuery = `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100) {
+        nodes {
+          id
+          path
+          line
+          isResolved
+          isOutdated
+          comments(first: 50) {
+            nodes {
+              databaseId
+              body
+              createdAt
+              author { login }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// FetchReviewThreads returns the review threads of a pull request. Unlike
+// FetchPRComments, threads carry resolution state and can be resolved.
+func (c *Client) FetchReviewThreads(prNumber int) ([]ReviewThread, error) {
+	owner, name, err := c.splitRepo()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("gh", "api", "graphql",
+		"-f", "query="+reviewThreadsQuery,
+		"-f", "owner="+owner,
+		"-f", "name="+name,
+		"-F", "number="+strconv.Itoa(prNumber))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch review threads: %w\nOutput: %s", err, string(output))
+	}
+
+	var result struct {
+		Data struct {
+			Repository struct {
+				PullRequest struct {
+					ReviewThreads struct {
+						Nodes []struct {
+							ID         string `json:"id"`
+							Path       string `json:"path"`
+							Line       int    `json:"line"`
+							IsResolved bool   `json:"isResolved"`
+							IsOutdated bool   `json:"isOutdated"`
+							Comments   struct {
+								Nodes []struct {
+									DatabaseID int64  `json:"databaseId"`
+									Body       string `json:"body"`
+									CreatedAt  string `json:"createdAt"`
+									Author     struct {
+										Login string `json:"login"`
+									} `json:"author"`
+								} `json:"nodes"`
+							} `json:"comments"`
+						} `json:"nodes"`
+					} `json:"reviewThreads"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse review threads: %w", err)
+	}
+
+	var threads []ReviewThread
+	for _, n := range result.Data.Repository.PullRequest.ReviewThreads.Nodes {
+		thread := ReviewThread{
+			ID:         n.ID,
+			Path:       n.Path,
+			Line:       n.Line,
+			IsResolved: n.IsResolved,
+			IsOutdated: n.IsOutdated,
+		}
+		for _, cm := range n.Comments.Nodes {
+			thread.Comments = append(thread.Comments, ReviewComment{
+				ID:        strconv.FormatInt(cm.DatabaseID, 10),
+				Author:    cm.Author.Login,
+				Body:      cm.Body,
+				Path:      n.Path,
+				Line:      n.Line,
+				CreatedAt: cm.CreatedAt,
+			})
+		}
+		threads = append(threads, thread)
+	}
+
+	return threads, nil
+}
+
+// GetUnresolvedThreads returns review threads that are neither resolved nor empty
+func (c *Client) GetUnresolvedThreads(prNumber int) ([]ReviewThread, error) {
+	threads, err := c.FetchReviewThreads(prNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	var unresolved []ReviewThread
+	for _, t := range threads {
+		if !t.IsResolved && len(t.Comments) > 0 {
+			unresolved = append(unresolved, t)
+		}
+	}
+	return unresolved, nil
+}
+
+// ReplyToThread posts a reply in a review thread
+func (c *Client) ReplyToThread(threadID, body string) error {
+	mutation := `mutation($thread: ID!, $body: String!) {
+  addPullRequestReviewThreadReply(input: {pullRequestReviewThreadId: $thread, body: $body}) { comment { id } }
+}`
+	cmd := exec.Command("gh", "api", "graphql",
+		"-f", "query="+mutation,
+		"-f", "thread="+threadID,
+		"-f", "body="+body)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to reply to thread %s: %w\nOutput: %s", threadID, err, string(output))
+	}
+	return nil
+}
+
+// ResolveThread marks a review thread as resolved
+func (c *Client) ResolveThread(threadID string) error {
+	mutation := `mutation($thread: ID!) {
+  resolveReviewThread(input: {threadId: $thread}) { thread { isResolved } }
+}`
+	cmd := exec.Command("gh", "api", "graphql",
+		"-f", "query="+mutation,
+		"-f", "thread="+threadID)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to resolve thread %s: %w\nOutput: %s", threadID, err, string(output))
+	}
+	return nil
+}
+
+// CheckoutPR checks out the head branch of a pull request in the work dir
+func (c *Client) CheckoutPR(prNumber int) (string, error) {
+	cmd := exec.Command("gh", "pr", "checkout", strconv.Itoa(prNumber), "--repo", c.repo)
+	if c.workDir != "" {
+		cmd.Dir = c.workDir
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to checkout PR #%d: %w\nOutput: %s", prNumber, err, string(output))
+	}
+
+	branchCmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	if c.workDir != "" {
+		branchCmd.Dir = c.workDir
+	}
+	output, err := branchCmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (c *Client) splitRepo() (string, string, error) {
+	parts := strings.Split(c.repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid repository %q, expected owner/repo", c.repo)
+	}
+	return parts[0], parts[1], nil
+}
@@ -16,6 +16,7 @@ type Builder struct {
 	root       string
 	cache      *Cache
 	moduleName string
+	crateName  string
 }
 
 // NewBuilder creates a new graph builder
@@ -31,6 +32,7 @@ func NewBuilder(root string) *Builder {
 func (b *Builder) Build() (*Graph, error) {
 	// Try to parse go.mod to get module name
 	b.parseGoMod()
+	b.parseCargoToml()
 
 	if cached, err := b.cache.Get(b.root); err == nil {
 		return cached, nil
@@ -61,6 +63,8 @@ func (b *Builder) Build() (*Graph, error) {
 			b.processRubyFile(path)
 		case ".rs":
 			b.processRustFile(path)
+		case ".ex", ".exs":
+			b.processElixirFile(path)
 		}
 
 		return nil
@@ -133,11 +137,38 @@ func (b *Builder) parseGoMod() {
 	}
 }
 
+// parseCargoToml reads the crate name so integration tests' `use mycrate::`
+// imports resolve like `use crate::`
+func (b *Builder) parseCargoToml() {
+	data, err := os.ReadFile(filepath.Join(b.root, "Cargo.toml"))
+	if err != nil {
+		return
+	}
+
+	inPackage := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inPackage = line == "[package]"
+			continue
+		}
+		if inPackage && strings.HasPrefix(line, "name") {
+			if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
+				name := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+				b.crateName = strings.ReplaceAll(name, "-", "_")
+				return
+			}
+		}
+	}
+}
+
 var (
 	pyImportRegex   = regexp.MustCompile(`^(?:from\s+([\w\.]+)|import\s+([\w\.]+))`)
 	jsImportRegex   = regexp.MustCompile(`(?:import|require)\s*.*?['"]([^'"]+)['"]`)
 	rubyImportRegex = regexp.MustCompile(`^(?:require|require_relative)\s+['"]([^'"]+)['"]`)
-	rustUseRegex    = regexp.MustCompile(`^use\s+([\w:]+)`)
+	rustUseRegex    = regexp.MustCompile(`^(?:pub\s+)?use\s+([\w:]+)`)
+	exAliasRegex    = regexp.MustCompile(`^(?:alias|import|require|use)\s+([A-Z][\w.]*)\.\{([^}]*)\}`)
+	exModuleRegex   = regexp.MustCompile(`\b[A-Z]\w*(?:\.[A-Z]\w*)*`)
 )
 
 func (b *Builder) processPythonFile(path string) {
@@ -184,7 +215,15 @@ func (b *Builder) processPythonFile(path string) {
 				continue
 			}
 
-			// 3. Try __init__.py in directory
+			// 3. Absolute from a src/ layout
+			targetPath = filepath.Join(b.root, "src", impPath+".py")
+			if _, err := os.Stat(targetPath); err == nil {
+				targetRel, _ := filepath.Rel(b.root, targetPath)
+				b.graph.AddEdge(relPath, targetRel)
+				continue
+			}
+
+			// 4. Try __init__.py in directory
 			targetPath = filepath.Join(b.root, impPath, "__init__.py")
 			if _, err := os.Stat(targetPath); err == nil {
 				targetRel, _ := filepath.Rel(b.root, targetPath)
@@ -288,23 +327,108 @@ func (b *Builder) processRustFile(path string) {
 		if len(matches) > 1 {
 			imp := matches[1]
 
-			// Simple heuristic: if starts with "crate::" it's local
-			if strings.HasPrefix(imp, "crate::") || strings.HasPrefix(imp, "super::") {
-				// Convert module path to file path
-				modPath := strings.TrimPrefix(imp, "crate::")
-				modPath = strings.TrimPrefix(modPath, "super::")
-				modPath = strings.ReplaceAll(modPath, "::", "/")
+			// Local if it starts with crate::, super:: or the crate's own name
+			var modPath string
+			switch {
+			case strings.HasPrefix(imp, "crate::"):
+				modPath = strings.TrimPrefix(imp, "crate::")
+			case strings.HasPrefix(imp, "super::"):
+				modPath = strings.TrimPrefix(imp, "super::")
+			case b.crateName != "" && strings.HasPrefix(imp, b.crateName+"::"):
+				modPath = strings.TrimPrefix(imp, b.crateName+"::")
+			default:
+				continue
+			}
 
-				// Try .rs file or mod.rs
+			// The path may end in an item rather than a module, so try
+			// shorter prefixes until one names a file
+			segments := strings.Split(modPath, "::")
+		resolve:
+			for n := len(segments); n > 0; n-- {
+				candidate := strings.Join(segments[:n], "/")
 				for _, suffix := range []string{".rs", "/mod.rs"} {
-					targetPath := filepath.Join(b.root, "src", modPath+suffix)
+					targetPath := filepath.Join(b.root, "src", candidate+suffix)
 					if _, err := os.Stat(targetPath); err == nil {
 						targetRel, _ := filepath.Rel(b.root, targetPath)
 						b.graph.AddEdge(relPath, targetRel)
-						break
+						break resolve
+					}
+				}
+			}
+		}
+	}
+}
+
+// processElixirFile links a file to the modules it mentions, resolved by the
+// Mix convention that MyApp.FooBar lives in lib/my_app/foo_bar.ex
+func (b *Builder) processElixirFile(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	relPath, _ := filepath.Rel(b.root, path)
+	b.graph.AddNode(relPath, "file")
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		modules := exModuleRegex.FindAllString(line, -1)
+		// alias MyApp.{Foo, Bar}
+		if m := exAliasRegex.FindStringSubmatch(line); m != nil {
+			for _, child := range strings.Split(m[2], ",") {
+				modules = append(modules, m[1]+"."+strings.TrimSpace(child))
+			}
+		}
+
+		for _, mod := range modules {
+			if seen[mod] {
+				continue
+			}
+			seen[mod] = true
+
+			// Nested modules often live in their parent's file
+			parts := strings.Split(mod, ".")
+			for n := len(parts); n >= 1 && (n >= 2 || len(parts) == 1); n-- {
+				targetPath := filepath.Join(b.root, "lib", elixirModulePath(strings.Join(parts[:n], "."))+".ex")
+				if _, err := os.Stat(targetPath); err == nil {
+					targetRel, _ := filepath.Rel(b.root, targetPath)
+					if targetRel != relPath {
+						b.graph.AddEdge(relPath, targetRel)
 					}
+					break
+				}
+			}
+		}
+	}
+}
+
+// elixirModulePath converts a module name to its conventional file path,
+// following Macro.underscore: MyApp.HTTPClient -> my_app/http_client
+func elixirModulePath(module string) string {
+	parts := strings.Split(module, ".")
+	for i, part := range parts {
+		var sb strings.Builder
+		runes := []rune(part)
+		for j, r := range runes {
+			isUpper := r >= 'A' && r <= 'Z'
+			if isUpper && j > 0 {
+				prev := runes[j-1]
+				prevLower := (prev >= 'a' && prev <= 'z') || (prev >= '0' && prev <= '9')
+				nextLower := j+1 < len(runes) && runes[j+1] >= 'a' && runes[j+1] <= 'z'
+				if prevLower || (prev >= 'A' && prev <= 'Z' && nextLower) {
+					sb.WriteRune('_')
 				}
 			}
+			sb.WriteString(strings.ToLower(string(r)))
 		}
+		parts[i] = sb.String()
 	}
+	return strings.Join(parts, "/")
 }
@@ -338,3 +338,67 @@ func hasEdge(g *Graph, from, to int64) bool {
 	}
 	return false
 }
+
+func TestElixirAndCrateImports(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"mix.exs":                `defmodule MyApp.MixProject do end`,
+		"lib/my_app/calc.ex":     `defmodule MyApp.Calc do end`,
+		"lib/my_app/http_api.ex": `defmodule MyApp.HTTPApi do alias MyApp.Calc end`,
+		"test/my_app/api_test.exs": `defmodule MyApp.HTTPApiTest do
+  alias MyApp.{HTTPApi}
+end`,
+
+		"Cargo.toml":       "[package]\nname = \"my-crate\"\n",
+		"src/lib.rs":       `pub mod parser;`,
+		"src/parser.rs":    `pub fn parse() {}`,
+		"tests/parsing.rs": `use my_crate::parser::parse;`,
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(tmpDir, path)
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		os.WriteFile(fullPath, []byte(content), 0644)
+	}
+
+	g, err := NewBuilder(tmpDir).Build()
+	if err != nil {
+		t.Fatalf("Failed to build graph: %v", err)
+	}
+
+	edges := [][2]string{
+		{"lib/my_app/http_api.ex", "lib/my_app/calc.ex"},
+		{"test/my_app/api_test.exs", "lib/my_app/http_api.ex"},
+		{"tests/parsing.rs", "src/parser.rs"},
+	}
+	for _, e := range edges {
+		if !hasEdge(g, g.Paths[e[0]], g.Paths[e[1]]) {
+			t.Errorf("Missing edge %s -> %s", e[0], e[1])
+		}
+	}
+
+	deps := g.Dependents([]string{"lib/my_app/calc.ex"})
+	want := []string{"lib/my_app/calc.ex", "lib/my_app/http_api.ex", "test/my_app/api_test.exs"}
+	if len(deps) != len(want) {
+		t.Fatalf("Expected dependents %v, got %v", want, deps)
+	}
+	for i := range want {
+		if deps[i] != want[i] {
+			t.Errorf("Expected dependents %v, got %v", want, deps)
+			break
+		}
+	}
+}
+
+func TestElixirModulePath(t *testing.T) {
+	cases := map[string]string{
+		"MyApp":           "my_app",
+		"MyApp.HTTPApi":   "my_app/http_api",
+		"MyApp.V2.Parser": "my_app/v2/parser",
+	}
+	for module, want := range cases {
+		if got := elixirModulePath(module); got != want {
+			t.Errorf("elixirModulePath(%q) = %q, want %q", module, got, want)
+		}
+	}
+}
@@ -49,7 +49,8 @@ func (c *Cache) computeHash(root string) (string, error) {
 		}
 
 		ext := filepath.Ext(path)
-		if ext == ".go" || ext == ".py" || ext == ".js" || ext == ".ts" || ext == ".jsx" || ext == ".tsx" {
+		switch ext {
+		case ".go", ".py", ".js", ".ts", ".jsx", ".tsx", ".rb", ".rs", ".ex", ".exs":
 			relPath, _ := filepath.Rel(root, path)
 			h.Write([]byte(relPath))
 			fmt.Fprintf(h, "%d", info.ModTime().Unix())
@@ -2,6 +2,7 @@ package graph
 
 import (
 	"math"
+	"sort"
 )
 
 // Node represents a file in the dependency graph
@@ -65,6 +66,42 @@ func (g *Graph) AddEdge(fromPath, toPath string) {
 	g.InEdges[toID] = append(g.InEdges[toID], fromID)
 }
 
+// Dependents returns the given paths plus every file that transitively
+// imports one of them, sorted
+func (g *Graph) Dependents(paths []string) []string {
+	seen := make(map[int64]bool)
+	var queue []int64
+	result := make(map[string]bool)
+
+	for _, p := range paths {
+		result[p] = true
+		if id, ok := g.Paths[p]; ok && !seen[id] {
+			seen[id] = true
+			queue = append(queue, id)
+		}
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, from := range g.InEdges[id] {
+			if seen[from] {
+				continue
+			}
+			seen[from] = true
+			result[g.Nodes[from].Path] = true
+			queue = append(queue, from)
+		}
+	}
+
+	out := make([]string, 0, len(result))
+	for p := range result {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
 // PageRank computes the importance of each node
 // damping: usually 0.85
 // iterations: usually 10-20
@@ -14,6 +14,7 @@ const (
 	Go         Language = "go"
 	TypeScript Language = "typescript"
 	Python     Language = "python"
+	Rust       Language = "rust"
 	Unknown    Language = "unknown"
 )
 
@@ -40,6 +41,10 @@ func DetectLanguage(path string) Language {
 		return Go
 	}
 
+	if fileExists(filepath.Join(absPath, "Cargo.toml")) {
+		return Rust
+	}
+
 	if fileExists(filepath.Join(absPath, "tsconfig.json")) ||
 		fileExists(filepath.Join(absPath, "package.json")) {
 		return TypeScript
@@ -74,6 +79,8 @@ func DetectLanguage(path string) Language {
 			langCounts[TypeScript]++
 		case ".py":
 			langCounts[Python]++
+		case ".rs":
+			langCounts[Rust]++
 		}
 
 		return nil
@@ -109,6 +116,8 @@ func DetectFromFilename(filename string) Language {
 		return TypeScript
 	case ".py":
 		return Python
+	case ".rs":
+		return Rust
 	default:
 		return Unknown
 	}
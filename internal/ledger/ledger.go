@@ -0,0 +1,326 @@
+// Package ledger records the cost of every LLM call in one append-only log
+package ledger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entry is one priced LLM call
+type Entry struct {
+	Time             time.Time `json:"time"`
+	Backend          string    `json:"backend"`
+	Model            string    `json:"model"`
+	Agent            string    `json:"agent,omitempty"`
+	Session          string    `json:"session,omitempty"`
+	Task             string    `json:"task,omitempty"`
+	Repo             string    `json:"repo,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CachedTokens     int       `json:"cached_tokens,omitempty"`
+	Cost             float64   `json:"cost"`
+	PriceSource      string    `json:"price_source"`
+}
+
+// Tokens is the total billed token count
+func (e Entry) Tokens() int {
+	return e.PromptTokens + e.CompletionTokens
+}
+
+// Ledger is a JSON Lines file that entries are only ever appended to
+type Ledger struct {
+	path   string
+	pricer *Pricer
+	mu     sync.Mutex
+}
+
+// Open returns a ledger stored at path, pricing with the default pricer
+func Open(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// EnvPath overrides where the default ledger lives; "off" disables it
+const EnvPath = "GPTCODE_LEDGER"
+
+// DefaultPath is ~/.gptcode/usage.jsonl
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".gptcode", "usage.jsonl")
+	}
+	return filepath.Join(home, ".gptcode", "usage.jsonl")
+}
+
+var (
+	defaultLedger     *Ledger
+	defaultLedgerOnce sync.Once
+)
+
+// Default returns the user's ledger, or nil when recording is off. Tests
+// that reach it point HOME or GPTCODE_LEDGER somewhere temporary first.
+func Default() *Ledger {
+	defaultLedgerOnce.Do(func() {
+		path := os.Getenv(EnvPath)
+		switch path {
+		case "off":
+			return
+		case "":
+			path = DefaultPath()
+		}
+		defaultLedger = Open(path)
+	})
+	return defaultLedger
+}
+
+// Path is where the ledger is stored
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// SetPricer replaces the pricer used by Record
+func (l *Ledger) SetPricer(p *Pricer) {
+	l.pricer = p
+}
+
+// Record prices a call, fills in the agent, session, task and repo from
+// ctx and the environment, and appends it
+func (l *Ledger) Record(ctx context.Context, e Entry) (Entry, error) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	meta := metaFrom(ctx)
+	if e.Agent == "" {
+		e.Agent = meta.agent
+	}
+	if e.Session == "" {
+		e.Session = meta.session
+	}
+	if e.Task == "" {
+		e.Task = meta.task
+	}
+	if e.Repo == "" {
+		e.Repo = currentRepo()
+	}
+	pricer := l.pricer
+	if pricer == nil {
+		pricer = DefaultPricer()
+	}
+	e.Cost, e.PriceSource = pricer.Cost(e.Backend, e.Model, e.PromptTokens, e.CompletionTokens, e.CachedTokens)
+	return e, l.Append(e)
+}
+
+// Append writes an entry as is
+func (l *Ledger) Append(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger entry: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	return nil
+}
+
+// Entries returns the entries recorded at or after since. Lines that don't
+// parse, such as one cut short by a crash, are skipped.
+func (l *Ledger) Entries(since time.Time) ([]Entry, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if !e.Time.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	return entries, nil
+}
+
+// Tail returns the entries on the complete lines after offset and the
+// offset to continue from. A line still being written is left for the next
+// call. When the file is shorter than offset it is read from the start, so
+// a returned offset below the one passed in means earlier entries are gone.
+func (l *Ledger) Tail(offset int64) ([]Entry, int64, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, offset, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, offset, fmt.Errorf("failed to stat ledger: %w", err)
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if info.Size() == offset {
+		return nil, offset, nil
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, fmt.Errorf("failed to seek ledger: %w", err)
+	}
+
+	var entries []Entry
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, offset, fmt.Errorf("failed to read ledger: %w", err)
+		}
+		offset += int64(len(line))
+		var e Entry
+		if json.Unmarshal(line, &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, offset, nil
+}
+
+// Spent sums the cost of entries at or after since that match filter,
+// which may be nil
+func (l *Ledger) Spent(since time.Time, filter func(Entry) bool) (float64, error) {
+	entries, err := l.Entries(since)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, e := range entries {
+		if filter == nil || filter(e) {
+			total += e.Cost
+		}
+	}
+	return total, nil
+}
+
+// Record appends to the default ledger. Recording never fails a call;
+// problems are only reported in debug mode.
+func Record(ctx context.Context, e Entry) {
+	l := Default()
+	if l == nil {
+		return
+	}
+	if _, err := l.Record(ctx, e); err != nil && os.Getenv("GPTCODE_DEBUG") == "1" {
+		fmt.Fprintf(os.Stderr, "[LEDGER] %v\n", err)
+	}
+}
+
+type metaKey struct{}
+
+type meta struct {
+	agent, session, task string
+}
+
+func metaFrom(ctx context.Context) meta {
+	m, _ := ctx.Value(metaKey{}).(meta)
+	if m.session == "" {
+		m.session = SessionID()
+	}
+	return m
+}
+
+func withMeta(ctx context.Context, update func(*meta)) context.Context {
+	m, _ := ctx.Value(metaKey{}).(meta)
+	update(&m)
+	return context.WithValue(ctx, metaKey{}, m)
+}
+
+// Scope returns the session and task calls made with ctx are recorded under
+func Scope(ctx context.Context) (session, task string) {
+	m := metaFrom(ctx)
+	return m.session, m.task
+}
+
+// WithAgent tags calls made with ctx as coming from an agent
+func WithAgent(ctx context.Context, agent string) context.Context {
+	return withMeta(ctx, func(m *meta) { m.agent = agent })
+}
+
+// WithSession groups calls made with ctx under a session ID
+func WithSession(ctx context.Context, session string) context.Context {
+	return withMeta(ctx, func(m *meta) { m.session = session })
+}
+
+// WithTask tags calls made with ctx with the task they serve. Only the
+// first line is kept, cut to 120 characters.
+func WithTask(ctx context.Context, task string) context.Context {
+	task, _, _ = strings.Cut(strings.TrimSpace(task), "\n")
+	if r := []rune(task); len(r) > 120 {
+		task = string(r[:120])
+	}
+	return withMeta(ctx, func(m *meta) { m.task = task })
+}
+
+var (
+	processSession     string
+	processSessionOnce sync.Once
+)
+
+// SessionID identifies this process's calls when no session is set
+func SessionID() string {
+	processSessionOnce.Do(func() { processSession = uuid.New().String() })
+	return processSession
+}
+
+var (
+	repoName     string
+	repoNameOnce sync.Once
+)
+
+// currentRepo names the git repository the process runs in, falling back
+// to the working directory's name
+func currentRepo() string {
+	repoNameOnce.Do(func() {
+		if out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output(); err == nil {
+			repoName = filepath.Base(strings.TrimSpace(string(out)))
+			return
+		}
+		if cwd, err := os.Getwd(); err == nil {
+			repoName = filepath.Base(cwd)
+		}
+	})
+	return repoName
+}
@@ -0,0 +1,179 @@
+package ledger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gptcode/internal/catalog"
+	"gptcode/internal/config"
+)
+
+func testPricer() *Pricer {
+	setup := &config.Setup{Backend: map[string]config.BackendConfig{
+		"work": {Pricing: map[string]config.ModelPricing{
+			"special": {Prompt: 5, Completion: 10},
+			"*":       {Prompt: 1, Completion: 1},
+		}},
+	}}
+	models := &catalog.OutputJSON{}
+	models.OpenRouter.Models = []catalog.ModelOutput{
+		{ID: "openai/gpt-4o-mini", PricingPrompt: 0.15, PricingComp: 0.60},
+		{ID: "openrouter/auto", PricingPrompt: -1000000, PricingComp: -1000000},
+	}
+	return NewPricer(setup, models)
+}
+
+func TestPricerResolution(t *testing.T) {
+	p := testPricer()
+
+	tests := []struct {
+		backend, model string
+		want           Price
+		source         string
+	}{
+		{"work", "special", Price{Prompt: 5, Completion: 10}, SourceOverride},
+		{"work", "anything", Price{Prompt: 1, Completion: 1}, SourceOverride},
+		{"openrouter", "openai/gpt-4o-mini", Price{Prompt: 0.15, Completion: 0.60}, SourceCatalog},
+		{"", "gpt-4o-mini", Price{Prompt: 0.15, Completion: 0.60}, SourceCatalog},
+		{"ollama", "qwen3-coder", Price{}, SourceCatalog},
+		{"openrouter", "some/model:free", Price{}, SourceCatalog},
+	}
+	for _, tt := range tests {
+		got, source := p.PriceFor(tt.backend, tt.model)
+		if got != tt.want || source != tt.source {
+			t.Errorf("PriceFor(%q, %q) = %+v (%s), want %+v (%s)", tt.backend, tt.model, got, source, tt.want, tt.source)
+		}
+	}
+
+	if _, source := p.PriceFor("openrouter", "openrouter/auto"); source != SourceEstimate {
+		t.Errorf("expected router prices to fall back to an estimate, got %s", source)
+	}
+}
+
+func TestPriceCostCountsCachedTokens(t *testing.T) {
+	price := Price{Prompt: 2, Completion: 8, Cached: 0.5}
+	got := price.Cost(1_000_000, 500_000, 400_000)
+	want := 0.6*2 + 0.4*0.5 + 0.5*8
+	if diff := got - want; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("expected %f, got %f", want, got)
+	}
+	if (Price{Prompt: 2}).Cost(1_000_000, 0, 1_000_000) != 2 {
+		t.Error("cached tokens without a cached rate should cost the prompt rate")
+	}
+}
+
+func TestLedgerRecordsAndSummarizes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	l := Open(path)
+	l.SetPricer(testPricer())
+
+	ctx := WithSession(WithAgent(context.Background(), "editor"), "s1")
+	ctx = WithTask(ctx, "fix the add function\nwith more detail below")
+	day1 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+
+	e, err := l.Record(ctx, Entry{Time: day1, Backend: "work", Model: "special", PromptTokens: 100_000, CompletionTokens: 10_000, Repo: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Agent != "editor" || e.Session != "s1" || e.Task != "fix the add function" || e.PriceSource != SourceOverride {
+		t.Errorf("expected metadata from the context, got %+v", e)
+	}
+	if e.Cost != 0.6 {
+		t.Errorf("expected $0.60, got %f", e.Cost)
+	}
+	if _, err := l.Record(WithAgent(ctx, "planner"), Entry{Time: day2, Backend: "work", Model: "other", PromptTokens: 1_000_000, Repo: "web"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A line cut short by a crash doesn't hide the others
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"time":"2025-03-`)
+	f.Close()
+
+	entries, err := l.Entries(day1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if later, _ := l.Entries(day2); len(later) != 1 {
+		t.Errorf("expected since to filter entries, got %d", len(later))
+	}
+	spent, _ := l.Spent(day1, func(e Entry) bool { return e.Repo == "api" })
+	if spent != 0.6 {
+		t.Errorf("expected $0.60 spent in api, got %f", spent)
+	}
+
+	byDay, _ := Summarize(entries, "day")
+	if len(byDay) != 2 || byDay[0].Key != "2025-03-01" || byDay[1].Key != "2025-03-02" {
+		t.Errorf("expected days in order, got %+v", byDay)
+	}
+	byAgent, _ := Summarize(entries, "agent")
+	if byAgent[0].Key != "planner" || byAgent[0].Cost != 1 || byAgent[1].Calls != 1 {
+		t.Errorf("expected agents by cost, got %+v", byAgent)
+	}
+	if _, err := Summarize(entries, "colour"); err == nil || !strings.Contains(err.Error(), "unknown dimension") {
+		t.Errorf("expected an unknown dimension error, got %v", err)
+	}
+}
+
+func TestLedgerTailReadsOnlyNewLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	l := Open(path)
+	if entries, offset, err := l.Tail(0); err != nil || entries != nil || offset != 0 {
+		t.Fatalf("expected nothing from a missing ledger, got %v, %d, %v", entries, offset, err)
+	}
+
+	l.Append(Entry{Model: "a", Cost: 1})
+	entries, offset, err := l.Tail(0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected the first entry, got %v, %v", entries, err)
+	}
+
+	// A line still being written waits for the next call
+	l.Append(Entry{Model: "b", Cost: 2})
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"model":"c",`)
+	entries, offset, _ = l.Tail(offset)
+	if len(entries) != 1 || entries[0].Model != "b" {
+		t.Fatalf("expected only the new complete line, got %+v", entries)
+	}
+	f.WriteString(`"cost":3}` + "\n")
+	f.Close()
+	entries, next, _ := l.Tail(offset)
+	if len(entries) != 1 || entries[0].Model != "c" {
+		t.Fatalf("expected the finished line, got %+v", entries)
+	}
+
+	// A shorter file is read from the start
+	os.WriteFile(path, []byte(`{"model":"d"}`+"\n"), 0644)
+	entries, offset, _ = l.Tail(next)
+	if len(entries) != 1 || entries[0].Model != "d" || offset >= next {
+		t.Errorf("expected a rewritten ledger to be reread, got %+v at %d", entries, offset)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 3, 31, 10, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"30d":        now.AddDate(0, 0, -30),
+		"2w":         now.AddDate(0, 0, -14),
+		"12h":        now.Add(-12 * time.Hour),
+		"2025-03-01": time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for in, want := range tests {
+		got, err := ParseSince(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseSince(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseSince("last week", now); err == nil {
+		t.Error("expected an error for an unparseable window")
+	}
+}
@@ -0,0 +1,155 @@
+package ledger
+
+import (
+	"strings"
+	"sync"
+
+	"gptcode/internal/catalog"
+	"gptcode/internal/config"
+	"gptcode/internal/intelligence"
+)
+
+// Price is what a model charges in USD per million tokens. Cached is the
+// rate for prompt tokens served from the provider's cache; zero means
+// cached tokens cost the same as other prompt tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+	Cached     float64 `json:"cached,omitempty"`
+}
+
+// Cost prices one call. Cached tokens are part of the prompt tokens, as
+// OpenAI-compatible APIs report them.
+func (p Price) Cost(prompt, completion, cached int) float64 {
+	cached = min(cached, prompt)
+	cachedRate := p.Cached
+	if cachedRate == 0 {
+		cachedRate = p.Prompt
+	}
+	return (float64(prompt-cached)*p.Prompt + float64(cached)*cachedRate + float64(completion)*p.Completion) / 1_000_000
+}
+
+// Where a price came from
+const (
+	SourceOverride = "override"
+	SourceCatalog  = "catalog"
+	SourceEstimate = "estimate"
+)
+
+// Pricer resolves model prices. Per-backend overrides from setup.yaml win
+// over the model catalog; models the catalog doesn't price fall back to
+// the intelligence catalog's blended estimate.
+type Pricer struct {
+	overrides map[string]map[string]Price
+	models    *catalog.OutputJSON
+	estimates *intelligence.ModelCatalog
+}
+
+// NewPricer builds a pricer from the setup's pricing overrides and the
+// model catalog. Either may be nil.
+func NewPricer(setup *config.Setup, models *catalog.OutputJSON) *Pricer {
+	p := &Pricer{
+		overrides: map[string]map[string]Price{},
+		models:    models,
+		estimates: intelligence.NewModelCatalog(),
+	}
+	if setup != nil {
+		for backend, cfg := range setup.Backend {
+			for model, mp := range cfg.Pricing {
+				if p.overrides[backend] == nil {
+					p.overrides[backend] = map[string]Price{}
+				}
+				p.overrides[backend][model] = Price{Prompt: mp.Prompt, Completion: mp.Completion, Cached: mp.Cached}
+			}
+		}
+	}
+	return p
+}
+
+var (
+	defaultPricer     *Pricer
+	defaultPricerOnce sync.Once
+)
+
+// DefaultPricer uses the user's setup and model catalog, loaded once
+func DefaultPricer() *Pricer {
+	defaultPricerOnce.Do(func() {
+		setup, _ := config.LoadSetup()
+		models, _ := catalog.Load()
+		defaultPricer = NewPricer(setup, models)
+	})
+	return defaultPricer
+}
+
+// PriceFor returns a model's price and where it came from. backend may be
+// empty when the caller doesn't know it; every catalog backend is searched.
+func (p *Pricer) PriceFor(backend, model string) (Price, string) {
+	if byModel, ok := p.overrides[backend]; ok {
+		if price, ok := byModel[model]; ok {
+			return price, SourceOverride
+		}
+		if price, ok := byModel["*"]; ok {
+			return price, SourceOverride
+		}
+	}
+
+	// Local models and OpenRouter's free variants cost nothing
+	if strings.EqualFold(backend, "ollama") || strings.HasSuffix(model, ":free") {
+		return Price{}, SourceCatalog
+	}
+	if price, ok := p.catalogPrice(backend, model); ok {
+		return price, SourceCatalog
+	}
+
+	info := p.estimates.GetModelInfo(backend, model)
+	return Price{Prompt: info.CostPer1M, Completion: info.CostPer1M}, SourceEstimate
+}
+
+// Cost prices a call and says where the price came from
+func (p *Pricer) Cost(backend, model string, prompt, completion, cached int) (float64, string) {
+	price, source := p.PriceFor(backend, model)
+	return price.Cost(prompt, completion, cached), source
+}
+
+// catalogPrice looks a model up by ID. Catalog IDs may carry a vendor
+// prefix the backend config leaves out ("openai/gpt-4o-mini"), and negative
+// prices mark routers whose price depends on the model picked.
+func (p *Pricer) catalogPrice(backend, model string) (Price, bool) {
+	if p.models == nil || model == "" {
+		return Price{}, false
+	}
+	lists := map[string][]catalog.ModelOutput{
+		"groq":       p.models.Groq.Models,
+		"openrouter": p.models.OpenRouter.Models,
+		"ollama":     p.models.Ollama.Models,
+		"openai":     p.models.OpenAI.Models,
+		"deepseek":   p.models.DeepSeek.Models,
+	}
+	var search [][]catalog.ModelOutput
+	if list, ok := lists[strings.ToLower(backend)]; ok {
+		search = append(search, list)
+	} else {
+		for _, name := range []string{"openrouter", "openai", "groq", "deepseek", "ollama"} {
+			search = append(search, lists[name])
+		}
+	}
+
+	for _, list := range search {
+		for _, m := range list {
+			if m.ID != model && !strings.HasSuffix(m.ID, "/"+model) {
+				continue
+			}
+			if m.PricingPrompt < 0 || m.PricingComp < 0 {
+				return Price{}, false
+			}
+			return Price{Prompt: m.PricingPrompt, Completion: m.PricingComp}, true
+		}
+	}
+	return Price{}, false
+}
+
+// Cost prices a call with the default pricer
+func Cost(backend, model string, prompt, completion, cached int) float64 {
+	cost, _ := DefaultPricer().Cost(backend, model, prompt, completion, cached)
+	return cost
+}
@@ -0,0 +1,111 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dimensions entries can be grouped by
+var Dimensions = []string{"day", "model", "backend", "agent", "repo", "session", "task"}
+
+// Row is the spend of one group of entries
+type Row struct {
+	Key              string  `json:"key"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CachedTokens     int     `json:"cached_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Key returns the value an entry is grouped under for a dimension
+func Key(e Entry, by string) string {
+	var key string
+	switch by {
+	case "day":
+		key = e.Time.Local().Format("2006-01-02")
+	case "model":
+		key = e.Model
+	case "backend":
+		key = e.Backend
+	case "agent":
+		key = e.Agent
+	case "repo":
+		key = e.Repo
+	case "session":
+		key = e.Session
+	case "task":
+		key = e.Task
+	}
+	if key == "" {
+		return "-"
+	}
+	return key
+}
+
+// Summarize groups entries by a dimension. Days are listed in order;
+// everything else is listed most expensive first.
+func Summarize(entries []Entry, by string) ([]Row, error) {
+	if !isDimension(by) {
+		return nil, fmt.Errorf("unknown dimension %q (expected one of %s)", by, strings.Join(Dimensions, ", "))
+	}
+
+	index := map[string]int{}
+	var rows []Row
+	for _, e := range entries {
+		key := Key(e, by)
+		i, ok := index[key]
+		if !ok {
+			i = len(rows)
+			index[key] = i
+			rows = append(rows, Row{Key: key})
+		}
+		r := &rows[i]
+		r.Calls++
+		r.PromptTokens += e.PromptTokens
+		r.CompletionTokens += e.CompletionTokens
+		r.CachedTokens += e.CachedTokens
+		r.Cost += e.Cost
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if by == "day" {
+			return rows[i].Key < rows[j].Key
+		}
+		return rows[i].Cost > rows[j].Cost
+	})
+	return rows, nil
+}
+
+func isDimension(by string) bool {
+	for _, d := range Dimensions {
+		if d == by {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSince reads a relative window such as "30d", "12h" or "2w", or a
+// date in YYYY-MM-DD form, and returns the time it starts at
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	if n, err := strconv.Atoi(s[:max(len(s)-1, 0)]); err == nil && n >= 0 {
+		switch s[len(s)-1] {
+		case 'd':
+			return now.AddDate(0, 0, -n), nil
+		case 'w':
+			return now.AddDate(0, 0, -7*n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (use e.g. 30d, 12h or 2006-01-02)", s)
+}
@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"gptcode/internal/crypto"
+	"gptcode/internal/redact"
+	"gptcode/internal/testreport"
 
 	"github.com/gorilla/websocket"
 )
@@ -246,7 +248,7 @@ func (c *Client) joinChannel() error {
 		"engine":    c.engine,
 		"type":      c.agentType,
 		"context":   c.context,
-		"task":      c.taskDescription,
+		"task":      maskText(c.taskDescription),
 		"hostname":  host["hostname"],
 		"workspace": host["workspace"],
 		"model":     c.model,
@@ -352,7 +354,7 @@ func (c *Client) handleRequestSessions(payload map[string]interface{}) {
 	sessionInfo := map[string]interface{}{
 		"agent_id":  c.agentID,
 		"type":      c.agentType,
-		"task":      c.taskDescription,
+		"task":      maskText(c.taskDescription),
 		"hostname":  hostname,
 		"workspace": workspace,
 		"pid":       os.Getpid(),
@@ -390,9 +392,9 @@ func (c *Client) SendContextUpdate(shared, next, roadmap string) error {
 		topic,
 		"context_update",
 		map[string]interface{}{
-			"shared":  shared,
-			"next":    next,
-			"roadmap": roadmap,
+			"shared":  maskText(shared),
+			"next":    maskText(next),
+			"roadmap": maskText(roadmap),
 		},
 	}
 	c.incrementMsgRef()
@@ -400,6 +402,26 @@ func (c *Client) SendContextUpdate(shared, next, roadmap string) error {
 	return c.conn.WriteJSON(msg)
 }
 
+// maskText masks secrets in content sent to Live. Only what the agent
+// produced goes through it: tokens, keys and ciphertext would be mangled.
+func maskText(s string) string {
+	return redact.Default().Redact(s)
+}
+
+// maskValue masks secrets in a JSON-encodable value sent to Live
+func maskValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	masked := redact.Default().Redact(string(data))
+	var out interface{}
+	if err := json.Unmarshal([]byte(masked), &out); err != nil {
+		return masked
+	}
+	return out
+}
+
 // SendTraceData sends trace data to the Live dashboard
 func (c *Client) SendTraceData(data map[string]interface{}) error {
 	c.mu.Lock()
@@ -411,7 +433,7 @@ func (c *Client) SendTraceData(data map[string]interface{}) error {
 		c.msgRef,
 		topic,
 		"trace_data",
-		data,
+		maskValue(data),
 	}
 	c.incrementMsgRef()
 
@@ -483,7 +505,7 @@ func (c *Client) SendEncrypted(sessionID string, data []byte) error {
 		return fmt.Errorf("encryption not ready")
 	}
 
-	ciphertext, err := c.e2e.Encrypt(data)
+	ciphertext, err := c.e2e.Encrypt([]byte(redact.Default().Redact(string(data))))
 	if err != nil {
 		return err
 	}
@@ -678,12 +700,14 @@ func (c *Client) SendExecutionStep(stepType, description string, metadata map[st
 
 	event := map[string]interface{}{
 		"type":        stepType,
-		"description": description,
+		"description": maskText(description),
 		"timestamp":   time.Now().Format(time.RFC3339),
 	}
 
-	for k, v := range metadata {
-		event[k] = v
+	if masked, ok := maskValue(metadata).(map[string]interface{}); ok {
+		for k, v := range masked {
+			event[k] = v
+		}
 	}
 
 	msg := []interface{}{
@@ -700,6 +724,21 @@ func (c *Client) SendExecutionStep(stepType, description string, metadata map[st
 	return c.conn.WriteJSON(msg)
 }
 
+// SendTestReport sends the outcome of a test run, with the failing tests
+func (c *Client) SendTestReport(report *testreport.TestReport) error {
+	if report == nil {
+		return nil
+	}
+	return c.SendExecutionStep("test_report", report.Summary(), map[string]interface{}{
+		"runner":      report.Runner,
+		"passed":      report.Passed(),
+		"failed":      report.Failed(),
+		"skipped":     report.Skipped(),
+		"duration_ms": report.Duration.Milliseconds(),
+		"failures":    report.Failures(),
+	})
+}
+
 // SendCommandResult sends the result of a dashboard command back to Live
 func (c *Client) SendCommandResult(command string, success bool, message string) error {
 	topic := fmt.Sprintf("agent:%s", c.agentID)
@@ -709,7 +748,7 @@ func (c *Client) SendCommandResult(command string, success bool, message string)
 		map[string]interface{}{
 			"command": command,
 			"success": success,
-			"message": message,
+			"message": maskText(message),
 		},
 	}
 
@@ -1,9 +1,18 @@
 package live
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"gptcode/internal/crypto"
 )
 
 func TestReadWriteContextFile(t *testing.T) {
@@ -140,3 +149,94 @@ func TestFindGPTCodeDir(t *testing.T) {
 		t.Errorf("Found wrong dir: got %s, want %s", found, gptcodeDir)
 	}
 }
+
+// frameRecorder is a Live endpoint that keeps the payload of every frame
+type frameRecorder struct {
+	frames chan []interface{}
+}
+
+func (f *frameRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for {
+		var msg []interface{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		f.frames <- msg
+	}
+}
+
+func (f *frameRecorder) next(t *testing.T, event string) map[string]interface{} {
+	t.Helper()
+	for {
+		select {
+		case msg := <-f.frames:
+			if len(msg) == 5 && msg[3] == event {
+				payload, _ := msg[4].(map[string]interface{})
+				return payload
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s frame received", event)
+		}
+	}
+}
+
+func TestClientMasksContentButNotProtocolFields(t *testing.T) {
+	recorder := &frameRecorder{frames: make(chan []interface{}, 16)}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	token := "lv_8f3K2pQ9zX7mN4vB6cR1tY5wE0aS3dG7hJ2kL9"
+	t.Setenv("GPTCODE_LIVE_TOKEN", token)
+	client := NewClient(server.URL, "agent-1")
+	client.SetTask("deploy with password=hunter2hunter2")
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	join := recorder.next(t, "phx_join")
+	if join["token"] != token {
+		t.Errorf("expected the join token sent unchanged, got %v", join["token"])
+	}
+	if task, _ := join["task"].(string); strings.Contains(task, "hunter2hunter2") {
+		t.Errorf("expected the secret in the task masked, got %q", task)
+	}
+
+	if err := client.EnableEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	exchange := recorder.next(t, "key_exchange")
+	if exchange["public_key"] != client.e2e.PublicKey() {
+		t.Errorf("expected the public key sent unchanged, got %v", exchange["public_key"])
+	}
+
+	browser, err := crypto.NewE2ESession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := browser.SetRemotePublicKey(client.e2e.PublicKey()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetRemotePublicKey(browser.PublicKey()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := client.SendEncrypted("s1", []byte(`{"output":"all good"}`)); err != nil {
+			t.Fatal(err)
+		}
+		ciphertext, _ := recorder.next(t, "encrypted_payload")["data"].(string)
+		plaintext, err := browser.Decrypt(ciphertext)
+		if err != nil {
+			t.Fatalf("expected the ciphertext sent unchanged, got %q: %v", ciphertext, err)
+		}
+		var decoded map[string]string
+		if err := json.Unmarshal(plaintext, &decoded); err != nil || decoded["output"] != "all good" {
+			t.Errorf("unexpected plaintext %q", plaintext)
+		}
+	}
+}
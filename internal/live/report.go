@@ -63,7 +63,7 @@ func (r *ReportConfig) Connect(agentID, agentType, task string) error {
 		"engine":   "gptcode",
 		"type":     agentType,
 		"context":  r.Workspace,
-		"task":     task,
+		"task":     maskText(task),
 		"hostname": r.Hostname,
 		"model":    r.Model,
 	}
@@ -79,7 +79,7 @@ func (r *ReportConfig) Step(description string, stepType string, opts ...map[str
 
 	payload := map[string]interface{}{
 		"agent_id":    r.AgentID,
-		"description": description,
+		"description": maskText(description),
 	}
 
 	if stepType != "" {
@@ -87,8 +87,10 @@ func (r *ReportConfig) Step(description string, stepType string, opts ...map[str
 	}
 
 	if len(opts) > 0 && opts[0] != nil {
-		for k, v := range opts[0] {
-			payload[k] = v
+		if masked, ok := maskValue(opts[0]).(map[string]interface{}); ok {
+			for k, v := range masked {
+				payload[k] = v
+			}
 		}
 	}
 
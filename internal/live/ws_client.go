@@ -171,7 +171,7 @@ func (w *WsClient) wsConnect() error {
 		"engine":   "gptcode",
 		"type":     w.agentType,
 		"context":  w.workspace,
-		"task":     w.task,
+		"task":     maskText(w.task),
 		"hostname": w.hostname,
 		"model":    w.model,
 	}
@@ -346,7 +346,7 @@ func (w *WsClient) Step(description string, stepType string) error {
 
 	payload := map[string]interface{}{
 		"agent_id":    w.agentID,
-		"description": description,
+		"description": maskText(description),
 		"step_type":   stepType,
 		"timestamp":   time.Now().Unix(),
 	}
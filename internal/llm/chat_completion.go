@@ -7,16 +7,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"gptcode/internal/budget"
 	"gptcode/internal/config"
+	"gptcode/internal/ledger"
+	"gptcode/internal/telemetry"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 type ChatCompletionProvider struct {
 	APIKey  string
 	BaseURL string
+	// Backend names the configured backend in the cost ledger
+	Backend string
 }
 
 func NewChatCompletion(baseURL, backendName string) *ChatCompletionProvider {
@@ -32,6 +38,7 @@ func NewChatCompletion(baseURL, backendName string) *ChatCompletionProvider {
 	return &ChatCompletionProvider{
 		APIKey:  apiKey,
 		BaseURL: baseURL,
+		Backend: backendName,
 	}
 }
 
@@ -122,6 +129,9 @@ type chatCompletionResponse struct {
 		PromptTokens     int `json:"prompt_tokens"`
 		CompletionTokens int `json:"completion_tokens"`
 		TotalTokens      int `json:"total_tokens"`
+		PromptDetails    *struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
 	} `json:"usage"`
 	Error *struct {
 		Message string `json:"message"`
@@ -129,6 +139,15 @@ type chatCompletionResponse struct {
 }
 
 func (c *ChatCompletionProvider) ChatStream(ctx context.Context, req ChatRequest, callback func(chunk string)) error {
+	ctx, span := telemetry.StartLLMCall(ctx, c.Backend, req.Model)
+	start := time.Now()
+	err := c.chatStream(ctx, req, callback)
+	telemetry.ObserveLLMRequest(c.Backend, req.Model, time.Since(start), err)
+	telemetry.End(span, err)
+	return err
+}
+
+func (c *ChatCompletionProvider) chatStream(ctx context.Context, req ChatRequest, callback func(chunk string)) error {
 	if c.APIKey == "" {
 		return errors.New(`API key not defined for this backend
 
@@ -145,6 +164,13 @@ Or use local models with Ollama:
 2. Run: gt setup`)
 	}
 
+	model, err := budget.Preflight(ctx, c.Backend, req.Model, EstimatePromptTokens(req), EstimateCompletionTokens)
+	if err != nil {
+		return err
+	}
+	req.Model = model
+	req = redactRequest(req)
+
 	messages := []chatCompletionMsg{
 		{Role: "system", Content: req.SystemPrompt},
 	}
@@ -248,6 +274,15 @@ Or use local models with Ollama:
 }
 
 func (c *ChatCompletionProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	ctx, span := telemetry.StartLLMCall(ctx, c.Backend, req.Model)
+	start := time.Now()
+	resp, err := c.chat(ctx, req)
+	telemetry.ObserveLLMRequest(c.Backend, req.Model, time.Since(start), err)
+	telemetry.End(span, err)
+	return resp, err
+}
+
+func (c *ChatCompletionProvider) chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
 	if c.APIKey == "" {
 		return nil, errors.New(`API key not defined for this backend
 
@@ -264,6 +299,13 @@ Or use local models with Ollama:
 2. Run: gt setup`)
 	}
 
+	model, err := budget.Preflight(ctx, c.Backend, req.Model, EstimatePromptTokens(req), EstimateCompletionTokens)
+	if err != nil {
+		return nil, err
+	}
+	req.Model = model
+	req = redactRequest(req)
+
 	messages := []chatCompletionMsg{
 		{Role: "system", Content: req.SystemPrompt},
 	}
@@ -373,10 +415,12 @@ Or use local models with Ollama:
 		}{Error: &errorDetail}); err == nil && errorDetail.FailedGeneration != "" {
 			parsedCalls := ParseToolCallsFromText(errorDetail.FailedGeneration)
 			if len(parsedCalls) > 0 {
-				return &ChatResponse{
+				response := &ChatResponse{
 					Text:      "",
 					ToolCalls: parsedCalls,
-				}, nil
+				}
+				restoreResponse(response)
+				return response, nil
 			}
 		}
 		return nil, fmt.Errorf("API error: %s", apiResp.Error.Message)
@@ -408,7 +452,19 @@ Or use local models with Ollama:
 			CompletionTokens: apiResp.Usage.CompletionTokens,
 			TotalTokens:      apiResp.Usage.TotalTokens,
 		}
+		if apiResp.Usage.PromptDetails != nil {
+			response.TokenUsage.CachedTokens = apiResp.Usage.PromptDetails.CachedTokens
+		}
+		ledger.Record(ctx, ledger.Entry{
+			Backend:          c.Backend,
+			Model:            req.Model,
+			PromptTokens:     response.TokenUsage.PromptTokens,
+			CompletionTokens: response.TokenUsage.CompletionTokens,
+			CachedTokens:     response.TokenUsage.CachedTokens,
+		})
+		telemetry.RecordLLMUsage(ctx, c.Backend, req.Model, response.TokenUsage.PromptTokens, response.TokenUsage.CompletionTokens, response.TokenUsage.CachedTokens)
 	}
 
+	restoreResponse(response)
 	return response, nil
 }
@@ -11,6 +11,9 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"gptcode/internal/ledger"
+	"gptcode/internal/telemetry"
 )
 
 type OllamaProvider struct {
@@ -45,6 +48,8 @@ type ollamaResp struct {
 		Content   string           `json:"content"`
 		ToolCalls []ollamaToolCall `json:"tool_calls"`
 	} `json:"message"`
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
 }
 
 type ollamaToolCall struct {
@@ -57,6 +62,16 @@ type ollamaToolCall struct {
 }
 
 func (o *OllamaProvider) ChatStream(ctx context.Context, req ChatRequest, callback func(chunk string)) error {
+	ctx, span := telemetry.StartLLMCall(ctx, "ollama", req.Model)
+	start := time.Now()
+	err := o.chatStream(ctx, req, callback)
+	telemetry.ObserveLLMRequest("ollama", req.Model, time.Since(start), err)
+	telemetry.End(span, err)
+	return err
+}
+
+func (o *OllamaProvider) chatStream(ctx context.Context, req ChatRequest, callback func(chunk string)) error {
+	req = redactRequest(req)
 	messages := []ollamaMessage{
 		{Role: "system", Content: req.SystemPrompt},
 	}
@@ -110,6 +125,16 @@ func (o *OllamaProvider) ChatStream(ctx context.Context, req ChatRequest, callba
 }
 
 func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	ctx, span := telemetry.StartLLMCall(ctx, "ollama", req.Model)
+	start := time.Now()
+	resp, err := o.chat(ctx, req)
+	telemetry.ObserveLLMRequest("ollama", req.Model, time.Since(start), err)
+	telemetry.End(span, err)
+	return resp, err
+}
+
+func (o *OllamaProvider) chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	req = redactRequest(req)
 	messages := []ollamaMessage{
 		{Role: "system", Content: req.SystemPrompt},
 	}
@@ -166,6 +191,20 @@ func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 	response := &ChatResponse{
 		Text: or.Message.Content,
 	}
+	if or.PromptEvalCount > 0 || or.EvalCount > 0 {
+		response.TokenUsage = &TokenUsage{
+			PromptTokens:     or.PromptEvalCount,
+			CompletionTokens: or.EvalCount,
+			TotalTokens:      or.PromptEvalCount + or.EvalCount,
+		}
+		ledger.Record(ctx, ledger.Entry{
+			Backend:          "ollama",
+			Model:            req.Model,
+			PromptTokens:     or.PromptEvalCount,
+			CompletionTokens: or.EvalCount,
+		})
+		telemetry.RecordLLMUsage(ctx, "ollama", req.Model, or.PromptEvalCount, or.EvalCount, 0)
+	}
 
 	if len(or.Message.ToolCalls) > 0 {
 		response.ToolCalls = make([]ChatToolCall, len(or.Message.ToolCalls))
@@ -191,6 +230,7 @@ func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 		}
 	}
 
+	restoreResponse(response)
 	return response, nil
 }
 
@@ -173,7 +173,7 @@ func (o *OrchestratorProvider) Chat(ctx context.Context, req ChatRequest) (*Chat
 						Name:      tc.Name,
 						Arguments: argsMap,
 					}
-					result := tools.ExecuteTool(toolCall, cwd)
+					result := tools.ExecuteToolContext(ctx, toolCall, cwd)
 					if result.Error != "" {
 						toolResult = fmt.Sprintf("Error: %s", result.Error)
 					} else {
@@ -1,6 +1,11 @@
 package llm
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+
+	"gptcode/internal/redact"
+)
 
 type Provider interface {
 	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
@@ -33,6 +38,8 @@ type TokenUsage struct {
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
+	// CachedTokens are prompt tokens served from the provider's cache
+	CachedTokens int
 }
 
 type ChatToolCall struct {
@@ -40,3 +47,60 @@ type ChatToolCall struct {
 	Name      string
 	Arguments string
 }
+
+// EstimateCompletionTokens is the completion size assumed when a request
+// is priced before it is sent
+const EstimateCompletionTokens = 1024
+
+// EstimatePromptTokens roughly counts a request's prompt tokens at four
+// characters per token
+func EstimatePromptTokens(req ChatRequest) int {
+	chars := len(req.SystemPrompt) + len(req.UserPrompt)
+	for _, msg := range req.Messages {
+		chars += len(msg.Content)
+		for _, tc := range msg.ToolCalls {
+			chars += len(tc.Name) + len(tc.Arguments)
+		}
+	}
+	if len(req.Tools) > 0 {
+		if data, err := json.Marshal(req.Tools); err == nil {
+			chars += len(data)
+		}
+	}
+	return chars / 4
+}
+
+// redactRequest masks secrets in everything a request sends
+func redactRequest(req ChatRequest) ChatRequest {
+	r := redact.Default()
+	if r == nil {
+		return req
+	}
+	req.SystemPrompt = r.Redact(req.SystemPrompt)
+	req.UserPrompt = r.Redact(req.UserPrompt)
+	messages := make([]ChatMessage, len(req.Messages))
+	for i, msg := range req.Messages {
+		msg.Content = r.Redact(msg.Content)
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]ChatToolCall, len(msg.ToolCalls))
+			for j, tc := range msg.ToolCalls {
+				tc.Arguments = r.Redact(tc.Arguments)
+				calls[j] = tc
+			}
+			msg.ToolCalls = calls
+		}
+		messages[i] = msg
+	}
+	req.Messages = messages
+	return req
+}
+
+// restoreResponse swaps placeholders the model wrote back for the secrets
+// they stand for
+func restoreResponse(resp *ChatResponse) {
+	r := redact.Default()
+	resp.Text = r.Restore(resp.Text)
+	for i := range resp.ToolCalls {
+		resp.ToolCalls[i].Arguments = r.RestoreJSON(resp.ToolCalls[i].Arguments)
+	}
+}
@@ -0,0 +1,432 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReplayMode selects whether a ReplayProvider talks to the real model
+type ReplayMode string
+
+const (
+	// ReplayRecord forwards requests to the wrapped provider and writes
+	// each request/response pair to a cassette
+	ReplayRecord ReplayMode = "record"
+	// ReplayReplay serves responses from cassettes without any network
+	ReplayReplay ReplayMode = "replay"
+)
+
+// ErrCassetteMiss is returned in replay mode for a request that was never
+// recorded
+var ErrCassetteMiss = errors.New("no recorded response for request")
+
+// Environment variables read by ReplayFromEnv
+const (
+	EnvCassetteDir  = "GPTCODE_CASSETTES"
+	EnvCassetteMode = "GPTCODE_CASSETTE_MODE"
+)
+
+// cassette holds the responses recorded for one normalized request. A
+// request sent several times, as happens in agent loops, keeps one response
+// per call in order.
+type cassette struct {
+	Key       string             `json:"key"`
+	Request   cassetteRequest    `json:"request"`
+	Responses []cassetteResponse `json:"responses"`
+}
+
+type cassetteRequest struct {
+	Model    string            `json:"model,omitempty"`
+	Messages []cassetteMessage `json:"messages"`
+	Tools    json.RawMessage   `json:"tools,omitempty"`
+}
+
+type cassetteMessage struct {
+	Role       string             `json:"role"`
+	Content    string             `json:"content"`
+	Name       string             `json:"name,omitempty"`
+	ToolCallID string             `json:"tool_call_id,omitempty"`
+	ToolCalls  []cassetteToolCall `json:"tool_calls,omitempty"`
+}
+
+type cassetteToolCall struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type cassetteResponse struct {
+	Text       string             `json:"text"`
+	ToolCalls  []cassetteToolCall `json:"tool_calls,omitempty"`
+	TokenUsage *TokenUsage        `json:"token_usage,omitempty"`
+}
+
+// Divergence describes a replayed request that didn't match the recording
+type Divergence struct {
+	Key    string
+	Reason string
+}
+
+// ReplayProvider wraps a provider to record its interactions to cassette
+// files, or to serve them back offline. Cassettes are keyed by a hash of
+// the normalized request, so tests replay deterministically as long as the
+// prompts they send don't change; when they do, the mismatch is reported
+// as a divergence.
+type ReplayProvider struct {
+	Inner Provider
+	Dir   string
+	Mode  ReplayMode
+	// Substitutions maps volatile strings, such as a test's temp directory,
+	// to stable placeholders. They are replaced before hashing and saving,
+	// and restored in replayed responses.
+	Substitutions map[string]string
+
+	state *cassetteState
+}
+
+// cassetteState is what a session has loaded, recorded and served from a
+// cassette directory. Providers created for each phase of a run share it,
+// so a request repeated across phases gets the next recorded response.
+type cassetteState struct {
+	mu          sync.Mutex
+	cassettes   map[string]*cassette
+	served      map[string]int
+	divergences []Divergence
+}
+
+var (
+	sharedStatesMu sync.Mutex
+	sharedStates   = map[string]*cassetteState{}
+)
+
+// NewReplayProvider wraps inner with cassettes stored in dir. inner may be
+// nil in replay mode. Each provider it returns keeps its own session; use
+// ReplayFromEnv to share one between providers.
+func NewReplayProvider(inner Provider, dir string, mode ReplayMode) *ReplayProvider {
+	return &ReplayProvider{
+		Inner:         inner,
+		Dir:           dir,
+		Mode:          mode,
+		Substitutions: map[string]string{},
+		state:         &cassetteState{served: map[string]int{}},
+	}
+}
+
+// ReplayFromEnv wraps p when GPTCODE_CASSETTES names a cassette directory.
+// GPTCODE_CASSETTE_MODE picks record or replay; replay is the default so CI
+// never reaches the network by accident. Every provider wrapped for the
+// same directory and mode shares one session, since commands create a
+// provider per phase or per attempt.
+func ReplayFromEnv(p Provider) Provider {
+	dir := os.Getenv(EnvCassetteDir)
+	if dir == "" {
+		return p
+	}
+	mode := ReplayMode(os.Getenv(EnvCassetteMode))
+	if mode != ReplayRecord {
+		mode = ReplayReplay
+	}
+	r := NewReplayProvider(p, dir, mode)
+	r.state = sharedState(dir, mode)
+	return r
+}
+
+// sharedState returns the session of a cassette directory and mode
+func sharedState(dir string, mode ReplayMode) *cassetteState {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	key := string(mode) + ":" + dir
+
+	sharedStatesMu.Lock()
+	defer sharedStatesMu.Unlock()
+	s := sharedStates[key]
+	if s == nil {
+		s = &cassetteState{served: map[string]int{}}
+		sharedStates[key] = s
+	}
+	return s
+}
+
+func (r *ReplayProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	normalized := r.normalizeRequest(req)
+	key := cassetteKey(normalized)
+
+	if r.Mode == ReplayRecord {
+		return r.record(ctx, req, key, normalized)
+	}
+	return r.replay(key, normalized)
+}
+
+func (r *ReplayProvider) record(ctx context.Context, req ChatRequest, key string, normalized cassetteRequest) (*ChatResponse, error) {
+	if r.Inner == nil {
+		return nil, fmt.Errorf("record mode needs a provider to record from")
+	}
+	resp, err := r.Inner.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s := r.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cassettes == nil {
+		s.cassettes = map[string]*cassette{}
+	}
+	// A recording session replaces what earlier sessions wrote for a key
+	c := s.cassettes[key]
+	if c == nil {
+		c = &cassette{Key: key, Request: normalized}
+		s.cassettes[key] = c
+	}
+	c.Responses = append(c.Responses, cassetteResponse{
+		Text:       r.substitute(resp.Text),
+		ToolCalls:  r.substituteCalls(resp.ToolCalls),
+		TokenUsage: resp.TokenUsage,
+	})
+	s.served[key]++
+	if err := r.save(c); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *ReplayProvider) replay(key string, normalized cassetteRequest) (*ChatResponse, error) {
+	s := r.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	c := s.cassettes[key]
+	if c == nil || len(c.Responses) == 0 {
+		reason := r.closestMismatch(normalized)
+		s.divergences = append(s.divergences, Divergence{Key: key, Reason: reason})
+		return nil, fmt.Errorf("%w (%s): %s", ErrCassetteMiss, key, reason)
+	}
+
+	n := s.served[key]
+	s.served[key] = n + 1
+	if n >= len(c.Responses) {
+		// Agent loops may ask once more than they did when recorded; keep
+		// answering, but say so
+		s.divergences = append(s.divergences, Divergence{
+			Key:    key,
+			Reason: fmt.Sprintf("request sent %d times, recorded %d", n+1, len(c.Responses)),
+		})
+		n = len(c.Responses) - 1
+	}
+
+	rec := c.Responses[n]
+	resp := &ChatResponse{Text: r.restore(rec.Text), TokenUsage: rec.TokenUsage}
+	for _, tc := range rec.ToolCalls {
+		resp.ToolCalls = append(resp.ToolCalls, ChatToolCall{ID: tc.ID, Name: tc.Name, Arguments: r.restore(tc.Arguments)})
+	}
+	return resp, nil
+}
+
+// Divergences returns the replayed requests that didn't match a recording
+func (r *ReplayProvider) Divergences() []Divergence {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	return append([]Divergence(nil), r.state.divergences...)
+}
+
+// Unused returns the keys of recordings that were never fully replayed,
+// which means the code under test made fewer calls than when recorded
+func (r *ReplayProvider) Unused() []string {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	var keys []string
+	for key, c := range r.state.cassettes {
+		if r.state.served[key] < len(c.Responses) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *ReplayProvider) load() error {
+	if r.state.cassettes != nil {
+		return nil
+	}
+	r.state.cassettes = map[string]*cassette{}
+	paths, err := filepath.Glob(filepath.Join(r.Dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list cassettes: %w", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read cassette: %w", err)
+		}
+		var c cassette
+		if err := json.Unmarshal(data, &c); err != nil {
+			return fmt.Errorf("failed to parse cassette %s: %w", filepath.Base(path), err)
+		}
+		r.state.cassettes[c.Key] = &c
+	}
+	return nil
+}
+
+func (r *ReplayProvider) save(c *cassette) error {
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, c.Key+".json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// normalizeRequest flattens the system and user prompts into the message
+// list and cleans up text that varies between otherwise identical runs.
+// Intent only steers loop detection and isn't part of the key.
+func (r *ReplayProvider) normalizeRequest(req ChatRequest) cassetteRequest {
+	n := cassetteRequest{Model: req.Model}
+	if req.SystemPrompt != "" {
+		n.Messages = append(n.Messages, cassetteMessage{Role: "system", Content: r.normalizeText(req.SystemPrompt)})
+	}
+	for _, m := range req.Messages {
+		n.Messages = append(n.Messages, cassetteMessage{
+			Role:       m.Role,
+			Content:    r.normalizeText(m.Content),
+			Name:       m.Name,
+			ToolCallID: m.ToolCallID,
+			ToolCalls:  r.substituteCalls(m.ToolCalls),
+		})
+	}
+	if req.UserPrompt != "" {
+		n.Messages = append(n.Messages, cassetteMessage{Role: "user", Content: r.normalizeText(req.UserPrompt)})
+	}
+	if len(req.Tools) > 0 {
+		if data, err := json.Marshal(req.Tools); err == nil {
+			n.Tools = data
+		}
+	}
+	return n
+}
+
+// normalizeText applies substitutions, unifies line endings and drops
+// trailing whitespace
+func (r *ReplayProvider) normalizeText(s string) string {
+	s = strings.ReplaceAll(r.substitute(s), "\r\n", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// substitute replaces volatile values with placeholders, longest first so
+// a path isn't cut by a substitution for its parent
+func (r *ReplayProvider) substitute(s string) string {
+	values := make([]string, 0, len(r.Substitutions))
+	for value := range r.Substitutions {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, r.Substitutions[value])
+	}
+	return s
+}
+
+func (r *ReplayProvider) restore(s string) string {
+	for value, placeholder := range r.Substitutions {
+		if value != "" && placeholder != "" {
+			s = strings.ReplaceAll(s, placeholder, value)
+		}
+	}
+	return s
+}
+
+func (r *ReplayProvider) substituteCalls(calls []ChatToolCall) []cassetteToolCall {
+	var out []cassetteToolCall
+	for _, tc := range calls {
+		out = append(out, cassetteToolCall{ID: tc.ID, Name: tc.Name, Arguments: r.substitute(tc.Arguments)})
+	}
+	return out
+}
+
+// closestMismatch explains a cassette miss against the recording that
+// shares the longest run of leading messages with the request
+func (r *ReplayProvider) closestMismatch(req cassetteRequest) string {
+	var best *cassette
+	bestShared := -1
+	for _, c := range r.state.cassettes {
+		shared := 0
+		for shared < len(req.Messages) && shared < len(c.Request.Messages) && messagesEqual(req.Messages[shared], c.Request.Messages[shared]) {
+			shared++
+		}
+		if shared > bestShared || (shared == bestShared && best != nil && c.Key < best.Key) {
+			best, bestShared = c, shared
+		}
+	}
+	if best == nil {
+		return fmt.Sprintf("no cassettes in %s", r.Dir)
+	}
+
+	rec := best.Request
+	switch {
+	case rec.Model != req.Model:
+		return fmt.Sprintf("closest recording %s used model %q, got %q", best.Key, rec.Model, req.Model)
+	case bestShared < len(req.Messages) && bestShared < len(rec.Messages):
+		got, want := req.Messages[bestShared], rec.Messages[bestShared]
+		return fmt.Sprintf("closest recording %s differs at message %d (%s): recorded %q, got %q",
+			best.Key, bestShared, got.Role, firstDifference(want.Content, got.Content), firstDifference(got.Content, want.Content))
+	case len(rec.Messages) != len(req.Messages):
+		return fmt.Sprintf("closest recording %s has %d messages, got %d", best.Key, len(rec.Messages), len(req.Messages))
+	default:
+		return fmt.Sprintf("closest recording %s offered different tools", best.Key)
+	}
+}
+
+func messagesEqual(a, b cassetteMessage) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+// firstDifference returns a short excerpt of a starting where it stops
+// matching b
+func firstDifference(a, b string) string {
+	ra, rb := []rune(a), []rune(b)
+	i := 0
+	for i < len(ra) && i < len(rb) && ra[i] == rb[i] {
+		i++
+	}
+	start := max(0, i-20)
+	end := min(len(ra), i+60)
+	excerpt := string(ra[start:end])
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(ra) {
+		excerpt += "…"
+	}
+	return excerpt
+}
+
+func cassetteKey(req cassetteRequest) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
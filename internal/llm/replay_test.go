@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type scriptedProvider struct {
+	responses []ChatResponse
+	calls     int
+}
+
+func (p *scriptedProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp := p.responses[p.calls%len(p.responses)]
+	p.calls++
+	return &resp, nil
+}
+
+func TestReplayProviderRecordsAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	inner := &scriptedProvider{responses: []ChatResponse{
+		{ToolCalls: []ChatToolCall{{ID: "call_1", Name: "read_file", Arguments: `{"path":"/tmp/run1/main.go"}`}}},
+		{Text: "done", TokenUsage: &TokenUsage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}},
+	}}
+
+	req := ChatRequest{
+		SystemPrompt: "You edit code in /tmp/run1",
+		Model:        "m",
+		Messages:     []ChatMessage{{Role: "user", Content: "fix main.go  \r\n"}},
+		Tools:        []interface{}{map[string]interface{}{"name": "read_file"}},
+	}
+
+	rec := NewReplayProvider(inner, dir, ReplayRecord)
+	rec.Substitutions["/tmp/run1"] = "{{workdir}}"
+	for i := 0; i < 2; i++ {
+		if _, err := rec.Chat(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A later run in another directory sends the same normalized request
+	replay := NewReplayProvider(nil, dir, ReplayReplay)
+	replay.Substitutions["/tmp/run2"] = "{{workdir}}"
+	req.SystemPrompt = "You edit code in /tmp/run2"
+	req.Messages[0].Content = "fix main.go"
+
+	first, err := replay.Chat(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.ToolCalls) != 1 || first.ToolCalls[0].Arguments != `{"path":"/tmp/run2/main.go"}` || first.ToolCalls[0].ID != "call_1" {
+		t.Errorf("unexpected replayed tool call: %+v", first.ToolCalls)
+	}
+	second, err := replay.Chat(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Text != "done" || second.TokenUsage == nil || second.TokenUsage.TotalTokens != 12 {
+		t.Errorf("expected recorded responses in order, got %+v", second)
+	}
+	if len(replay.Divergences()) != 0 || len(replay.Unused()) != 0 {
+		t.Errorf("expected a clean replay, got %v unused %v", replay.Divergences(), replay.Unused())
+	}
+
+	// One call more than recorded keeps answering but is flagged
+	if _, err := replay.Chat(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if d := replay.Divergences(); len(d) != 1 || !strings.Contains(d[0].Reason, "sent 3 times, recorded 2") {
+		t.Errorf("expected repeated request to diverge, got %+v", d)
+	}
+}
+
+func TestReplayProviderFlagsChangedPrompts(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	req := ChatRequest{
+		SystemPrompt: "system",
+		Model:        "m",
+		Messages:     []ChatMessage{{Role: "user", Content: "add a retry to the HTTP client"}},
+	}
+	rec := NewReplayProvider(&scriptedProvider{responses: []ChatResponse{{Text: "ok"}}}, dir, ReplayRecord)
+	if _, err := rec.Chat(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := NewReplayProvider(nil, dir, ReplayReplay)
+	req.Messages[0].Content = "add a timeout to the HTTP client"
+	_, err := replay.Chat(ctx, req)
+	if !errors.Is(err, ErrCassetteMiss) {
+		t.Fatalf("expected a cassette miss, got %v", err)
+	}
+	d := replay.Divergences()
+	if len(d) != 1 || !strings.Contains(d[0].Reason, "differs at message 1 (user)") || !strings.Contains(d[0].Reason, "timeout") {
+		t.Errorf("expected divergence to point at the changed message, got %+v", d)
+	}
+	if len(replay.Unused()) != 1 {
+		t.Errorf("expected the original recording to be reported unused")
+	}
+}
+
+func TestReplayFromEnv(t *testing.T) {
+	inner := &scriptedProvider{responses: []ChatResponse{{Text: "live"}}}
+	t.Setenv(EnvCassetteDir, "")
+	if ReplayFromEnv(inner) != Provider(inner) {
+		t.Error("expected provider to be left alone without a cassette directory")
+	}
+
+	t.Setenv(EnvCassetteDir, t.TempDir())
+	t.Setenv(EnvCassetteMode, "")
+	p, ok := ReplayFromEnv(inner).(*ReplayProvider)
+	if !ok || p.Mode != ReplayReplay {
+		t.Fatalf("expected replay mode by default, got %+v", p)
+	}
+	if _, err := p.Chat(context.Background(), ChatRequest{UserPrompt: "hi"}); !errors.Is(err, ErrCassetteMiss) || inner.calls != 0 {
+		t.Errorf("replay must never reach the wrapped provider, got %v after %d calls", err, inner.calls)
+	}
+}
+
+func TestReplayFromEnvSharesSessionPerDirectory(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	req := ChatRequest{Model: "m", UserPrompt: "review the change"}
+	rec := NewReplayProvider(&scriptedProvider{responses: []ChatResponse{{Text: "FAIL"}, {Text: "SUCCESS"}}}, dir, ReplayRecord)
+	for i := 0; i < 2; i++ {
+		if _, err := rec.Chat(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Commands create a provider per phase; the second must continue where
+	// the first stopped
+	t.Setenv(EnvCassetteDir, dir)
+	t.Setenv(EnvCassetteMode, "")
+	first, _ := ReplayFromEnv(nil).Chat(ctx, req)
+	second, _ := ReplayFromEnv(nil).Chat(ctx, req)
+	if first == nil || second == nil || first.Text != "FAIL" || second.Text != "SUCCESS" {
+		t.Fatalf("expected the recorded responses in order across providers, got %+v and %+v", first, second)
+	}
+	if unused := ReplayFromEnv(nil).(*ReplayProvider).Unused(); len(unused) != 0 {
+		t.Errorf("expected every recording served, got %v", unused)
+	}
+}
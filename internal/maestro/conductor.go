@@ -11,10 +11,13 @@ import (
 
 	"gptcode/internal/agents"
 	"gptcode/internal/config"
+	"gptcode/internal/events"
 	"gptcode/internal/feedback"
+	"gptcode/internal/ledger"
 	"gptcode/internal/live"
 	"gptcode/internal/llm"
 	"gptcode/internal/observability"
+	"gptcode/internal/telemetry"
 )
 
 // ProgressCallback is called during execution to report progress
@@ -83,13 +86,15 @@ func (c *Conductor) SetProgressCallback(callback ProgressCallback) {
 	c.progressCallback = callback
 }
 
-// ReportProgress sends progress to Live Dashboard via HTTP and WebSocket, and calls progress callback
+// ReportProgress sends progress to Live Dashboard via HTTP and WebSocket, headless output, and calls progress callback
 func (c *Conductor) ReportProgress(phase string, details string) {
+	events.PublishStep(events.Step{Title: phase, Status: "running", Detail: details})
 	c.sendProgress(phase, details)
 }
 
-// ReportError sends error to Live Dashboard
+// ReportError sends error to Live Dashboard and headless output
 func (c *Conductor) ReportError(phase string, errMsg string) {
+	events.PublishStep(events.Step{Title: phase, Status: "failed", Detail: errMsg})
 	c.sendError(phase, errMsg)
 }
 
@@ -220,6 +225,8 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 
 	// Begin tracing session
 	sessionID := uuid.New().String()
+	ctx = ledger.WithSession(ctx, sessionID)
+	defer telemetry.TrackSession()()
 	if c.Tracer != nil {
 		_ = c.Tracer.Begin(sessionID, task)
 		defer func() { _ = c.Tracer.End(true) }() // End with success status (will be updated on error)
@@ -326,7 +333,7 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 
 		// Create editor with selected model and observer
 		editProvider := c.createProvider(editBackend)
-		editor := agents.NewEditorWithObserver(editProvider, c.cwd, editModel, c.Observer)
+		editor := agents.NewEditorWithObserver(editProvider, c.cwd, editModel, observability.NewTracingObserver(c.Observer, c.Tracer))
 
 		// Execute with editor
 		fmt.Println("Executing changes...")
@@ -489,6 +496,7 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 		if !review.Success {
 			// LoopDetector will handle max iterations check on next iteration
 			issuesStr := strings.Join(review.Issues, "\n")
+			telemetry.RecordVerification(false, string(ClassifyError(issuesStr)))
 			fmt.Printf("[WARNING] Validation failed:\n%s\n", issuesStr)
 
 			// Use enhanced recovery system
@@ -527,6 +535,8 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 			continue
 		}
 
+		telemetry.RecordVerification(true, "")
+
 		// Record validation success metrics
 		if c.Tracer != nil {
 			metrics := observability.Metrics{
@@ -611,6 +621,7 @@ func (c *Conductor) createProvider(backendName string) llm.Provider {
 	} else {
 		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
 	}
+	provider = llm.ReplayFromEnv(provider)
 
 	return &trackingProvider{inner: provider, conductor: c}
 }
@@ -0,0 +1,147 @@
+package maestro
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gptcode/internal/config"
+	"gptcode/internal/llm"
+)
+
+// fakeChatServer answers OpenAI-style chat requests for the planner, editor
+// and reviewer. The first review fails, so the conductor retries and sends
+// the same review request a second time.
+type fakeChatServer struct {
+	mu      sync.Mutex
+	reviews int
+	calls   int
+}
+
+func (f *fakeChatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+		Tools []json.RawMessage `json:"tools"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	message := map[string]interface{}{"role": "assistant"}
+	last := req.Messages[len(req.Messages)-1]
+	switch {
+	case len(req.Messages) > 1 && strings.HasPrefix(req.Messages[1].Content, "Validate if the implementation"):
+		f.reviews++
+		message["content"] = "SUCCESS"
+		if f.reviews == 1 {
+			message["content"] = "FAIL\n- greet.go must declare package greet"
+		}
+	case len(req.Tools) > 0 && last.Role == "tool":
+		message["content"] = "Created greet.go"
+	case len(req.Tools) > 0:
+		message["content"] = ""
+		message["tool_calls"] = []map[string]interface{}{{
+			"id":   "call_1",
+			"type": "function",
+			"function": map[string]string{
+				"name":      "write_file",
+				"arguments": `{"path":"greet.go","content":"package greet\n"}`,
+			},
+		}}
+	default:
+		message["content"] = "## Plan\n\nCreate greet.go declaring package greet.\n\n## Success Criteria\n- greet.go exists"
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []map[string]interface{}{{"message": message}},
+	})
+}
+
+func newReplayConductor(t *testing.T, baseURL, cwd string) *Conductor {
+	t.Helper()
+	setup := &config.Setup{}
+	setup.Defaults.Backend = "fake"
+	setup.Defaults.Lang = "go"
+	setup.Backend = map[string]config.BackendConfig{
+		"fake": {Type: "openai", BaseURL: baseURL, DefaultModel: "gpt-4o-fake"},
+	}
+	selector, err := config.NewModelSelector(setup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConductor(selector, setup, cwd, "go")
+	c.Tracer = nil
+	return c
+}
+
+// TestConductor_Replay records a run that needs a retry after a failed
+// review, then replays it with the model server gone. Every phase creates
+// its own provider, so the replay only retries like the recording when
+// they share the session and the repeated review request gets its second
+// response.
+func TestConductor_Replay(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("FAKE_API_KEY", "test")
+	catalog := `{"fake": {"models": [{"id": "gpt-4o-fake", "name": "Fake", "context_window": 128000}]}}`
+	if err := os.MkdirAll(filepath.Join(home, ".gptcode"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".gptcode", "models_catalog.json"), []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cassettes := t.TempDir()
+	cwd := t.TempDir()
+	t.Setenv(llm.EnvCassetteDir, cassettes)
+	ctx := context.Background()
+	task := "Create greet.go declaring package greet"
+
+	fake := &fakeChatServer{}
+	server := httptest.NewServer(fake)
+	t.Setenv(llm.EnvCassetteMode, string(llm.ReplayRecord))
+	if err := newReplayConductor(t, server.URL, cwd).ExecuteTask(ctx, task, "simple"); err != nil {
+		t.Fatalf("recording run failed: %v", err)
+	}
+	server.Close()
+	if fake.reviews != 2 {
+		t.Fatalf("expected the recorded run to retry after a failed review, got %d reviews", fake.reviews)
+	}
+	recorded := fake.calls
+
+	if err := os.Remove(filepath.Join(cwd, "greet.go")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(llm.EnvCassetteMode, string(llm.ReplayReplay))
+	replayed := newReplayConductor(t, server.URL, cwd)
+	if err := replayed.ExecuteTask(ctx, task, "simple"); err != nil {
+		t.Fatalf("replayed run failed: %v", err)
+	}
+	if replayed.apiCalls != recorded {
+		t.Errorf("expected the replay to retry like the recording did, got %d model calls, recorded %d", replayed.apiCalls, recorded)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cwd, "greet.go")); string(data) != "package greet\n" {
+		t.Errorf("unexpected file content %q", data)
+	}
+	if fake.calls != recorded {
+		t.Error("replay reached the model server")
+	}
+
+	session := llm.ReplayFromEnv(nil).(*llm.ReplayProvider)
+	if d := session.Divergences(); len(d) > 0 {
+		t.Errorf("replay diverged from the recording: %+v", d)
+	}
+	if unused := session.Unused(); len(unused) > 0 {
+		t.Errorf("replay made fewer model calls than recorded: %v", unused)
+	}
+}
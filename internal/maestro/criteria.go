@@ -0,0 +1,655 @@
+package maestro
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gptcode/internal/llm"
+	"gptcode/internal/observability"
+)
+
+// CriterionKind is how an acceptance criterion gets checked
+type CriterionKind string
+
+const (
+	CriterionHTTP  CriterionKind = "http"
+	CriterionCLI   CriterionKind = "cli"
+	CriterionGrep  CriterionKind = "grep"
+	CriterionJudge CriterionKind = "judge"
+)
+
+// CriterionCheck is the executable form of one acceptance criterion
+type CriterionCheck struct {
+	Index int           `json:"criterion"`
+	Kind  CriterionKind `json:"kind"`
+
+	// http: request against the local server
+	Method       string `json:"method,omitempty"`
+	Path         string `json:"path,omitempty"`
+	Body         string `json:"body,omitempty"`
+	ExpectStatus int    `json:"expect_status,omitempty"`
+
+	// cli: shell command run in the project directory
+	Command    string `json:"command,omitempty"`
+	ExpectExit int    `json:"expect_exit,omitempty"`
+
+	// http and cli: substring expected in the response body or output
+	Expect string `json:"expect,omitempty"`
+
+	// grep: regexp that must (or, if Absent, must not) match in Files
+	Pattern string `json:"pattern,omitempty"`
+	Files   string `json:"files,omitempty"`
+	Absent  bool   `json:"absent,omitempty"`
+}
+
+// ServerSpec says how to start the project's server for HTTP checks
+type ServerSpec struct {
+	Command string `json:"command"`
+	URL     string `json:"url"`
+}
+
+// CriteriaVerifier checks a plan step's acceptance criteria. Criteria that
+// can be checked mechanically become HTTP probes, CLI invocations or grep
+// invariants; the rest are judged by a reviewer model against the diff.
+type CriteriaVerifier struct {
+	Dir      string
+	Criteria []string
+
+	// Provider and Model turn free-form criteria into checks
+	Provider llm.Provider
+	Model    string
+
+	// JudgeProvider and JudgeModel review criteria that can't be executed.
+	// Without them such criteria are reported as skipped.
+	JudgeProvider llm.Provider
+	JudgeModel    string
+
+	// Server overrides the server the model would pick for HTTP checks
+	Server *ServerSpec
+
+	CheckTimeout   time.Duration
+	StartupTimeout time.Duration
+
+	// Results holds the per-criterion outcome of the last Verify
+	Results []observability.CriterionResult
+}
+
+// NewCriteriaVerifier creates a verifier for the given acceptance criteria
+func NewCriteriaVerifier(dir string, criteria []string, provider llm.Provider, model string) *CriteriaVerifier {
+	return &CriteriaVerifier{
+		Dir:            dir,
+		Criteria:       criteria,
+		Provider:       provider,
+		Model:          model,
+		JudgeProvider:  provider,
+		JudgeModel:     model,
+		CheckTimeout:   30 * time.Second,
+		StartupTimeout: 30 * time.Second,
+	}
+}
+
+func (v *CriteriaVerifier) Verify(ctx context.Context) (*VerificationResult, error) {
+	v.Results = make([]observability.CriterionResult, len(v.Criteria))
+	for i, c := range v.Criteria {
+		v.Results[i] = observability.CriterionResult{Criterion: c, Kind: string(CriterionJudge)}
+	}
+	if len(v.Criteria) == 0 {
+		return &VerificationResult{Success: true}, nil
+	}
+
+	checks, server := v.compile(ctx)
+
+	var httpChecks, judged []CriterionCheck
+	for _, check := range checks {
+		v.Results[check.Index].Kind = string(check.Kind)
+		switch check.Kind {
+		case CriterionHTTP:
+			httpChecks = append(httpChecks, check)
+		case CriterionCLI:
+			v.record(check.Index, v.runCLI(ctx, check))
+		case CriterionGrep:
+			v.record(check.Index, v.runGrep(check))
+		default:
+			judged = append(judged, check)
+		}
+	}
+
+	if len(httpChecks) > 0 {
+		v.runHTTP(ctx, server, httpChecks)
+	}
+	if len(judged) > 0 {
+		v.judge(ctx, judged)
+	}
+
+	var failed, unverified []string
+	for _, r := range v.Results {
+		switch {
+		case r.Skipped:
+			unverified = append(unverified, fmt.Sprintf("- [%s] %s (%s)", r.Kind, r.Criterion, r.Evidence))
+		case !r.Passed:
+			failed = append(failed, fmt.Sprintf("- [%s] %s\n  %s", r.Kind, r.Criterion, strings.ReplaceAll(r.Evidence, "\n", "\n  ")))
+		}
+	}
+	// Criteria that couldn't be checked don't fail the step, but they are
+	// listed on their own rather than counted as met
+	var notVerified string
+	if len(unverified) > 0 {
+		notVerified = fmt.Sprintf("\n%d acceptance criteria not verified:\n%s", len(unverified), strings.Join(unverified, "\n"))
+	}
+	if len(failed) > 0 {
+		return &VerificationResult{
+			Success:  false,
+			Output:   "Acceptance criteria not met:\n" + strings.Join(failed, "\n") + notVerified,
+			Error:    fmt.Errorf("%d of %d acceptance criteria not met", len(failed), len(v.Criteria)),
+			Criteria: v.Results,
+		}, nil
+	}
+	return &VerificationResult{
+		Success:  true,
+		Output:   fmt.Sprintf("%d of %d acceptance criteria met", len(v.Criteria)-len(unverified), len(v.Criteria)) + notVerified,
+		Criteria: v.Results,
+	}, nil
+}
+
+type checkOutcome struct {
+	passed   bool
+	skipped  bool
+	evidence string
+}
+
+func (v *CriteriaVerifier) record(i int, o checkOutcome) {
+	v.Results[i].Passed = o.passed
+	v.Results[i].Skipped = o.skipped
+	v.Results[i].Evidence = o.evidence
+}
+
+var (
+	httpCriterionPattern = regexp.MustCompile(`(?i)^http:\s*(GET|POST|PUT|PATCH|DELETE|HEAD)\s+(\S+)(?:\s*->\s*(\d{3}))?(?:\s+contains\s+(.+))?$`)
+	cliCriterionPattern  = regexp.MustCompile(`(?i)^cli:\s*(.+?)(?:\s+=>\s*(.+))?$`)
+	grepCriterionPattern = regexp.MustCompile(`(?i)^(!?)grep:\s*(.+?)\s+in\s+(\S+)$`)
+)
+
+// parseExplicitCheck recognizes the "http:", "cli:" and "grep:" forms the
+// planner is asked to use, so they don't need a model to interpret
+func parseExplicitCheck(i int, criterion string) (CriterionCheck, bool) {
+	c := strings.TrimSpace(criterion)
+	if m := httpCriterionPattern.FindStringSubmatch(c); m != nil {
+		status, _ := strconv.Atoi(m[3])
+		return CriterionCheck{Index: i, Kind: CriterionHTTP, Method: strings.ToUpper(m[1]), Path: m[2], ExpectStatus: status, Expect: unquote(m[4])}, true
+	}
+	if m := grepCriterionPattern.FindStringSubmatch(c); m != nil {
+		return CriterionCheck{Index: i, Kind: CriterionGrep, Pattern: unquote(m[2]), Files: m[3], Absent: m[1] == "!"}, true
+	}
+	if m := cliCriterionPattern.FindStringSubmatch(c); m != nil {
+		return CriterionCheck{Index: i, Kind: CriterionCLI, Command: unquote(m[1]), Expect: unquote(m[2])}, true
+	}
+	return CriterionCheck{}, false
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && strings.ContainsAny(s[:1], "\"'`") && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// compile turns criteria into checks: explicit forms directly, the rest by
+// asking the model which can be executed. Anything left is judged.
+func (v *CriteriaVerifier) compile(ctx context.Context) ([]CriterionCheck, *ServerSpec) {
+	checks := make([]CriterionCheck, len(v.Criteria))
+	var pending []int
+	for i, c := range v.Criteria {
+		if check, ok := parseExplicitCheck(i, c); ok {
+			checks[i] = check
+			continue
+		}
+		checks[i] = CriterionCheck{Index: i, Kind: CriterionJudge}
+		pending = append(pending, i)
+	}
+
+	server := v.Server
+	needServer := false
+	for _, c := range checks {
+		needServer = needServer || c.Kind == CriterionHTTP
+	}
+	if v.Provider == nil || (len(pending) == 0 && (!needServer || server != nil)) {
+		return checks, server
+	}
+
+	compiled, compiledServer, err := v.compileWithModel(ctx, pending)
+	if err != nil {
+		return checks, server
+	}
+	for _, c := range compiled {
+		if c.Index < 0 || c.Index >= len(checks) || checks[c.Index].Kind != CriterionJudge {
+			continue
+		}
+		switch c.Kind {
+		case CriterionHTTP, CriterionCLI, CriterionGrep:
+			checks[c.Index] = c
+		}
+	}
+	if server == nil {
+		server = compiledServer
+	}
+	return checks, server
+}
+
+func (v *CriteriaVerifier) compileWithModel(ctx context.Context, pending []int) ([]CriterionCheck, *ServerSpec, error) {
+	var list strings.Builder
+	for _, i := range pending {
+		fmt.Fprintf(&list, "%d. %s\n", i, v.Criteria[i])
+	}
+	var all strings.Builder
+	for i, c := range v.Criteria {
+		fmt.Fprintf(&all, "%d. %s\n", i, c)
+	}
+
+	prompt := fmt.Sprintf(`Turn acceptance criteria into executable checks where possible.
+
+Project files (top level): %s
+
+All criteria:
+%s
+Criteria to convert:
+%s
+Kinds:
+- "http": request to the locally running server. Fields: method, path, body, expect_status, expect (substring of the response body)
+- "cli": shell command run in the project root. Fields: command, expect_exit, expect (substring of the output)
+- "grep": invariant over source files. Fields: pattern (Go regexp), files (glob such as "internal/**/*.go"), absent (true if it must NOT match)
+- "judge": anything that needs reading the code to decide
+
+Only use http/cli/grep when the check faithfully decides the criterion. If any check (including already
+converted "http:" criteria) needs the server, set "server" to the command that starts it in the foreground
+and its base URL.
+
+Respond with JSON only:
+{"server": {"command": "go run ./cmd/server", "url": "http://localhost:8080"}, "checks": [{"criterion": 0, "kind": "cli", "command": "...", "expect": "..."}]}`,
+		strings.Join(topLevelFiles(v.Dir), ", "), all.String(), list.String())
+
+	resp, err := v.Provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You convert acceptance criteria into precise, side-effect free verification checks. Output only JSON.",
+		UserPrompt:   prompt,
+		Model:        v.Model,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out struct {
+		Server *ServerSpec      `json:"server"`
+		Checks []CriterionCheck `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(extractJSONObject(resp.Text)), &out); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse checks: %w", err)
+	}
+	if out.Server != nil && (out.Server.Command == "" || out.Server.URL == "") {
+		out.Server = nil
+	}
+	return out.Checks, out.Server, nil
+}
+
+// extractJSONObject returns the outermost {...} or [...] in a model reply
+func extractJSONObject(text string) string {
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closer := "}"
+	if text[start] == '[' {
+		closer = "]"
+	}
+	end := strings.LastIndex(text, closer)
+	if end < start {
+		return text
+	}
+	return text[start : end+1]
+}
+
+func topLevelFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func (v *CriteriaVerifier) runCLI(ctx context.Context, check CriterionCheck) checkOutcome {
+	ctx, cancel := context.WithTimeout(ctx, v.CheckTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", check.Command)
+	cmd.Dir = v.Dir
+	output, err := cmd.CombinedOutput()
+
+	exitCode := 0
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return checkOutcome{evidence: fmt.Sprintf("$ %s\nfailed to run: %v", check.Command, err)}
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	evidence := fmt.Sprintf("$ %s (exit %d)\n%s", check.Command, exitCode, truncateOutput(string(output), 1500))
+	if exitCode != check.ExpectExit {
+		return checkOutcome{evidence: fmt.Sprintf("expected exit %d\n%s", check.ExpectExit, evidence)}
+	}
+	if check.Expect != "" && !strings.Contains(string(output), check.Expect) {
+		return checkOutcome{evidence: fmt.Sprintf("output does not contain %q\n%s", check.Expect, evidence)}
+	}
+	return checkOutcome{passed: true, evidence: evidence}
+}
+
+func (v *CriteriaVerifier) runGrep(check CriterionCheck) checkOutcome {
+	re, err := regexp.Compile(check.Pattern)
+	if err != nil {
+		return checkOutcome{evidence: fmt.Sprintf("invalid pattern %q: %v", check.Pattern, err)}
+	}
+
+	var matches []string
+	_ = filepath.Walk(v.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if name := info.Name(); path != v.Dir && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(v.Dir, path)
+		if !matchGlob(check.Files, filepath.ToSlash(rel)) || info.Size() > 1<<20 {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for n, line := range strings.Split(string(data), "\n") {
+			if re.MatchString(line) {
+				matches = append(matches, fmt.Sprintf("%s:%d: %s", rel, n+1, strings.TrimSpace(line)))
+				if len(matches) >= 5 {
+					return io.EOF
+				}
+			}
+		}
+		return nil
+	})
+
+	desc := fmt.Sprintf("/%s/ in %s", check.Pattern, check.Files)
+	switch {
+	case check.Absent && len(matches) > 0:
+		return checkOutcome{evidence: fmt.Sprintf("%s must not match, found:\n%s", desc, strings.Join(matches, "\n"))}
+	case check.Absent:
+		return checkOutcome{passed: true, evidence: desc + ": no matches"}
+	case len(matches) == 0:
+		return checkOutcome{evidence: desc + ": no matches"}
+	default:
+		return checkOutcome{passed: true, evidence: strings.Join(matches, "\n")}
+	}
+}
+
+// matchGlob matches a slash-separated path against a glob where "**"
+// spans directories. An empty glob matches everything.
+func matchGlob(glob, path string) bool {
+	if glob == "" || glob == "." || glob == "**" {
+		return true
+	}
+	if !strings.Contains(glob, "**") {
+		if ok, _ := filepath.Match(glob, path); ok {
+			return true
+		}
+		// A bare directory matches everything under it
+		return strings.HasPrefix(path, strings.TrimSuffix(glob, "/")+"/")
+	}
+
+	parts := strings.SplitN(glob, "**", 2)
+	prefix, suffix := parts[0], strings.TrimPrefix(parts[1], "/")
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	rest := strings.TrimPrefix(path, prefix)
+	segments := strings.Split(rest, "/")
+	for i := range segments {
+		if matchGlob(suffix, strings.Join(segments[i:], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// runHTTP starts the server, waits for it to answer and probes it
+func (v *CriteriaVerifier) runHTTP(ctx context.Context, server *ServerSpec, checks []CriterionCheck) {
+	if server == nil {
+		for _, c := range checks {
+			v.record(c.Index, checkOutcome{skipped: true, evidence: "no server command known to run HTTP checks against"})
+		}
+		return
+	}
+
+	serverCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	// exec so the shell is replaced, and a process group so that children
+	// such as the binary `go run` builds are killed with it
+	cmd := exec.CommandContext(serverCtx, "sh", "-c", "exec "+server.Command)
+	cmd.Dir = v.Dir
+	killGroupOnCancel(cmd)
+	// Don't wait forever for output from anything that escaped the group
+	cmd.WaitDelay = 5 * time.Second
+	var logs bytes.Buffer
+	cmd.Stdout = &logs
+	cmd.Stderr = &logs
+	if err := cmd.Start(); err != nil {
+		for _, c := range checks {
+			v.record(c.Index, checkOutcome{evidence: fmt.Sprintf("failed to start server %q: %v", server.Command, err)})
+		}
+		return
+	}
+	defer func() { _ = cmd.Wait() }()
+	defer stop()
+
+	client := &http.Client{Timeout: v.CheckTimeout}
+	if !waitForServer(serverCtx, client, server.URL, v.StartupTimeout) {
+		for _, c := range checks {
+			v.record(c.Index, checkOutcome{evidence: fmt.Sprintf("server %q did not answer at %s within %s\n%s",
+				server.Command, server.URL, v.StartupTimeout, truncateOutput(logs.String(), 1500))})
+		}
+		return
+	}
+
+	for _, c := range checks {
+		v.record(c.Index, probe(serverCtx, client, server.URL, c))
+	}
+}
+
+func waitForServer(ctx context.Context, client *http.Client, baseURL string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
+		if err != nil {
+			return false
+		}
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+	return false
+}
+
+func probe(ctx context.Context, client *http.Client, baseURL string, check CriterionCheck) checkOutcome {
+	url := check.Path
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(url, "/")
+	}
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if check.Body != "" {
+		body = strings.NewReader(check.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return checkOutcome{evidence: fmt.Sprintf("invalid request %s %s: %v", method, url, err)}
+	}
+	if check.Body != "" && json.Valid([]byte(check.Body)) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return checkOutcome{evidence: fmt.Sprintf("%s %s: %v", method, url, err)}
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	evidence := fmt.Sprintf("%s %s -> %d\n%s", method, url, resp.StatusCode, truncateOutput(string(respBody), 1000))
+	expectStatus := check.ExpectStatus
+	if expectStatus == 0 && resp.StatusCode >= 400 {
+		return checkOutcome{evidence: "expected a successful status\n" + evidence}
+	}
+	if expectStatus != 0 && resp.StatusCode != expectStatus {
+		return checkOutcome{evidence: fmt.Sprintf("expected status %d\n%s", expectStatus, evidence)}
+	}
+	if check.Expect != "" && !strings.Contains(string(respBody), check.Expect) {
+		return checkOutcome{evidence: fmt.Sprintf("body does not contain %q\n%s", check.Expect, evidence)}
+	}
+	return checkOutcome{passed: true, evidence: evidence}
+}
+
+// maxJudgeDiff caps the diff sent to the reviewer model
+const maxJudgeDiff = 30000
+
+// judge asks the reviewer model whether the diff meets the criteria
+func (v *CriteriaVerifier) judge(ctx context.Context, checks []CriterionCheck) {
+	if v.JudgeProvider == nil {
+		for _, c := range checks {
+			v.record(c.Index, checkOutcome{skipped: true, evidence: "no reviewer model configured"})
+		}
+		return
+	}
+
+	var list strings.Builder
+	for _, c := range checks {
+		fmt.Fprintf(&list, "%d. %s\n", c.Index, v.Criteria[c.Index])
+	}
+
+	prompt := fmt.Sprintf(`Decide whether the changes below meet each acceptance criterion.
+Judge only from the evidence. If a criterion's feature is missing or incomplete in the diff, it is NOT met,
+even if the code compiles and tests pass.
+
+Criteria:
+%s
+Changes:
+%s
+
+Respond with JSON only: [{"criterion": 0, "met": true, "reason": "one sentence citing the code"}]`,
+		list.String(), workingTreeDiff(ctx, v.Dir, maxJudgeDiff))
+
+	resp, err := v.JudgeProvider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You are a strict code reviewer verifying acceptance criteria. Output only JSON.",
+		UserPrompt:   prompt,
+		Model:        v.JudgeModel,
+	})
+	if err != nil {
+		for _, c := range checks {
+			v.record(c.Index, checkOutcome{evidence: fmt.Sprintf("reviewer failed, so the criterion could not be confirmed: %v", err)})
+		}
+		return
+	}
+
+	var verdicts []struct {
+		Criterion int    `json:"criterion"`
+		Met       bool   `json:"met"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(extractJSONObject(resp.Text)), &verdicts); err != nil {
+		for _, c := range checks {
+			v.record(c.Index, checkOutcome{evidence: fmt.Sprintf("could not parse reviewer verdict, so the criterion could not be confirmed:\n%s", truncateOutput(resp.Text, 500))})
+		}
+		return
+	}
+
+	judged := make(map[int]bool)
+	for _, verdict := range verdicts {
+		for _, c := range checks {
+			if c.Index == verdict.Criterion {
+				v.record(c.Index, checkOutcome{passed: verdict.Met, evidence: verdict.Reason})
+				judged[c.Index] = true
+			}
+		}
+	}
+	for _, c := range checks {
+		if !judged[c.Index] {
+			v.record(c.Index, checkOutcome{evidence: "reviewer gave no verdict"})
+		}
+	}
+}
+
+// workingTreeDiff returns the uncommitted changes, including new files
+func workingTreeDiff(ctx context.Context, dir string, limit int) string {
+	var sb strings.Builder
+
+	cmd := exec.CommandContext(ctx, "git", "--no-pager", "diff", "HEAD")
+	cmd.Dir = dir
+	if out, err := cmd.Output(); err == nil {
+		sb.Write(out)
+	}
+
+	cmd = exec.CommandContext(ctx, "git", "ls-files", "--others", "--exclude-standard")
+	cmd.Dir = dir
+	if out, err := cmd.Output(); err == nil {
+		for _, f := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if f == "" || sb.Len() > limit {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, f))
+			if err != nil || bytes.IndexByte(data, 0) >= 0 {
+				continue
+			}
+			fmt.Fprintf(&sb, "\n--- /dev/null\n+++ b/%s (new file)\n%s\n", f, data)
+		}
+	}
+
+	return truncateOutput(sb.String(), limit)
+}
+
+func truncateOutput(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n... (truncated)"
+}
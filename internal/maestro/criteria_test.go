@@ -0,0 +1,196 @@
+package maestro
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gptcode/internal/llm"
+	"gptcode/internal/observability"
+)
+
+func TestParseExplicitCheck(t *testing.T) {
+	tests := []struct {
+		criterion string
+		want      CriterionCheck
+	}{
+		{`http: POST /save -> 201 contains "ok"`, CriterionCheck{Kind: CriterionHTTP, Method: "POST", Path: "/save", ExpectStatus: 201, Expect: "ok"}},
+		{`http: GET /health`, CriterionCheck{Kind: CriterionHTTP, Method: "GET", Path: "/health"}},
+		{`cli: ./app --version => v1.2`, CriterionCheck{Kind: CriterionCLI, Command: "./app --version", Expect: "v1.2"}},
+		{`cli: go vet ./...`, CriterionCheck{Kind: CriterionCLI, Command: "go vet ./..."}},
+		{`grep: func Save\( in internal/**/*.go`, CriterionCheck{Kind: CriterionGrep, Pattern: `func Save\(`, Files: "internal/**/*.go"}},
+		{`!grep: "fmt.Println" in cmd`, CriterionCheck{Kind: CriterionGrep, Pattern: "fmt.Println", Files: "cmd", Absent: true}},
+	}
+	for _, tt := range tests {
+		got, ok := parseExplicitCheck(0, tt.criterion)
+		if !ok || got != tt.want {
+			t.Errorf("parseExplicitCheck(%q) = %+v, %v; want %+v", tt.criterion, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := parseExplicitCheck(0, "Save rejects empty keys"); ok {
+		t.Error("expected free-form criterion to need the model")
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob, path string
+		want       bool
+	}{
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "internal/store/store.go", true},
+		{"internal/**/*.go", "internal/store/store.go", true},
+		{"internal/**/*.go", "cmd/main.go", false},
+		{"*.go", "internal/store.go", false},
+		{"cmd", "cmd/app/main.go", true},
+		{"", "anything", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.glob, tt.path); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.glob, tt.path, got, tt.want)
+		}
+	}
+}
+
+// judgeProvider answers the reviewer prompt with a fixed verdict
+type judgeProvider struct {
+	verdict string
+	prompt  string
+}
+
+func (p *judgeProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.prompt = req.UserPrompt
+	return &llm.ChatResponse{Text: p.verdict}, nil
+}
+
+func TestCriteriaVerifierRunsChecksAndJudge(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"internal/store/store.go": "package store\n\nfunc Save(key string) error { return nil }\n",
+	})
+
+	criteria := []string{
+		"cli: echo hello => hello",
+		"cli: echo hello => goodbye",
+		`grep: func Save\( in internal/**/*.go`,
+		"!grep: TODO in **/*.go",
+		"Save rejects empty keys",
+	}
+	judge := &judgeProvider{verdict: "```json\n[{\"criterion\": 4, \"met\": false, \"reason\": \"Save never checks key\"}]\n```"}
+
+	v := NewCriteriaVerifier(dir, criteria, nil, "")
+	v.JudgeProvider = judge
+	result, err := v.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Success {
+		t.Fatal("expected failing criteria")
+	}
+	wantPassed := []bool{true, false, true, true, false}
+	wantKind := []string{"cli", "cli", "grep", "grep", "judge"}
+	for i, r := range result.Criteria {
+		if r.Passed != wantPassed[i] || r.Kind != wantKind[i] || r.Skipped {
+			t.Errorf("criterion %d (%s): got %+v", i, criteria[i], r)
+		}
+	}
+	if !strings.Contains(result.Criteria[1].Evidence, `does not contain "goodbye"`) {
+		t.Errorf("expected output evidence, got %q", result.Criteria[1].Evidence)
+	}
+	if result.Criteria[4].Evidence != "Save never checks key" {
+		t.Errorf("expected judge reason as evidence, got %q", result.Criteria[4].Evidence)
+	}
+	if !strings.Contains(judge.prompt, "4. Save rejects empty keys") || strings.Contains(judge.prompt, "echo hello") {
+		t.Errorf("expected only the free-form criterion to be judged:\n%s", judge.prompt)
+	}
+	if !strings.Contains(result.Output, "Save rejects empty keys") || ClassifyResult(result) != ErrorAcceptance {
+		t.Errorf("unexpected failure output:\n%s", result.Output)
+	}
+}
+
+func TestCriteriaVerifierSkipsWithoutJudge(t *testing.T) {
+	v := NewCriteriaVerifier(t.TempDir(), []string{"Code is readable"}, nil, "")
+	result, err := v.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || !result.Criteria[0].Skipped {
+		t.Errorf("expected unjudged criterion to be skipped, got %+v", result.Criteria)
+	}
+	if !strings.HasPrefix(result.Output, "0 of 1 acceptance criteria met") || !strings.Contains(result.Output, "not verified:\n- [judge] Code is readable") {
+		t.Errorf("expected the skipped criterion reported as not verified rather than met, got:\n%s", result.Output)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/save" {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	check, _ := parseExplicitCheck(0, `http: POST /save -> 201 contains "ok"`)
+	if o := probe(ctx, server.Client(), server.URL, check); !o.passed {
+		t.Errorf("expected probe to pass: %s", o.evidence)
+	}
+
+	check, _ = parseExplicitCheck(0, "http: GET /missing")
+	if o := probe(ctx, server.Client(), server.URL, check); o.passed || !strings.Contains(o.evidence, "-> 404") {
+		t.Errorf("expected 404 to fail: %s", o.evidence)
+	}
+}
+
+func TestRunHTTPKillsServerChildren(t *testing.T) {
+	// Like `go run`, the shell starts the real server as a child that
+	// inherits the output pipe
+	v := NewCriteriaVerifier(t.TempDir(), []string{"GET /health"}, nil, "")
+	v.Results = make([]observability.CriterionResult, 1)
+	v.StartupTimeout = 300 * time.Millisecond
+	server := &ServerSpec{Command: "sh -c 'sleep 30 & wait'", URL: "http://127.0.0.1:1"}
+
+	start := time.Now()
+	v.runHTTP(context.Background(), server, []CriterionCheck{{Index: 0, Path: "/health"}})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the server's children killed with it, waited %s", elapsed)
+	}
+	if v.Results[0].Passed || !strings.Contains(v.Results[0].Evidence, "did not answer") {
+		t.Errorf("unexpected result %+v", v.Results[0])
+	}
+}
+
+// failingJudge is a reviewer model that errors
+type failingJudge struct{}
+
+func (failingJudge) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	return nil, errors.New("rate limited")
+}
+
+func TestJudgeFailureFailsTheCriterion(t *testing.T) {
+	for name, provider := range map[string]llm.Provider{
+		"error":       failingJudge{},
+		"bad verdict": &judgeProvider{verdict: "Looks fine to me"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			v := NewCriteriaVerifier(t.TempDir(), []string{"Save rejects empty keys"}, nil, "")
+			v.JudgeProvider = provider
+			result, err := v.Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Success || result.Criteria[0].Skipped || result.Criteria[0].Passed {
+				t.Errorf("expected the unconfirmed criterion to fail, got %+v", result.Criteria)
+			}
+		})
+	}
+}
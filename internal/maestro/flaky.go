@@ -0,0 +1,284 @@
+package maestro
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gptcode/internal/testreport"
+)
+
+// DefaultFlakeReruns is how many times a failing test is re-run in
+// isolation before its failure is believed
+const DefaultFlakeReruns = 3
+
+// FlakyTest is the history of one test's failures and the isolated re-runs
+// that checked them. Every run counted was of the same tree as the failure
+// it re-checks, so a test that passes once the code is fixed never becomes
+// flaky.
+type FlakyTest struct {
+	Name        string    `json:"name"`
+	Suite       string    `json:"suite,omitempty"`
+	File        string    `json:"file,omitempty"`
+	Line        int       `json:"line,omitempty"`
+	Passes      int       `json:"passes"`
+	Failures    int       `json:"failures"`
+	Flaky       bool      `json:"flaky"`
+	LastFailure string    `json:"last_failure,omitempty"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// FailureRate is the share of recorded runs that failed
+func (t FlakyTest) FailureRate() float64 {
+	runs := t.Passes + t.Failures
+	if runs == 0 {
+		return 0
+	}
+	return float64(t.Failures) / float64(runs)
+}
+
+// FlakyStore persists test history in .gptcode/flaky.json
+type FlakyStore struct {
+	path  string
+	Tests map[string]*FlakyTest `json:"tests"`
+}
+
+// FlakyStorePath returns where a project's flake history is kept
+func FlakyStorePath(dir string) string {
+	return filepath.Join(dir, ".gptcode", "flaky.json")
+}
+
+// LoadFlakyStore reads the project's flake history. A missing file yields
+// an empty store.
+func LoadFlakyStore(dir string) (*FlakyStore, error) {
+	s := &FlakyStore{path: FlakyStorePath(dir), Tests: map[string]*FlakyTest{}}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flaky test history: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	if s.Tests == nil {
+		s.Tests = map[string]*FlakyTest{}
+	}
+	return s, nil
+}
+
+// Save writes the store back to disk
+func (s *FlakyStore) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal flaky test history: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write flaky test history: %w", err)
+	}
+	return nil
+}
+
+// Record adds one run of a test to its history. It doesn't decide whether
+// the test is flaky; see MarkFlaky.
+func (s *FlakyStore) Record(key string, tc testreport.TestCase) {
+	t := s.Tests[key]
+	if t == nil {
+		t = &FlakyTest{Name: key, Suite: tc.Suite}
+		s.Tests[key] = t
+	}
+	if tc.File != "" {
+		t.File, t.Line = tc.File, tc.Line
+	}
+	if tc.Status == testreport.StatusFail {
+		t.Failures++
+		t.LastFailure = tc.Message
+	} else {
+		t.Passes++
+	}
+	t.LastSeen = time.Now()
+}
+
+// MarkFlaky records that a test both failed and passed on the same tree
+func (s *FlakyStore) MarkFlaky(key string) {
+	if t := s.Tests[key]; t != nil {
+		t.Flaky = true
+	}
+}
+
+// IsFlaky reports whether a test is a known flake
+func (s *FlakyStore) IsFlaky(key string) bool {
+	t := s.Tests[key]
+	return t != nil && t.Flaky
+}
+
+// Forget drops a test's history, for example once its flake is fixed
+func (s *FlakyStore) Forget(key string) bool {
+	if _, ok := s.Tests[key]; !ok {
+		return false
+	}
+	delete(s.Tests, key)
+	return true
+}
+
+// List returns the tracked tests, highest failure rate first. With
+// flakyOnly, tests whose failures never passed on a re-run are left out.
+func (s *FlakyStore) List(flakyOnly bool) []FlakyTest {
+	var out []FlakyTest
+	for _, t := range s.Tests {
+		if flakyOnly && !t.Flaky {
+			continue
+		}
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if ri, rj := out[i].FailureRate(), out[j].FailureRate(); ri != rj {
+			return ri > rj
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// flakyKey names a test in the store. Go names are qualified by package;
+// other runners need the file to tell same-named tests apart.
+func (v *TestVerifier) flakyKey(tc testreport.TestCase) string {
+	if v.Language == "go" || tc.File == "" {
+		return tc.FullName()
+	}
+	return tc.File + " › " + tc.FullName()
+}
+
+// checkFlakes splits a failed run into real failures and flakes. Every
+// failing test is re-run in isolation on the same tree, known flakes
+// included, and counts as flaky only when a re-run passes; a known flake
+// that keeps failing may have been broken for real. When only flakes
+// failed, the result becomes a success that lists them in Flaky so they
+// are reported instead of handed to recovery.
+func (v *TestVerifier) checkFlakes(ctx context.Context, result *VerificationResult) *VerificationResult {
+	failures := result.Report.Failures()
+	if len(failures) == 0 {
+		return result
+	}
+	for _, tc := range failures {
+		if tc.Name == testreport.SetupFailure {
+			return result
+		}
+	}
+
+	store, err := LoadFlakyStore(v.Dir)
+	if err != nil {
+		return result
+	}
+
+	var flaky []testreport.TestCase
+	remaining := &testreport.TestReport{Runner: result.Report.Runner}
+	for _, tc := range result.Report.Tests {
+		if tc.Status != testreport.StatusFail {
+			remaining.Tests = append(remaining.Tests, tc)
+			continue
+		}
+		key := v.flakyKey(tc)
+		store.Record(key, tc)
+		if v.rerunPasses(ctx, store, key, tc) {
+			store.MarkFlaky(key)
+			flaky = append(flaky, tc)
+			continue
+		}
+		remaining.Tests = append(remaining.Tests, tc)
+	}
+	_ = store.Save()
+
+	if len(flaky) == 0 {
+		return result
+	}
+	result.Flaky = flaky
+	if remaining.Failed() == 0 {
+		return &VerificationResult{
+			Success: true,
+			Output:  fmt.Sprintf("only flaky tests failed: %s", flakyNames(flaky)),
+			Report:  result.Report,
+			Flaky:   flaky,
+		}
+	}
+	result.Output = remaining.FailureText()
+	return result
+}
+
+// rerunPasses re-runs one failing test in isolation up to FlakeReruns
+// times, recording each outcome, and reports whether any run passed
+func (v *TestVerifier) rerunPasses(ctx context.Context, store *FlakyStore, key string, tc testreport.TestCase) bool {
+	format, name, args, ok := v.isolatedCommand(tc)
+	if !ok {
+		return false
+	}
+	for i := 0; i < v.FlakeReruns; i++ {
+		report, _, _ := testreport.Run(ctx, v.Dir, format, name, args...)
+		run, found := findTest(report, tc, v.flakyKey, key)
+		if !found {
+			// The runner didn't report the test on its own; we can't tell
+			return false
+		}
+		store.Record(key, run)
+		if run.Status == testreport.StatusPass {
+			return true
+		}
+	}
+	return false
+}
+
+// isolatedCommand returns a command that runs only the given test
+func (v *TestVerifier) isolatedCommand(tc testreport.TestCase) (format testreport.Format, name string, args []string, ok bool) {
+	switch v.Language {
+	case "go":
+		var parts []string
+		for _, p := range strings.Split(tc.Name, "/") {
+			parts = append(parts, "^"+regexp.QuoteMeta(p)+"$")
+		}
+		pkg := tc.Suite
+		if pkg == "" {
+			pkg = "./..."
+		}
+		return testreport.FormatGoJSON, "go", []string{"test", "-count=1", "-run", strings.Join(parts, "/"), pkg}, true
+	case "javascript", "typescript":
+		format, name, base, ok := v.testCommand()
+		if !ok || format != testreport.FormatJest || tc.File == "" {
+			return "", "", nil, false
+		}
+		title := strings.TrimSpace(strings.ReplaceAll(tc.Suite, " › ", " ") + " " + tc.Name)
+		args := append(append([]string(nil), base...), tc.File, "-t", "^"+regexp.QuoteMeta(title)+"$")
+		return format, name, args, true
+	}
+	return "", "", nil, false
+}
+
+// findTest looks up a test in a re-run's report by its store key
+func findTest(report *testreport.TestReport, tc testreport.TestCase, keyOf func(testreport.TestCase) string, key string) (testreport.TestCase, bool) {
+	if report == nil {
+		return testreport.TestCase{}, false
+	}
+	for _, run := range report.Tests {
+		if run.Name == tc.Name && (run.Suite == tc.Suite || keyOf(run) == key) {
+			return run, true
+		}
+	}
+	return testreport.TestCase{}, false
+}
+
+func flakyNames(tests []testreport.TestCase) string {
+	names := make([]string, len(tests))
+	for i, tc := range tests {
+		names[i] = tc.FullName()
+	}
+	return strings.Join(names, ", ")
+}
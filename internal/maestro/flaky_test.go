@@ -0,0 +1,189 @@
+package maestro
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/testreport"
+)
+
+func writeFlakyModule(t *testing.T, tests string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module demo\n\ngo 1.21\n",
+		"demo/demo.go":      "package demo\n\nfunc Answer() int { return 42 }\n",
+		"demo/demo_test.go": tests,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestFlaky fails on its first run and passes afterwards, leaving a marker
+// file in the package directory
+const flakyTests = `package demo
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFlaky(t *testing.T) {
+	if _, err := os.Stat("ran"); err != nil {
+		os.WriteFile("ran", nil, 0644)
+		t.Fatal("first run fails")
+	}
+}
+
+func TestStable(t *testing.T) {
+	if Answer() != 42 {
+		t.Fatal("wrong answer")
+	}
+}
+`
+
+func TestTestVerifierDetectsFlakes(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := writeFlakyModule(t, flakyTests)
+	v := NewTestVerifier(dir)
+	v.FullSuite = true
+
+	result, err := v.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success {
+		t.Fatalf("expected flaky failure to be set aside, got %s", result.Output)
+	}
+	if len(result.Flaky) != 1 || result.Flaky[0].Name != "TestFlaky" {
+		t.Fatalf("expected TestFlaky to be reported as flaky, got %+v", result.Flaky)
+	}
+
+	store, err := LoadFlakyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := store.Tests["demo/demo › TestFlaky"]
+	if history == nil || !history.Flaky || history.Failures != 1 || history.Passes != 1 {
+		t.Fatalf("unexpected history: %+v", store.Tests)
+	}
+	if store.Tests["demo/demo › TestStable"] != nil {
+		t.Error("tests that never failed should not be tracked")
+	}
+}
+
+func TestTestVerifierKeepsRealFailures(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := writeFlakyModule(t, flakyTests+`
+func TestBroken(t *testing.T) {
+	t.Fatal("always fails")
+}
+`)
+	v := NewTestVerifier(dir)
+	v.FullSuite = true
+
+	result, err := v.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success {
+		t.Fatal("expected a real failure to fail verification")
+	}
+	if len(result.Flaky) != 1 {
+		t.Errorf("expected the flake to be listed alongside the failure, got %+v", result.Flaky)
+	}
+	if result.Report.Failed() != 2 {
+		t.Errorf("report should keep every failure, got %s", result.Report.Summary())
+	}
+
+	store, _ := LoadFlakyStore(dir)
+	broken := store.Tests["demo/demo › TestBroken"]
+	if broken == nil || broken.Flaky || broken.Failures != 1+DefaultFlakeReruns {
+		t.Errorf("expected TestBroken to fail every re-run, got %+v", broken)
+	}
+	if !strings.Contains(result.Output, "TestBroken") || strings.Contains(result.Output, "TestFlaky") {
+		t.Errorf("recovery output should only describe the real failure:\n%s", result.Output)
+	}
+}
+
+func TestKnownFlakeIsRerunNotSetAside(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := writeFlakyModule(t, `package demo
+
+import "testing"
+
+func TestFlaky(t *testing.T) {
+	t.Fatal("broken for real now")
+}
+`)
+	store, _ := LoadFlakyStore(dir)
+	store.Record("demo/demo › TestFlaky", testreport.TestCase{Name: "TestFlaky", Status: testreport.StatusFail})
+	store.Record("demo/demo › TestFlaky", testreport.TestCase{Name: "TestFlaky", Status: testreport.StatusPass})
+	store.MarkFlaky("demo/demo › TestFlaky")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewTestVerifier(dir)
+	v.FullSuite = true
+	result, err := v.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || len(result.Flaky) != 0 {
+		t.Fatalf("expected a known flake that fails every re-run to fail verification, got %+v", result)
+	}
+}
+
+func TestFlakyStoreKnownFlakes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := LoadFlakyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := testreport.TestCase{Name: "TestA", Suite: "p", Status: testreport.StatusFail, Message: "boom"}
+	pass := testreport.TestCase{Name: "TestA", Suite: "p", Status: testreport.StatusPass}
+	store.Record("p › TestA", fail)
+	store.Record("p › TestA", pass)
+	store.Record("p › TestA", fail)
+	store.MarkFlaky("p › TestA")
+	// A failure followed by a pass after the code changed isn't a flake
+	store.Record("p › TestB", fail)
+	store.Record("p › TestB", pass)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadFlakyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.IsFlaky("p › TestA") || loaded.IsFlaky("p › TestB") {
+		t.Fatalf("unexpected flakes: %+v", loaded.Tests)
+	}
+	list := loaded.List(true)
+	if len(list) != 1 || list[0].FailureRate() < 0.66 || list[0].FailureRate() > 0.67 {
+		t.Errorf("unexpected flaky list: %+v", list)
+	}
+	if len(loaded.List(false)) != 2 {
+		t.Error("expected --all to include tests that aren't flaky")
+	}
+}
@@ -9,14 +9,21 @@ import (
 type LintVerifier struct {
 	Dir      string
 	Language string
+	// Command replaces the detected linter when the project config sets
+	// commands.lint
+	Command string
 }
 
 func NewLintVerifier(dir string) *LintVerifier {
 	lang := detectLanguage(dir)
-	return &LintVerifier{Dir: dir, Language: lang}
+	return &LintVerifier{Dir: dir, Language: lang, Command: projectCommands(dir).Lint}
 }
 
 func (v *LintVerifier) Verify(ctx context.Context) (*VerificationResult, error) {
+	if v.Command != "" {
+		return runProjectCommand(ctx, v.Dir, v.Command, "lint failed"), nil
+	}
+
 	var cmd *exec.Cmd
 
 	switch v.Language {
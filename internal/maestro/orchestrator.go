@@ -2,17 +2,20 @@ package maestro
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	"gptcode/internal/agents"
-	"gptcode/internal/config"
+	"gptcode/internal/budget"
 	"gptcode/internal/events"
+	"gptcode/internal/ledger"
 	"gptcode/internal/live"
 	"gptcode/internal/llm"
 	"gptcode/internal/observability"
@@ -29,10 +32,21 @@ type Maestro struct {
 	Recovery       *RecoveryStrategy
 	Checkpoints    *CheckpointSystem
 	MaxRetries     int
+	MaxParallel    int
 	ModifiedFiles  []string
 	CurrentStepIdx int
 	Tracer         observability.Tracer
+	Observer       observability.Observer
 	UsageTracker   *telemetry.UsageTracker
+
+	// JudgeProvider and JudgeModel review acceptance criteria that can't be
+	// checked by running something. They default to Provider and Model.
+	JudgeProvider llm.Provider
+	JudgeModel    string
+
+	// fullSuite makes verification run every test instead of only those
+	// impacted by the modified files
+	fullSuite bool
 }
 
 // NewMaestro creates a new Maestro orchestrator
@@ -44,16 +58,20 @@ func NewMaestro(provider llm.Provider, cwd, model string) *Maestro {
 	usageTracker := telemetry.NewUsageTracker()
 
 	return &Maestro{
-		Provider:     provider,
-		CWD:          cwd,
-		Model:        model,
-		Events:       events.NewEmitter(os.Stderr),
-		Verifiers:    []Verifier{},
-		Recovery:     recovery,
-		Checkpoints:  checkpoints,
-		MaxRetries:   3,
-		Tracer:       tracer,
-		UsageTracker: usageTracker,
+		Provider:      provider,
+		CWD:           cwd,
+		Model:         model,
+		Events:        events.NewEmitter(os.Stderr),
+		Verifiers:     []Verifier{},
+		Recovery:      recovery,
+		Checkpoints:   checkpoints,
+		MaxRetries:    3,
+		MaxParallel:   3,
+		Tracer:        tracer,
+		Observer:      observability.NewObserver(),
+		UsageTracker:  usageTracker,
+		JudgeProvider: provider,
+		JudgeModel:    model,
 	}
 }
 
@@ -63,150 +81,319 @@ func (m *Maestro) checkPause() {
 }
 
 // ExecutePlan runs the autonomous execution loop
-func (m *Maestro) ExecutePlan(ctx context.Context, planContent string) error {
+func (m *Maestro) ExecutePlan(ctx context.Context, planContent string) (err error) {
 	m.CurrentStepIdx = 0
 	m.ModifiedFiles = nil
 	_ = m.Events.Status("\u001b[36mStarting autonomous execution...\u001b[0m")
 
-	// Load setup to access budget settings
-	setup, err := config.LoadSetup()
-	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
-	}
-
-	// Check if budget is exceeded before starting
-	if setup.Defaults.BudgetMode {
-		exceeded, remaining := m.UsageTracker.CheckBudget(setup.Defaults.MonthlyBudget)
-		if exceeded {
-			return fmt.Errorf("monthly budget exceeded: $%.2f spent, $%.2f budget, -$%.2f remaining",
-				m.UsageTracker.GetTotalCost(), setup.Defaults.MonthlyBudget, -remaining)
-		}
-		if remaining < setup.Defaults.MaxCostPerTask {
-			_ = m.Events.Notify(fmt.Sprintf("\u001b[33mWarning: Budget running low ($%.2f remaining)\u001b[0m", remaining), "warn")
-		}
+	sessionID := uuid.New().String()
+	ctx = ledger.WithSession(ctx, sessionID)
+	ctx, span := telemetry.StartSession(ctx, sessionID, planContent)
+	defer func() { telemetry.End(span, err) }()
+	defer telemetry.TrackSession()()
+
+	// Don't start a plan when a budget is already spent; requests made
+	// while it runs are checked by the provider
+	if _, err := budget.Preflight(ctx, "", "", 0, 0); err != nil {
+		return err
 	}
 
 	// Begin tracing session
-	sessionID := uuid.New().String()
 	if m.Tracer != nil {
 		_ = m.Tracer.Begin(sessionID, planContent)
 		defer func() { _ = m.Tracer.End(true) }() // End with success status (will be updated on error)
 	}
 
-	// Parse plan into steps (simple version: split by phases)
-	steps := m.parsePlan(planContent)
+	steps, err := m.parsePlanDAG(planContent)
+	if err != nil {
+		if m.Tracer != nil {
+			_ = m.Tracer.End(false)
+		}
+		return err
+	}
 
-	for stepIdx, step := range steps {
-		_ = m.Events.Status(fmt.Sprintf("\u001b[34mStep %d/%d\u001b[0m: %s", stepIdx+1, len(steps), step.Title))
+	err = m.runSteps(ctx, steps)
+	if err == nil {
+		err = m.verifyFullSuite(ctx, len(steps))
+	}
+	if err != nil {
+		// Update tracer with failure status
+		if m.Tracer != nil {
+			// Override the success status set in defer
+			_ = m.Tracer.End(false)
+		}
+		return err
+	}
+
+	_ = m.Events.Message("\u001b[32mAutonomous execution completed successfully!\u001b[0m")
+	// On successful completion, update tracer status
+	if m.Tracer != nil {
+		_ = m.Tracer.End(true)
+	}
+	return nil
+}
+
+// runSteps schedules the plan DAG. Independent steps with disjoint target
+// files run concurrently; the combined result is verified at the join.
+func (m *Maestro) runSteps(ctx context.Context, steps []PlanStep) error {
+	done := make(map[string]bool, len(steps))
+	for len(done) < len(steps) {
+		batch := nextBatch(steps, done, m.MaxParallel)
+		if len(batch) == 0 {
+			return fmt.Errorf("no runnable steps left; check depends_on in the plan")
+		}
 
-		var lastCheckpoint *Checkpoint
 		var err error
+		if len(batch) == 1 {
+			err = m.runStep(ctx, batch[0], steps, nil)
+		} else {
+			err = m.runParallel(ctx, batch, steps)
+		}
+		if err != nil {
+			return err
+		}
 
-		// Track history across attempts for context
-		var history []llm.ChatMessage
+		for _, idx := range batch {
+			done[steps[idx].ID] = true
+		}
+	}
+	return nil
+}
 
-		// Try execution with retries
-		for attempt := 0; attempt < m.MaxRetries; attempt++ {
-			if attempt > 0 {
-				_ = m.Events.Status(fmt.Sprintf("Retry %d/%d", attempt, m.MaxRetries))
-			}
+// verifyFullSuite runs the whole test suite once, after the last step. Steps
+// are only verified against the tests they impact, so this catches anything
+// that selection missed; failures get one more repair step.
+func (m *Maestro) verifyFullSuite(ctx context.Context, stepIdx int) error {
+	_ = m.Events.Status("Running full test suite...")
+	verifier := NewTestVerifier(m.CWD)
+	verifier.FullSuite = true
+	result, err := verifier.Verify(ctx)
+	if err != nil {
+		return err
+	}
+	if result.Report != nil {
+		_ = live.GetClient().SendTestReport(result.Report)
+	}
+	m.reportFlaky(result)
+	if result.Success {
+		_ = m.Events.Status("\u001b[32mFull test suite passed\u001b[0m")
+		return nil
+	}
 
-			// Execute the step
-			m.CurrentStepIdx = stepIdx
-			m.checkPause()
+	_ = m.Events.Notify(fmt.Sprintf("\u001b[33mFull test suite failed\u001b[0m: %s", result.Output), "warn")
+	m.fullSuite = true
+	defer func() { m.fullSuite = false }()
 
-			result, modifiedFiles, err := m.executeStepWithHistory(ctx, step, history)
-			m.ModifiedFiles = modifiedFiles // Use the actual modified files returned by the agent
-			_ = result                      // Use the result to avoid unused variable error, could be used for additional processing later
+	fix := PlanStep{
+		ID:      "full-suite",
+		Title:   "Fix full test suite",
+		Content: fmt.Sprintf("All plan steps are done, but the full test suite fails:\n\n%s\n\nFix the implementation so the whole suite passes.", result.Output),
+	}
+	steps := make([]PlanStep, stepIdx+1)
+	steps[stepIdx] = fix
+	return m.runStep(ctx, stepIdx, steps, nil)
+}
 
-			if err != nil {
-				_ = m.Events.Notify(fmt.Sprintf("\u001b[31mExecution failed\u001b[0m: %v", err), "error")
-				continue
-			}
+// runParallel executes a batch of independent steps concurrently, then
+// verifies once. Steps that failed, or all of them if the join doesn't
+// verify, are repaired one at a time with the usual retry loop.
+func (m *Maestro) runParallel(ctx context.Context, batch []int, steps []PlanStep) error {
+	titles := make([]string, len(batch))
+	for i, idx := range batch {
+		titles[i] = steps[idx].Title
+	}
+	_ = m.Events.Status(fmt.Sprintf("\u001b[34mRunning %d steps in parallel\u001b[0m: %s", len(batch), strings.Join(titles, ", ")))
+	m.checkPause()
 
-			// Verify the changes
-			verifyResult, verifyErr := m.verify(ctx)
-			if verifyErr != nil {
-				_ = m.Events.Notify(fmt.Sprintf("\u001b[31mVerification error\u001b[0m: %v", verifyErr), "error")
-				if m.Tracer != nil {
-					_ = m.Tracer.RecordMetrics("Verification", observability.Metrics{ErrorMessage: verifyErr.Error()})
+	type outcome struct {
+		files []string
+		err   error
+	}
+	outcomes := make([]outcome, len(batch))
+
+	var wg sync.WaitGroup
+	for i, idx := range batch {
+		wg.Add(1)
+		go func(i, idx int) {
+			defer wg.Done()
+			stepCtx, span := telemetry.StartStep(ctx, idx+1, steps[idx].Title)
+			_, files, err := m.executeStepWithHistory(stepCtx, steps[idx], nil)
+			telemetry.End(span, err)
+			outcomes[i] = outcome{files: files, err: err}
+		}(i, idx)
+	}
+	wg.Wait()
+
+	var modified []string
+	var failed []int
+	for i, o := range outcomes {
+		if o.err != nil {
+			_ = m.Events.Notify(fmt.Sprintf("\u001b[31mExecution failed\u001b[0m (%s): %v", steps[batch[i]].Title, o.err), "error")
+			failed = append(failed, batch[i])
+			continue
+		}
+		modified = append(modified, o.files...)
+	}
+	m.ModifiedFiles = modified
+
+	if len(failed) == 0 {
+		_ = m.Events.Status("Join point: verifying combined changes...")
+		batchSteps := make([]PlanStep, len(batch))
+		for i, idx := range batch {
+			batchSteps[i] = steps[idx]
+		}
+		verifyResult, verifyErr := m.verifyStep(ctx, batchSteps...)
+		if verifyErr == nil && verifyResult.Success {
+			_ = m.Events.Status("\u001b[32mVerification passed\u001b[0m, saving checkpoint...")
+			for _, idx := range batch {
+				if _, err := m.Checkpoints.Save(idx, m.ModifiedFiles); err != nil {
+					_ = m.Events.Notify(fmt.Sprintf("Checkpoint save failed: %v", err), "warn")
 				}
-				continue
 			}
+			_ = m.Events.Complete()
+			return nil
+		}
 
-			if !verifyResult.Success {
-				_ = m.Events.Notify(fmt.Sprintf("\u001b[33mVerification failed\u001b[0m: %s", verifyResult.Output), "warn")
-
-				// Classify error and decide recovery strategy
-				errorType := ClassifyError(verifyResult.Output)
-				_ = m.Events.Status(fmt.Sprintf("Error type: %s, attempting recovery...", errorType))
-
-				// Create recovery context with more information
-				recoveryCtx := &RecoveryContext{
-					ErrorType:     errorType,
-					ErrorOutput:   verifyResult.Output,
-					ModifiedFiles: m.ModifiedFiles,
-					StepIndex:     stepIdx,
-					Attempts:      attempt,
-					MaxAttempts:   m.MaxRetries,
-				}
+		output := ""
+		if verifyErr != nil {
+			output = verifyErr.Error()
+		} else {
+			output = verifyResult.Output
+		}
+		_ = m.Events.Notify(fmt.Sprintf("\u001b[33mJoin verification failed\u001b[0m: %s", output), "warn")
 
-				// Try advanced recovery first
-				advancedPrompt, found := m.Recovery.AdvancedRecovery(recoveryCtx)
-				if !found {
-					// Fall back to basic error formatting
-					advancedPrompt = m.Recovery.GenerateFixPromptWithContext(recoveryCtx)
-				}
+		for _, idx := range batch {
+			history := []llm.ChatMessage{
+				{Role: "user", Content: stepPrompt(steps[idx])},
+				{Role: "user", Content: fmt.Sprintf("This step ran in parallel with: %s. The combined changes failed verification:\n\n%s\n\nFix any problems caused by this step's changes.", strings.Join(titles, ", "), output)},
+			}
+			if err := m.runStep(ctx, idx, steps, history); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
-				// For build errors, rollback if we have a checkpoint
-				if errorType == ErrorBuild && lastCheckpoint != nil {
-					_ = m.Events.Status("\u001b[35mRolling back to last checkpoint...\u001b[0m")
-					if rollbackErr := m.Recovery.Rollback(lastCheckpoint.ID); rollbackErr != nil {
-						_ = m.Events.Notify(fmt.Sprintf("Rollback failed: %v", rollbackErr), "error")
-					}
-				}
+	for _, idx := range failed {
+		if err := m.runStep(ctx, idx, steps, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-				// Add recovery prompt to history for next attempt
-				recoveryMessage := llm.ChatMessage{
-					Role:    "user",
-					Content: advancedPrompt,
-				}
-				history = append(history, recoveryMessage)
+// runStep executes one step with verification, recovery and retries
+func (m *Maestro) runStep(ctx context.Context, stepIdx int, steps []PlanStep, history []llm.ChatMessage) (err error) {
+	step := steps[stepIdx]
+	ctx, span := telemetry.StartStep(ctx, stepIdx+1, step.Title)
+	defer func() { telemetry.End(span, err) }()
+	progress := events.Step{Index: stepIdx + 1, Total: len(steps), Title: step.Title, Status: "running"}
+	events.PublishStep(progress)
+	defer func() {
+		progress.Status = "completed"
+		if err != nil {
+			progress.Status = "failed"
+			progress.Detail = err.Error()
+		}
+		events.PublishStep(progress)
+	}()
+	_ = m.Events.Status(fmt.Sprintf("\u001b[34mStep %d/%d\u001b[0m: %s", stepIdx+1, len(steps), step.Title))
 
-				verificationErr := fmt.Errorf("verification failed: %s", verifyResult.Error)
-				if m.Tracer != nil {
-					_ = m.Tracer.RecordMetrics("Verification", observability.Metrics{ErrorMessage: verificationErr.Error()})
-				}
-				continue
+	var lastCheckpoint *Checkpoint
+	var lastErr error
+
+	// Try execution with retries
+	for attempt := 0; attempt < m.MaxRetries; attempt++ {
+		if attempt > 0 {
+			_ = m.Events.Status(fmt.Sprintf("Retry %d/%d", attempt, m.MaxRetries))
+		}
+
+		// Execute the step
+		m.CurrentStepIdx = stepIdx
+		m.checkPause()
+
+		_, modifiedFiles, err := m.executeStepWithHistory(ctx, step, history)
+		m.ModifiedFiles = modifiedFiles // Use the actual modified files returned by the agent
+
+		if errors.Is(err, budget.ErrExceeded) {
+			_ = m.Events.Notify(fmt.Sprintf("\u001b[31mStopped\u001b[0m: %v", err), "error")
+			return fmt.Errorf("step %d stopped: %w", stepIdx, err)
+		}
+		if err != nil {
+			_ = m.Events.Notify(fmt.Sprintf("\u001b[31mExecution failed\u001b[0m: %v", err), "error")
+			lastErr = err
+			continue
+		}
+
+		// Verify the changes
+		verifyResult, verifyErr := m.verifyStep(ctx, step)
+		if verifyErr != nil {
+			_ = m.Events.Notify(fmt.Sprintf("\u001b[31mVerification error\u001b[0m: %v", verifyErr), "error")
+			if m.Tracer != nil {
+				_ = m.Tracer.RecordMetrics("Verification", observability.Metrics{ErrorMessage: verifyErr.Error()})
 			}
+			lastErr = verifyErr
+			continue
+		}
 
-			// Success! Save checkpoint
-			_ = m.Events.Status("\u001b[32mVerification passed\u001b[0m, saving checkpoint...")
-			_, err = m.Checkpoints.Save(stepIdx, m.ModifiedFiles)
-			if err != nil {
-				_ = m.Events.Notify(fmt.Sprintf("Checkpoint save failed: %v", err), "warn")
+		if !verifyResult.Success {
+			_ = m.Events.Notify(fmt.Sprintf("\u001b[33mVerification failed\u001b[0m: %s", verifyResult.Output), "warn")
+
+			// Classify error and decide recovery strategy
+			errorType := ClassifyResult(verifyResult)
+			telemetry.RecordVerification(false, string(errorType))
+			_ = m.Events.Status(fmt.Sprintf("Error type: %s, attempting recovery...", errorType))
+
+			// Create recovery context with more information
+			recoveryCtx := &RecoveryContext{
+				ErrorType:     errorType,
+				ErrorOutput:   verifyResult.Output,
+				ModifiedFiles: m.ModifiedFiles,
+				StepIndex:     stepIdx,
+				Attempts:      attempt,
+				MaxAttempts:   m.MaxRetries,
 			}
 
-			_ = m.Events.Complete()
-			break
-		}
+			// Try advanced recovery first
+			advancedPrompt, found := m.Recovery.AdvancedRecovery(recoveryCtx)
+			if !found {
+				// Fall back to basic error formatting
+				advancedPrompt = m.Recovery.GenerateFixPromptWithContext(recoveryCtx)
+			}
 
-		if err != nil {
-			// Update tracer with failure status
+			// For build errors, rollback if we have a checkpoint
+			if errorType == ErrorBuild && lastCheckpoint != nil {
+				_ = m.Events.Status("\u001b[35mRolling back to last checkpoint...\u001b[0m")
+				if rollbackErr := m.Recovery.Rollback(lastCheckpoint.ID); rollbackErr != nil {
+					_ = m.Events.Notify(fmt.Sprintf("Rollback failed: %v", rollbackErr), "error")
+				}
+			}
+
+			// Keep the step prompt and add the recovery prompt for the next attempt
+			if len(history) == 0 {
+				history = append(history, llm.ChatMessage{Role: "user", Content: stepPrompt(step)})
+			}
+			history = append(history, llm.ChatMessage{Role: "user", Content: advancedPrompt})
+
+			lastErr = fmt.Errorf("verification failed: %s", verifyResult.Error)
 			if m.Tracer != nil {
-				// Override the success status set in defer
-				_ = m.Tracer.End(false)
+				_ = m.Tracer.RecordMetrics("Verification", observability.Metrics{ErrorMessage: lastErr.Error()})
 			}
-			return fmt.Errorf("step %d failed after %d retries: %w", stepIdx, m.MaxRetries, err)
+			continue
 		}
-	}
 
-	_ = m.Events.Message("\u001b[32mAutonomous execution completed successfully!\u001b[0m")
-	// On successful completion, update tracer status
-	if m.Tracer != nil {
-		_ = m.Tracer.End(true)
+		// Success! Save checkpoint
+		telemetry.RecordVerification(true, "")
+		_ = m.Events.Status("\u001b[32mVerification passed\u001b[0m, saving checkpoint...")
+		if _, err := m.Checkpoints.Save(stepIdx, m.ModifiedFiles); err != nil {
+			_ = m.Events.Notify(fmt.Sprintf("Checkpoint save failed: %v", err), "warn")
+		}
+
+		_ = m.Events.Complete()
+		return nil
 	}
-	return nil
+
+	return fmt.Errorf("step %d failed after %d retries: %w", stepIdx, m.MaxRetries, lastErr)
 }
 
 // ResumeExecution continues from the last successful checkpoint
@@ -242,7 +429,7 @@ func (m *Maestro) ExecuteStep(ctx context.Context, step PlanStep) (string, []str
 }
 
 func (m *Maestro) executeStepWithHistory(ctx context.Context, step PlanStep, history []llm.ChatMessage) (string, []string, error) {
-	editorAgent := agents.NewEditor(m.Provider, m.CWD, m.Model)
+	editorAgent := agents.NewEditorWithObserver(m.Provider, m.CWD, m.Model, observability.NewTracingObserver(m.Observer, m.Tracer))
 
 	statusCallback := func(status string) {
 		_ = m.Events.Status(status)
@@ -251,7 +438,7 @@ func (m *Maestro) executeStepWithHistory(ctx context.Context, step PlanStep, his
 	// If no history provided, create initial history
 	if len(history) == 0 {
 		history = []llm.ChatMessage{
-			{Role: "user", Content: fmt.Sprintf("Implement this step:\n\n# %s\n\n%s", step.Title, step.Content)},
+			{Role: "user", Content: stepPrompt(step)},
 		}
 	}
 
@@ -282,7 +469,7 @@ func (m *Maestro) executeStep(ctx context.Context, step PlanStep) (string, []str
 	}
 
 	history := []llm.ChatMessage{
-		{Role: "user", Content: fmt.Sprintf("Implement this step:\n\n# %s\n\n%s", step.Title, step.Content)},
+		{Role: "user", Content: stepPrompt(step)},
 	}
 
 	result, modifiedFiles, err := editorAgent.Execute(ctx, history, statusCallback)
@@ -294,6 +481,68 @@ func (m *Maestro) executeStep(ctx context.Context, step PlanStep) (string, []str
 	return result, modifiedFiles, err
 }
 
+// verifyStep runs the verifiers and, once they pass, checks the acceptance
+// criteria of the given steps
+func (m *Maestro) verifyStep(ctx context.Context, steps ...PlanStep) (*VerificationResult, error) {
+	result, err := m.verify(ctx)
+	if err != nil || !result.Success {
+		return result, err
+	}
+	return m.checkAcceptance(ctx, steps)
+}
+
+// checkAcceptance verifies the steps' acceptance criteria and reports the
+// per-criterion results as a ValidationEvent
+func (m *Maestro) checkAcceptance(ctx context.Context, steps []PlanStep) (*VerificationResult, error) {
+	var criteria, ids []string
+	for _, step := range steps {
+		criteria = append(criteria, step.Acceptance...)
+		if len(step.Acceptance) > 0 {
+			ids = append(ids, step.ID)
+		}
+	}
+	if len(criteria) == 0 {
+		return &VerificationResult{Success: true}, nil
+	}
+
+	_ = m.Events.Status(fmt.Sprintf("Checking %d acceptance criteria...", len(criteria)))
+	verifier := NewCriteriaVerifier(m.CWD, criteria, m.Provider, m.Model)
+	verifier.JudgeProvider = m.JudgeProvider
+	verifier.JudgeModel = m.JudgeModel
+
+	start := time.Now()
+	result, err := verifier.Verify(ctx)
+	if m.Tracer != nil {
+		metrics := observability.Metrics{DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			metrics.ErrorMessage = err.Error()
+		} else if !result.Success {
+			metrics.ErrorMessage = result.Error.Error()
+		}
+		_ = m.Tracer.RecordMetrics("CriteriaVerifier", metrics)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Observer != nil {
+		var issues []string
+		for _, c := range result.Criteria {
+			if !c.Passed && !c.Skipped {
+				issues = append(issues, c.Criterion)
+			}
+		}
+		m.Observer.Emit(&observability.ValidationEvent{
+			BaseEvent: observability.BaseEvent{Time: time.Now()},
+			Success:   result.Success,
+			Issues:    issues,
+			Step:      strings.Join(ids, ","),
+			Criteria:  result.Criteria,
+		})
+	}
+	return result, nil
+}
+
 // verify runs all verifiers
 func (m *Maestro) verify(ctx context.Context) (*VerificationResult, error) {
 	// Dynamically select verifiers based on modified files
@@ -319,6 +568,10 @@ func (m *Maestro) verify(ctx context.Context) (*VerificationResult, error) {
 		if err != nil {
 			return nil, err
 		}
+		if result.Report != nil {
+			_ = live.GetClient().SendTestReport(result.Report)
+		}
+		m.reportFlaky(result)
 		if !result.Success {
 			return result, nil
 		}
@@ -327,6 +580,15 @@ func (m *Maestro) verify(ctx context.Context) (*VerificationResult, error) {
 	return &VerificationResult{Success: true}, nil
 }
 
+// reportFlaky tells the user about flaky tests that were kept out of
+// recovery
+func (m *Maestro) reportFlaky(result *VerificationResult) {
+	if len(result.Flaky) == 0 {
+		return
+	}
+	_ = m.Events.Notify(fmt.Sprintf("\u001b[33mIgnoring %d flaky test(s)\u001b[0m: %s (see gptcode test flaky)", len(result.Flaky), flakyNames(result.Flaky)), "warn")
+}
+
 // selectVerifiers dynamically selects which verifiers to run based on modified files
 func (m *Maestro) selectVerifiers() []Verifier {
 	// Get current modified files (including added, modified, deleted, renamed, copied)
@@ -367,8 +629,10 @@ func (m *Maestro) selectVerifiers() []Verifier {
 	// Only add verifiers if code files were modified
 	var verifiers []Verifier
 	if hasCodeFiles {
+		testVerifier := NewTestVerifier(m.CWD)
+		testVerifier.FullSuite = m.fullSuite
 		verifiers = append(verifiers, NewBuildVerifier(m.CWD))
-		verifiers = append(verifiers, NewTestVerifier(m.CWD))
+		verifiers = append(verifiers, testVerifier)
 	}
 
 	// Add lint verifier if it was specifically requested
@@ -383,16 +647,41 @@ func (m *Maestro) selectVerifiers() []Verifier {
 }
 
 type PlanStep struct {
-	Title    string
-	Content  string
-	SubSteps []PlanStep
+	ID         string
+	Title      string
+	Content    string
+	SubSteps   []PlanStep
+	DependsOn  []string
+	Files      []string
+	Acceptance []string
+}
+
+func stepPrompt(step PlanStep) string {
+	return fmt.Sprintf("Implement this step:\n\n# %s\n\n%s", step.Title, step.Content)
 }
 
 func (m *Maestro) ParsePlan(plan string) []PlanStep {
 	return m.parsePlan(plan)
 }
 
+// parsePlanDAG returns the structured step DAG when the plan has one, or the
+// heading-based steps chained sequentially otherwise.
+func (m *Maestro) parsePlanDAG(plan string) ([]PlanStep, error) {
+	steps, ok, err := parseStructuredPlan(plan)
+	if ok {
+		return steps, err
+	}
+	return chainSteps(m.parseHeadings(plan)), nil
+}
+
 func (m *Maestro) parsePlan(plan string) []PlanStep {
+	if steps, err := m.parsePlanDAG(plan); err == nil {
+		return steps
+	}
+	return chainSteps(m.parseHeadings(plan))
+}
+
+func (m *Maestro) parseHeadings(plan string) []PlanStep {
 	var steps []PlanStep
 	lines := strings.Split(plan, "\n")
 
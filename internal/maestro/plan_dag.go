@@ -0,0 +1,221 @@
+package maestro
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// planDocument is the machine-readable plan schema emitted by the planner,
+// either as YAML front-matter or as a fenced JSON block.
+type planDocument struct {
+	Steps []planStepSpec `json:"steps" yaml:"steps"`
+}
+
+type planStepSpec struct {
+	ID          string   `json:"id" yaml:"id"`
+	Title       string   `json:"title" yaml:"title"`
+	Description string   `json:"description" yaml:"description"`
+	DependsOn   []string `json:"depends_on" yaml:"depends_on"`
+	Files       []string `json:"files" yaml:"files"`
+	Acceptance  []string `json:"acceptance" yaml:"acceptance"`
+}
+
+var fencedPlanPattern = regexp.MustCompile("(?s)```(?:json|plan)\\s*\\n(.*?)```")
+
+// parseStructuredPlan extracts a step DAG from a plan. ok is false when the
+// plan has no structured section; err is set when it has an invalid one.
+func parseStructuredPlan(plan string) (steps []PlanStep, ok bool, err error) {
+	var doc planDocument
+	found := false
+
+	if strings.HasPrefix(plan, "---\n") {
+		if end := strings.Index(plan[4:], "\n---"); end >= 0 {
+			if err := yaml.Unmarshal([]byte(plan[4:4+end]), &doc); err == nil && len(doc.Steps) > 0 {
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		for _, m := range fencedPlanPattern.FindAllStringSubmatch(plan, -1) {
+			if !strings.Contains(m[1], `"steps"`) {
+				continue
+			}
+			if err := json.Unmarshal([]byte(m[1]), &doc); err != nil {
+				return nil, true, fmt.Errorf("invalid plan JSON: %w", err)
+			}
+			found = len(doc.Steps) > 0
+			break
+		}
+	}
+
+	if !found {
+		return nil, false, nil
+	}
+
+	for i, spec := range doc.Steps {
+		id := strings.TrimSpace(spec.ID)
+		if id == "" {
+			id = fmt.Sprintf("step-%d", i+1)
+		}
+		title := spec.Title
+		if title == "" {
+			title = id
+		}
+		steps = append(steps, PlanStep{
+			ID:         id,
+			Title:      title,
+			Content:    stepContent(spec),
+			DependsOn:  spec.DependsOn,
+			Files:      spec.Files,
+			Acceptance: spec.Acceptance,
+		})
+	}
+
+	if err := validateDAG(steps); err != nil {
+		return nil, true, err
+	}
+	return steps, true, nil
+}
+
+func stepContent(spec planStepSpec) string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(spec.Description))
+	sb.WriteString("\n")
+	if len(spec.Files) > 0 {
+		sb.WriteString("\nTarget files:\n")
+		for _, f := range spec.Files {
+			fmt.Fprintf(&sb, "- %s\n", f)
+		}
+	}
+	if len(spec.Acceptance) > 0 {
+		sb.WriteString("\nAcceptance criteria:\n")
+		for _, a := range spec.Acceptance {
+			fmt.Fprintf(&sb, "- %s\n", a)
+		}
+	}
+	return sb.String()
+}
+
+// validateDAG checks IDs are unique, dependencies exist and there are no cycles
+func validateDAG(steps []PlanStep) error {
+	index := make(map[string]int, len(steps))
+	for i, s := range steps {
+		if _, dup := index[s.ID]; dup {
+			return fmt.Errorf("duplicate step id %q", s.ID)
+		}
+		index[s.ID] = i
+	}
+
+	indegree := make([]int, len(steps))
+	for i, s := range steps {
+		for _, dep := range s.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return fmt.Errorf("step %q depends on unknown step %q", s.ID, dep)
+			}
+			indegree[i]++
+		}
+	}
+
+	var queue []int
+	for i, d := range indegree {
+		if d == 0 {
+			queue = append(queue, i)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		visited++
+		for i, s := range steps {
+			for _, dep := range s.DependsOn {
+				if dep == steps[cur].ID {
+					indegree[i]--
+					if indegree[i] == 0 {
+						queue = append(queue, i)
+					}
+				}
+			}
+		}
+	}
+	if visited != len(steps) {
+		return fmt.Errorf("plan has a dependency cycle")
+	}
+	return nil
+}
+
+// chainSteps gives heading-based steps IDs and sequential dependencies
+func chainSteps(steps []PlanStep) []PlanStep {
+	for i := range steps {
+		steps[i].ID = fmt.Sprintf("step-%d", i+1)
+		if i > 0 {
+			steps[i].DependsOn = []string{steps[i-1].ID}
+		}
+	}
+	return steps
+}
+
+// nextBatch picks the steps to run together: ready steps (all dependencies
+// done) whose target files don't overlap, in plan order. A step without
+// declared files may touch anything, so it always runs alone.
+func nextBatch(steps []PlanStep, done map[string]bool, maxParallel int) []int {
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+
+	var batch []int
+	var claimed []string
+	for i, s := range steps {
+		if done[s.ID] || !depsDone(s, done) {
+			continue
+		}
+		if len(batch) == 0 {
+			batch = append(batch, i)
+			claimed = append(claimed, s.Files...)
+			if len(s.Files) == 0 {
+				return batch
+			}
+			continue
+		}
+		if len(batch) >= maxParallel {
+			break
+		}
+		if len(s.Files) == 0 || filesOverlap(claimed, s.Files) {
+			continue
+		}
+		batch = append(batch, i)
+		claimed = append(claimed, s.Files...)
+	}
+	return batch
+}
+
+func depsDone(s PlanStep, done map[string]bool) bool {
+	for _, dep := range s.DependsOn {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// filesOverlap reports whether two file sets share a path, treating
+// directories as covering everything beneath them.
+func filesOverlap(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			cx, cy := filepath.Clean(x), filepath.Clean(y)
+			if cx == cy ||
+				strings.HasPrefix(cy, cx+string(filepath.Separator)) ||
+				strings.HasPrefix(cx, cy+string(filepath.Separator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
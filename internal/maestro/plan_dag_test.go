@@ -0,0 +1,173 @@
+package maestro
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gptcode/internal/llm"
+)
+
+const dagPlan = "# Plan\n\n## Changes\nAdd two helpers.\n\n```json\n" + `{"steps": [
+  {"id": "a", "title": "Add A", "description": "Create a.go", "files": ["a.go"], "acceptance": ["go build ./... succeeds"]},
+  {"id": "b", "title": "Add B", "description": "Create b.go", "files": ["b.go"]},
+  {"id": "c", "title": "Wire", "description": "Create c.go", "depends_on": ["a", "b"], "files": ["c.go"]}
+]}` + "\n```\n"
+
+func TestParsePlan_StructuredJSON(t *testing.T) {
+	m := NewMaestro(nil, ".", "")
+	steps, err := m.parsePlanDAG(dagPlan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != 3 || steps[2].ID != "c" || len(steps[2].DependsOn) != 2 {
+		t.Fatalf("unexpected steps: %#v", steps)
+	}
+	if !strings.Contains(steps[0].Content, "Acceptance criteria:\n- go build ./... succeeds") {
+		t.Errorf("expected acceptance criteria in step content, got %q", steps[0].Content)
+	}
+}
+
+func TestParsePlan_FrontMatter(t *testing.T) {
+	plan := `---
+steps:
+  - id: one
+    title: First
+    files: [x.go]
+  - id: two
+    title: Second
+    depends_on: [one]
+---
+# Plan
+`
+	m := NewMaestro(nil, ".", "")
+	steps, err := m.parsePlanDAG(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != 2 || steps[1].DependsOn[0] != "one" {
+		t.Fatalf("unexpected steps: %#v", steps)
+	}
+}
+
+func TestParsePlan_InvalidDAG(t *testing.T) {
+	m := NewMaestro(nil, ".", "")
+	cycle := "```json\n" + `{"steps": [{"id": "a", "depends_on": ["b"]}, {"id": "b", "depends_on": ["a"]}]}` + "\n```"
+	if _, err := m.parsePlanDAG(cycle); err == nil {
+		t.Error("expected cycle to be rejected")
+	}
+	unknown := "```json\n" + `{"steps": [{"id": "a", "depends_on": ["zzz"]}]}` + "\n```"
+	if _, err := m.parsePlanDAG(unknown); err == nil {
+		t.Error("expected unknown dependency to be rejected")
+	}
+}
+
+func TestParsePlan_HeadingsAreSequential(t *testing.T) {
+	m := NewMaestro(nil, ".", "")
+	steps := m.parsePlan("## A\na\n## B\nb\n")
+	if len(steps) != 2 || len(steps[1].DependsOn) != 1 || steps[1].DependsOn[0] != steps[0].ID {
+		t.Fatalf("expected heading steps to be chained, got %#v", steps)
+	}
+}
+
+func TestNextBatch(t *testing.T) {
+	steps := []PlanStep{
+		{ID: "a", Files: []string{"pkg/a.go"}},
+		{ID: "b", Files: []string{"pkg/a.go"}},
+		{ID: "c", Files: []string{"other"}},
+		{ID: "d", Files: []string{"other/x.go"}},
+		{ID: "e"},
+		{ID: "f", DependsOn: []string{"a"}, Files: []string{"f.go"}},
+	}
+
+	batch := nextBatch(steps, map[string]bool{}, 4)
+	if fmt.Sprint(batch) != "[0 2]" {
+		t.Errorf("expected a and c to run together, got %v", batch)
+	}
+
+	batch = nextBatch(steps, map[string]bool{"a": true, "b": true, "c": true, "d": true}, 4)
+	if fmt.Sprint(batch) != "[4]" {
+		t.Errorf("expected step without files to run alone, got %v", batch)
+	}
+
+	if batch := nextBatch(steps, map[string]bool{}, 1); len(batch) != 1 {
+		t.Errorf("expected max parallel to be respected, got %v", batch)
+	}
+}
+
+// barrierProvider writes the file named in the step and blocks the first
+// call of each parallel step until all of them are in flight.
+type barrierProvider struct {
+	mu      sync.Mutex
+	arrived map[string]bool
+	release chan struct{}
+	want    int
+}
+
+func (p *barrierProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	if len(req.Messages) == 0 {
+		// Acceptance criteria compilation: leave them to the judge
+		return &llm.ChatResponse{Text: `{"checks": []}`}, nil
+	}
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role == "tool" {
+		return &llm.ChatResponse{Text: "done"}, nil
+	}
+
+	prompt := req.Messages[0].Content
+	var file string
+	for _, f := range []string{"a.go", "b.go", "c.go"} {
+		if strings.Contains(prompt, "- "+f) {
+			file = f
+		}
+	}
+
+	if file != "c.go" {
+		p.mu.Lock()
+		p.arrived[file] = true
+		if len(p.arrived) == p.want {
+			close(p.release)
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-p.release:
+		case <-time.After(5 * time.Second):
+			return nil, fmt.Errorf("%s was not run concurrently", file)
+		}
+	}
+
+	name := strings.ToUpper(strings.TrimSuffix(file, ".go"))
+	args := fmt.Sprintf(`{"path":%q,"content":"package demo\n\nfunc %s() {}\n"}`, file, name)
+	return &llm.ChatResponse{ToolCalls: []llm.ChatToolCall{{ID: "call_" + file, Name: "write_file", Arguments: args}}}, nil
+}
+
+func TestRunSteps_ParallelBranchesThenJoin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module demo\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMaestro(nil, dir, "test-model")
+	m.Provider = &barrierProvider{arrived: map[string]bool{}, release: make(chan struct{}), want: 2}
+	m.MaxRetries = 1
+
+	steps, err := m.parsePlanDAG(dagPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.runSteps(context.Background(), steps); err != nil {
+		t.Fatalf("runSteps failed: %v", err)
+	}
+
+	for _, f := range []string{"a.go", "b.go", "c.go"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("expected %s to be written: %v", f, err)
+		}
+	}
+}
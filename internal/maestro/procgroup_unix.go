@@ -0,0 +1,19 @@
+//go:build !windows
+
+package maestro
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killGroupOnCancel starts cmd in its own process group and makes
+// cancelling its context kill the whole group. Servers started through
+// `go run` or npm leave the real server as a child that would otherwise
+// keep running, holding the port and the output pipe.
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package maestro
+
+import "os/exec"
+
+// killGroupOnCancel leaves the default of killing only the process itself;
+// WaitDelay bounds the wait for any children
+func killGroupOnCancel(cmd *exec.Cmd) {}
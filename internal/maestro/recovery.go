@@ -3,6 +3,8 @@ package maestro
 import (
 	"fmt"
 	"strings"
+
+	"gptcode/internal/testreport"
 )
 
 // RecoveryStrategy handles error recovery during execution
@@ -49,6 +51,10 @@ const (
 	ErrorSnapshot ErrorType = "snapshot"
 	ErrorLint     ErrorType = "lint"
 	ErrorUnknown  ErrorType = "unknown"
+
+	// ErrorAcceptance means the code builds and tests pass but the step's
+	// acceptance criteria aren't met
+	ErrorAcceptance ErrorType = "acceptance"
 )
 
 // ClassifyError attempts to categorize an error based on output
@@ -107,6 +113,25 @@ func ClassifyError(output string) ErrorType {
 	return ErrorUnknown
 }
 
+// ClassifyResult categorizes a failed verification. A structured test report
+// with failing tests is trusted over pattern matching on the output, which
+// otherwise mistakes assertion messages like "expected 3" for syntax errors.
+func ClassifyResult(result *VerificationResult) ErrorType {
+	if len(result.Criteria) > 0 {
+		return ErrorAcceptance
+	}
+	errorType := ClassifyError(result.Output)
+	if result.Report == nil || errorType == ErrorSnapshot {
+		return errorType
+	}
+	for _, tc := range result.Report.Failures() {
+		if tc.Name != testreport.SetupFailure {
+			return ErrorTest
+		}
+	}
+	return errorType
+}
+
 func (rs *RecoveryStrategy) GenerateFixPrompt(errorType ErrorType, errorOutput string) string {
 	ctx := &RecoveryContext{
 		ErrorType:   errorType,
@@ -166,6 +191,15 @@ Please fix the implementation to make tests pass. Common issues:
 
 Analyze the failing tests and correct the implementation.`, ctx.ErrorOutput)
 
+	case ErrorAcceptance:
+		prompt = fmt.Sprintf(`The code builds and tests pass, but the step's ACCEPTANCE CRITERIA ARE NOT MET.
+
+%s
+
+Each failing criterion is listed with the evidence from its check. Complete the
+missing behavior so every criterion holds. Do not weaken or remove checks, and
+do not special-case the probes; implement the feature itself.`, ctx.ErrorOutput)
+
 	case ErrorSnapshot:
 		snapshotPrompt := `SNAPSHOT TESTS FAILED - OUTPUT CHANGED
 
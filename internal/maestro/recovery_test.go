@@ -1,6 +1,10 @@
 package maestro
 
-import "testing"
+import (
+	"testing"
+
+	"gptcode/internal/testreport"
+)
 
 func TestClassifyError(t *testing.T) {
 	cases := map[string]ErrorType{
@@ -14,3 +18,19 @@ func TestClassifyError(t *testing.T) {
 		}
 	}
 }
+
+func TestClassifyResultTrustsTestReport(t *testing.T) {
+	report := &testreport.TestReport{Tests: []testreport.TestCase{
+		{Name: "TestAdd", Status: testreport.StatusFail, Message: "expected 3, got 4"},
+	}}
+	result := &VerificationResult{Output: report.FailureText(), Report: report}
+	if got := ClassifyResult(result); got != ErrorTest {
+		t.Fatalf("want %s got %s", ErrorTest, got)
+	}
+
+	report.Tests[0] = testreport.TestCase{Name: testreport.SetupFailure, Status: testreport.StatusFail, Message: "build failed"}
+	result.Output = report.FailureText()
+	if got := ClassifyResult(result); got != ErrorBuild {
+		t.Fatalf("want %s got %s", ErrorBuild, got)
+	}
+}
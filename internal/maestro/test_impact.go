@@ -0,0 +1,171 @@
+package maestro
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/graph"
+	"gptcode/internal/testreport"
+)
+
+// isTestFile reports whether path is a test file by the language's naming
+// conventions
+func isTestFile(lang, path string) bool {
+	base := filepath.Base(path)
+	slashed := "/" + filepath.ToSlash(path)
+
+	switch lang {
+	case "python":
+		return strings.HasSuffix(base, ".py") &&
+			(strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test.py"))
+	case "javascript", "typescript":
+		for _, marker := range []string{".test.", ".spec."} {
+			if strings.Contains(base, marker) {
+				return true
+			}
+		}
+		return strings.Contains(slashed, "/__tests__/")
+	case "ruby":
+		return strings.HasSuffix(base, "_spec.rb") || strings.HasSuffix(base, "_test.rb")
+	case "elixir":
+		return strings.HasSuffix(base, "_test.exs")
+	case "rust":
+		// Integration tests; unit tests live inline and are found by content
+		return strings.HasSuffix(base, ".rs") && strings.HasPrefix(filepath.ToSlash(path), "tests/")
+	}
+	return false
+}
+
+// impactedTests maps modified files to the test files that import them,
+// directly or transitively, using the import graph. Modified test files
+// are always included.
+func impactedTests(dir, lang string, modified []string) ([]string, error) {
+	g, err := graph.NewBuilder(dir).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build import graph: %w", err)
+	}
+
+	var tests []string
+	for _, path := range g.Dependents(modified) {
+		if !fileExists(filepath.Join(dir, path)) {
+			continue
+		}
+		if isTestFile(lang, path) || (lang == "rust" && hasInlineRustTests(filepath.Join(dir, path))) {
+			tests = append(tests, path)
+		}
+	}
+	return tests, nil
+}
+
+func hasInlineRustTests(path string) bool {
+	if !strings.HasSuffix(path, ".rs") {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), "#[test]") || strings.Contains(string(data), "#[cfg(test)]")
+}
+
+// selectionArgs returns the argument lists that run only the given tests
+// with the project's runner. Most runners take test files as positional
+// arguments; Rust needs separate runs for integration test targets and for
+// module-path filters on unit tests.
+func (v *TestVerifier) selectionArgs(base []string, tests []string) [][]string {
+	withArgs := func(extra ...string) []string {
+		return append(append([]string(nil), base...), extra...)
+	}
+
+	switch v.Language {
+	case "javascript", "typescript":
+		// jest's args already end with "--"; plain npm test needs one
+		if len(base) == 0 || base[len(base)-1] != "--" {
+			return [][]string{withArgs(append([]string{"--"}, tests...)...)}
+		}
+		return [][]string{withArgs(tests...)}
+	case "rust":
+		return v.rustSelectionArgs(base, tests)
+	default:
+		return [][]string{withArgs(tests...)}
+	}
+}
+
+func (v *TestVerifier) rustSelectionArgs(base []string, tests []string) [][]string {
+	var targets, filters []string
+	wholeCrate := false
+	for _, t := range tests {
+		t = filepath.ToSlash(t)
+		if strings.HasPrefix(t, "tests/") {
+			targets = append(targets, "--test", strings.TrimSuffix(strings.TrimPrefix(t, "tests/"), ".rs"))
+			continue
+		}
+		mod := strings.TrimSuffix(strings.TrimPrefix(t, "src/"), ".rs")
+		mod = strings.TrimSuffix(mod, "/mod")
+		if mod == "lib" || mod == "main" {
+			wholeCrate = true
+			continue
+		}
+		filters = append(filters, strings.ReplaceAll(mod, "/", "::")+"::")
+	}
+
+	var runs [][]string
+	if len(targets) > 0 {
+		runs = append(runs, append(append([]string(nil), base...), targets...))
+	}
+	if wholeCrate || len(filters) > 0 {
+		unit := append([]string(nil), base...)
+		if fileExists(filepath.Join(v.Dir, "src", "lib.rs")) {
+			unit = append(unit, "--lib")
+		} else {
+			unit = append(unit, "--bins")
+		}
+		if !wholeCrate {
+			unit = append(append(unit, "--"), filters...)
+		}
+		runs = append(runs, unit)
+	}
+	return runs
+}
+
+// runImpactedTests runs only the tests affected by the modified files. When
+// the graph can't be built it falls back to the whole suite; when no test is
+// affected it passes, since the full suite still runs at the end of the plan.
+func (v *TestVerifier) runImpactedTests(ctx context.Context, modifiedFiles []string) (*VerificationResult, error) {
+	format, name, base, ok := v.testCommand()
+	if !ok {
+		return &VerificationResult{Success: true}, nil
+	}
+
+	tests, err := impactedTests(v.Dir, v.Language, modifiedFiles)
+	if err != nil {
+		return v.runAllTests(ctx)
+	}
+	if len(tests) == 0 {
+		return &VerificationResult{
+			Success: true,
+			Output:  fmt.Sprintf("no tests import the %d modified file(s)", len(modifiedFiles)),
+		}, nil
+	}
+
+	combined := &testreport.TestReport{}
+	for _, args := range v.selectionArgs(base, tests) {
+		result := v.runTestCommand(ctx, format, name, args)
+		if !result.Success {
+			return result, nil
+		}
+		combined.Merge(result.Report)
+	}
+
+	result := &VerificationResult{
+		Success: true,
+		Output:  fmt.Sprintf("%d impacted test file(s) passed: %s", len(tests), strings.Join(tests, ", ")),
+	}
+	if len(combined.Tests) > 0 {
+		result.Report = combined
+	}
+	return result, nil
+}
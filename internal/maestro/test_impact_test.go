@@ -0,0 +1,88 @@
+package maestro
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImpactedTestsFollowsTransitiveImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/util.js":           "export const add = (a, b) => a + b;\n",
+		"src/calc.js":           "import { add } from './util';\nexport const sum = (xs) => xs.reduce(add, 0);\n",
+		"src/other.js":          "export const x = 1;\n",
+		"src/calc.test.js":      "import { sum } from './calc';\n",
+		"src/other.test.js":     "import { x } from './other';\n",
+		"src/__tests__/util.js": "import { add } from '../util';\n",
+	})
+
+	tests, err := impactedTests(dir, "javascript", []string{"src/util.js"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(tests) != "[src/__tests__/util.js src/calc.test.js]" {
+		t.Errorf("unexpected impacted tests: %v", tests)
+	}
+
+	tests, _ = impactedTests(dir, "javascript", []string{"src/other.test.js"})
+	if fmt.Sprint(tests) != "[src/other.test.js]" {
+		t.Errorf("expected a modified test to select itself, got %v", tests)
+	}
+}
+
+func TestImpactedTestsPython(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app/models.py":           "class User: pass\n",
+		"app/views.py":            "from app.models import User\n",
+		"tests/test_views.py":     "from app.views import *\n",
+		"tests/test_unrelated.py": "import os\n",
+	})
+
+	tests, err := impactedTests(dir, "python", []string{"app/models.py"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(tests) != "[tests/test_views.py]" {
+		t.Errorf("unexpected impacted tests: %v", tests)
+	}
+}
+
+func TestSelectionArgs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/lib.rs": "pub mod net;\n"})
+
+	rust := &TestVerifier{Dir: dir, Language: "rust"}
+	runs := rust.selectionArgs([]string{"test"}, []string{"src/net/client.rs", "src/net/mod.rs", "tests/api.rs"})
+	if fmt.Sprint(runs) != "[[test --test api] [test --lib -- net::client:: net::]]" {
+		t.Errorf("unexpected rust runs: %v", runs)
+	}
+
+	npm := &TestVerifier{Dir: dir, Language: "typescript"}
+	if runs := npm.selectionArgs([]string{"test"}, []string{"a.test.ts"}); fmt.Sprint(runs) != "[[test -- a.test.ts]]" {
+		t.Errorf("unexpected npm runs: %v", runs)
+	}
+	if runs := npm.selectionArgs([]string{"test", "--"}, []string{"a.test.ts"}); fmt.Sprint(runs) != "[[test -- a.test.ts]]" {
+		t.Errorf("unexpected jest runs: %v", runs)
+	}
+
+	mix := &TestVerifier{Dir: dir, Language: "elixir"}
+	if runs := mix.selectionArgs([]string{"test"}, []string{"test/a_test.exs"}); fmt.Sprint(runs) != "[[test test/a_test.exs]]" {
+		t.Errorf("unexpected mix runs: %v", runs)
+	}
+}
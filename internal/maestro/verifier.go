@@ -8,30 +8,89 @@ import (
 	"path/filepath"
 	"strings"
 
+	"gptcode/internal/config"
 	"gptcode/internal/langdetect"
+	"gptcode/internal/observability"
+	"gptcode/internal/testreport"
 )
 
 type VerificationResult struct {
 	Success bool
 	Output  string
 	Error   error
+	// Report holds per-test results when the verifier ran a test runner
+	// in machine-readable mode
+	Report *testreport.TestReport
+	// Criteria holds per-criterion results from the acceptance check
+	Criteria []observability.CriterionResult
+	// Flaky holds failing tests that turned out to be flaky. They don't
+	// fail the result and are reported rather than repaired.
+	Flaky []testreport.TestCase
 }
 
 type Verifier interface {
 	Verify(ctx context.Context) (*VerificationResult, error)
 }
 
+// projectCommands returns the test, lint and build commands set in the
+// project config, if any
+func projectCommands(dir string) config.Commands {
+	setup, _ := config.LoadSetupAt(dir)
+	return setup.Commands
+}
+
+// runProjectCommand runs a command from the project config through the shell
+func runProjectCommand(ctx context.Context, dir, command, failMsg string) *VerificationResult {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return &VerificationResult{
+			Success: false,
+			Output:  string(output),
+			Error:   fmt.Errorf("%s: %w", failMsg, err),
+		}
+	}
+	return &VerificationResult{Success: true, Output: string(output)}
+}
+
 type TestVerifier struct {
 	Dir      string
 	Language string
+	// FullSuite disables test-impact selection. Maestro sets it for the
+	// final check once every plan step is done.
+	FullSuite bool
+	// FlakeReruns is how many times each failing test is re-run in
+	// isolation to tell flakes from real failures; 0 disables re-runs
+	FlakeReruns int
+	// Command replaces the detected test runner when the project config
+	// sets commands.test
+	Command string
 }
 
 func NewTestVerifier(dir string) *TestVerifier {
 	lang := detectLanguage(dir)
-	return &TestVerifier{Dir: dir, Language: lang}
+	return &TestVerifier{Dir: dir, Language: lang, FlakeReruns: DefaultFlakeReruns, Command: projectCommands(dir).Test}
 }
 
 func (v *TestVerifier) Verify(ctx context.Context) (*VerificationResult, error) {
+	result, err := v.runTests(ctx)
+	if err != nil || result.Success || result.Report == nil {
+		return result, err
+	}
+	return v.checkFlakes(ctx, result), nil
+}
+
+// runTests runs the tests impacted by the working tree changes, or the
+// whole suite
+func (v *TestVerifier) runTests(ctx context.Context) (*VerificationResult, error) {
+	if v.Command != "" {
+		return v.runTestCommand(ctx, "", "sh", []string{"-c", v.Command}), nil
+	}
+	if v.FullSuite {
+		return v.runAllTests(ctx)
+	}
+
 	// Get modified files from git to determine what to test
 	gitCmd := exec.CommandContext(ctx, "git", "--no-pager", "diff", "--name-only")
 	gitCmd.Dir = v.Dir
@@ -41,6 +100,13 @@ func (v *TestVerifier) Verify(ctx context.Context) (*VerificationResult, error)
 		return v.runAllTests(ctx)
 	}
 
+	// New files (often new tests) are untracked, so git diff misses them
+	untrackedCmd := exec.CommandContext(ctx, "git", "ls-files", "--others", "--exclude-standard")
+	untrackedCmd.Dir = v.Dir
+	if untracked, err := untrackedCmd.Output(); err == nil {
+		gitOut = append(gitOut, untracked...)
+	}
+
 	modifiedFiles := strings.Split(strings.TrimSpace(string(gitOut)), "\n")
 	if len(modifiedFiles) == 0 || (len(modifiedFiles) == 1 && modifiedFiles[0] == "") {
 		// No files modified, run all tests
@@ -78,58 +144,66 @@ func (v *TestVerifier) Verify(ctx context.Context) (*VerificationResult, error)
 		return v.runGoTestsForModifiedFiles(ctx, testableFiles)
 	}
 
-	// For other languages, run all tests
-	return v.runAllTests(ctx)
+	// For other languages, run the tests that import the modified files
+	return v.runImpactedTests(ctx, testableFiles)
 }
 
 // runAllTests runs tests on the entire project
 func (v *TestVerifier) runAllTests(ctx context.Context) (*VerificationResult, error) {
-	var cmd *exec.Cmd
+	format, name, args, ok := v.testCommand()
+	if !ok {
+		return &VerificationResult{Success: true}, nil
+	}
+	return v.runTestCommand(ctx, format, name, args), nil
+}
+
+// runTestCommand runs one test command, in machine-readable mode when the
+// runner has one
+func (v *TestVerifier) runTestCommand(ctx context.Context, format testreport.Format, name string, args []string) *VerificationResult {
+	if format == "" {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Dir = v.Dir
+		output, err := cmd.CombinedOutput()
+		return testVerification(nil, string(output), err, "tests failed")
+	}
+
+	report, output, err := testreport.Run(ctx, v.Dir, format, name, args...)
+	return testVerification(report, output, err, "tests failed")
+}
 
+// testCommand returns the command that runs the whole suite. ok is false
+// when the project has no test runner to call.
+func (v *TestVerifier) testCommand() (format testreport.Format, name string, args []string, ok bool) {
 	switch v.Language {
 	case "go":
-		cmd = exec.CommandContext(ctx, "go", "test", "./...")
+		format, name, args = testreport.FormatGoJSON, "go", []string{"test", "./..."}
 	case "javascript", "typescript":
-		if fileExists(filepath.Join(v.Dir, "package.json")) {
-			cmd = exec.CommandContext(ctx, "npm", "test")
-		} else {
-			return &VerificationResult{Success: true}, nil
+		if !fileExists(filepath.Join(v.Dir, "package.json")) {
+			return "", "", nil, false
+		}
+		name, args = "npm", []string{"test"}
+		if testreport.UsesJest(v.Dir) {
+			format, args = testreport.FormatJest, []string{"test", "--"}
 		}
 	case "python":
 		if fileExists(filepath.Join(v.Dir, "pytest.ini")) || fileExists(filepath.Join(v.Dir, "setup.py")) {
-			cmd = exec.CommandContext(ctx, "pytest")
+			format, name = testreport.FormatJUnit, "pytest"
 		} else {
-			return &VerificationResult{Success: true}, nil
+			return "", "", nil, false
 		}
 	case "elixir":
-		cmd = exec.CommandContext(ctx, "mix", "test")
+		format, name, args = testreport.FormatExUnit, "mix", []string{"test"}
 	case "ruby":
+		format, name = testreport.FormatRSpec, "rspec"
 		if fileExists(filepath.Join(v.Dir, "Gemfile")) {
-			cmd = exec.CommandContext(ctx, "bundle", "exec", "rspec")
-		} else {
-			cmd = exec.CommandContext(ctx, "rspec")
+			name, args = "bundle", []string{"exec", "rspec"}
 		}
+	case "rust":
+		name, args = "cargo", []string{"test"}
 	default:
-		return &VerificationResult{Success: true}, nil
-	}
-
-	cmd.Dir = v.Dir
-	output, err := cmd.CombinedOutput()
-	outStr := string(output)
-
-	if err != nil {
-		return &VerificationResult{
-			Success: false,
-			Output:  outStr,
-			Error:   fmt.Errorf("tests failed: %w", err),
-		}, nil
+		return "", "", nil, false
 	}
-
-	return &VerificationResult{
-		Success: true,
-		Output:  outStr,
-		Error:   nil,
-	}, nil
+	return format, name, args, true
 }
 
 // runGoTestsForModifiedFiles runs Go tests for packages containing modified files
@@ -147,36 +221,52 @@ func (v *TestVerifier) runGoTestsForModifiedFiles(ctx context.Context, modifiedF
 	}
 
 	// Run tests for each package that has modified files
+	combined := &testreport.TestReport{Runner: "go"}
 	for pkgDir := range packageDirs {
-		cmd := exec.CommandContext(ctx, "go", "test", "./...")
-		cmd.Dir = pkgDir
-		output, err := cmd.CombinedOutput()
-		outStr := string(output)
-
+		report, output, err := testreport.Run(ctx, pkgDir, testreport.FormatGoJSON, "go", "test", "./...")
 		if err != nil {
-			return &VerificationResult{
-				Success: false,
-				Output:  outStr,
-				Error:   fmt.Errorf("tests failed in package %s: %w", pkgDir, err),
-			}, nil
+			return testVerification(report, output, err, fmt.Sprintf("tests failed in package %s", pkgDir)), nil
 		}
+		combined.Merge(report)
 	}
 
 	return &VerificationResult{
 		Success: true,
-		Output:  "tests passed for modified packages",
+		Output:  fmt.Sprintf("tests passed for modified packages (%s)", combined.Summary()),
 		Error:   nil,
+		Report:  combined,
 	}, nil
 }
 
+// testVerification builds a result from a test run. When the runner's report
+// has failures, Output holds only the failing tests rather than the full log.
+func testVerification(report *testreport.TestReport, output string, err error, failMsg string) *VerificationResult {
+	if err == nil {
+		return &VerificationResult{Success: true, Output: output, Report: report}
+	}
+
+	if report.Failed() > 0 {
+		output = report.FailureText()
+	}
+	return &VerificationResult{
+		Success: false,
+		Output:  output,
+		Error:   fmt.Errorf("%s: %w", failMsg, err),
+		Report:  report,
+	}
+}
+
 type BuildVerifier struct {
 	Dir      string
 	Language string
+	// Command replaces the detected build when the project config sets
+	// commands.build
+	Command string
 }
 
 func NewBuildVerifier(dir string) *BuildVerifier {
 	lang := detectLanguage(dir)
-	return &BuildVerifier{Dir: dir, Language: lang}
+	return &BuildVerifier{Dir: dir, Language: lang, Command: projectCommands(dir).Build}
 }
 
 func (v *BuildVerifier) Verify(ctx context.Context) (*VerificationResult, error) {
@@ -240,6 +330,10 @@ func (v *BuildVerifier) Verify(ctx context.Context) (*VerificationResult, error)
 		return &VerificationResult{Success: true, Output: "No code files modified, skipping build"}, nil
 	}
 
+	if v.Command != "" {
+		return runProjectCommand(ctx, v.Dir, v.Command, "build failed"), nil
+	}
+
 	var cmd *exec.Cmd
 
 	switch v.Language {
@@ -353,6 +447,8 @@ func detectLanguage(dir string) string {
 		return "elixir"
 	case langdetect.Ruby:
 		return "ruby"
+	case langdetect.Rust:
+		return "rust"
 	default:
 		return "unknown"
 	}
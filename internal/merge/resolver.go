@@ -9,12 +9,24 @@ import (
 	"time"
 
 	"gptcode/internal/llm"
+	"gptcode/internal/maestro"
+)
+
+// Resolution strategies recorded on a ConflictFile
+const (
+	StrategyStructured = "structured"
+	StrategyLLM        = "llm"
 )
 
 type ConflictFile struct {
 	Path     string
 	Content  string
 	Resolved string
+	Strategy string
+	// AutoResolved counts declarations merged deterministically and
+	// LLMResolved the overlapping ones sent to the LLM (structured only).
+	AutoResolved int
+	LLMResolved  int
 }
 
 type Resolver struct {
@@ -84,14 +96,25 @@ func (r *Resolver) ResolveFile(ctx context.Context, path string) (ConflictFile,
 		}, nil
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Minute)
+	defer cancel()
+
+	if merged, stats, err := r.resolveStructured(ctx, path); err == nil {
+		return ConflictFile{
+			Path:         path,
+			Content:      string(content),
+			Resolved:     merged,
+			Strategy:     StrategyStructured,
+			AutoResolved: stats.AutoResolved,
+			LLMResolved:  len(stats.Overlapping),
+		}, nil
+	}
+
 	branches, err := r.getConflictBranches()
 	if err != nil {
 		branches = "unknown branches"
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Minute)
-	defer cancel()
-
 	prompt := r.buildPrompt(path, string(content), branches)
 	resp, err := r.provider.Chat(ctx, llm.ChatRequest{
 		SystemPrompt: "You are a helpful assistant that resolves Git merge conflicts intelligently.",
@@ -109,11 +132,95 @@ func (r *Resolver) ResolveFile(ctx context.Context, path string) (ConflictFile,
 		Path:     path,
 		Content:  string(content),
 		Resolved: resolvedContent,
+		Strategy: StrategyLLM,
 	}, nil
 }
 
+// resolveStructured merges disjoint declarations and imports deterministically
+// and only sends declarations changed on both sides to the LLM.
+func (r *Resolver) resolveStructured(ctx context.Context, path string) (string, *MergeStats, error) {
+	versions := LoadVersions("", path)
+	if versions.Ours == "" || versions.Theirs == "" {
+		return "", nil, ErrUnsupported
+	}
+
+	commits := r.getConflictingCommits(path)
+	resolve := func(c DeclConflict) (string, error) {
+		resp, err := r.provider.Chat(ctx, llm.ChatRequest{
+			SystemPrompt: "You are a helpful assistant that resolves Git merge conflicts intelligently.",
+			UserPrompt:   r.buildDeclPrompt(path, c, commits),
+			Model:        r.model,
+		})
+		if err != nil {
+			return "", fmt.Errorf("LLM resolution failed: %w", err)
+		}
+		return r.cleanResponse(resp.Text), nil
+	}
+	if r.provider == nil {
+		resolve = nil
+	}
+
+	return MergeDeclarations(path, versions, resolve)
+}
+
+// getConflictingCommits lists the commits on both sides touching path
+func (r *Resolver) getConflictingCommits(path string) string {
+	cmd := exec.Command("git", "log", "--merge", "--left-right", "--format=%m %h %s", "-n", "20", "--", path)
+	output, err := cmd.Output()
+	if err != nil || strings.TrimSpace(string(output)) == "" {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func (r *Resolver) buildDeclPrompt(path string, c DeclConflict, commits string) string {
+	side := func(s string) string {
+		if s == "" {
+			return "(does not exist)"
+		}
+		return s
+	}
+	merged := ""
+	if c.Merged != "" {
+		merged = fmt.Sprintf(`
+LINE MERGE (lines changed on one side are already merged; only the hunks
+between <<<<<<< and >>>>>>> markers still need a decision):
+%s
+`, c.Merged)
+	}
+
+	return fmt.Sprintf(`Both sides of a merge changed the same declaration. Merge them.
+
+File: %s
+Declaration: %s
+
+Commits involved ("<" ours, ">" theirs):
+%s
+
+BASE (common ancestor):
+%s
+
+OURS:
+%s
+
+THEIRS:
+%s
+%s
+Keep the intent of both sides. If one side deleted the declaration and the
+other changed it, decide from the commit messages whether it should exist.
+
+Return ONLY the merged declaration (including its doc comment), or nothing
+if it should be deleted. Do NOT include markdown code blocks or explanations.`,
+		path, c.Key, commits, side(c.Base), side(c.Ours), side(c.Theirs), merged)
+}
+
 func (r *Resolver) ApplyResolution(cf ConflictFile) error {
-	if err := os.WriteFile(cf.Path, []byte(cf.Resolved), 0644); err != nil {
+	// Keep the file's mode, such as the executable bit of a script
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(cf.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(cf.Path, []byte(cf.Resolved), mode); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -180,6 +287,11 @@ func (r *Resolver) cleanResponse(text string) string {
 	return strings.TrimSpace(cleaned)
 }
 
+// Verify builds the project after resolutions have been applied
+func (r *Resolver) Verify(ctx context.Context) (*maestro.VerificationResult, error) {
+	return maestro.NewBuildVerifier(".").Verify(ctx)
+}
+
 func (r *Resolver) ValidateResolution(cf ConflictFile) error {
 	if strings.Contains(cf.Resolved, "<<<<<<<") ||
 		strings.Contains(cf.Resolved, "=======") ||
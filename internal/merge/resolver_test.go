@@ -0,0 +1,38 @@
+package merge
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestApplyResolutionKeepsFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no executable bit on windows")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	path := filepath.Join(dir, "build.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n<<<<<<< ours\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewResolver(nil, "").ApplyResolution(ConflictFile{Path: "build.sh", Resolved: "#!/bin/sh\necho ok\n"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected the executable bits kept, got %v", info.Mode().Perm())
+	}
+}
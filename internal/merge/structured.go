@@ -0,0 +1,634 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrUnsupported means the file type has no declaration-level merge
+	ErrUnsupported = errors.New("structured merge not supported for this file type")
+	// ErrOverlapping means some declarations were changed on both sides
+	ErrOverlapping = errors.New("overlapping changes need manual or LLM resolution")
+)
+
+// Versions holds the three sides of a conflicted file
+type Versions struct {
+	Base   string
+	Ours   string
+	Theirs string
+}
+
+// LoadVersions reads the base, ours and theirs stages of a conflicted path
+// from the git index. A missing stage (add/add, delete) yields "".
+func LoadVersions(dir, path string) Versions {
+	stage := func(n int) string {
+		cmd := exec.Command("git", "show", fmt.Sprintf(":%d:%s", n, path))
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			return ""
+		}
+		return string(out)
+	}
+	return Versions{Base: stage(1), Ours: stage(2), Theirs: stage(3)}
+}
+
+// DeclConflict is a declaration that both sides changed differently.
+// An empty side means the declaration does not exist there.
+type DeclConflict struct {
+	Key    string
+	Base   string
+	Ours   string
+	Theirs string
+	// Merged is the line-level merge of the three sides with conflict
+	// markers around the hunks that still overlap, when all sides exist
+	Merged string
+}
+
+// ConflictFunc resolves a single overlapping declaration
+type ConflictFunc func(c DeclConflict) (string, error)
+
+// MergeStats describes how a structured merge went
+type MergeStats struct {
+	AutoResolved int // declarations that diverged but were merged deterministically
+	Overlapping  []DeclConflict
+}
+
+// decl is one declaration segment of a source file: a top-level
+// declaration, or a member or the closing line of a class-like body
+type decl struct {
+	key  string
+	text string
+}
+
+// parsed is a source file split into a preamble (package clause, imports)
+// and declarations.
+type parsed struct {
+	preamble string
+	decls    []decl
+}
+
+func (p *parsed) lookup() map[string]string {
+	m := make(map[string]string, len(p.decls))
+	for _, d := range p.decls {
+		m[d.key] = d.text
+	}
+	return m
+}
+
+// MergeDeclarations performs a three-way merge at declaration level.
+//
+// Declarations changed on only one side, additions on either side and import
+// changes are merged deterministically. Declarations changed on both sides are
+// passed to resolve; when resolve is nil they are reported through
+// MergeStats.Overlapping together with ErrOverlapping.
+func MergeDeclarations(path string, v Versions, resolve ConflictFunc) (string, *MergeStats, error) {
+	split := splitterFor(path)
+	if split == nil {
+		return "", nil, ErrUnsupported
+	}
+
+	var sides [3]*parsed
+	for i, src := range []string{v.Base, v.Ours, v.Theirs} {
+		p, err := split(src)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		sides[i] = p
+	}
+	base, ours, theirs := sides[0], sides[1], sides[2]
+	stats := &MergeStats{}
+
+	preamble, ok := mergePreamble(base.preamble, ours.preamble, theirs.preamble)
+	if !ok {
+		stats.Overlapping = append(stats.Overlapping, DeclConflict{
+			Key: "(imports and header)", Base: base.preamble, Ours: ours.preamble, Theirs: theirs.preamble,
+		})
+		if resolve == nil {
+			return "", stats, ErrOverlapping
+		}
+		text, err := resolve(stats.Overlapping[0])
+		if err != nil {
+			return "", stats, err
+		}
+		preamble = ensureTrailingBlank(text)
+	} else if ours.preamble != theirs.preamble {
+		stats.AutoResolved++
+	}
+
+	b, o, t := base.lookup(), ours.lookup(), theirs.lookup()
+	var out strings.Builder
+	out.WriteString(preamble)
+
+	for _, key := range mergeOrder(base, ours, theirs) {
+		bt, inB := b[key]
+		ot, inO := o[key]
+		tt, inT := t[key]
+
+		var text string
+		switch {
+		case ot == tt && inO == inT:
+			text = ot
+		case ot == bt && inO == inB:
+			text = tt
+			stats.AutoResolved++
+		case tt == bt && inT == inB:
+			text = ot
+			stats.AutoResolved++
+		default:
+			c := DeclConflict{Key: key, Base: bt, Ours: ot, Theirs: tt}
+			// Edits to different lines of the declaration merge like git
+			// would; only overlapping hunks need resolving
+			if inO && inT {
+				merged, clean, err := mergeLines(bt, ot, tt)
+				if err == nil && clean {
+					out.WriteString(merged)
+					stats.AutoResolved++
+					continue
+				}
+				if err == nil {
+					c.Merged = merged
+				}
+			}
+			stats.Overlapping = append(stats.Overlapping, c)
+			if resolve == nil {
+				continue
+			}
+			resolved, err := resolve(c)
+			if err != nil {
+				return "", stats, fmt.Errorf("failed to resolve %s: %w", key, err)
+			}
+			if strings.TrimSpace(resolved) != "" {
+				text = withBlankLines(resolved, firstNonEmpty(ot, tt, bt))
+			}
+		}
+		out.WriteString(text)
+	}
+
+	if resolve == nil && len(stats.Overlapping) > 0 {
+		return "", stats, ErrOverlapping
+	}
+
+	merged := out.String()
+	if filepath.Ext(path) == ".go" {
+		formatted, err := format.Source([]byte(merged))
+		if err != nil {
+			return "", stats, fmt.Errorf("merged result does not parse: %w", err)
+		}
+		merged = string(formatted)
+	}
+	return merged, stats, nil
+}
+
+// mergeLines runs a line-level three-way merge with git merge-file and
+// reports whether it was free of conflicts. Conflicts are left marked in
+// diff3 style.
+func mergeLines(base, ours, theirs string) (string, bool, error) {
+	dir, err := os.MkdirTemp("", "gptcode-merge-")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create merge directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var paths []string
+	for _, side := range []struct{ name, text string }{{"ours", ours}, {"base", base}, {"theirs", theirs}} {
+		path := filepath.Join(dir, side.name)
+		if err := os.WriteFile(path, []byte(side.text), 0644); err != nil {
+			return "", false, fmt.Errorf("failed to write %s: %w", side.name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	cmd := exec.Command("git", append([]string{"merge-file", "-p", "--diff3", "-L", "ours", "-L", "base", "-L", "theirs"}, paths...)...)
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return string(out), true, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128:
+		// The exit code is the number of conflicts
+		return string(out), false, nil
+	}
+	return "", false, fmt.Errorf("failed to merge lines: %w", err)
+}
+
+// mergeOrder keeps our declaration order and inserts declarations added on
+// their side after the declaration that precedes them there.
+func mergeOrder(base, ours, theirs *parsed) []string {
+	var order []string
+	seen := make(map[string]bool)
+	for _, d := range ours.decls {
+		order = append(order, d.key)
+		seen[d.key] = true
+	}
+	// Declarations only in base were deleted by us; keep their slot in case
+	// they were modified by them (a delete/modify conflict).
+	for _, d := range base.decls {
+		if !seen[d.key] {
+			order = append(order, d.key)
+			seen[d.key] = true
+		}
+	}
+
+	prev := ""
+	for _, d := range theirs.decls {
+		if !seen[d.key] {
+			idx := 0
+			if prev != "" {
+				for i, k := range order {
+					if k == prev {
+						idx = i + 1
+						break
+					}
+				}
+			}
+			order = append(order[:idx], append([]string{d.key}, order[idx:]...)...)
+			seen[d.key] = true
+		}
+		prev = d.key
+	}
+	return order
+}
+
+var importLinePattern = regexp.MustCompile(`^\s*(` +
+	`(import\s+)?([\w.]+\s+)?"[^"]+"` + // Go
+	`|import\s.*|from\s+\S+\s+import\s.*` + // Python, JS/TS
+	`|(const|let|var)\s+.*=\s*require\(.*\).*` + // CommonJS
+	`|require(_relative)?\s.*` + // Ruby
+	`|use\s.*|extern\s+crate\s.*` + // Rust
+	`|(alias|import|require|use)\s+[A-Z][\w.]*.*` + // Elixir
+	`)\s*;?\s*$`)
+
+// mergePreamble merges file headers. Besides the usual one-sided changes it
+// handles both sides adding or removing import lines.
+func mergePreamble(base, ours, theirs string) (string, bool) {
+	switch {
+	case ours == theirs:
+		return ours, true
+	case ours == base:
+		return theirs, true
+	case theirs == base:
+		return ours, true
+	}
+
+	bl, ol, tl := lines(base), lines(ours), lines(theirs)
+	inB, inO, inT := set(bl), set(ol), set(tl)
+
+	for _, l := range diff(ol, inB) {
+		if !importLinePattern.MatchString(l) {
+			return "", false
+		}
+	}
+	for _, l := range diff(tl, inB) {
+		if !importLinePattern.MatchString(l) {
+			return "", false
+		}
+	}
+	for _, l := range diff(bl, inO) {
+		if !importLinePattern.MatchString(l) {
+			return "", false
+		}
+	}
+	for _, l := range diff(bl, inT) {
+		if !importLinePattern.MatchString(l) {
+			return "", false
+		}
+	}
+
+	// Start from ours, drop what they removed, add what they added
+	var result []string
+	lastImport := -1
+	for _, l := range ol {
+		if inB[l] && !inT[l] {
+			continue
+		}
+		result = append(result, l)
+		if importLinePattern.MatchString(l) {
+			lastImport = len(result) - 1
+		}
+	}
+	var added []string
+	for _, l := range tl {
+		if !inB[l] && !inO[l] {
+			added = append(added, l)
+		}
+	}
+	if lastImport < 0 {
+		lastImport = len(result) - 1
+	}
+	result = append(result[:lastImport+1], append(added, result[lastImport+1:]...)...)
+	return strings.Join(result, "\n"), true
+}
+
+func splitterFor(path string) func(string) (*parsed, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".go":
+		return splitGo
+	case ".py", ".js", ".jsx", ".ts", ".tsx", ".mjs", ".rb", ".rs", ".ex", ".exs":
+		return splitGeneric
+	}
+	return nil
+}
+
+// splitGo uses the Go parser to find top-level declarations. Import decls
+// stay in the preamble.
+func splitGo(src string) (*parsed, error) {
+	if strings.TrimSpace(src) == "" {
+		return &parsed{}, nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	type span struct {
+		key   string
+		start int
+	}
+	var spans []span
+	counts := make(map[string]int)
+	for _, d := range f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			continue
+		}
+		start := d.Pos()
+		switch n := d.(type) {
+		case *ast.FuncDecl:
+			if n.Doc != nil {
+				start = n.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			if n.Doc != nil {
+				start = n.Doc.Pos()
+			}
+		}
+		key := goDeclKey(d)
+		counts[key]++
+		if counts[key] > 1 {
+			key = fmt.Sprintf("%s#%d", key, counts[key])
+		}
+		spans = append(spans, span{key: key, start: fset.Position(start).Offset})
+	}
+
+	if len(spans) == 0 {
+		return &parsed{preamble: src}, nil
+	}
+
+	p := &parsed{preamble: src[:spans[0].start]}
+	for i, s := range spans {
+		end := len(src)
+		if i+1 < len(spans) {
+			end = spans[i+1].start
+		}
+		p.decls = append(p.decls, decl{key: s.key, text: src[s.start:end]})
+	}
+	return p, nil
+}
+
+func goDeclKey(d ast.Decl) string {
+	switch n := d.(type) {
+	case *ast.FuncDecl:
+		if n.Recv != nil && len(n.Recv.List) > 0 {
+			return fmt.Sprintf("func (%s) %s", receiverName(n.Recv.List[0].Type), n.Name.Name)
+		}
+		return "func " + n.Name.Name
+	case *ast.GenDecl:
+		for _, spec := range n.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				return "type " + s.Name.Name
+			case *ast.ValueSpec:
+				if len(s.Names) > 0 {
+					return n.Tok.String() + " " + s.Names[0].Name
+				}
+			}
+		}
+		return n.Tok.String()
+	}
+	return "decl"
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+var genericDeclPattern = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?` +
+	`(def|defp|defmodule|defmacro|class|module|function\*?|interface|type|enum|const|let|var|fn|struct|trait|impl|mod|static)\s+` +
+	`([A-Za-z_$][\w$.!?]*)`)
+
+// containerKinds are the declarations whose bodies hold members that are
+// split out as declarations of their own
+var containerKinds = map[string]bool{
+	"class": true, "module": true, "defmodule": true, "interface": true,
+	"impl": true, "trait": true, "mod": true,
+}
+
+// methodPattern matches JS/TS class members, which have no keyword
+var methodPattern = regexp.MustCompile(`^(?:(?:public|private|protected|static|readonly|async|abstract|override|get|set)\s+)*\*?(#?[A-Za-z_$][\w$]*)\s*(?:<[^>]*>)?\(`)
+
+// notMethods are keywords methodPattern would otherwise take for a name
+var notMethods = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true, "super": true}
+
+// closingPattern matches the column 0 line that ends a container body
+var closingPattern = regexp.MustCompile(`^(?:end|\};?)\s*$`)
+
+// splitGeneric splits at top-level (column 0) declarations, which is how
+// idiomatic Python, JS/TS, Ruby, Rust and Elixir files are laid out, and at
+// the members one indentation level inside classes, modules and impls.
+// Members are keyed under their container, whose closing line is a
+// declaration of its own so members added on both sides go before it.
+// Decorators and comments directly above a declaration belong to it.
+func splitGeneric(src string) (*parsed, error) {
+	ls := strings.SplitAfter(src, "\n")
+
+	type span struct {
+		key   string
+		start int
+	}
+	var spans []span
+	counts := make(map[string]int)
+	add := func(key string, start int) string {
+		counts[key]++
+		if counts[key] > 1 {
+			key = fmt.Sprintf("%s#%d", key, counts[key])
+		}
+		spans = append(spans, span{key: key, start: start})
+		return key
+	}
+	// attach moves a declaration's start up over the comment and
+	// decorator lines at its indentation
+	attach := func(i int, indent string) int {
+		start := i
+		for start > 0 && isAttachedAt(ls[start-1], indent) {
+			start--
+		}
+		if len(spans) > 0 && start < spans[len(spans)-1].start+1 {
+			start = i
+		}
+		return start
+	}
+
+	container, indent := "", ""
+	for i, line := range ls {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !isIndented(line) {
+			if container != "" && closingPattern.MatchString(line) {
+				add(container+" / end", i)
+				container = ""
+				continue
+			}
+			container = ""
+			m := genericDeclPattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			key := add(m[1]+" "+m[2], attach(i, ""))
+			if containerKinds[m[1]] {
+				container, indent = key, ""
+			}
+			continue
+		}
+		if container == "" {
+			continue
+		}
+		// The first line of the body sets the members' indentation
+		if indent == "" {
+			indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		}
+		if !strings.HasPrefix(line, indent) || isIndented(line[len(indent):]) {
+			continue
+		}
+		if kind, name, ok := memberDecl(line[len(indent):]); ok {
+			add(container+" / "+kind+" "+name, attach(i, indent))
+		}
+	}
+
+	if len(spans) == 0 {
+		return &parsed{preamble: src}, nil
+	}
+
+	// Blank lines between declarations lead the next one, so adding a
+	// declaration doesn't change the text of the one before it
+	for i := 1; i < len(spans); i++ {
+		for spans[i].start > spans[i-1].start+1 && strings.TrimSpace(ls[spans[i].start-1]) == "" {
+			spans[i].start--
+		}
+	}
+
+	p := &parsed{preamble: strings.Join(ls[:spans[0].start], "")}
+	for i, s := range spans {
+		end := len(ls)
+		if i+1 < len(spans) {
+			end = spans[i+1].start
+		}
+		p.decls = append(p.decls, decl{key: s.key, text: strings.Join(ls[s.start:end], "")})
+	}
+	return p, nil
+}
+
+// memberDecl returns the kind and name of a member declared on line, which
+// has had its indentation removed
+func memberDecl(line string) (kind, name string, ok bool) {
+	if m := methodPattern.FindStringSubmatch(line); m != nil && !notMethods[m[1]] {
+		return "method", m[1], true
+	}
+	if m := genericDeclPattern.FindStringSubmatch(line); m != nil {
+		return m[1], m[2], true
+	}
+	return "", "", false
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+func isAttached(line string) bool {
+	for _, prefix := range []string{"@", "#", "//", "/*", " *"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAttachedAt is isAttached for a line at the given indentation
+func isAttachedAt(line, indent string) bool {
+	if !strings.HasPrefix(line, indent) {
+		return false
+	}
+	rest := line[len(indent):]
+	if indent != "" && isIndented(rest) && !strings.HasPrefix(rest, " *") {
+		return false
+	}
+	return isAttached(rest)
+}
+
+func ensureTrailingBlank(s string) string {
+	return strings.TrimRight(s, "\n") + "\n\n"
+}
+
+// withBlankLines gives text the blank lines that lead and trail like
+func withBlankLines(text, like string) string {
+	body := strings.Trim(like, "\n")
+	if body == "" {
+		return ensureTrailingBlank(text)
+	}
+	i := strings.Index(like, body)
+	return like[:i] + strings.Trim(text, "\n") + like[i+len(body):]
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func lines(s string) []string {
+	return strings.Split(s, "\n")
+}
+
+func set(ls []string) map[string]bool {
+	m := make(map[string]bool, len(ls))
+	for _, l := range ls {
+		m[l] = true
+	}
+	return m
+}
+
+// diff returns the lines of ls that are not in other
+func diff(ls []string, other map[string]bool) []string {
+	var out []string
+	for _, l := range ls {
+		if !other[l] {
+			out = append(out, l)
+		}
+	}
+	return out
+}
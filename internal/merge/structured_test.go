@@ -0,0 +1,214 @@
+package merge
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const goBase = `package calc
+
+import (
+	"fmt"
+)
+
+// Add adds two numbers
+func Add(a, b int) int {
+	return a + b
+}
+
+func Sub(a, b int) int {
+	return a - b
+}
+
+func Describe(n int) string {
+	return fmt.Sprint(n)
+}
+`
+
+func TestMergeDeclarationsGoDisjoint(t *testing.T) {
+	ours := strings.Replace(goBase, "return a + b", "return b + a", 1)
+	ours = strings.Replace(ours, `"fmt"`, "\"fmt\"\n\t\"os\"", 1)
+	ours += "\nfunc Env() string {\n\treturn os.Getenv(\"CALC\")\n}\n"
+
+	theirs := strings.Replace(goBase, "return a - b", "return a - b - 0", 1)
+	theirs = strings.Replace(theirs, `"fmt"`, "\"fmt\"\n\t\"strings\"", 1)
+	theirs = strings.Replace(theirs, "func Describe", "func Upper(s string) string {\n\treturn strings.ToUpper(s)\n}\n\nfunc Describe", 1)
+
+	merged, stats, err := MergeDeclarations("calc.go", Versions{Base: goBase, Ours: ours, Theirs: theirs}, nil)
+	if err != nil {
+		t.Fatalf("expected clean merge, got %v", err)
+	}
+
+	for _, want := range []string{"return b + a", "return a - b - 0", `"os"`, `"strings"`, "func Upper", "func Env", "// Add adds two numbers"} {
+		if !strings.Contains(merged, want) {
+			t.Errorf("merged result missing %q:\n%s", want, merged)
+		}
+	}
+	if strings.Index(merged, "func Upper") > strings.Index(merged, "func Describe") {
+		t.Error("expected their new declaration to keep its position")
+	}
+	if stats.AutoResolved == 0 {
+		t.Error("expected deterministic resolutions to be counted")
+	}
+}
+
+func TestMergeDeclarationsOverlapping(t *testing.T) {
+	ours := strings.Replace(goBase, "return a + b", "return a + b + 1", 1)
+	theirs := strings.Replace(goBase, "return a + b", "return a + b + 2", 1)
+	v := Versions{Base: goBase, Ours: ours, Theirs: theirs}
+
+	_, stats, err := MergeDeclarations("calc.go", v, nil)
+	if !errors.Is(err, ErrOverlapping) {
+		t.Fatalf("expected ErrOverlapping, got %v", err)
+	}
+	if len(stats.Overlapping) != 1 || stats.Overlapping[0].Key != "func Add" {
+		t.Fatalf("unexpected conflicts: %+v", stats.Overlapping)
+	}
+
+	var calls int
+	merged, _, err := MergeDeclarations("calc.go", v, func(c DeclConflict) (string, error) {
+		calls++
+		if !strings.Contains(c.Ours, "+ 1") || !strings.Contains(c.Theirs, "+ 2") {
+			t.Errorf("unexpected conflict sides: %+v", c)
+		}
+		return "// Add adds two numbers\nfunc Add(a, b int) int {\n\treturn a + b + 3\n}", nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 || !strings.Contains(merged, "+ 3") || !strings.Contains(merged, "func Sub") {
+		t.Errorf("unexpected merge (%d calls):\n%s", calls, merged)
+	}
+}
+
+func TestMergeDeclarationsPython(t *testing.T) {
+	base := "import os\n\n\ndef a():\n    return 1\n\n\ndef b():\n    return 2\n"
+	ours := "import os\nimport sys\n\n\ndef a():\n    return 10\n\n\ndef b():\n    return 2\n"
+	theirs := "import os\nimport json\n\n\ndef a():\n    return 1\n\n\n@cache\ndef b():\n    return 20\n"
+
+	merged, _, err := MergeDeclarations("mod.py", Versions{Base: base, Ours: ours, Theirs: theirs}, nil)
+	if err != nil {
+		t.Fatalf("expected clean merge, got %v", err)
+	}
+	for _, want := range []string{"import sys", "import json", "return 10", "@cache\ndef b():\n    return 20"} {
+		if !strings.Contains(merged, want) {
+			t.Errorf("merged result missing %q:\n%s", want, merged)
+		}
+	}
+}
+
+func TestMergeDeclarationsUnsupported(t *testing.T) {
+	if _, _, err := MergeDeclarations("data.json", Versions{}, nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestMergeDeclarationsNestedMembers(t *testing.T) {
+	tests := []struct {
+		path               string
+		base, ours, theirs string
+		want               []string
+	}{
+		{
+			path:   "calc.rb",
+			base:   "class Calc\n  def add(a, b)\n    a + b\n  end\n\n  def sub(a, b)\n    a - b\n  end\nend\n",
+			ours:   "class Calc\n  def add(a, b)\n    b + a\n  end\n\n  def sub(a, b)\n    a - b\n  end\n\n  def mul(a, b)\n    a * b\n  end\nend\n",
+			theirs: "class Calc\n  def add(a, b)\n    a + b\n  end\n\n  def sub(a, b)\n    a - b - 0\n  end\n\n  def div(a, b)\n    a / b\n  end\nend\n",
+			want:   []string{"b + a", "a - b - 0", "def mul", "def div"},
+		},
+		{
+			path:   "calc.py",
+			base:   "class Calc:\n    def add(self, a, b):\n        return a + b\n\n    def sub(self, a, b):\n        return a - b\n",
+			ours:   "class Calc:\n    def add(self, a, b):\n        return b + a\n\n    def sub(self, a, b):\n        return a - b\n\n    def mul(self, a, b):\n        return a * b\n",
+			theirs: "class Calc:\n    def add(self, a, b):\n        return a + b\n\n    @staticmethod\n    def sub(a, b):\n        return a - b\n\n    def div(self, a, b):\n        return a / b\n",
+			want:   []string{"return b + a", "@staticmethod\n    def sub(a, b)", "def mul", "def div"},
+		},
+		{
+			path:   "calc.ts",
+			base:   "export class Calc {\n  add(a: number, b: number): number {\n    return a + b;\n  }\n\n  sub(a: number, b: number): number {\n    return a - b;\n  }\n}\n",
+			ours:   "export class Calc {\n  add(a: number, b: number): number {\n    return b + a;\n  }\n\n  sub(a: number, b: number): number {\n    return a - b;\n  }\n\n  mul(a: number, b: number): number {\n    return a * b;\n  }\n}\n",
+			theirs: "export class Calc {\n  add(a: number, b: number): number {\n    return a + b;\n  }\n\n  private sub(a: number, b: number): number {\n    return a - b;\n  }\n\n  static div(a: number, b: number): number {\n    return a / b;\n  }\n}\n",
+			want:   []string{"return b + a", "private sub", "mul(a", "static div"},
+		},
+		{
+			path:   "calc.rs",
+			base:   "impl Calc {\n    pub fn add(a: i32, b: i32) -> i32 {\n        a + b\n    }\n\n    fn sub(a: i32, b: i32) -> i32 {\n        a - b\n    }\n}\n",
+			ours:   "impl Calc {\n    pub fn add(a: i32, b: i32) -> i32 {\n        b + a\n    }\n\n    fn sub(a: i32, b: i32) -> i32 {\n        a - b\n    }\n\n    fn mul(a: i32, b: i32) -> i32 {\n        a * b\n    }\n}\n",
+			theirs: "impl Calc {\n    pub fn add(a: i32, b: i32) -> i32 {\n        a + b\n    }\n\n    pub fn sub(a: i32, b: i32) -> i32 {\n        a - b\n    }\n\n    fn div(a: i32, b: i32) -> i32 {\n        a / b\n    }\n}\n",
+			want:   []string{"b + a", "pub fn sub", "fn mul", "fn div"},
+		},
+		{
+			path:   "calc.ex",
+			base:   "defmodule Calc do\n  def add(a, b) do\n    a + b\n  end\n\n  def sub(a, b) do\n    a - b\n  end\nend\n",
+			ours:   "defmodule Calc do\n  def add(a, b) do\n    b + a\n  end\n\n  def sub(a, b) do\n    a - b\n  end\n\n  def mul(a, b), do: a * b\nend\n",
+			theirs: "defmodule Calc do\n  def add(a, b) do\n    a + b\n  end\n\n  @doc \"Subtracts\"\n  def sub(a, b) do\n    a - b\n  end\n\n  defp div(a, b), do: a / b\nend\n",
+			want:   []string{"b + a", "@doc \"Subtracts\"\n  def sub", "def mul", "defp div"},
+		},
+	}
+	for _, tt := range tests {
+		merged, _, err := MergeDeclarations(tt.path, Versions{Base: tt.base, Ours: tt.ours, Theirs: tt.theirs}, nil)
+		if err != nil {
+			t.Errorf("%s: expected clean merge, got %v", tt.path, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(merged, want) {
+				t.Errorf("%s: merged result missing %q:\n%s", tt.path, want, merged)
+			}
+		}
+		if closers := strings.Count(merged, "\nend\n") + strings.Count(merged, "\n}\n"); tt.path != "calc.py" && closers != 1 {
+			t.Errorf("%s: expected the class closed once, got %d:\n%s", tt.path, closers, merged)
+		}
+		if !strings.HasSuffix(strings.TrimSpace(merged), "end") && !strings.HasSuffix(strings.TrimSpace(merged), "}") && tt.path != "calc.py" {
+			t.Errorf("%s: expected new members inside the class:\n%s", tt.path, merged)
+		}
+	}
+}
+
+func TestMergeDeclarationsNestedConflictKeepsLayout(t *testing.T) {
+	base := "class Calc\n  def add(a, b)\n    a + b\n  end\n\n  def sub(a, b)\n    a - b\n  end\nend\n"
+	ours := strings.Replace(base, "a + b", "a + b + 1", 1)
+	theirs := strings.Replace(base, "a + b", "a + b + 2", 1)
+
+	_, stats, err := MergeDeclarations("calc.rb", Versions{Base: base, Ours: ours, Theirs: theirs}, nil)
+	if !errors.Is(err, ErrOverlapping) || len(stats.Overlapping) != 1 || stats.Overlapping[0].Key != "class Calc / def add" {
+		t.Fatalf("expected only the member to conflict, got %v %+v", err, stats.Overlapping)
+	}
+
+	merged, _, err := MergeDeclarations("calc.rb", Versions{Base: base, Ours: ours, Theirs: theirs}, func(c DeclConflict) (string, error) {
+		return "  def add(a, b)\n    a + b + 3\n  end\n", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(base, "a + b", "a + b + 3", 1); merged != want {
+		t.Errorf("expected %q, got %q", want, merged)
+	}
+}
+
+func TestMergeDeclarationsMergesDisjointLinesOfOneDeclaration(t *testing.T) {
+	base := "package calc\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n"
+	ours := strings.Replace(base, "total := 0", "total := 1", 1)
+	theirs := strings.Replace(base, "return total", "return total * 2", 1)
+
+	merged, stats, err := MergeDeclarations("calc.go", Versions{Base: base, Ours: ours, Theirs: theirs}, func(c DeclConflict) (string, error) {
+		t.Errorf("expected no resolution for edits to different lines, got %+v", c)
+		return c.Ours, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(merged, "total := 1") || !strings.Contains(merged, "return total * 2") || stats.AutoResolved != 1 {
+		t.Errorf("expected both edits kept (%d auto-resolved):\n%s", stats.AutoResolved, merged)
+	}
+
+	theirs = strings.Replace(base, "total := 0", "total := 2", 1)
+	_, stats, err = MergeDeclarations("calc.go", Versions{Base: base, Ours: ours, Theirs: theirs}, nil)
+	if !errors.Is(err, ErrOverlapping) || len(stats.Overlapping) != 1 {
+		t.Fatalf("expected the same line changed twice to conflict, got %v", err)
+	}
+	if m := stats.Overlapping[0].Merged; !strings.Contains(m, "<<<<<<< ours\n\ttotal := 1") || !strings.Contains(m, "return total\n") {
+		t.Errorf("expected only the overlapping hunk marked:\n%s", m)
+	}
+}
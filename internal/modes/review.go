@@ -17,10 +17,12 @@ type ReviewOptions struct {
 	Focus  string
 }
 
-func RunReview(opts ReviewOptions) error {
+// RunReview reviews a file or directory, prints the review and returns it
+// split into findings
+func RunReview(opts ReviewOptions) (*ReviewReport, error) {
 	setup, err := config.LoadSetup()
 	if err != nil {
-		return fmt.Errorf("failed to load setup: %w", err)
+		return nil, fmt.Errorf("failed to load setup: %w", err)
 	}
 
 	backendName := setup.Defaults.Backend
@@ -43,7 +45,7 @@ func RunReview(opts ReviewOptions) error {
 
 	cwd, err := os.Getwd()
 	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
 
 	reviewAgent := agents.NewReview(provider, cwd, model)
@@ -60,7 +62,7 @@ func RunReview(opts ReviewOptions) error {
 
 	info, err := os.Stat(targetPath)
 	if err != nil {
-		return fmt.Errorf("target not found: %w", err)
+		return nil, fmt.Errorf("target not found: %w", err)
 	}
 
 	reviewPrompt := buildReviewPrompt(targetPath, info.IsDir(), opts.Focus)
@@ -85,7 +87,7 @@ func RunReview(opts ReviewOptions) error {
 	ctx := context.Background()
 	result, err := reviewAgent.Execute(ctx, history, statusCallback)
 	if err != nil {
-		return fmt.Errorf("review failed: %w", err)
+		return nil, fmt.Errorf("review failed: %w", err)
 	}
 
 	fmt.Println("\n" + strings.Repeat("=", 80))
@@ -94,7 +96,10 @@ func RunReview(opts ReviewOptions) error {
 	fmt.Println(result)
 	fmt.Println()
 
-	return nil
+	report := ParseReview(result)
+	report.Target = target
+	report.Focus = opts.Focus
+	return &report, nil
 }
 
 func buildReviewPrompt(targetPath string, isDir bool, focus string) string {
@@ -117,6 +122,8 @@ func buildReviewPrompt(targetPath string, isDir bool, focus string) string {
 	prompt.WriteString("2. Critical Issues: Bugs, security risks, or breaking problems\n")
 	prompt.WriteString("3. Suggestions: Quality, performance, or maintainability improvements\n")
 	prompt.WriteString("4. Nitpicks: Style, naming, or minor preferences\n")
+	prompt.WriteString("\nGive each issue as a bullet that names its location as file:line or file:start-end.\n")
+	prompt.WriteString("When you know the fix, follow the bullet with a line reading \"Fix:\" and a fenced code block holding the corrected code for exactly those lines.\n")
 
 	return prompt.String()
 }
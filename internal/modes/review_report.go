@@ -0,0 +1,188 @@
+package modes
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severities of review findings, matching the sections the review prompt
+// asks for
+const (
+	SeverityCritical   = "critical"
+	SeveritySuggestion = "suggestion"
+	SeverityNitpick    = "nitpick"
+)
+
+// ReviewFinding is one point a review raised
+type ReviewFinding struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	EndLine  int    `json:"end_line,omitempty"`
+	// Fix is the corrected code for the lines the finding names, from a
+	// code block under it marked as a fix
+	Fix string `json:"fix,omitempty"`
+}
+
+// ReviewReport is a review split into the sections of the review prompt
+type ReviewReport struct {
+	Target   string          `json:"target"`
+	Focus    string          `json:"focus,omitempty"`
+	Summary  string          `json:"summary"`
+	Findings []ReviewFinding `json:"findings"`
+	// Text is the review as the model wrote it
+	Text string `json:"text"`
+}
+
+var (
+	reviewSections = []struct {
+		prefix   string
+		severity string
+	}{
+		{"summary", ""},
+		{"critical", SeverityCritical},
+		{"suggestion", SeveritySuggestion},
+		{"nitpick", SeverityNitpick},
+		{"nit", SeverityNitpick},
+	}
+
+	reviewBullet   = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	reviewLocation = regexp.MustCompile(`([\w./-]+\.[A-Za-z]\w*):(\d+)(?:-(\d+))?`)
+	reviewFile     = regexp.MustCompile("`([\\w./-]+\\.[A-Za-z]\\w*)`")
+	reviewNone     = regexp.MustCompile(`(?i)^(none|n/a|no (critical )?issues)\b`)
+	// reviewFixLeadIn ends the text that introduces a fix, as in "Fix:" or
+	// "**Suggested fix:**"
+	reviewFixLeadIn = regexp.MustCompile(`(?i)(?:^|\s)[*_]*(?:suggested\s+)?fix[*_]*:[*_]*\s*$`)
+)
+
+// ParseReview splits a review into its summary and findings. Bullets under
+// the Critical Issues, Suggestions and Nitpicks headings become findings,
+// with the first file:line or file:start-end they mention as their
+// location. A code block under a finding is its fix when it follows a
+// "Fix:" lead-in or is a suggestion block; other blocks only illustrate.
+func ParseReview(text string) ReviewReport {
+	report := ReviewReport{Text: text, Findings: []ReviewFinding{}}
+	var summary []string
+	section := ""
+	inSection := false
+	var current *ReviewFinding
+	var code []string
+	inCode := false
+	codeIndent := ""
+	// leadIn is set by a "Fix:" line and marks the next code block as a fix
+	leadIn, isFix := false, false
+
+	flush := func() {
+		if current != nil && !reviewNone.MatchString(current.Message) {
+			locate(current)
+			report.Findings = append(report.Findings, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") {
+			if inCode && isFix && current != nil && current.Fix == "" {
+				current.Fix = strings.Join(code, "\n")
+			}
+			if !inCode {
+				isFix = leadIn || strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(trimmed, "```")), "suggestion")
+				leadIn = false
+			}
+			inCode = !inCode
+			codeIndent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			code = nil
+			continue
+		}
+		if inCode {
+			code = append(code, strings.TrimPrefix(line, codeIndent))
+			continue
+		}
+
+		if severity, rest, ok := reviewHeading(line); ok {
+			flush()
+			section, inSection = severity, true
+			line = rest
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || !inSection {
+			continue
+		}
+
+		if section == "" {
+			summary = append(summary, trimmed)
+			continue
+		}
+		text := trimmed
+		loc := reviewBullet.FindStringIndex(line)
+		if loc != nil {
+			text = strings.TrimSpace(line[loc[1]:])
+		}
+		leadIn = false
+		if m := reviewFixLeadIn.FindStringIndex(text); m != nil {
+			leadIn = true
+			text = strings.TrimSpace(text[:m[0]])
+		}
+		if loc != nil && !(text == "" && leadIn && current != nil) {
+			flush()
+			current = &ReviewFinding{Severity: section, Message: text}
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		if current != nil {
+			current.Message += " " + text
+		} else {
+			current = &ReviewFinding{Severity: section, Message: text}
+		}
+	}
+	flush()
+
+	report.Summary = strings.Join(summary, " ")
+	return report
+}
+
+// reviewHeading recognises a section heading such as "## 2. Critical
+// Issues" or "**Summary:** looks good", returning the section's severity
+// and any text after it
+func reviewHeading(line string) (string, string, bool) {
+	head, rest := line, ""
+	if i := strings.Index(line, ":"); i >= 0 {
+		head, rest = line[:i], line[i+1:]
+	}
+	isHeading := strings.HasPrefix(strings.TrimSpace(line), "#")
+	head = strings.Trim(head, " \t#*_")
+	head = strings.TrimLeft(head, "0123456789.) ")
+	head = strings.Trim(head, " *_")
+	if len(head) > 30 || (!isHeading && rest == "" && !strings.HasSuffix(strings.TrimSpace(line), ":") && !strings.HasPrefix(strings.TrimSpace(line), "**")) {
+		return "", "", false
+	}
+	lower := strings.ToLower(head)
+	for _, s := range reviewSections {
+		if strings.HasPrefix(lower, s.prefix) {
+			return s.severity, strings.TrimLeft(rest, " *_"), true
+		}
+	}
+	return "", "", false
+}
+
+// locate fills in the file and line a finding mentions
+func locate(f *ReviewFinding) {
+	if m := reviewLocation.FindStringSubmatch(f.Message); m != nil {
+		f.File = m[1]
+		f.Line, _ = strconv.Atoi(m[2])
+		if end, _ := strconv.Atoi(m[3]); end > f.Line {
+			f.EndLine = end
+		}
+		return
+	}
+	if m := reviewFile.FindStringSubmatch(f.Message); m != nil {
+		f.File = m[1]
+	}
+}
@@ -0,0 +1,183 @@
+package modes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/sarif"
+)
+
+func TestParseReview(t *testing.T) {
+	text := `## 1. Summary
+The handler is mostly sound but misses input validation.
+
+## 2. Critical Issues
+- SQL built with string concatenation in internal/store/users.go:42 allows injection.
+- ` + "`auth.go`" + ` never checks the token expiry,
+  so expired tokens are accepted.
+
+## 3. Suggestions
+1. Wrap errors with context in handler.go:17.
+
+**Nitpicks:** None
+`
+	r := ParseReview(text)
+	if r.Summary != "The handler is mostly sound but misses input validation." {
+		t.Errorf("unexpected summary %q", r.Summary)
+	}
+	want := []ReviewFinding{
+		{Severity: SeverityCritical, File: "internal/store/users.go", Line: 42},
+		{Severity: SeverityCritical, File: "auth.go"},
+		{Severity: SeveritySuggestion, File: "handler.go", Line: 17},
+	}
+	if len(r.Findings) != len(want) {
+		t.Fatalf("expected %d findings, got %+v", len(want), r.Findings)
+	}
+	for i, w := range want {
+		f := r.Findings[i]
+		if f.Severity != w.Severity || f.File != w.File || f.Line != w.Line {
+			t.Errorf("finding %d: got %+v, want %+v", i, f, w)
+		}
+	}
+	if r.Findings[1].Message != "`auth.go` never checks the token expiry, so expired tokens are accepted." {
+		t.Errorf("expected continuation lines joined, got %q", r.Findings[1].Message)
+	}
+	if r.Text != text {
+		t.Error("expected the review text kept")
+	}
+}
+
+func TestReviewSARIF(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "internal", "store"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	users := "package store\n" + strings.Repeat("\n", 40) + "row := db.QueryRow(\"SELECT * FROM users WHERE id = \" + id)\nif err != nil {\n"
+	if err := os.WriteFile(filepath.Join(root, "internal", "store", "users.go"), []byte(users), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	text := "## Critical Issues\n" +
+		"- SQL built with string concatenation in " + filepath.Join(root, "internal", "store") + "/users.go:42-43 allows injection.\n" +
+		"  Fix:\n" +
+		"  ```go\n" +
+		"  row := db.QueryRow(\"SELECT * FROM users WHERE id = ?\", id)\n" +
+		"  ```\n" +
+		"- SQL built with string concatenation in " + filepath.Join(root, "internal", "store") + "/users.go:42-43 allows injection.\n" +
+		"\n## Nitpicks\n" +
+		"- `users.go` has a stale comment\n"
+	report := ParseReview(text)
+	report.Target = "internal/store"
+	if f := report.Findings[0]; f.Line != 42 || f.EndLine != 43 || f.Fix != "row := db.QueryRow(\"SELECT * FROM users WHERE id = ?\", id)" {
+		t.Fatalf("expected the range and fix parsed, got %+v", f)
+	}
+
+	log := report.SARIF("dev", root)
+	results := log.Results()
+	if len(results) != 2 {
+		t.Fatalf("expected the repeated finding dropped, got %d results", len(results))
+	}
+
+	critical := results[0]
+	loc := critical.Locations[0].PhysicalLocation
+	if critical.RuleID != "review/critical" || critical.Level != sarif.LevelError || loc.ArtifactLocation.URI != "internal/store/users.go" {
+		t.Errorf("unexpected result %+v", critical)
+	}
+	if strings.Contains(critical.Message.Text, root) {
+		t.Errorf("expected the checkout path stripped from %q", critical.Message.Text)
+	}
+	if len(critical.Fixes) != 1 || critical.Fixes[0].ArtifactChanges[0].Replacements[0].DeletedRegion.EndLine != 43 {
+		t.Errorf("expected a fix replacing lines 42-43, got %+v", critical.Fixes)
+	}
+
+	// A file named relative to the reviewed directory is found in it
+	nit := results[1]
+	if nit.Level != sarif.LevelNote || nit.Locations[0].PhysicalLocation.ArtifactLocation.URI != "internal/store/users.go" || nit.Fixes != nil {
+		t.Errorf("unexpected result %+v", nit)
+	}
+
+	// Findings without a file are reported at the reviewed directory
+	general := ParseReview("## Suggestions\n- Add more tests\n")
+	general.Target = "internal/store"
+	if loc := general.SARIF("dev", root).Results()[0].Locations; len(loc) != 1 || loc[0].PhysicalLocation.ArtifactLocation.URI != "internal/store" {
+		t.Errorf("expected the reviewed directory as location, got %+v", loc)
+	}
+	general.Target = "."
+	if loc := general.SARIF("dev", root).Results()[0].Locations; len(loc) != 1 || loc[0].PhysicalLocation.ArtifactLocation.URI != "." {
+		t.Errorf("expected the project root as location, got %+v", loc)
+	}
+
+	// The fingerprint doesn't depend on where the repo is checked out
+	other := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(other, "internal", "store"), 0o755)
+	_ = os.WriteFile(filepath.Join(other, "internal", "store", "users.go"), []byte(users), 0o644)
+	moved := ParseReview(strings.ReplaceAll(text, root, other))
+	moved.Target = "internal/store"
+	if fp := moved.SARIF("dev", other).Results()[0].PartialFingerprints[sarif.FingerprintKey]; fp != critical.PartialFingerprints[sarif.FingerprintKey] {
+		t.Error("expected the same fingerprint in another checkout")
+	}
+}
+
+func TestReviewSARIFFingerprintsTheCode(t *testing.T) {
+	root := t.TempDir()
+	src := "package store\n\nfunc Find(id string) {\n\trow := db.QueryRow(\"SELECT * FROM users WHERE id = \" + id)\n}\n"
+	if err := os.WriteFile(filepath.Join(root, "users.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := func(text string) string {
+		report := ParseReview("## Critical Issues\n- " + text + "\n")
+		return report.SARIF("dev", root).Results()[0].PartialFingerprints[sarif.FingerprintKey]
+	}
+
+	first := fingerprint("users.go:4 builds SQL by concatenation, allowing injection.")
+	reworded := fingerprint("Injection risk: the query in users.go:4 is concatenated from user input.")
+	if first != reworded {
+		t.Error("expected a reworded finding on the same code to keep its fingerprint")
+	}
+
+	// Code moving down keeps it too
+	if err := os.WriteFile(filepath.Join(root, "users.go"), []byte("// Package store reads users\n"+src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if moved := fingerprint("users.go:5 builds SQL by concatenation."); moved != first {
+		t.Error("expected the fingerprint to follow the code when it moves")
+	}
+	if other := fingerprint("users.go:4 builds SQL by concatenation, allowing injection."); other == first {
+		t.Error("expected a finding on different code to get another fingerprint")
+	}
+}
+
+func TestReviewFixesNeedAMarkAndAFit(t *testing.T) {
+	root := t.TempDir()
+	src := "package calc\n\nfunc Add(a, b int) int {\n\treturn a - b\n}\n"
+	if err := os.WriteFile(filepath.Join(root, "calc.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, review string
+		fix          bool
+	}{
+		{"fix lead-in", "- calc.go:4 subtracts instead of adding.\n  Fix:\n  ```go\n  \treturn a + b\n  ```\n", true},
+		{"lead-in on the bullet", "- calc.go:4 subtracts instead of adding. **Suggested fix:**\n  ```go\n  \treturn a + b\n  ```\n", true},
+		{"suggestion block", "- calc.go:4 subtracts instead of adding.\n  ```suggestion\n  \treturn a + b\n  ```\n", true},
+		{"illustration", "- calc.go:4 subtracts; callers do this:\n  ```go\n  total := Add(1, 2)\n  ```\n", false},
+		{"lines past the end", "- calc.go:40-41 subtracts.\n  Fix:\n  ```go\n  \treturn a + b\n  ```\n", false},
+		{"whole function for one line", "- calc.go:4 subtracts.\n  Fix:\n  ```go\n  // Add adds\n  // two numbers\n  // together\n  func Add(a, b int) int {\n  \treturn a + b\n  }\n  ```\n", false},
+	}
+	for _, tt := range tests {
+		report := ParseReview("## Critical Issues\n" + tt.review)
+		if len(report.Findings) != 1 {
+			t.Fatalf("%s: expected one finding, got %+v", tt.name, report.Findings)
+		}
+		if strings.Contains(strings.ToLower(report.Findings[0].Message), "fix:") {
+			t.Errorf("%s: expected the lead-in left out of the message, got %q", tt.name, report.Findings[0].Message)
+		}
+		results := report.SARIF("dev", root).Results()
+		if got := len(results[0].Fixes) == 1; got != tt.fix {
+			t.Errorf("%s: expected fix %v, got %+v", tt.name, tt.fix, results[0].Fixes)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package modes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/sarif"
+)
+
+// reviewRules are the SARIF rules of review findings, one per severity
+var reviewRules = []struct {
+	severity    string
+	level       string
+	name        string
+	description string
+}{
+	{SeverityCritical, sarif.LevelError, "CriticalIssue", "Bug, security risk or breaking problem found in review"},
+	{SeveritySuggestion, sarif.LevelWarning, "Suggestion", "Quality, performance or maintainability improvement suggested in review"},
+	{SeverityNitpick, sarif.LevelNote, "Nitpick", "Style, naming or minor preference raised in review"},
+}
+
+// SARIF converts the review to a SARIF log. root is the directory the
+// review ran in, which locations are relative to. Findings without a file
+// are located at the reviewed directory. Findings with a line range and a
+// fix that fits it carry the fix as a SARIF fix.
+func (r *ReviewReport) SARIF(toolVersion, root string) *sarif.Log {
+	log := sarif.NewLog("gptcode review", toolVersion, "https://gptcode.cloud")
+	levels := make(map[string]string)
+	for _, rule := range reviewRules {
+		levels[rule.severity] = rule.level
+		log.AddRule(sarif.Rule{
+			ID:                   "review/" + rule.severity,
+			Name:                 rule.name,
+			ShortDescription:     &sarif.Message{Text: rule.description},
+			DefaultConfiguration: &sarif.Configuration{Level: rule.level},
+		})
+	}
+
+	for _, f := range r.Findings {
+		ruleID := "review/" + f.Severity
+		file := r.findingFile(f, root)
+		uri := ""
+		if file != "" {
+			uri = sarif.ArtifactRelative(root, file).URI
+		}
+		// The review prompt names the target by its absolute path, which
+		// would tie messages and fingerprints to this checkout
+		message := f.Message
+		if root != "" {
+			message = strings.ReplaceAll(message, root+string(filepath.Separator), "")
+		}
+		result := sarif.Result{
+			RuleID:  ruleID,
+			Level:   levels[f.Severity],
+			Message: sarif.Message{Text: message},
+		}
+		// The model words the same finding differently on every run, so
+		// the code it points at identifies it; the message only does when
+		// there are no lines to read
+		region := sarif.Lines(f.Line, f.EndLine)
+		identity := message
+		if file != "" {
+			if hash := sarif.RegionHash(root, file, region); hash != "" {
+				identity = hash
+			}
+		}
+		result.PartialFingerprints = map[string]string{
+			sarif.FingerprintKey: sarif.Fingerprint(ruleID, uri, identity),
+		}
+		if file == "" {
+			// Code scanning rejects results without a location
+			result.Locations = []sarif.Location{r.targetLocation(root)}
+			log.AddResult(result)
+			continue
+		}
+
+		result.Locations = []sarif.Location{sarif.NewLocation(root, file, region)}
+		if region != nil && f.Fix != "" && fixFits(root, file, f) {
+			result.Fixes = []sarif.Fix{{
+				ArtifactChanges: []sarif.ArtifactChange{{
+					ArtifactLocation: sarif.ArtifactRelative(root, file),
+					Replacements: []sarif.Replacement{{
+						DeletedRegion:   *region,
+						InsertedContent: &sarif.ArtifactContent{Text: strings.TrimSuffix(f.Fix, "\n")},
+					}},
+				}},
+			}}
+		}
+		log.AddResult(result)
+	}
+	return log
+}
+
+// maxFixLineDrift is how many lines a fix may add or remove compared with
+// the lines it replaces
+const maxFixLineDrift = 3
+
+// fixFits reports whether a finding's fix can replace the lines it names:
+// they must exist in the file, and the fix must change them without
+// growing or shrinking them by more than a few lines, which would mean the
+// model's range doesn't match its code
+func fixFits(root, file string, f ReviewFinding) bool {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(root, file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), "\n")
+	end := max(f.EndLine, f.Line)
+	if f.Line <= 0 || end > len(lines) {
+		return false
+	}
+	current := strings.Join(lines[f.Line-1:end], "\n")
+	fix := strings.TrimSuffix(f.Fix, "\n")
+	if strings.TrimSpace(fix) == strings.TrimSpace(current) {
+		return false
+	}
+	drift := len(strings.Split(fix, "\n")) - (end - f.Line + 1)
+	return drift <= maxFixLineDrift && drift >= -maxFixLineDrift
+}
+
+// targetLocation is where findings that name no file are reported: the
+// reviewed directory, or the project root
+func (r *ReviewReport) targetLocation(root string) sarif.Location {
+	target := r.Target
+	if target != "" && !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	if target == "" || filepath.Clean(target) == filepath.Clean(root) {
+		return sarif.RootLocation()
+	}
+	if _, err := os.Stat(target); err != nil {
+		return sarif.RootLocation()
+	}
+	return sarif.NewLocation(root, target, nil)
+}
+
+// findingFile returns the file a finding is about. Paths the model gives
+// relative to a reviewed directory are joined to it, and findings without
+// a file belong to the reviewed file.
+func (r *ReviewReport) findingFile(f ReviewFinding, root string) string {
+	target := r.Target
+	if target != "" && !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	info, err := os.Stat(target)
+
+	if f.File == "" {
+		if err == nil && !info.IsDir() {
+			return target
+		}
+		return ""
+	}
+	if filepath.IsAbs(f.File) {
+		return f.File
+	}
+	if _, statErr := os.Stat(filepath.Join(root, f.File)); statErr != nil && err == nil && info.IsDir() {
+		if _, err := os.Stat(filepath.Join(target, f.File)); err == nil {
+			return filepath.Join(target, f.File)
+		}
+	}
+	return f.File
+}
@@ -11,6 +11,7 @@ import (
 	"gptcode/internal/live"
 	"gptcode/internal/llm"
 	"gptcode/internal/prompt"
+	"gptcode/internal/telemetry"
 	"gptcode/internal/tools"
 )
 
@@ -37,6 +38,7 @@ func RunExecute(builder *prompt.Builder, provider llm.Provider, model string, ar
 	if task == "" {
 		return fmt.Errorf("no task provided")
 	}
+	defer telemetry.TrackSession()()
 
 	// Report start to Live via HTTP
 	if reportConfig != nil {
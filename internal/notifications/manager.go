@@ -96,6 +96,11 @@ func (m *Manager) SendBlockedNotification(ctx context.Context, action, language,
 
 // saveNotification saves notification to a file
 func (m *Manager) saveNotification(n BlockedNotification) error {
+	return writeNotification(n.ID, n)
+}
+
+// writeNotification stores a notification where Live picks it up
+func writeNotification(id string, n interface{}) error {
 	// Get notifications directory
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -106,7 +111,7 @@ func (m *Manager) saveNotification(n BlockedNotification) error {
 		return err
 	}
 
-	filename := filepath.Join(notifyDir, fmt.Sprintf("%s.json", n.ID))
+	filename := filepath.Join(notifyDir, fmt.Sprintf("%s.json", id))
 	data, err := json.MarshalIndent(n, "", "  ")
 	if err != nil {
 		return err
@@ -142,6 +147,51 @@ func (m *Manager) sendToTelegram(n BlockedNotification) {
 	fmt.Printf("[NOTIFICATIONS] Would send Telegram notification: %s\n", n.Message)
 }
 
+// BudgetNotification reports spend approaching or over a limit
+type BudgetNotification struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`  // "budget"
+	Level     string    `json:"level"` // warning or critical
+	Scope     string    `json:"scope"` // task, session, daily or monthly
+	Spent     float64   `json:"spent"`
+	Limit     float64   `json:"limit"`
+	Model     string    `json:"model,omitempty"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SendBudgetAlert sends a budget warning through the configured channels
+func (m *Manager) SendBudgetAlert(ctx context.Context, n BudgetNotification) error {
+	if m.setup == nil || !m.setup.Notifications.Budget.Enabled {
+		return nil
+	}
+
+	n.Type = "budget"
+	if n.ID == "" {
+		n.ID = fmt.Sprintf("budget-%s-%d", n.Scope, time.Now().UnixNano())
+	}
+	if n.Timestamp.IsZero() {
+		n.Timestamp = time.Now()
+	}
+	if err := writeNotification(n.ID, n); err != nil {
+		fmt.Fprintf(os.Stderr, "[NOTIFICATIONS] Failed to save notification: %v\n", err)
+	}
+
+	for _, channel := range m.setup.Notifications.Budget.Channels {
+		switch channel {
+		case "live":
+			if m.liveClient != nil {
+				if err := m.liveClient.SendExecutionStep("error", "BUDGET: "+n.Message, map[string]interface{}{"data": n}); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to stream notification to Live dashboard: %v\n", err)
+				}
+			}
+		case "telegram":
+			fmt.Printf("[NOTIFICATIONS] Would send Telegram notification: %s\n", n.Message)
+		}
+	}
+	return nil
+}
+
 // GetNotifications returns all notifications
 func (m *Manager) GetNotifications() []BlockedNotification {
 	return m.notifications
@@ -0,0 +1,177 @@
+package observability
+
+import (
+	"fmt"
+	"io"
+)
+
+// DecisionChange is a decision two runs made differently. A is empty when
+// only the second run made it, B when only the first did.
+type DecisionChange struct {
+	Node string `json:"node"`
+	Type string `json:"type"`
+	A    string `json:"a,omitempty"`
+	B    string `json:"b,omitempty"`
+}
+
+// TraceDiff compares two runs, usually of the same task
+type TraceDiff struct {
+	A        string `json:"a"`
+	B        string `json:"b"`
+	SameTask bool   `json:"same_task"`
+	SuccessA bool   `json:"success_a"`
+	SuccessB bool   `json:"success_b"`
+
+	Decisions []DecisionChange `json:"decisions,omitempty"`
+	// ExtraToolCalls ran only in B, MissingToolCalls only in A
+	ExtraToolCalls   []ToolCallTrace `json:"extra_tool_calls,omitempty"`
+	MissingToolCalls []ToolCallTrace `json:"missing_tool_calls,omitempty"`
+
+	CostDelta       float64 `json:"cost_delta"`
+	TokensDelta     int     `json:"tokens_delta"`
+	DurationDeltaMs int64   `json:"duration_delta_ms"`
+}
+
+// DiffTraces compares run b against run a. Decisions are paired by node
+// and type in the order they were made; tool calls match when the tool and
+// arguments are the same.
+func DiffTraces(a, b SessionTrace) TraceDiff {
+	ta, tb := Totals(a), Totals(b)
+	d := TraceDiff{
+		A:               a.SessionID,
+		B:               b.SessionID,
+		SameTask:        a.Command == b.Command,
+		SuccessA:        a.Success,
+		SuccessB:        b.Success,
+		CostDelta:       tb.Cost - ta.Cost,
+		TokensDelta:     (tb.TokensIn + tb.TokensOut) - (ta.TokensIn + ta.TokensOut),
+		DurationDeltaMs: b.TotalTimeMs - a.TotalTimeMs,
+	}
+
+	decisionsA, decisionsB := decisionsByKey(a), decisionsByKey(b)
+	for _, key := range decisionKeys(a, b) {
+		da, db := decisionsA[key], decisionsB[key]
+		for i := 0; i < len(da) || i < len(db); i++ {
+			c := DecisionChange{Node: key[0], Type: key[1]}
+			if i < len(da) {
+				c.A = da[i]
+			}
+			if i < len(db) {
+				c.B = db[i]
+			}
+			if c.A != c.B {
+				d.Decisions = append(d.Decisions, c)
+			}
+		}
+	}
+
+	d.ExtraToolCalls = subtractCalls(ToolCalls(b), ToolCalls(a))
+	d.MissingToolCalls = subtractCalls(ToolCalls(a), ToolCalls(b))
+	return d
+}
+
+func decisionsByKey(t SessionTrace) map[[2]string][]string {
+	m := map[[2]string][]string{}
+	for _, s := range t.Steps {
+		if s.Decision == nil {
+			continue
+		}
+		key := [2]string{s.Node, s.Decision.Type}
+		m[key] = append(m[key], s.Decision.Chosen)
+	}
+	return m
+}
+
+// decisionKeys lists the node and type of every decision in either run,
+// in the order first seen
+func decisionKeys(traces ...SessionTrace) [][2]string {
+	seen := map[[2]string]bool{}
+	var keys [][2]string
+	for _, t := range traces {
+		for _, s := range t.Steps {
+			if s.Decision == nil {
+				continue
+			}
+			key := [2]string{s.Node, s.Decision.Type}
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// ToolCalls returns a trace's tool calls in the order they ran
+func ToolCalls(t SessionTrace) []ToolCallTrace {
+	var calls []ToolCallTrace
+	for _, s := range t.Steps {
+		if s.ToolCall != nil {
+			calls = append(calls, *s.ToolCall)
+		}
+	}
+	return calls
+}
+
+// subtractCalls returns the calls in from that have no match in other,
+// counting repeats
+func subtractCalls(from, other []ToolCallTrace) []ToolCallTrace {
+	counts := map[[2]string]int{}
+	for _, c := range other {
+		counts[[2]string{c.Name, c.Arguments}]++
+	}
+	var left []ToolCallTrace
+	for _, c := range from {
+		key := [2]string{c.Name, c.Arguments}
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		left = append(left, c)
+	}
+	return left
+}
+
+// RenderDiff writes a trace diff for the terminal
+func RenderDiff(w io.Writer, d TraceDiff) {
+	fmt.Fprintf(w, "A: %s (%s)\nB: %s (%s)\n", d.A, outcome(d.SuccessA), d.B, outcome(d.SuccessB))
+	if !d.SameTask {
+		fmt.Fprintln(w, "⚠️  The runs are of different tasks")
+	}
+
+	fmt.Fprintln(w, "\nDecisions")
+	if len(d.Decisions) == 0 {
+		fmt.Fprintln(w, "  Same decisions")
+	}
+	for _, c := range d.Decisions {
+		fmt.Fprintf(w, "  %s %s: %s → %s\n", c.Node, c.Type, orNone(c.A), orNone(c.B))
+	}
+
+	fmt.Fprintln(w, "\nTool calls")
+	if len(d.ExtraToolCalls) == 0 && len(d.MissingToolCalls) == 0 {
+		fmt.Fprintln(w, "  Same tool calls")
+	}
+	for _, c := range d.MissingToolCalls {
+		fmt.Fprintf(w, "  - %s %s\n", c.Name, summarizeArguments(c.Arguments))
+	}
+	for _, c := range d.ExtraToolCalls {
+		fmt.Fprintf(w, "  + %s %s\n", c.Name, summarizeArguments(c.Arguments))
+	}
+
+	fmt.Fprintln(w, "\nCost")
+	fmt.Fprintf(w, "  %+.4f USD, %+d tokens, %+.1fs\n", d.CostDelta, d.TokensDelta, float64(d.DurationDeltaMs)/1000)
+}
+
+func outcome(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failed"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -81,8 +81,19 @@ func (e MovementEvent) EventType() string { return "movement" }
 // ValidationEvent is emitted after validation
 type ValidationEvent struct {
 	BaseEvent
-	Success bool     `json:"success"`
-	Issues  []string `json:"issues,omitempty"`
+	Success  bool              `json:"success"`
+	Issues   []string          `json:"issues,omitempty"`
+	Step     string            `json:"step,omitempty"`
+	Criteria []CriterionResult `json:"criteria,omitempty"`
+}
+
+// CriterionResult is the outcome of checking one acceptance criterion
+type CriterionResult struct {
+	Criterion string `json:"criterion"`
+	Kind      string `json:"kind"` // "http", "cli", "grep", "judge"
+	Passed    bool   `json:"passed"`
+	Skipped   bool   `json:"skipped,omitempty"`
+	Evidence  string `json:"evidence,omitempty"`
 }
 
 func (e ValidationEvent) EventType() string { return "validation" }
@@ -247,6 +258,16 @@ func (o *AgentObserver) printEvent(event Event) {
 			}
 			fmt.Printf("  [AGENT] %s ended: %s\n", e.Name, status)
 		}
+	case *ValidationEvent:
+		for _, c := range e.Criteria {
+			status := "PASS"
+			if c.Skipped {
+				status = "SKIP"
+			} else if !c.Passed {
+				status = "FAIL"
+			}
+			fmt.Printf("  [CHECK] %-5s %-6s %s\n", status, c.Kind, c.Criterion)
+		}
 	case *MovementEvent:
 		if e.Phase == "start" {
 			fmt.Printf("\n  >> Movement: %s\n", e.Name)
@@ -0,0 +1,77 @@
+package observability
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"gptcode/internal/redact"
+)
+
+// ToolRunner runs a recorded tool call in dir and returns its result and
+// error message
+type ToolRunner func(call ToolCallTrace, dir string) (result, errMsg string)
+
+// ReplayResult is how a recorded tool call went when run again
+type ReplayResult struct {
+	Call   ToolCallTrace `json:"call"`
+	Result string        `json:"result,omitempty"`
+	Error  string        `json:"error,omitempty"`
+	// Diverged is set when the call failed in one run but not the other,
+	// or when it couldn't be run again
+	Diverged bool `json:"diverged"`
+	// Masked is set when the saved arguments hold redacted secrets, so the
+	// call wasn't run
+	Masked bool `json:"masked,omitempty"`
+}
+
+// Checkout adds a detached git worktree of the commit the trace started
+// from at dir, using the repository in repo
+func Checkout(t SessionTrace, repo, dir string) error {
+	if t.Commit == "" {
+		return fmt.Errorf("trace %s has no commit to check out; it was recorded outside a git repository or by an older version", t.SessionID)
+	}
+	cmd := exec.Command("git", "worktree", "add", "--detach", dir, t.Commit)
+	cmd.Dir = repo
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to check out %.12s: %s", t.Commit, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// RemoveCheckout deletes a worktree added by Checkout
+func RemoveCheckout(repo, dir string) error {
+	cmd := exec.Command("git", "worktree", "remove", "--force", dir)
+	cmd.Dir = repo
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove worktree %s: %s", dir, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Replay runs the trace's tool calls in order in dir. Traces are saved
+// redacted, so calls whose arguments hold a secret placeholder aren't run:
+// they're reported as diverged rather than replayed with the placeholder.
+func Replay(t SessionTrace, dir string, run ToolRunner) []ReplayResult {
+	calls := ToolCalls(t)
+	results := make([]ReplayResult, 0, len(calls))
+	for _, call := range calls {
+		if redact.HasPlaceholder(call.Arguments) {
+			results = append(results, ReplayResult{
+				Call:     call,
+				Error:    "arguments hold secrets that were masked when the trace was saved, so the call can't be replayed",
+				Diverged: true,
+				Masked:   true,
+			})
+			continue
+		}
+		result, errMsg := run(call, dir)
+		results = append(results, ReplayResult{
+			Call:     call,
+			Result:   result,
+			Error:    errMsg,
+			Diverged: (call.Error != "") != (errMsg != ""),
+		})
+	}
+	return results
+}
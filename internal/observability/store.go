@@ -0,0 +1,106 @@
+package observability
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TracesDirEnv overrides where session traces are written
+const TracesDirEnv = "GPTCODE_TRACES_DIR"
+
+// TracesDir is where session traces are kept, ~/.gptcode/traces unless
+// GPTCODE_TRACES_DIR says otherwise
+func TracesDir() string {
+	if dir := os.Getenv(TracesDirEnv); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".gptcode", "traces")
+	}
+	return filepath.Join(home, ".gptcode", "traces")
+}
+
+// TraceFile is a session trace and the file it was read from
+type TraceFile struct {
+	Path string `json:"path"`
+	SessionTrace
+}
+
+// LoadTrace reads a trace file
+func LoadTrace(path string) (*TraceFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trace: %w", err)
+	}
+	var t SessionTrace
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse trace %s: %w", path, err)
+	}
+	return &TraceFile{Path: path, SessionTrace: t}, nil
+}
+
+// ListTraces reads the trace_*.json files in dirs, newest first. Files
+// that don't parse are skipped, and a missing directory has no traces.
+func ListTraces(dirs ...string) ([]TraceFile, error) {
+	seen := map[string]bool{}
+	var traces []TraceFile
+	for _, dir := range dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "trace_*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list traces in %s: %w", dir, err)
+		}
+		for _, path := range paths {
+			abs, err := filepath.Abs(path)
+			if err == nil {
+				path = abs
+			}
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			t, err := LoadTrace(path)
+			if err != nil {
+				continue
+			}
+			traces = append(traces, *t)
+		}
+	}
+	sort.SliceStable(traces, func(i, j int) bool {
+		return traces[i].StartTime.After(traces[j].StartTime)
+	})
+	return traces, nil
+}
+
+// FindTrace resolves ref as a trace file, or as a session ID or a unique
+// prefix of one among the traces in dirs
+func FindTrace(ref string, dirs ...string) (*TraceFile, error) {
+	if info, err := os.Stat(ref); err == nil && !info.IsDir() {
+		return LoadTrace(ref)
+	}
+	traces, err := ListTraces(dirs...)
+	if err != nil {
+		return nil, err
+	}
+	var matches []TraceFile
+	for _, t := range traces {
+		if t.SessionID == ref {
+			return &t, nil
+		}
+		if ref != "" && strings.HasPrefix(t.SessionID, ref) {
+			matches = append(matches, t)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no trace matches %q", ref)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("%q matches %d traces; use more of the session ID", ref, len(matches))
+	}
+}
@@ -0,0 +1,256 @@
+package observability
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// TimelineEntry is one row of a trace's timeline
+type TimelineEntry struct {
+	// OffsetMs is the time since the session started
+	OffsetMs int64 `json:"offset_ms"`
+	// Kind is decision, tool, llm or step
+	Kind       string  `json:"kind"`
+	Node       string  `json:"node"`
+	Summary    string  `json:"summary"`
+	DurationMs int64   `json:"duration_ms,omitempty"`
+	TokensIn   int     `json:"tokens_in,omitempty"`
+	TokensOut  int     `json:"tokens_out,omitempty"`
+	Cost       float64 `json:"cost,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// TraceTotals adds up a trace's steps
+type TraceTotals struct {
+	Steps       int     `json:"steps"`
+	Decisions   int     `json:"decisions"`
+	ToolCalls   int     `json:"tool_calls"`
+	ToolErrors  int     `json:"tool_errors"`
+	LLMCalls    int     `json:"llm_calls"`
+	TokensIn    int     `json:"tokens_in"`
+	TokensOut   int     `json:"tokens_out"`
+	Cost        float64 `json:"cost"`
+	DurationMs  int64   `json:"duration_ms"`
+	FailedSteps int     `json:"failed_steps"`
+}
+
+// Timeline orders a trace's steps by time
+func Timeline(t SessionTrace) []TimelineEntry {
+	steps := append([]StepTrace(nil), t.Steps...)
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Timestamp.Before(steps[j].Timestamp)
+	})
+
+	entries := make([]TimelineEntry, 0, len(steps))
+	for _, s := range steps {
+		e := TimelineEntry{
+			Kind:       stepKind(s),
+			Node:       s.Node,
+			DurationMs: s.Metrics.DurationMs,
+			TokensIn:   s.Metrics.TokensIn,
+			TokensOut:  s.Metrics.TokensOut,
+			Cost:       s.Metrics.Cost,
+			Error:      s.Metrics.ErrorMessage,
+		}
+		if !t.StartTime.IsZero() && !s.Timestamp.IsZero() {
+			e.OffsetMs = s.Timestamp.Sub(t.StartTime).Milliseconds()
+		}
+		switch e.Kind {
+		case "decision":
+			d := s.Decision
+			e.Summary = fmt.Sprintf("%s → %s", d.Type, d.Chosen)
+			if d.Reasoning != "" {
+				e.Summary += " (" + d.Reasoning + ")"
+			}
+		case "tool":
+			e.Node = s.ToolCall.Name
+			e.Summary = summarizeArguments(s.ToolCall.Arguments)
+			e.Error = s.ToolCall.Error
+		case "llm":
+			if model, ok := s.Inputs["model"].(string); ok {
+				e.Summary = model
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func stepKind(s StepTrace) string {
+	switch {
+	case s.Decision != nil:
+		return "decision"
+	case s.ToolCall != nil:
+		return "tool"
+	case s.Node == "LLM":
+		return "llm"
+	default:
+		return "step"
+	}
+}
+
+// summarizeArguments picks the argument that says the most about a tool
+// call, such as its path or command
+func summarizeArguments(args string) string {
+	var m map[string]interface{}
+	if json.Unmarshal([]byte(args), &m) != nil {
+		return truncate(args, 80)
+	}
+	for _, key := range []string{"path", "command", "pattern", "query", "name"} {
+		if v, ok := m[key].(string); ok && v != "" {
+			return truncate(v, 80)
+		}
+	}
+	return truncate(args, 80)
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// Totals adds up tool calls, LLM usage and cost. Cost comes from the
+// steps, falling back to the session total for traces without LLM steps.
+func Totals(t SessionTrace) TraceTotals {
+	totals := TraceTotals{Steps: len(t.Steps), DurationMs: t.TotalTimeMs}
+	for _, s := range t.Steps {
+		switch stepKind(s) {
+		case "decision":
+			totals.Decisions++
+		case "tool":
+			totals.ToolCalls++
+			if s.ToolCall.Error != "" {
+				totals.ToolErrors++
+			}
+		case "llm":
+			totals.LLMCalls++
+		default:
+			if s.Metrics.ErrorMessage != "" {
+				totals.FailedSteps++
+			}
+		}
+		totals.TokensIn += s.Metrics.TokensIn
+		totals.TokensOut += s.Metrics.TokensOut
+		totals.Cost += s.Metrics.Cost
+	}
+	if totals.Cost == 0 {
+		totals.Cost = t.TotalCost
+	}
+	return totals
+}
+
+var kindIcons = map[string]string{
+	"decision": "⚖️ ",
+	"tool":     "🔧",
+	"llm":      "🤖",
+	"step":     "▶️ ",
+}
+
+// RenderTimeline writes a trace as a timeline for the terminal
+func RenderTimeline(w io.Writer, t SessionTrace) {
+	status := "✅ success"
+	if !t.Success {
+		status = "❌ failed"
+	}
+	totals := Totals(t)
+	fmt.Fprintf(w, "Session %s  %s  %s  %s  $%.4f\n", t.SessionID, status,
+		t.StartTime.Format("2006-01-02 15:04"), formatMs(t.TotalTimeMs), totals.Cost)
+	if t.Command != "" {
+		fmt.Fprintf(w, "Task: %s\n", truncate(t.Command, 100))
+	}
+	if t.Commit != "" {
+		fmt.Fprintf(w, "Commit: %.12s in %s\n", t.Commit, t.WorkDir)
+	}
+	fmt.Fprintln(w)
+
+	for _, e := range Timeline(t) {
+		fmt.Fprintf(w, "  %8s  %s %-18s %s", "+"+formatMs(e.OffsetMs), kindIcons[e.Kind], truncate(e.Node, 18), e.Summary)
+		if e.TokensIn > 0 || e.TokensOut > 0 {
+			fmt.Fprintf(w, "  %s in / %s out", formatNumber(e.TokensIn), formatNumber(e.TokensOut))
+		}
+		if e.Cost > 0 {
+			fmt.Fprintf(w, "  $%.4f", e.Cost)
+		}
+		if e.DurationMs > 0 {
+			fmt.Fprintf(w, "  (%s)", formatMs(e.DurationMs))
+		}
+		if e.Error != "" {
+			fmt.Fprintf(w, "\n  %8s     ✗ %s", "", truncate(e.Error, 100))
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "\n%d steps, %d decisions, %d tool calls (%d failed), %d LLM calls, %s tokens in, %s out, $%.4f\n",
+		totals.Steps, totals.Decisions, totals.ToolCalls, totals.ToolErrors, totals.LLMCalls,
+		formatNumber(totals.TokensIn), formatNumber(totals.TokensOut), totals.Cost)
+}
+
+func formatMs(ms int64) string {
+	if ms < 1000 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	return fmt.Sprintf("%.1fs", float64(ms)/1000)
+}
+
+var htmlTimeline = template.Must(template.New("trace").Funcs(template.FuncMap{
+	"ms":     formatMs,
+	"number": formatNumber,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>gptcode trace {{.Trace.SessionID}}</title>
+<style>
+body { font: 14px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 2rem; color: #1f2328; }
+h1 { font-size: 1.25rem; margin-bottom: 0.25rem; }
+.meta { color: #59636e; margin-bottom: 1.5rem; }
+.ok { color: #1a7f37; } .failed { color: #cf222e; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.35rem 0.6rem; border-bottom: 1px solid #d1d9e0; vertical-align: top; }
+th { background: #f6f8fa; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; white-space: nowrap; }
+tr.decision td.kind { color: #8250df; } tr.tool td.kind { color: #0969da; } tr.llm td.kind { color: #9a6700; }
+.error { color: #cf222e; font-size: 0.9em; }
+.totals { margin-top: 1rem; color: #59636e; }
+</style>
+</head>
+<body>
+<h1>{{.Trace.Command}}</h1>
+<div class="meta">
+Session {{.Trace.SessionID}} ·
+{{if .Trace.Success}}<span class="ok">success</span>{{else}}<span class="failed">failed</span>{{end}} ·
+{{.Trace.StartTime.Format "2006-01-02 15:04:05"}} · {{ms .Trace.TotalTimeMs}}
+{{if .Trace.Commit}} · commit {{.Trace.Commit}}{{end}}
+</div>
+<table>
+<tr><th>Time</th><th>Kind</th><th>Node</th><th>Details</th><th>Tokens in/out</th><th>Cost</th><th>Duration</th></tr>
+{{range .Entries}}<tr class="{{.Kind}}">
+<td class="num">+{{ms .OffsetMs}}</td>
+<td class="kind">{{.Kind}}</td>
+<td>{{.Node}}</td>
+<td>{{.Summary}}{{if .Error}}<div class="error">{{.Error}}</div>{{end}}</td>
+<td class="num">{{if or .TokensIn .TokensOut}}{{number .TokensIn}} / {{number .TokensOut}}{{end}}</td>
+<td class="num">{{if .Cost}}${{printf "%.4f" .Cost}}{{end}}</td>
+<td class="num">{{if .DurationMs}}{{ms .DurationMs}}{{end}}</td>
+</tr>
+{{end}}</table>
+<div class="totals">{{.Totals.Steps}} steps · {{.Totals.Decisions}} decisions · {{.Totals.ToolCalls}} tool calls ({{.Totals.ToolErrors}} failed) · {{.Totals.LLMCalls}} LLM calls · {{number .Totals.TokensIn}} tokens in, {{number .Totals.TokensOut}} out · ${{printf "%.4f" .Totals.Cost}}</div>
+</body>
+</html>
+`))
+
+// RenderHTML writes a trace as a self-contained HTML page
+func RenderHTML(w io.Writer, t SessionTrace) error {
+	return htmlTimeline.Execute(w, struct {
+		Trace   SessionTrace
+		Entries []TimelineEntry
+		Totals  TraceTotals
+	}{t, Timeline(t), Totals(t)})
+}
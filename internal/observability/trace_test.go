@@ -0,0 +1,189 @@
+package observability
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracerRecordsToolCallsAndKeepsFirstEnd(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(TracesDirEnv, dir)
+
+	tracer := NewTracer()
+	_ = tracer.Begin("1234abcd-run", "add a health check")
+	observer := NewTracingObserver(NewObserver(), tracer)
+	observer.Emit(&LLMRequestEvent{BaseEvent: BaseEvent{Time: time.Now()}, Model: "gpt-4o-mini", TokensIn: 1000, TokensOut: 200, Cost: 0.002})
+	observer.Emit(&ToolCallEvent{BaseEvent: BaseEvent{Time: time.Now()}, Name: "write_file", Arguments: `{"path":"health.go","content":"package main"}`, Error: "permission denied"})
+	_ = tracer.End(false)
+	_ = tracer.End(true)
+
+	traces, err := ListTraces(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 1 {
+		t.Fatalf("expected one trace in %s, got %d", dir, len(traces))
+	}
+	trace := traces[0]
+	if trace.Success {
+		t.Error("a later End(true) should not overwrite the failure")
+	}
+	if trace.Commit == "" || trace.WorkDir == "" {
+		t.Errorf("expected the starting commit and directory, got %q in %q", trace.Commit, trace.WorkDir)
+	}
+	calls := ToolCalls(trace.SessionTrace)
+	if len(calls) != 1 || calls[0].Name != "write_file" || calls[0].Error != "permission denied" {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+	totals := Totals(trace.SessionTrace)
+	if totals.LLMCalls != 1 || totals.TokensIn != 1000 || totals.ToolErrors != 1 {
+		t.Errorf("unexpected totals: %+v", totals)
+	}
+
+	found, err := FindTrace("1234", dir)
+	if err != nil || found.SessionID != "1234abcd-run" {
+		t.Errorf("expected the trace found by prefix, got %v, %v", found, err)
+	}
+	if _, err := FindTrace("ffff", dir); err == nil {
+		t.Error("expected no match for an unknown session")
+	}
+}
+
+func TestDiffTraces(t *testing.T) {
+	start := time.Now()
+	decision := func(chosen string) *Decision {
+		return &Decision{Type: "model_selection", Chosen: chosen}
+	}
+	tool := func(name, args string) *ToolCallTrace {
+		return &ToolCallTrace{Name: name, Arguments: args}
+	}
+	a := SessionTrace{SessionID: "a", Command: "fix the build", StartTime: start, TotalTimeMs: 4000, Success: false, Steps: []StepTrace{
+		{Node: "ModelSelector", Decision: decision("groq/llama")},
+		{Node: "Tool", ToolCall: tool("read_file", `{"path":"main.go"}`)},
+		{Node: "Tool", ToolCall: tool("run_command", `{"command":"go test ./..."}`)},
+		{Node: "LLM", Metrics: Metrics{TokensIn: 100, TokensOut: 50, Cost: 0.01}},
+	}}
+	b := SessionTrace{SessionID: "b", Command: "fix the build", StartTime: start, TotalTimeMs: 6000, Success: true, Steps: []StepTrace{
+		{Node: "ModelSelector", Decision: decision("openrouter/claude")},
+		{Node: "RecoverySystem", Decision: &Decision{Type: "retry", Chosen: "rollback"}},
+		{Node: "Tool", ToolCall: tool("read_file", `{"path":"main.go"}`)},
+		{Node: "Tool", ToolCall: tool("write_file", `{"path":"main.go"}`)},
+		{Node: "LLM", Metrics: Metrics{TokensIn: 300, TokensOut: 100, Cost: 0.05}},
+	}}
+
+	d := DiffTraces(a, b)
+	if !d.SameTask {
+		t.Error("expected the runs to be of the same task")
+	}
+	if len(d.Decisions) != 2 || d.Decisions[0].A != "groq/llama" || d.Decisions[0].B != "openrouter/claude" || d.Decisions[1].A != "" {
+		t.Errorf("unexpected decision changes: %+v", d.Decisions)
+	}
+	if len(d.ExtraToolCalls) != 1 || d.ExtraToolCalls[0].Name != "write_file" {
+		t.Errorf("unexpected extra tool calls: %+v", d.ExtraToolCalls)
+	}
+	if len(d.MissingToolCalls) != 1 || d.MissingToolCalls[0].Name != "run_command" {
+		t.Errorf("unexpected missing tool calls: %+v", d.MissingToolCalls)
+	}
+	if d.CostDelta < 0.0399 || d.CostDelta > 0.0401 || d.TokensDelta != 250 || d.DurationDeltaMs != 2000 {
+		t.Errorf("unexpected deltas: %+v", d)
+	}
+
+	var out bytes.Buffer
+	RenderDiff(&out, d)
+	for _, want := range []string{"groq/llama → openrouter/claude", "+ write_file main.go", "- run_command go test ./...", "+0.0400 USD"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRenderHTMLIsSelfContainedAndEscaped(t *testing.T) {
+	trace := SessionTrace{SessionID: "s", Command: "<script>alert(1)</script>", Steps: []StepTrace{
+		{Node: "Tool", ToolCall: &ToolCallTrace{Name: "read_file", Arguments: `{"path":"a.go"}`}},
+	}}
+	var out bytes.Buffer
+	if err := RenderHTML(&out, trace); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	if strings.Contains(html, "<script>") || !strings.Contains(html, "&lt;script&gt;") {
+		t.Error("expected the task to be escaped")
+	}
+	if strings.Contains(html, "src=") || strings.Contains(html, "<link") {
+		t.Error("expected no external resources")
+	}
+	if !strings.Contains(html, "read_file") || !strings.Contains(html, "a.go") {
+		t.Error("expected the tool call in the timeline")
+	}
+}
+
+func TestReplayOnCheckout(t *testing.T) {
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-qm", "init")
+	commit := git("rev-parse", "HEAD")
+
+	trace := SessionTrace{SessionID: "r", Commit: commit, WorkDir: repo, Steps: []StepTrace{
+		{Node: "Tool", ToolCall: &ToolCallTrace{Name: "read_file", Arguments: `{"path":"main.go"}`}},
+		{Node: "Tool", ToolCall: &ToolCallTrace{Name: "read_file", Arguments: `{"path":"gone.go"}`, Error: "not found"}},
+		{Node: "Tool", ToolCall: &ToolCallTrace{Name: "read_file", Arguments: `{"path":"later.go"}`}},
+		{Node: "Tool", ToolCall: &ToolCallTrace{Name: "run_command", Arguments: `{"command":"curl -H 'Authorization: Bearer __SECRET_BEARER_1__' x"}`}},
+	}}
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	if err := Checkout(trace, repo, dir); err != nil {
+		t.Fatal(err)
+	}
+	results := Replay(trace, dir, func(call ToolCallTrace, dir string) (string, string) {
+		path := filepath.Join(dir, strings.TrimSuffix(strings.TrimPrefix(call.Arguments, `{"path":"`), `"}`))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "not found"
+		}
+		return string(data), ""
+	})
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	if results[0].Result != "package main\n" || results[0].Diverged {
+		t.Errorf("expected the first call to read the checkout, got %+v", results[0])
+	}
+	if results[1].Error == "" || results[1].Diverged {
+		t.Errorf("expected the recorded failure reproduced, got %+v", results[1])
+	}
+	if !results[2].Diverged {
+		t.Errorf("expected a call that now fails to be marked, got %+v", results[2])
+	}
+	if !results[3].Masked || !results[3].Diverged {
+		t.Errorf("expected a call with masked arguments to be reported instead of run, got %+v", results[3])
+	}
+
+	if err := RemoveCheckout(repo, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("expected the worktree removed")
+	}
+	if err := Checkout(SessionTrace{SessionID: "old"}, repo, dir); err == nil {
+		t.Error("expected a trace without a commit to be refused")
+	}
+}
@@ -32,6 +32,16 @@ type StepTrace struct {
 	Inputs    map[string]interface{} `json:"inputs,omitempty"`
 	Outputs   map[string]interface{} `json:"outputs,omitempty"`
 	Decision  *Decision              `json:"decision,omitempty"`
+	ToolCall  *ToolCallTrace         `json:"tool_call,omitempty"`
+}
+
+// ToolCallTrace is a tool the model called, kept so a run can be replayed
+type ToolCallTrace struct {
+	Name string `json:"name"`
+	// Arguments is the JSON object the model sent
+	Arguments string `json:"arguments"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
 // SessionTrace represents a complete command execution
@@ -45,6 +55,10 @@ type SessionTrace struct {
 	TotalCost   float64     `json:"total_cost"`
 	TotalTimeMs int64       `json:"total_time_ms"`
 	Success     bool        `json:"success"`
+
+	// WorkDir and Commit are where the run started, for replay
+	WorkDir string `json:"work_dir,omitempty"`
+	Commit  string `json:"commit,omitempty"`
 }
 
 // Tracer is the interface for recording execution traces
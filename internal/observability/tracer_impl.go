@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"gptcode/internal/live"
+	"gptcode/internal/redact"
 )
 
 // TracerImpl is a concrete implementation of the Tracer interface
@@ -18,6 +22,9 @@ type TracerImpl struct {
 	steps     []StepTrace
 	path      []string
 	totalCost float64
+	workDir   string
+	commit    string
+	ended     bool
 	mutex     sync.Mutex
 }
 
@@ -37,6 +44,12 @@ func (t *TracerImpl) Begin(sessionID, command string) error {
 	t.sessionID = sessionID
 	t.command = command
 	t.startTime = time.Now()
+	t.steps = make([]StepTrace, 0)
+	t.path = make([]string, 0)
+	t.totalCost = 0
+	t.ended = false
+	t.workDir, _ = os.Getwd()
+	t.commit = gitHead(t.workDir)
 
 	return nil
 }
@@ -95,11 +108,17 @@ func (t *TracerImpl) RecordMetrics(node string, metrics Metrics) error {
 	return t.RecordStep(step)
 }
 
-// End finalizes the session trace
+// End finalizes the session trace. Only the first call counts, so a
+// deferred End(true) doesn't overwrite a failure recorded earlier.
 func (t *TracerImpl) End(success bool) error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
+	if t.ended {
+		return nil
+	}
+	t.ended = true
+
 	endTime := time.Now()
 	totalTimeMs := endTime.Sub(t.startTime).Milliseconds()
 
@@ -113,6 +132,8 @@ func (t *TracerImpl) End(success bool) error {
 		TotalCost:   t.totalCost,
 		TotalTimeMs: totalTimeMs,
 		Success:     success,
+		WorkDir:     t.workDir,
+		Commit:      t.commit,
 	}
 
 	// Write to file for now (can be extended to other storage)
@@ -136,10 +157,15 @@ func (t *TracerImpl) Flush() error {
 	return nil
 }
 
-// writeToFile writes the session trace to a file
+// writeToFile writes the session trace to a file in TracesDir
 func (t *TracerImpl) writeToFile(sessionTrace SessionTrace) error {
+	dir := TracesDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create traces directory: %w", err)
+	}
+
 	// Create a filename with timestamp and session ID
-	filename := fmt.Sprintf("trace_%s_%s.json", t.sessionID, t.startTime.Format("20060102_150405"))
+	filename := filepath.Join(dir, fmt.Sprintf("trace_%s_%s.json", t.sessionID, t.startTime.Format("20060102_150405")))
 
 	// Marshal to JSON
 	data, err := json.MarshalIndent(sessionTrace, "", "  ")
@@ -147,8 +173,19 @@ func (t *TracerImpl) writeToFile(sessionTrace SessionTrace) error {
 		return fmt.Errorf("failed to marshal session trace: %w", err)
 	}
 
-	// Write to file
-	return os.WriteFile(filename, data, 0644)
+	// Write to file, with any secrets in step inputs and outputs masked
+	return os.WriteFile(filename, []byte(redact.Default().Redact(string(data))), 0644)
+}
+
+// gitHead is the commit checked out in dir, or "" outside a repository
+func gitHead(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
 }
 
 // streamToLive sends the step to the Live dashboard via WebSocket
@@ -206,3 +243,53 @@ func (t *TracerImpl) streamToLiveSession(sessionTrace SessionTrace) error {
 	// Send via WebSocket
 	return client.SendTraceData(traceData)
 }
+
+// tracingObserver records tool calls and LLM requests in a tracer before
+// passing events on
+type tracingObserver struct {
+	Observer
+	tracer Tracer
+}
+
+// NewTracingObserver returns an observer that also records tool calls and
+// LLM requests in tracer, so traces can be shown, diffed and replayed
+func NewTracingObserver(observer Observer, tracer Tracer) Observer {
+	if tracer == nil {
+		return observer
+	}
+	return &tracingObserver{Observer: observer, tracer: tracer}
+}
+
+// Emit records tool calls and LLM requests, then forwards the event
+func (o *tracingObserver) Emit(event Event) {
+	switch e := event.(type) {
+	case *ToolCallEvent:
+		_ = o.tracer.RecordStep(StepTrace{
+			Node:      "Tool",
+			Timestamp: e.Time,
+			Metrics:   Metrics{DurationMs: e.Duration.Milliseconds(), ErrorMessage: e.Error},
+			ToolCall: &ToolCallTrace{
+				Name:      e.Name,
+				Arguments: e.Arguments,
+				Result:    e.Result,
+				Error:     e.Error,
+			},
+		})
+	case *LLMRequestEvent:
+		_ = o.tracer.RecordStep(StepTrace{
+			Node:      "LLM",
+			Timestamp: e.Time,
+			Metrics: Metrics{
+				DurationMs:   e.Duration.Milliseconds(),
+				TokensIn:     e.TokensIn,
+				TokensOut:    e.TokensOut,
+				Cost:         e.Cost,
+				ErrorMessage: e.Error,
+			},
+			Inputs: map[string]interface{}{"model": e.Model},
+		})
+	}
+	if o.Observer != nil {
+		o.Observer.Emit(event)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+
+	"gptcode/internal/config"
 )
 
 type BuildOptions struct {
@@ -18,17 +21,24 @@ type Builder struct {
 	SystemPath   string
 	Store        MemoryStore
 	SkillsLoader *SkillsLoader
+	// Skills are always loaded, as listed in the setup or project config
+	Skills []string
 }
 
 func NewDefaultBuilder(store MemoryStore) *Builder {
 	home, _ := os.UserHomeDir()
 	profile := filepath.Join(home, ".gptcode", "profile.yaml")
 	system := filepath.Join(home, ".gptcode", "system_prompt.md")
+	var skills []string
+	if setup, err := config.LoadSetup(); err == nil && setup != nil {
+		skills = setup.Skills
+	}
 	return &Builder{
 		ProfilePath:  profile,
 		SystemPath:   system,
 		Store:        store,
 		SkillsLoader: NewSkillsLoader(),
+		Skills:       skills,
 	}
 }
 
@@ -75,6 +85,14 @@ func (b *Builder) BuildSystemPrompt(opts BuildOptions) string {
 			}
 		}
 
+		// 4. Skills the setup or project config asks for
+		for _, name := range b.Skills {
+			skill := b.SkillsLoader.LoadConfigured(name)
+			if skill != "" && !slices.Contains(skillContents, skill) {
+				skillContents = append(skillContents, skill)
+			}
+		}
+
 		// Combine all skills
 		if len(skillContents) > 0 {
 			combined := ""
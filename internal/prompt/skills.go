@@ -1,6 +1,8 @@
 package prompt
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -182,6 +184,31 @@ func (sl *SkillsLoader) LoadByName(name string) string {
 	return loadEmbeddedSkill(name)
 }
 
+// LoadConfigured returns a skill listed under skills in the setup or
+// project config: a path to a markdown file, a built-in skill name, or the
+// name of a file in ~/.gptcode/skills
+func (sl *SkillsLoader) LoadConfigured(name string) string {
+	if strings.HasSuffix(name, ".md") {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return ""
+		}
+		return string(content)
+	}
+	if content := sl.LoadByName(name); content != "" {
+		return content
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	content, err := os.ReadFile(filepath.Join(home, ".gptcode", "skills", name+".md"))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
 // LoadProductSkillsForTask analyzes task description and returns relevant product skills
 func (sl *SkillsLoader) LoadProductSkillsForTask(task string) []string {
 	if task == "" {
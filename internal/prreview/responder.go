@@ -0,0 +1,445 @@
+package prreview
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gptcode/internal/agents"
+	"gptcode/internal/github"
+	"gptcode/internal/llm"
+	"gptcode/internal/maestro"
+)
+
+// declinePrefix is how the editor signals it won't make a requested change
+const declinePrefix = "DECLINE:"
+
+// ThreadClient is the subset of github.Client the responder needs
+type ThreadClient interface {
+	GetUnresolvedThreads(prNumber int) ([]github.ReviewThread, error)
+	ReplyToThread(threadID, body string) error
+	ResolveThread(threadID string) error
+	PushBranch(branchName string) error
+}
+
+// OutcomeStatus describes what happened to a thread
+type OutcomeStatus string
+
+const (
+	OutcomeApplied  OutcomeStatus = "applied"
+	OutcomeDeclined OutcomeStatus = "declined"
+	OutcomeFailed   OutcomeStatus = "failed"
+)
+
+// ThreadOutcome records the result of handling one review thread
+type ThreadOutcome struct {
+	Thread  github.ReviewThread
+	Status  OutcomeStatus
+	Summary string
+	Files   []string
+	Commit  string
+}
+
+// Batch groups the unresolved threads anchored to the same file
+type Batch struct {
+	Path    string
+	Threads []github.ReviewThread
+}
+
+// Report summarizes a full run over a pull request
+type Report struct {
+	PRNumber int
+	Outcomes []ThreadOutcome
+	Commits  []string
+}
+
+// Count returns the number of outcomes with the given status
+func (r *Report) Count(status OutcomeStatus) int {
+	n := 0
+	for _, o := range r.Outcomes {
+		if o.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Responder applies fixes for review threads, validates them, commits one
+// commit per file batch and replies in-thread.
+type Responder struct {
+	Provider    llm.Provider
+	Model       string
+	CWD         string
+	Client      ThreadClient
+	MaxAttempts int
+	// Push publishes commits, and replies and resolves threads. Without
+	// it nothing is visible on the pull request, so threads aren't told
+	// about commits that only exist locally.
+	Push    bool
+	Resolve bool
+	// Self is the login replies are posted as. Threads whose last comment
+	// is ours are waiting on the reviewer and are skipped.
+	Self string
+
+	// Verifiers returns the verifiers to run after each thread. Defaults to
+	// maestro build and test verifiers for CWD.
+	Verifiers func() []maestro.Verifier
+	// Status receives progress messages; defaults to stdout
+	Status func(string)
+}
+
+// NewResponder creates a responder that pushes and resolves threads
+func NewResponder(provider llm.Provider, model, cwd string, client ThreadClient) *Responder {
+	return &Responder{
+		Provider:    provider,
+		Model:       model,
+		CWD:         cwd,
+		Client:      client,
+		MaxAttempts: 2,
+		Push:        true,
+		Resolve:     true,
+	}
+}
+
+// GroupByFile groups threads by path, ordered by path and then line, so that
+// each batch can be committed on its own.
+func GroupByFile(threads []github.ReviewThread) []Batch {
+	byPath := make(map[string][]github.ReviewThread)
+	for _, t := range threads {
+		byPath[t.Path] = append(byPath[t.Path], t)
+	}
+
+	var paths []string
+	for p := range byPath {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var batches []Batch
+	for _, p := range paths {
+		ts := byPath[p]
+		sort.SliceStable(ts, func(i, j int) bool { return ts[i].Line < ts[j].Line })
+		batches = append(batches, Batch{Path: p, Threads: ts})
+	}
+	return batches
+}
+
+// Respond handles every unresolved thread of prNumber on the checked-out branch
+func (r *Responder) Respond(ctx context.Context, prNumber int, branch string) (*Report, error) {
+	threads, err := r.Client.GetUnresolvedThreads(prNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	threads = r.awaitingUs(threads)
+	if !r.Push && len(threads) > 0 {
+		r.status("💾 Not pushing, so threads won't be replied to or resolved")
+	}
+
+	report := &Report{PRNumber: prNumber}
+	for _, batch := range GroupByFile(threads) {
+		outcomes, sha, err := r.handleBatch(ctx, batch)
+		if err != nil {
+			return report, err
+		}
+		if sha != "" {
+			report.Commits = append(report.Commits, sha)
+			if r.Push {
+				r.status(fmt.Sprintf("🚀 Pushing %s...", branch))
+				if err := r.Client.PushBranch(branch); err != nil {
+					return report, fmt.Errorf("failed to push: %w", err)
+				}
+			}
+		}
+
+		for i := range outcomes {
+			outcomes[i].Commit = sha
+			if r.Push {
+				r.reply(&outcomes[i])
+			}
+		}
+		report.Outcomes = append(report.Outcomes, outcomes...)
+	}
+
+	return report, nil
+}
+
+// awaitingUs drops threads whose last comment is our own reply, so a
+// rerun doesn't answer its own explanations
+func (r *Responder) awaitingUs(threads []github.ReviewThread) []github.ReviewThread {
+	if r.Self == "" {
+		return threads
+	}
+	var open []github.ReviewThread
+	for _, t := range threads {
+		if n := len(t.Comments); n > 0 && strings.EqualFold(t.Comments[n-1].Author, r.Self) {
+			r.status(fmt.Sprintf("⏭️  %s:%d — waiting on the reviewer after our reply", t.Path, t.Line))
+			continue
+		}
+		open = append(open, t)
+	}
+	return open
+}
+
+func (r *Responder) handleBatch(ctx context.Context, batch Batch) ([]ThreadOutcome, string, error) {
+	label := batch.Path
+	if label == "" {
+		label = "(general)"
+	}
+	r.status(fmt.Sprintf("\n📄 %s — %d thread(s)", label, len(batch.Threads)))
+
+	var outcomes []ThreadOutcome
+	var changed []string
+	for i, thread := range batch.Threads {
+		r.status(fmt.Sprintf("  [%d/%d] line %d: %s", i+1, len(batch.Threads), thread.Line, firstLine(thread.Body())))
+		outcome := r.handleThread(ctx, thread)
+		r.status(fmt.Sprintf("     → %s", outcome.Status))
+		if outcome.Status == OutcomeApplied {
+			changed = append(changed, outcome.Files...)
+		}
+		outcomes = append(outcomes, outcome)
+	}
+
+	if len(changed) == 0 {
+		return outcomes, "", nil
+	}
+
+	sha, err := r.commit(batch, outcomes, changed)
+	if err != nil {
+		return outcomes, "", err
+	}
+	return outcomes, sha, nil
+}
+
+func (r *Responder) handleThread(ctx context.Context, thread github.ReviewThread) ThreadOutcome {
+	outcome := ThreadOutcome{Thread: thread}
+
+	base := r.snapshot()
+	editor := agents.NewEditor(r.Provider, r.CWD, r.Model)
+	history := []llm.ChatMessage{{Role: "user", Content: buildThreadPrompt(thread)}}
+
+	attempts := r.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var modified []string
+	for attempt := 0; attempt < attempts; attempt++ {
+		result, files, err := editor.Execute(ctx, history, nil)
+		modified = appendUnique(modified, files...)
+		if err != nil {
+			outcome.Status = OutcomeFailed
+			outcome.Summary = fmt.Sprintf("The editor failed: %v", err)
+			continue
+		}
+
+		if reason, declined := parseDecline(result); declined && len(modified) == 0 {
+			outcome.Status = OutcomeDeclined
+			outcome.Summary = reason
+			return outcome
+		}
+		if len(modified) == 0 {
+			outcome.Status = OutcomeDeclined
+			outcome.Summary = "No code change was needed. " + strings.TrimSpace(result)
+			return outcome
+		}
+
+		failure := r.verify(ctx)
+		if failure == "" {
+			outcome.Status = OutcomeApplied
+			outcome.Summary = strings.TrimSpace(result)
+			outcome.Files = modified
+			return outcome
+		}
+
+		outcome.Status = OutcomeFailed
+		outcome.Summary = "The change did not pass verification:\n\n```\n" + truncate(failure, 1500) + "\n```"
+		history = append(history,
+			llm.ChatMessage{Role: "assistant", Content: result},
+			llm.ChatMessage{Role: "user", Content: "Verification failed after your change. Fix it:\n\n" + truncate(failure, 4000)},
+		)
+	}
+
+	// Don't let a failed attempt leak into the batch commit
+	r.restore(base, modified)
+	return outcome
+}
+
+func (r *Responder) verify(ctx context.Context) string {
+	verifiers := []maestro.Verifier{maestro.NewBuildVerifier(r.CWD), maestro.NewTestVerifier(r.CWD)}
+	if r.Verifiers != nil {
+		verifiers = r.Verifiers()
+	}
+
+	for _, v := range verifiers {
+		result, err := v.Verify(ctx)
+		if err != nil {
+			return err.Error()
+		}
+		if !result.Success {
+			if result.Output != "" {
+				return result.Output
+			}
+			if result.Error != nil {
+				return result.Error.Error()
+			}
+			return "verification failed"
+		}
+	}
+	return ""
+}
+
+func (r *Responder) commit(batch Batch, outcomes []ThreadOutcome, files []string) (string, error) {
+	target := batch.Path
+	if target == "" {
+		target = "pull request"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Address review comments on %s\n\n", target)
+	for _, o := range outcomes {
+		if o.Status == OutcomeApplied {
+			fmt.Fprintf(&sb, "- L%d: %s\n", o.Thread.Line, firstLine(o.Thread.Comments[0].Body))
+		}
+	}
+
+	add := append([]string{"add", "--"}, files...)
+	if _, err := r.git(add...); err != nil {
+		return "", err
+	}
+	if _, err := r.git("commit", "-m", strings.TrimSpace(sb.String())); err != nil {
+		return "", err
+	}
+	sha, err := r.git("rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sha), nil
+}
+
+func (r *Responder) reply(o *ThreadOutcome) {
+	var body string
+	switch o.Status {
+	case OutcomeApplied:
+		body = fmt.Sprintf("🤖 Addressed in %s.\n\n%s", o.Commit, o.Summary)
+	case OutcomeDeclined:
+		body = fmt.Sprintf("🤖 I didn't change this:\n\n%s", o.Summary)
+	default:
+		body = fmt.Sprintf("🤖 I tried to address this but couldn't produce a passing change. %s", o.Summary)
+	}
+
+	if err := r.Client.ReplyToThread(o.Thread.ID, body); err != nil {
+		r.status(fmt.Sprintf("⚠️  Failed to reply on %s:%d: %v", o.Thread.Path, o.Thread.Line, err))
+	}
+	if o.Status == OutcomeApplied && r.Resolve {
+		if err := r.Client.ResolveThread(o.Thread.ID); err != nil {
+			r.status(fmt.Sprintf("⚠️  Failed to resolve %s:%d: %v", o.Thread.Path, o.Thread.Line, err))
+		}
+	}
+}
+
+// snapshot records the working tree state so a failed thread can be undone
+// without touching changes made by earlier threads in the same batch.
+func (r *Responder) snapshot() string {
+	out, err := r.git("stash", "create")
+	if err != nil || strings.TrimSpace(out) == "" {
+		return "HEAD"
+	}
+	return strings.TrimSpace(out)
+}
+
+func (r *Responder) restore(ref string, files []string) {
+	for _, f := range files {
+		if _, err := r.git("cat-file", "-e", ref+":"+f); err == nil {
+			r.git("checkout", ref, "--", f)
+		} else {
+			os.Remove(filepath.Join(r.CWD, f))
+		}
+	}
+}
+
+func (r *Responder) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.CWD
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("git %s failed: %w: %s", args[0], err, string(out))
+	}
+	return string(out), nil
+}
+
+func (r *Responder) status(msg string) {
+	if r.Status != nil {
+		r.Status(msg)
+		return
+	}
+	fmt.Println(msg)
+}
+
+func buildThreadPrompt(thread github.ReviewThread) string {
+	location := thread.Path
+	if thread.Line > 0 {
+		location = fmt.Sprintf("%s (line %d)", thread.Path, thread.Line)
+	}
+
+	return fmt.Sprintf(`Address this pull request review thread.
+
+File: %s
+
+Conversation:
+%s
+
+Read the file, understand the context and make the smallest change that
+resolves the reviewer's request. Keep unrelated code untouched.
+
+If the request is wrong, unclear, out of scope or already satisfied, do NOT
+modify any file. Instead reply with a single line starting with "%s"
+followed by a short, polite explanation for the reviewer.
+
+When done, reply with one or two sentences describing what you changed.`,
+		location, thread.Body(), declinePrefix)
+}
+
+func parseDecline(result string) (string, bool) {
+	trimmed := strings.TrimSpace(result)
+	idx := strings.Index(strings.ToUpper(trimmed), declinePrefix)
+	if idx < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(trimmed[idx+len(declinePrefix):]), true
+}
+
+func firstLine(s string) string {
+	line := strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+	if len(line) > 72 {
+		line = line[:69] + "..."
+	}
+	return line
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n... [truncated]"
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
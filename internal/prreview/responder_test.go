@@ -0,0 +1,198 @@
+package prreview
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/github"
+	"gptcode/internal/llm"
+	"gptcode/internal/maestro"
+)
+
+type mockProvider struct {
+	responses []llm.ChatResponse
+	callCount int
+}
+
+func (m *mockProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	if m.callCount >= len(m.responses) {
+		return &llm.ChatResponse{Text: "No more responses configured"}, nil
+	}
+	resp := m.responses[m.callCount]
+	m.callCount++
+	return &resp, nil
+}
+
+type mockClient struct {
+	threads  []github.ReviewThread
+	replies  map[string]string
+	resolved []string
+	pushed   []string
+}
+
+func (m *mockClient) GetUnresolvedThreads(prNumber int) ([]github.ReviewThread, error) {
+	return m.threads, nil
+}
+
+func (m *mockClient) ReplyToThread(threadID, body string) error {
+	m.replies[threadID] = body
+	return nil
+}
+
+func (m *mockClient) ResolveThread(threadID string) error {
+	m.resolved = append(m.resolved, threadID)
+	return nil
+}
+
+func (m *mockClient) PushBranch(branchName string) error {
+	m.pushed = append(m.pushed, branchName)
+	return nil
+}
+
+func thread(id, path string, line int, body string) github.ReviewThread {
+	return github.ReviewThread{
+		ID:       id,
+		Path:     path,
+		Line:     line,
+		Comments: []github.ReviewComment{{Author: "reviewer", Body: body, Path: path, Line: line}},
+	}
+}
+
+func TestGroupByFile(t *testing.T) {
+	batches := GroupByFile([]github.ReviewThread{
+		thread("t1", "b.go", 30, "x"),
+		thread("t2", "a.go", 10, "y"),
+		thread("t3", "b.go", 5, "z"),
+	})
+
+	if len(batches) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(batches))
+	}
+	if batches[0].Path != "a.go" || batches[1].Path != "b.go" {
+		t.Errorf("expected batches ordered by path, got %s, %s", batches[0].Path, batches[1].Path)
+	}
+	if batches[1].Threads[0].ID != "t3" || batches[1].Threads[1].ID != "t1" {
+		t.Errorf("expected threads ordered by line within a batch")
+	}
+}
+
+func TestParseDecline(t *testing.T) {
+	reason, ok := parseDecline("DECLINE: the name matches the package convention")
+	if !ok || reason != "the name matches the package convention" {
+		t.Errorf("unexpected decline parse: %q %v", reason, ok)
+	}
+	if _, ok := parseDecline("Renamed the variable."); ok {
+		t.Error("expected plain summary not to be a decline")
+	}
+}
+
+func TestRespondAppliesAndDeclines(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(dir, "main.txt"), []byte("foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+
+	provider := &mockProvider{responses: []llm.ChatResponse{
+		{ToolCalls: []llm.ChatToolCall{{ID: "call_1", Name: "write_file", Arguments: `{"path":"main.txt","content":"bar\n"}`}}},
+		{Text: "Renamed foo to bar."},
+		{Text: "DECLINE: this is already covered by the tests"},
+	}}
+	client := &mockClient{
+		threads: []github.ReviewThread{
+			thread("t1", "main.txt", 1, "rename foo to bar"),
+			thread("t2", "main.txt", 2, "add a test"),
+		},
+		replies: make(map[string]string),
+	}
+
+	responder := NewResponder(provider, "test-model", dir, client)
+	responder.Verifiers = func() []maestro.Verifier { return nil }
+	responder.Status = func(string) {}
+
+	report, err := responder.Respond(context.Background(), 1, "feature")
+	if err != nil {
+		t.Fatalf("respond failed: %v", err)
+	}
+
+	if report.Count(OutcomeApplied) != 1 || report.Count(OutcomeDeclined) != 1 {
+		t.Fatalf("unexpected outcomes: %+v", report.Outcomes)
+	}
+	if len(report.Commits) != 1 {
+		t.Errorf("expected one commit for the batch, got %d", len(report.Commits))
+	}
+	if len(client.pushed) != 1 || client.pushed[0] != "feature" {
+		t.Errorf("expected branch to be pushed once, got %v", client.pushed)
+	}
+	if len(client.resolved) != 1 || client.resolved[0] != "t1" {
+		t.Errorf("expected only the applied thread to be resolved, got %v", client.resolved)
+	}
+	if !strings.Contains(client.replies["t2"], "already covered") {
+		t.Errorf("expected decline explanation in reply, got %q", client.replies["t2"])
+	}
+
+	content, _ := os.ReadFile(filepath.Join(dir, "main.txt"))
+	if string(content) != "bar\n" {
+		t.Errorf("expected file to be edited, got %q", content)
+	}
+}
+
+func TestRespondWithoutPushLeavesThreadsAlone(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(dir, "main.txt"), []byte("foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+
+	provider := &mockProvider{responses: []llm.ChatResponse{
+		{ToolCalls: []llm.ChatToolCall{{ID: "call_1", Name: "write_file", Arguments: `{"path":"main.txt","content":"bar\n"}`}}},
+		{Text: "Renamed foo to bar."},
+	}}
+	answered := thread("t2", "main.txt", 2, "add a test")
+	answered.Comments = append(answered.Comments, github.ReviewComment{Author: "gptcode-bot", Body: "🤖 I didn't change this"})
+	client := &mockClient{
+		threads: []github.ReviewThread{thread("t1", "main.txt", 1, "rename foo to bar"), answered},
+		replies: make(map[string]string),
+	}
+
+	responder := NewResponder(provider, "test-model", dir, client)
+	responder.Verifiers = func() []maestro.Verifier { return nil }
+	responder.Status = func(string) {}
+	responder.Push = false
+	responder.Self = "gptcode-bot"
+
+	report, err := responder.Respond(context.Background(), 1, "feature")
+	if err != nil {
+		t.Fatalf("respond failed: %v", err)
+	}
+	if len(report.Outcomes) != 1 || report.Outcomes[0].Thread.ID != "t1" || report.Count(OutcomeApplied) != 1 {
+		t.Fatalf("expected only the thread awaiting us handled, got %+v", report.Outcomes)
+	}
+	if len(report.Commits) != 1 || len(client.pushed) != 0 {
+		t.Errorf("expected a local commit and no push, got %v pushed %v", report.Commits, client.pushed)
+	}
+	if len(client.replies) != 0 || len(client.resolved) != 0 {
+		t.Errorf("expected no replies or resolutions for a local commit, got %v and %v", client.replies, client.resolved)
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
@@ -46,7 +46,7 @@ func (ef *ErrorFixer) FixTestFailures(ctx context.Context, testResult *validatio
 		return result, nil
 	}
 
-	failures := ef.extractTestFailures(testResult.Output)
+	failures, output := ef.testFailures(testResult)
 	if len(failures) == 0 {
 		return result, fmt.Errorf("could not extract test failures from output")
 	}
@@ -54,7 +54,7 @@ func (ef *ErrorFixer) FixTestFailures(ctx context.Context, testResult *validatio
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		result.FixAttempts = attempt
 
-		fixPrompt := ef.buildTestFixPrompt(failures, testResult.Output)
+		fixPrompt := ef.buildTestFixPrompt(failures, output)
 
 		resp, err := ef.provider.Chat(ctx, llm.ChatRequest{
 			SystemPrompt: `You are a code fixing expert. Analyze test failures and provide exact fixes.
@@ -90,7 +90,7 @@ Your response must be in this format:
 			return result, nil
 		}
 
-		failures = ef.extractTestFailures(newResult.Output)
+		failures, output = ef.testFailures(newResult)
 	}
 
 	result.FinalStatus = fmt.Sprintf("Failed after %d attempts", maxAttempts)
@@ -233,6 +233,25 @@ Format your response as:
 	return result, nil
 }
 
+// testFailures lists the failing tests and the output to show the model,
+// limited to the failing tests when the runner produced a structured report
+func (ef *ErrorFixer) testFailures(result *validation.TestResult) ([]string, string) {
+	failed := result.Report.Failures()
+	if len(failed) == 0 {
+		return ef.extractTestFailures(result.Output), result.Output
+	}
+
+	failures := make([]string, 0, len(failed))
+	for _, tc := range failed {
+		if loc := tc.Location(); loc != "" {
+			failures = append(failures, fmt.Sprintf("Test failed: %s (%s)", tc.FullName(), loc))
+		} else {
+			failures = append(failures, "Test failed: "+tc.FullName())
+		}
+	}
+	return failures, result.Report.FailureText()
+}
+
 func (ef *ErrorFixer) extractTestFailures(output string) []string {
 	var failures []string
 
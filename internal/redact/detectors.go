@@ -0,0 +1,149 @@
+package redact
+
+import (
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Detector finds one kind of secret
+type Detector struct {
+	// Kind names the secret in its placeholder, e.g. AWS_ACCESS_KEY
+	Kind    string
+	Pattern *regexp.Regexp
+	// Group is the submatch holding the secret; 0 masks the whole match
+	Group int
+	// Skip rejects a match, given the secret and the text right after it
+	Skip func(secret, after string) bool
+}
+
+// Detectors are the built-in patterns
+var Detectors = []Detector{
+	{Kind: "PRIVATE_KEY", Pattern: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{Kind: "AWS_ACCESS_KEY", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{Kind: "AWS_SECRET_KEY", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`), Group: 1},
+	{Kind: "GCP_API_KEY", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{Kind: "GCP_KEY_ID", Pattern: regexp.MustCompile(`"private_key_id"\s*:\s*"([0-9a-f]{40})"`), Group: 1},
+	{Kind: "JWT", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{Kind: "GITHUB_TOKEN", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{Kind: "API_KEY", Pattern: regexp.MustCompile(`\b(?:sk-(?:ant-|proj-|or-v1-)?[A-Za-z0-9_\-]{20,}|sk_live_[A-Za-z0-9]{20,}|gsk_[A-Za-z0-9]{20,}|xox[abprs]-[A-Za-z0-9-]{10,})`)},
+	{Kind: "CONNECTION_PASSWORD", Pattern: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.\-]*://[^\s:/@'"]+:([^\s@/'"]+)@`), Group: 1},
+	{
+		Kind:    "SECRET",
+		Pattern: regexp.MustCompile(`(?i)\b[A-Za-z0-9_]*(?:SECRET|TOKEN|PASSWORD|PASSWD|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIALS?)[A-Za-z0-9_]*["']?\s*[:=]\s*["']?([^\s"'` + "`" + `,;(){}<>$]{8,})`),
+		Group:   1,
+		Skip:    looksLikeCode,
+	},
+}
+
+var (
+	identifierChain = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)+$`)
+	headerName      = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*(?:-[A-Z][A-Za-z0-9]*)+$`)
+	identifierWord  = regexp.MustCompile(`[A-Z]+[a-z]*|[a-z]+|[0-9]+|_+`)
+)
+
+// looksLikeCode rejects assignments from expressions, such as
+// password := os.Getenv or token: config.Token, from other variables, such
+// as token = nextToken, and of header names, such as "X-Session-Token"
+func looksLikeCode(secret, after string) bool {
+	if strings.HasPrefix(after, "(") || strings.HasPrefix(after, "[") {
+		return true
+	}
+	if identifierChain.MatchString(secret) {
+		return true
+	}
+	if strings.HasPrefix(after, `"`) || strings.HasPrefix(after, "'") || strings.HasPrefix(after, "`") {
+		return headerName.MatchString(secret)
+	}
+	return isIdentifier(secret)
+}
+
+// isIdentifier reports whether s reads as a camelCase, PascalCase or
+// snake_case name made of words, like defaultMaxTokens or
+// TestParseRFC3339Timestamps. Digits may only follow an acronym or end the
+// name, so hunter2hunter2 is not one.
+func isIdentifier(s string) bool {
+	words := identifierWord.FindAllString(s, -1)
+	if strings.Join(words, "") != s {
+		return false
+	}
+	letters := 0
+	for i, w := range words {
+		switch {
+		case w[0] == '_':
+		case w[0] >= '0' && w[0] <= '9':
+			if i == 0 {
+				return false
+			}
+			prev := words[i-1]
+			if i < len(words)-1 && strings.ToUpper(prev) != prev {
+				return false
+			}
+		case strings.ToUpper(w) == w:
+			letters++
+		default:
+			if len(w) < 3 {
+				return false
+			}
+			letters++
+		}
+	}
+	return letters >= 2
+}
+
+// envLine matches an assignment in a dotenv file
+var envLine = regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?[A-Za-z_][A-Za-z0-9_]*[ \t]*=[ \t]*(?:"([^"\n]*)"|'([^'\n]*)'|([^\s#\n]+))`)
+
+// isEnvFile reports whether a path is a dotenv file such as .env,
+// .env.production or prod.env
+func isEnvFile(path string) bool {
+	base := filepath.Base(path)
+	if base == ".env.example" || base == ".env.sample" || base == ".env.template" {
+		return false
+	}
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+// entropyToken matches candidates for entropy detection
+var entropyToken = regexp.MustCompile(`[A-Za-z0-9_\-]{24,}={0,2}`)
+
+// hashPrefixes mark checksums that are high-entropy but not secret
+var hashPrefixes = []string{"h1:", "sha1-", "sha256-", "sha384-", "sha512-", "sha256:"}
+
+// highEntropy reports whether a token looks randomly generated: mixed
+// case, digits, and enough bits per character, and not a name made of
+// words such as ErrInvalidAccessTokenFormat2
+func highEntropy(token string, min float64) bool {
+	if isIdentifier(token) {
+		return false
+	}
+	var upper, lower, digit bool
+	for _, c := range token {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit && entropy(token) >= min
+}
+
+// entropy is the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,268 @@
+// Package redact masks secrets before content leaves the machine. Each
+// secret is swapped for a stable placeholder and the mapping stays in
+// memory, so placeholders the model writes back can be restored locally.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gptcode/internal/config"
+)
+
+// DefaultMinEntropy is the bits per character above which a long mixed
+// token is treated as a secret
+const DefaultMinEntropy = 4.0
+
+// placeholder matches what Redact puts in place of a secret
+var placeholder = regexp.MustCompile(`__SECRET_[A-Z0-9_]+?_[0-9]+__`)
+
+// Redactor masks secrets and remembers them for Restore
+type Redactor struct {
+	Detectors []Detector
+	// MinEntropy turns on entropy detection when positive
+	MinEntropy float64
+	// Allow lists values that are never masked
+	Allow map[string]bool
+
+	mu            sync.Mutex
+	bySecret      map[string]string
+	byPlaceholder map[string]string
+	counts        map[string]int
+}
+
+// New returns a redactor with the built-in detectors
+func New() *Redactor {
+	return &Redactor{
+		Detectors:  append([]Detector(nil), Detectors...),
+		MinEntropy: DefaultMinEntropy,
+	}
+}
+
+// NewFromConfig applies the redaction section of setup.yaml
+func NewFromConfig(cfg config.RedactionConfig) (*Redactor, error) {
+	r := New()
+	switch {
+	case cfg.MinEntropy < 0:
+		r.MinEntropy = 0
+	case cfg.MinEntropy > 0:
+		r.MinEntropy = cfg.MinEntropy
+	}
+	for i, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		d := Detector{Kind: fmt.Sprintf("CUSTOM_%d", i+1), Pattern: re}
+		if re.NumSubexp() > 0 {
+			d.Group = 1
+		}
+		r.Detectors = append(r.Detectors, d)
+	}
+	if len(cfg.Allow) > 0 {
+		r.Allow = map[string]bool{}
+		for _, v := range cfg.Allow {
+			r.Allow[v] = true
+		}
+	}
+	return r, nil
+}
+
+var (
+	defaultRedactor     *Redactor
+	defaultRedactorOnce sync.Once
+)
+
+// Default returns the redactor for the user's setup, or nil when redaction
+// is disabled. A nil redactor passes content through.
+func Default() *Redactor {
+	defaultRedactorOnce.Do(func() {
+		setup, _ := config.LoadSetup()
+		if setup.Redaction.Disabled {
+			return
+		}
+		r, err := NewFromConfig(setup.Redaction)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v; using the built-in detectors only\n", err)
+			r = New()
+		}
+		defaultRedactor = r
+	})
+	return defaultRedactor
+}
+
+// span is a secret found in a text
+type span struct {
+	start, end int
+	kind       string
+}
+
+// Redact masks every secret the detectors find in text
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	return r.apply(text, r.find(text))
+}
+
+// RedactFile masks text read from path. Every value in a dotenv file is
+// treated as a secret on top of what the detectors find.
+func (r *Redactor) RedactFile(path, text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	spans := r.find(text)
+	if isEnvFile(path) {
+		for _, m := range envLine.FindAllStringSubmatchIndex(text, -1) {
+			for g := 1; g <= 3; g++ {
+				start, end := m[2*g], m[2*g+1]
+				if start < 0 || end-start == 0 || strings.HasPrefix(text[start:end], "${") {
+					continue
+				}
+				spans = append(spans, span{start, end, "ENV_VALUE"})
+			}
+		}
+	}
+	return r.apply(text, spans)
+}
+
+// find returns the secrets in text, in any order and possibly overlapping
+func (r *Redactor) find(text string) []span {
+	var spans []span
+	for _, d := range r.Detectors {
+		for _, m := range d.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if d.Group > 0 && 2*d.Group+1 < len(m) {
+				start, end = m[2*d.Group], m[2*d.Group+1]
+			}
+			if start < 0 || end-start < 8 {
+				continue
+			}
+			if d.Skip != nil && d.Skip(text[start:end], text[end:]) {
+				continue
+			}
+			spans = append(spans, span{start, end, d.Kind})
+		}
+	}
+	if r.MinEntropy > 0 {
+		for _, m := range entropyToken.FindAllStringIndex(text, -1) {
+			if isHash(text[:m[0]]) || !highEntropy(text[m[0]:m[1]], r.MinEntropy) {
+				continue
+			}
+			spans = append(spans, span{m[0], m[1], "HIGH_ENTROPY"})
+		}
+	}
+	return spans
+}
+
+func isHash(before string) bool {
+	for _, p := range hashPrefixes {
+		if strings.HasSuffix(before, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply swaps spans for placeholders, keeping the first and longest of
+// overlapping spans
+func (r *Redactor) apply(text string, spans []span) string {
+	if len(spans) == 0 {
+		return text
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			continue
+		}
+		secret := text[s.start:s.end]
+		if r.Allow[secret] || placeholder.MatchString(secret) {
+			continue
+		}
+		b.WriteString(text[last:s.start])
+		b.WriteString(r.placeholderFor(secret, s.kind))
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// placeholderFor returns the placeholder a secret always maps to
+func (r *Redactor) placeholderFor(secret, kind string) string {
+	if p, ok := r.bySecret[secret]; ok {
+		return p
+	}
+	if r.bySecret == nil {
+		r.bySecret = map[string]string{}
+		r.byPlaceholder = map[string]string{}
+		r.counts = map[string]int{}
+	}
+	r.counts[kind]++
+	p := fmt.Sprintf("__SECRET_%s_%d__", kind, r.counts[kind])
+	r.bySecret[secret] = p
+	r.byPlaceholder[p] = secret
+	return p
+}
+
+// Restore puts back the secrets behind placeholders in text. Placeholders
+// this redactor didn't hand out are left alone.
+func (r *Redactor) Restore(text string) string {
+	return r.restore(text, func(secret string) string { return secret })
+}
+
+// RestoreJSON restores placeholders inside JSON, escaping the secrets so
+// the document stays valid
+func (r *Redactor) RestoreJSON(text string) string {
+	return r.restore(text, func(secret string) string {
+		quoted, err := json.Marshal(secret)
+		if err != nil {
+			return secret
+		}
+		return string(quoted[1 : len(quoted)-1])
+	})
+}
+
+func (r *Redactor) restore(text string, encode func(string) string) string {
+	if r == nil || !strings.Contains(text, "__SECRET_") {
+		return text
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return placeholder.ReplaceAllStringFunc(text, func(p string) string {
+		if secret, ok := r.byPlaceholder[p]; ok {
+			return encode(secret)
+		}
+		return p
+	})
+}
+
+// HasPlaceholder reports whether text holds a placeholder Redact handed out
+func HasPlaceholder(text string) bool {
+	return strings.Contains(text, "__SECRET_") && placeholder.MatchString(text)
+}
+
+// Len is how many distinct secrets have been masked
+func (r *Redactor) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.bySecret)
+}
@@ -0,0 +1,384 @@
+// Package sarif writes findings as SARIF 2.1.0 logs, the format GitHub code
+// scanning and IDE SARIF viewers load.
+package sarif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Version is the SARIF version written
+	Version = "2.1.0"
+	// Schema is the JSON schema of SARIF 2.1.0
+	Schema = "https://json.schemastore.org/sarif-2.1.0.json"
+	// SrcRoot is the base id that result paths are relative to
+	SrcRoot = "%SRCROOT%"
+	// FingerprintKey names gptcode's fingerprint among a result's
+	// partialFingerprints
+	FingerprintKey = "gptcode/v1"
+)
+
+// Result levels
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+)
+
+// Baseline states of a result compared with an earlier run
+const (
+	BaselineNew       = "new"
+	BaselineUnchanged = "unchanged"
+	BaselineAbsent    = "absent"
+)
+
+// Log is a SARIF log
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the output of one tool run
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+// Tool describes the tool that produced a run
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the tool's main component and the rules it checks
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule is a kind of finding, such as one advisory
+type Rule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name,omitempty"`
+	ShortDescription     *Message               `json:"shortDescription,omitempty"`
+	FullDescription      *Message               `json:"fullDescription,omitempty"`
+	Help                 *Message               `json:"help,omitempty"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	DefaultConfiguration *Configuration         `json:"defaultConfiguration,omitempty"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Configuration is a rule's default settings
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+// Message is text shown to people
+type Message struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+// Result is one finding
+type Result struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             Message           `json:"message"`
+	Locations           []Location        `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	Fixes               []Fix             `json:"fixes,omitempty"`
+	BaselineState       string            `json:"baselineState,omitempty"`
+}
+
+// Location is where a result was found
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a region of a file
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is a file, relative to URIBaseID
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// Region is a range of lines, and optionally columns, counted from 1.
+// Without columns it spans the whole lines, excluding the last newline.
+type Region struct {
+	StartLine   int `json:"startLine"`
+	EndLine     int `json:"endLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// Fix is a proposed change that resolves a result
+type Fix struct {
+	Description     *Message         `json:"description,omitempty"`
+	ArtifactChanges []ArtifactChange `json:"artifactChanges"`
+}
+
+// ArtifactChange is the part of a fix that edits one file
+type ArtifactChange struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Replacements     []Replacement    `json:"replacements"`
+}
+
+// Replacement replaces a region with new content
+type Replacement struct {
+	DeletedRegion   Region           `json:"deletedRegion"`
+	InsertedContent *ArtifactContent `json:"insertedContent,omitempty"`
+}
+
+// ArtifactContent is text inserted by a fix
+type ArtifactContent struct {
+	Text string `json:"text"`
+}
+
+// NewLog creates a log with one run of the named tool
+func NewLog(name, version, informationURI string) *Log {
+	return &Log{
+		Schema:  Schema,
+		Version: Version,
+		Runs: []Run{{
+			Tool:    Tool{Driver: Driver{Name: name, Version: version, InformationURI: informationURI, Rules: []Rule{}}},
+			Results: []Result{},
+		}},
+	}
+}
+
+// AddRule adds a rule to the run unless one with its ID is there
+func (l *Log) AddRule(rule Rule) {
+	run := &l.Runs[0]
+	for _, r := range run.Tool.Driver.Rules {
+		if r.ID == rule.ID {
+			return
+		}
+	}
+	run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+}
+
+// AddResult adds a result to the run. A result whose fingerprint is already
+// in the run is a duplicate and is dropped; AddResult reports whether it
+// was added.
+func (l *Log) AddResult(result Result) bool {
+	run := &l.Runs[0]
+	if fp := result.PartialFingerprints[FingerprintKey]; fp != "" {
+		for _, r := range run.Results {
+			if r.PartialFingerprints[FingerprintKey] == fp {
+				return false
+			}
+		}
+	}
+	run.Results = append(run.Results, result)
+	return true
+}
+
+// Results returns the results of the run
+func (l *Log) Results() []Result {
+	return l.Runs[0].Results
+}
+
+// ApplyBaseline compares the run with an earlier log of the same tool by
+// fingerprint. Results found before are marked unchanged and the rest new.
+// It returns how many baseline results are gone. They are only added to the
+// run, marked absent, with keepAbsent: code scanning closes alerts missing
+// from an upload by itself and would reopen absent results as alerts.
+func (l *Log) ApplyBaseline(baseline *Log, keepAbsent bool) int {
+	run := &l.Runs[0]
+	before := make(map[string]bool)
+	for _, prev := range baseline.Runs {
+		for _, r := range prev.Results {
+			if r.BaselineState != BaselineAbsent {
+				before[r.PartialFingerprints[FingerprintKey]] = true
+			}
+		}
+	}
+
+	now := make(map[string]bool)
+	for i := range run.Results {
+		fp := run.Results[i].PartialFingerprints[FingerprintKey]
+		now[fp] = true
+		if fp != "" && before[fp] {
+			run.Results[i].BaselineState = BaselineUnchanged
+		} else {
+			run.Results[i].BaselineState = BaselineNew
+		}
+	}
+
+	gone := 0
+	for _, prev := range baseline.Runs {
+		for _, r := range prev.Results {
+			fp := r.PartialFingerprints[FingerprintKey]
+			if fp == "" || now[fp] || r.BaselineState == BaselineAbsent {
+				continue
+			}
+			now[fp] = true
+			gone++
+			if !keepAbsent {
+				continue
+			}
+			for _, rule := range prev.Tool.Driver.Rules {
+				if rule.ID == r.RuleID {
+					l.AddRule(rule)
+				}
+			}
+			r.BaselineState = BaselineAbsent
+			r.Fixes = nil
+			run.Results = append(run.Results, r)
+		}
+	}
+	return gone
+}
+
+// Count returns how many results have the given baseline state
+func (l *Log) Count(state string) int {
+	n := 0
+	for _, r := range l.Runs[0].Results {
+		if r.BaselineState == state {
+			n++
+		}
+	}
+	return n
+}
+
+// Write writes the log as indented JSON
+func (l *Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(l); err != nil {
+		return fmt.Errorf("failed to encode SARIF: %w", err)
+	}
+	return nil
+}
+
+// WriteFile writes the log to path
+func (l *Log) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := l.Write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadFile reads a log written earlier, such as a baseline
+func ReadFile(path string) (*Log, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var l Log
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("failed to parse SARIF %s: %w", path, err)
+	}
+	return &l, nil
+}
+
+var (
+	lineReference = regexp.MustCompile(`:\d+(-\d+)?\b|\b[Ll]ines? \d+(-\d+)?\b`)
+	whitespace    = regexp.MustCompile(`\s+`)
+)
+
+// Fingerprint hashes what identifies a finding. Case, spacing and line
+// numbers in the parts are ignored, so a finding keeps its fingerprint
+// across runs while the code around it moves.
+func Fingerprint(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		p = lineReference.ReplaceAllString(p, "")
+		p = whitespace.ReplaceAllString(strings.ToLower(strings.TrimSpace(p)), " ")
+		_, _ = io.WriteString(h, p)
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// RegionHash hashes the source text of a region with spacing collapsed,
+// like the primaryLocationLineHash of code scanning, so a finding keeps its
+// fingerprint while the code around it moves and however its message is
+// worded. path is absolute or relative to root. It returns "" when the
+// lines can't be read.
+func RegionHash(root, path string, region *Region) string {
+	if region == nil || region.StartLine <= 0 {
+		return ""
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	end := max(region.EndLine, region.StartLine)
+	if end > len(lines) {
+		return ""
+	}
+	text := strings.Join(lines[region.StartLine-1:end], "\n")
+	text = whitespace.ReplaceAllString(strings.TrimSpace(text), " ")
+	if text == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])[:32]
+}
+
+// RootLocation is the location of the project root, for findings that
+// belong to no file
+func RootLocation() Location {
+	return Location{PhysicalLocation: PhysicalLocation{
+		ArtifactLocation: ArtifactLocation{URI: ".", URIBaseID: SrcRoot},
+	}}
+}
+
+// NewLocation returns the location of a file relative to root, with the
+// region when it is known. Files outside root keep their path.
+func NewLocation(root, path string, region *Region) Location {
+	return Location{PhysicalLocation: PhysicalLocation{
+		ArtifactLocation: ArtifactRelative(root, path),
+		Region:           region,
+	}}
+}
+
+// ArtifactRelative returns path as a location relative to root, or as it is
+// when it lies outside root
+func ArtifactRelative(root, path string) ArtifactLocation {
+	if filepath.IsAbs(path) && root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return ArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: SrcRoot}
+		}
+		return ArtifactLocation{URI: "file://" + filepath.ToSlash(path)}
+	}
+	return ArtifactLocation{URI: strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./"), URIBaseID: SrcRoot}
+}
+
+// Lines returns a region spanning lines start to end, or nil when start is
+// unknown
+func Lines(start, end int) *Region {
+	if start <= 0 {
+		return nil
+	}
+	r := &Region{StartLine: start}
+	if end > start {
+		r.EndLine = end
+	}
+	return r
+}
@@ -0,0 +1,115 @@
+package sarif
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func result(rule, fingerprint string) Result {
+	return Result{
+		RuleID:              rule,
+		Level:               LevelWarning,
+		Message:             Message{Text: rule},
+		PartialFingerprints: map[string]string{FingerprintKey: fingerprint},
+	}
+}
+
+func TestFingerprintIgnoresLinesAndSpacing(t *testing.T) {
+	a := Fingerprint("review/critical", "store/users.go", "SQL built with concatenation in store/users.go:42")
+	b := Fingerprint("review/critical", "store/users.go", "SQL  built with concatenation in\nstore/users.go:57-60 ")
+	if a != b {
+		t.Errorf("expected the same fingerprint after the code moved, got %s and %s", a, b)
+	}
+	if a == Fingerprint("review/critical", "store/orders.go", "SQL built with concatenation in store/users.go:42") {
+		t.Error("expected a different file to change the fingerprint")
+	}
+}
+
+func TestAddResultDropsDuplicates(t *testing.T) {
+	log := NewLog("gptcode", "dev", "")
+	log.AddRule(Rule{ID: "r1"})
+	log.AddRule(Rule{ID: "r1"})
+	if !log.AddResult(result("r1", "a")) || log.AddResult(result("r1", "a")) || !log.AddResult(result("r1", "b")) {
+		t.Error("expected only the repeated fingerprint dropped")
+	}
+	if len(log.Results()) != 2 || len(log.Runs[0].Tool.Driver.Rules) != 1 {
+		t.Errorf("unexpected run: %+v", log.Runs[0])
+	}
+}
+
+func TestApplyBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prev.sarif")
+	prev := NewLog("gptcode", "dev", "")
+	prev.AddRule(Rule{ID: "fixed-rule"})
+	prev.AddResult(result("r1", "kept"))
+	prev.AddResult(result("fixed-rule", "fixed"))
+	if err := prev.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// By default fixed results are counted but left out of the log
+	upload := NewLog("gptcode", "dev", "")
+	upload.AddResult(result("r1", "kept"))
+	if gone := upload.ApplyBaseline(baseline, false); gone != 1 || len(upload.Results()) != 1 || upload.Count(BaselineAbsent) != 0 {
+		t.Errorf("expected 1 fixed result left out, got %d gone and %+v", gone, upload.Results())
+	}
+
+	log := NewLog("gptcode", "dev", "")
+	log.AddResult(result("r1", "kept"))
+	log.AddResult(result("r1", "added"))
+	log.ApplyBaseline(baseline, true)
+
+	states := make(map[string]string)
+	for _, r := range log.Results() {
+		states[r.PartialFingerprints[FingerprintKey]] = r.BaselineState
+	}
+	want := map[string]string{"kept": BaselineUnchanged, "added": BaselineNew, "fixed": BaselineAbsent}
+	for fp, state := range want {
+		if states[fp] != state {
+			t.Errorf("%s: got %q, want %q", fp, states[fp], state)
+		}
+	}
+	if len(log.Runs[0].Tool.Driver.Rules) != 1 || log.Runs[0].Tool.Driver.Rules[0].ID != "fixed-rule" {
+		t.Errorf("expected the rule of the absent result carried over, got %+v", log.Runs[0].Tool.Driver.Rules)
+	}
+
+	// Comparing with a log that already has absent results doesn't bring
+	// them back a second time
+	next := NewLog("gptcode", "dev", "")
+	next.ApplyBaseline(log, true)
+	if next.Count(BaselineAbsent) != 2 {
+		t.Errorf("expected 2 absent results, got %d", next.Count(BaselineAbsent))
+	}
+}
+
+func TestLogIsValidSARIF(t *testing.T) {
+	log := NewLog("gptcode", "dev", "https://gptcode.cloud")
+	r := result("r1", "a")
+	r.Locations = []Location{NewLocation("/repo", "/repo/internal/a.go", Lines(3, 5))}
+	log.AddResult(r)
+
+	data, err := json.Marshal(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	_ = json.Unmarshal(data, &doc)
+	if doc["version"] != "2.1.0" || doc["$schema"] != Schema {
+		t.Errorf("unexpected header: %s", data)
+	}
+	loc := log.Results()[0].Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "internal/a.go" || loc.ArtifactLocation.URIBaseID != SrcRoot {
+		t.Errorf("expected a path relative to the root, got %+v", loc.ArtifactLocation)
+	}
+	if loc.Region.StartLine != 3 || loc.Region.EndLine != 5 {
+		t.Errorf("unexpected region %+v", loc.Region)
+	}
+	if Lines(0, 0) != nil || Lines(4, 4).EndLine != 0 {
+		t.Error("expected no region without a line and no end line for one line")
+	}
+}
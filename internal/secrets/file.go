@@ -0,0 +1,265 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// PassphraseEnv unlocks the encrypted file without asking
+const PassphraseEnv = "GPTCODE_SECRETS_PASSPHRASE"
+
+// scrypt parameters recommended for interactive logins
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// FileStore keeps secrets in a file sealed with NaCl secretbox under a key
+// derived from a passphrase with scrypt
+type FileStore struct {
+	Path string
+	// Passphrase returns the passphrase; confirm is set when the file is
+	// about to be created. Nil reads GPTCODE_SECRETS_PASSPHRASE, then asks
+	// on the terminal.
+	Passphrase func(confirm bool) (string, error)
+
+	mu         sync.Mutex
+	passphrase string
+}
+
+// sealedFile is the on-disk format
+type sealedFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Box     []byte `json:"box"`
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+func (f *FileStore) Name() string {
+	return f.Path + " (encrypted)"
+}
+
+// Exists reports whether the file has been created
+func (f *FileStore) Exists() bool {
+	_, err := os.Stat(f.Path)
+	return err == nil
+}
+
+func (f *FileStore) Get(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.open()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (f *FileStore) Set(name, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.open()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return f.seal(secrets)
+}
+
+func (f *FileStore) Delete(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.open()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return ErrNotFound
+	}
+	delete(secrets, name)
+	return f.seal(secrets)
+}
+
+// Names lists the secrets in the file
+func (f *FileStore) Names() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// open decrypts the file; a missing file holds no secrets
+func (f *FileStore) open() (map[string]string, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+
+	var sealed sealedFile
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", f.Path, err)
+	}
+	if sealed.Version != 1 || sealed.KDF != "scrypt" || len(sealed.Nonce) != 24 {
+		return nil, fmt.Errorf("unsupported format in %s", f.Path)
+	}
+
+	passphrase, err := f.getPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, sealed.Salt)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], sealed.Nonce)
+	plain, ok := secretbox.Open(nil, sealed.Box, &nonce, key)
+	if !ok {
+		// Don't keep a wrong passphrase around for the next call
+		f.passphrase = ""
+		return nil, fmt.Errorf("wrong passphrase for %s", f.Path)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", f.Path, err)
+	}
+	return secrets, nil
+}
+
+// seal encrypts secrets under a fresh salt and nonce and replaces the file
+func (f *FileStore) seal(secrets map[string]string) error {
+	passphrase, err := f.getPassphrase(!f.Exists())
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	sealed := sealedFile{Version: 1, KDF: "scrypt", Salt: make([]byte, 16), Nonce: make([]byte, 24)}
+	if _, err := rand.Read(sealed.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	key, err := deriveKey(passphrase, sealed.Salt)
+	if err != nil {
+		return err
+	}
+	var nonce [24]byte
+	copy(nonce[:], sealed.Nonce)
+	sealed.Box = secretbox.Seal(nil, plain, &nonce, key)
+
+	data, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(f.Path), err)
+	}
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, f.Path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+func (f *FileStore) getPassphrase(confirm bool) (string, error) {
+	if f.passphrase != "" {
+		return f.passphrase, nil
+	}
+	read := f.Passphrase
+	if read == nil {
+		read = f.readPassphrase
+	}
+	passphrase, err := read(confirm)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("empty passphrase")
+	}
+	f.passphrase = passphrase
+	return passphrase, nil
+}
+
+// readPassphrase takes the passphrase from the environment or the terminal
+func (f *FileStore) readPassphrase(confirm bool) (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("%s is locked: set %s to unlock it", f.Path, PassphraseEnv)
+	}
+
+	fmt.Fprintf(os.Stderr, "Passphrase for %s: ", f.Path)
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		second, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if string(first) != string(second) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return string(first), nil
+}
+
+func deriveKey(passphrase string, salt []byte) (*[32]byte, error) {
+	k, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	var key [32]byte
+	copy(key[:], k)
+	return &key, nil
+}
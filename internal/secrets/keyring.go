@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Keyring stores secrets with the freedesktop Secret Service over D-Bus
+// (GNOME Keyring, KWallet), through libsecret's secret-tool
+type Keyring struct {
+	Service string
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{Service: "gptcode"}
+}
+
+func (k *Keyring) Name() string {
+	return "the system keyring"
+}
+
+// Available reports whether a Secret Service can be reached
+func (k *Keyring) Available() bool {
+	if runtime.GOOS != "linux" || os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return false
+	}
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+func (k *Keyring) Get(name string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", k.Service, "key", name).Output()
+	if err != nil {
+		// secret-tool exits 1 without output when nothing matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(exitErr.Stderr) == 0 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("failed to read %s from the keyring: %w", name, err)
+	}
+	value := strings.TrimRight(string(out), "\n")
+	if value == "" {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (k *Keyring) Set(name, value string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", "gptcode: "+name, "service", k.Service, "key", name)
+	cmd.Stdin = strings.NewReader(value)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to save %s to the keyring: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (k *Keyring) Delete(name string) error {
+	if out, err := exec.Command("secret-tool", "clear", "service", k.Service, "key", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete %s from the keyring: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
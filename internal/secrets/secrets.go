@@ -0,0 +1,124 @@
+// Package secrets keeps API keys out of plaintext files: in the system
+// keyring when there is one, otherwise in a passphrase-encrypted file
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when a store has no secret under a name
+var ErrNotFound = errors.New("secret not found")
+
+// Store saves named secrets
+type Store interface {
+	// Name describes where secrets end up, for messages to the user
+	Name() string
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+}
+
+// StoreEnv forces a store: "keyring" or "file"
+const StoreEnv = "GPTCODE_SECRET_STORE"
+
+var (
+	defaultFile     *FileStore
+	defaultFileOnce sync.Once
+)
+
+// DefaultFile returns the encrypted file store at ~/.gptcode/secrets.enc.
+// It is shared so the passphrase is only asked for once per run.
+func DefaultFile() *FileStore {
+	defaultFileOnce.Do(func() {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "."
+		}
+		defaultFile = NewFileStore(filepath.Join(home, ".gptcode", "secrets.enc"))
+	})
+	return defaultFile
+}
+
+// Default returns the store new secrets are saved to: the keyring when it
+// is reachable, otherwise the encrypted file
+func Default() Store {
+	switch os.Getenv(StoreEnv) {
+	case "file":
+		return DefaultFile()
+	case "keyring":
+		return NewKeyring()
+	}
+	if k := NewKeyring(); k.Available() {
+		return k
+	}
+	return DefaultFile()
+}
+
+// Lookup finds a secret in the keyring, then in the encrypted file
+func Lookup(name string) (string, error) {
+	var errs []error
+	if k := NewKeyring(); os.Getenv(StoreEnv) != "file" && k.Available() {
+		value, err := k.Get(name)
+		if err == nil {
+			return value, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	if f := DefaultFile(); os.Getenv(StoreEnv) != "keyring" && f.Exists() {
+		value, err := f.Get(name)
+		if err == nil {
+			return value, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	return "", ErrNotFound
+}
+
+// Resolve reads a key reference from setup.yaml:
+//
+//	env:NAME      the NAME environment variable
+//	cmd:COMMAND   the output of a shell command, e.g. "cmd: pass show openrouter"
+//	anything else the value itself
+func Resolve(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimSpace(strings.TrimPrefix(ref, "env:"))
+		value := os.Getenv(name)
+		if value == "" {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, "cmd:"):
+		command := strings.TrimSpace(strings.TrimPrefix(ref, "cmd:"))
+		var stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to run %q: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+		}
+		// Tools such as pass print the secret on the first line
+		value, _, _ := strings.Cut(string(out), "\n")
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("%q printed nothing", command)
+		}
+		return value, nil
+	default:
+		return ref, nil
+	}
+}
@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/term"
+)
+
+func testFileStore(t *testing.T, passphrase string) *FileStore {
+	t.Helper()
+	f := NewFileStore(filepath.Join(t.TempDir(), "secrets.enc"))
+	f.Passphrase = func(bool) (string, error) { return passphrase, nil }
+	return f
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	f := testFileStore(t, "correct horse")
+
+	if _, err := f.Get("openrouter"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a missing file to hold nothing, got %v", err)
+	}
+	if err := f.Set("openrouter", "sk-or-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("groq", "gsk-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Error("expected the file not to hold plaintext")
+	}
+	if info, _ := os.Stat(f.Path); info.Mode().Perm() != 0o600 {
+		t.Errorf("expected 0600 permissions, got %v", info.Mode().Perm())
+	}
+
+	reopened := NewFileStore(f.Path)
+	reopened.Passphrase = func(bool) (string, error) { return "correct horse", nil }
+	if v, err := reopened.Get("openrouter"); err != nil || v != "sk-or-secret" {
+		t.Errorf("expected the key back, got %q, %v", v, err)
+	}
+	if err := reopened.Delete("groq"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := reopened.Names(); len(names) != 1 || names[0] != "openrouter" {
+		t.Errorf("expected only openrouter left, got %v", names)
+	}
+
+	wrong := NewFileStore(f.Path)
+	wrong.Passphrase = func(bool) (string, error) { return "battery staple", nil }
+	if _, err := wrong.Get("openrouter"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("expected a wrong passphrase error, got %v", err)
+	}
+}
+
+func TestFileStoreNeedsPassphrase(t *testing.T) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		t.Skip("would ask for the passphrase on the terminal")
+	}
+	t.Setenv(PassphraseEnv, "")
+	f := NewFileStore(filepath.Join(t.TempDir(), "secrets.enc"))
+	if err := f.Set("openrouter", "sk"); err == nil || !strings.Contains(err.Error(), PassphraseEnv) {
+		t.Errorf("expected to be told how to unlock the file, got %v", err)
+	}
+
+	t.Setenv(PassphraseEnv, "from env")
+	if err := f.Set("openrouter", "sk"); err != nil {
+		t.Fatalf("expected the passphrase from the environment, got %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv("GPTCODE_TEST_KEY", "from-env")
+
+	tests := map[string]string{
+		"env:GPTCODE_TEST_KEY":                  "from-env",
+		"env: GPTCODE_TEST_KEY":                 "from-env",
+		"cmd: printf 'from-cmd\\nlogin: me\\n'": "from-cmd",
+		"sk-literal":                            "sk-literal",
+	}
+	for ref, want := range tests {
+		if got, err := Resolve(ref); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+
+	for _, ref := range []string{"env:GPTCODE_UNSET_KEY", "cmd: exit 3", "cmd: true"} {
+		if _, err := Resolve(ref); err == nil {
+			t.Errorf("expected Resolve(%q) to fail", ref)
+		}
+	}
+}
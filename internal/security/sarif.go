@@ -0,0 +1,240 @@
+package security
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gptcode/internal/sarif"
+)
+
+// fallbackRuleID is the rule of vulnerabilities reported without an
+// advisory or CVE id
+const fallbackRuleID = "vulnerability"
+
+// manifests are the dependency files a language's vulnerabilities are
+// reported against when the scanner gives no file, in order of preference
+var manifests = map[string][]string{
+	"go":         {"go.mod"},
+	"typescript": {"package.json", "package-lock.json"},
+	"python":     {"requirements.txt", "pyproject.toml", "Pipfile", "poetry.lock"},
+	"ruby":       {"Gemfile.lock", "Gemfile"},
+}
+
+// goStdlib is the package govulncheck reports standard library
+// vulnerabilities under
+const goStdlib = "stdlib"
+
+var goDirective = regexp.MustCompile(`^\s*(?:toolchain|go)\s`)
+
+var fixedVersion = regexp.MustCompile(`v?\d+\.\d+(?:\.\d+)?(?:[-+][\w.]+)?`)
+
+// SARIF converts the report to a SARIF log. root is the scanned directory,
+// which locations are relative to. A fix that names a version becomes a
+// SARIF fix that bumps the dependency in its manifest.
+func (r *SecurityReport) SARIF(toolVersion, root string) *sarif.Log {
+	log := sarif.NewLog("gptcode security", toolVersion, "https://gptcode.cloud")
+
+	for _, v := range r.Vulnerabilities {
+		// A fixed vulnerability is no longer open, and its fix would point
+		// at manifest lines the fix already changed
+		if v.Fixed {
+			continue
+		}
+		ruleID := v.ID
+		if ruleID == "" {
+			ruleID = v.CVE
+		}
+		if ruleID == "" {
+			ruleID = fallbackRuleID
+		}
+		level := severityLevel(v.Severity)
+
+		rule := sarif.Rule{
+			ID:                   ruleID,
+			ShortDescription:     &sarif.Message{Text: ruleSummary(ruleID, v)},
+			HelpURI:              advisoryURL(v),
+			DefaultConfiguration: &sarif.Configuration{Level: level},
+			Properties: map[string]interface{}{
+				"tags":              []string{"security"},
+				"security-severity": securitySeverity(v.Severity),
+			},
+		}
+		if v.Description != "" && ruleID != fallbackRuleID {
+			rule.FullDescription = &sarif.Message{Text: v.Description}
+		}
+		log.AddRule(rule)
+
+		text := v.Description
+		if text == "" {
+			text = "Vulnerability"
+			if ruleID != fallbackRuleID {
+				text = ruleSummary(ruleID, v)
+			}
+		}
+		if v.Fix != "" {
+			text += "\nFix: " + v.Fix
+		}
+
+		result := sarif.Result{
+			RuleID:  ruleID,
+			Level:   level,
+			Message: sarif.Message{Text: text},
+		}
+		parts := []string{ruleID, v.Package, v.File}
+		if ruleID == fallbackRuleID {
+			parts = append(parts, v.Description)
+		}
+		result.PartialFingerprints = map[string]string{sarif.FingerprintKey: sarif.Fingerprint(parts...)}
+
+		if v.File != "" {
+			result.Locations = []sarif.Location{sarif.NewLocation(root, v.File, sarif.Lines(v.Line, v.Line))}
+		} else if manifest := findManifest(root, r.Language); manifest != "" {
+			path := filepath.Join(root, manifest)
+			line, fix := manifestFix(path, v)
+			if line == 0 && r.Language == "go" && v.Package == goStdlib {
+				// The standard library comes with the toolchain go.mod asks for
+				line = directiveLine(path)
+			}
+			result.Locations = []sarif.Location{sarif.NewLocation(root, manifest, sarif.Lines(line, line))}
+			if fix != nil {
+				fix.ArtifactChanges[0].ArtifactLocation = sarif.ArtifactRelative(root, manifest)
+				result.Fixes = []sarif.Fix{*fix}
+			}
+		} else {
+			// Code scanning rejects results without a location
+			result.Locations = []sarif.Location{sarif.RootLocation()}
+		}
+		log.AddResult(result)
+	}
+	return log
+}
+
+// findManifest returns the first of a language's manifests found in root
+func findManifest(root, language string) string {
+	for _, manifest := range manifests[language] {
+		if _, err := os.Stat(filepath.Join(root, manifest)); err == nil {
+			return manifest
+		}
+	}
+	return ""
+}
+
+// directiveLine returns the line of go.mod's toolchain directive, or of its
+// go directive when there is none
+func directiveLine(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	line := 0
+	for i, l := range strings.Split(string(data), "\n") {
+		if !goDirective.MatchString(l) {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(l), "toolchain") {
+			return i + 1
+		}
+		if line == 0 {
+			line = i + 1
+		}
+	}
+	return line
+}
+
+// manifestFix finds the line of the manifest that pins the vulnerable
+// version, and the edit that bumps it when the fix names a version
+func manifestFix(path string, v Vulnerability) (int, *sarif.Fix) {
+	if v.Package == "" || v.Version == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, nil
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		if !strings.Contains(line, v.Package) {
+			continue
+		}
+		col := strings.Index(line, v.Version)
+		if col < 0 {
+			continue
+		}
+		fixed := fixedVersion.FindString(v.Fix)
+		if fixed == "" {
+			return i + 1, nil
+		}
+		if strings.HasPrefix(v.Version, "v") != strings.HasPrefix(fixed, "v") {
+			if strings.HasPrefix(fixed, "v") {
+				fixed = fixed[1:]
+			} else {
+				fixed = "v" + fixed
+			}
+		}
+		return i + 1, &sarif.Fix{
+			Description: &sarif.Message{Text: fmt.Sprintf("Upgrade %s to %s", v.Package, fixed)},
+			ArtifactChanges: []sarif.ArtifactChange{{
+				Replacements: []sarif.Replacement{{
+					DeletedRegion: sarif.Region{
+						StartLine:   i + 1,
+						StartColumn: col + 1,
+						EndColumn:   col + len(v.Version) + 1,
+					},
+					InsertedContent: &sarif.ArtifactContent{Text: fixed},
+				}},
+			}},
+		}
+	}
+	return 0, nil
+}
+
+func ruleSummary(ruleID string, v Vulnerability) string {
+	if ruleID == fallbackRuleID {
+		return "Vulnerability reported by the dependency scanner"
+	}
+	if v.Package != "" {
+		return fmt.Sprintf("%s in %s", ruleID, v.Package)
+	}
+	return ruleID
+}
+
+func advisoryURL(v Vulnerability) string {
+	switch {
+	case strings.HasPrefix(v.ID, "GO-"):
+		return "https://pkg.go.dev/vuln/" + v.ID
+	case strings.HasPrefix(v.ID, "GHSA-"):
+		return "https://github.com/advisories/" + v.ID
+	case v.CVE != "":
+		return "https://nvd.nist.gov/vuln/detail/" + v.CVE
+	}
+	return ""
+}
+
+// severityLevel maps a scanner severity to a SARIF level
+func severityLevel(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "high":
+		return sarif.LevelError
+	case "low":
+		return sarif.LevelNote
+	}
+	return sarif.LevelWarning
+}
+
+// securitySeverity is the CVSS-like score GitHub code scanning ranks
+// security alerts by
+func securitySeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "9.5"
+	case "high":
+		return "8.0"
+	case "medium", "moderate":
+		return "5.5"
+	case "low":
+		return "2.0"
+	}
+	return "5.0"
+}
@@ -0,0 +1,98 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gptcode/internal/sarif"
+)
+
+func TestSecurityReportSARIF(t *testing.T) {
+	root := t.TempDir()
+	gomod := "module example.com/app\n\ngo 1.22\n\nrequire (\n\tgolang.org/x/net v0.8.0\n)\n"
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report := &SecurityReport{
+		Language: "go",
+		Vulnerabilities: []Vulnerability{
+			{ID: "GO-2023-2102", Severity: "High", Package: "golang.org/x/net", Version: "v0.8.0", Description: "HTTP/2 rapid reset", Fix: "Upgrade to 0.17.0"},
+			{ID: "GO-2023-2102", Severity: "High", Package: "golang.org/x/net", Version: "v0.8.0", Description: "HTTP/2 rapid reset"},
+			{CVE: "CVE-2024-1234", Severity: "Low", File: "internal/auth/token.go", Line: 12, Description: "weak token"},
+			{ID: "GO-2024-9999", Severity: "High", Package: "golang.org/x/net", Version: "v0.8.0", Description: "fixed by --fix", Fixed: true},
+		},
+	}
+	log := report.SARIF("dev", root)
+
+	results := log.Results()
+	if len(results) != 2 {
+		t.Fatalf("expected the repeated advisory and the fixed one dropped, got %d results", len(results))
+	}
+
+	dep := results[0]
+	loc := dep.Locations[0].PhysicalLocation
+	if dep.RuleID != "GO-2023-2102" || dep.Level != sarif.LevelError || loc.ArtifactLocation.URI != "go.mod" || loc.Region.StartLine != 6 {
+		t.Errorf("unexpected result %+v", dep)
+	}
+	if len(dep.Fixes) != 1 {
+		t.Fatalf("expected a fix, got %+v", dep.Fixes)
+	}
+	r := dep.Fixes[0].ArtifactChanges[0].Replacements[0]
+	if r.InsertedContent.Text != "v0.17.0" || r.DeletedRegion.StartColumn != 19 || r.DeletedRegion.EndColumn != 25 {
+		t.Errorf("expected v0.8.0 replaced by v0.17.0, got %+v", r)
+	}
+
+	src := results[1]
+	if src.RuleID != "CVE-2024-1234" || src.Level != sarif.LevelNote || src.Locations[0].PhysicalLocation.Region.StartLine != 12 {
+		t.Errorf("unexpected result %+v", src)
+	}
+
+	rule := log.Runs[0].Tool.Driver.Rules[0]
+	if rule.HelpURI != "https://pkg.go.dev/vuln/GO-2023-2102" || rule.Properties["security-severity"] != "8.0" {
+		t.Errorf("unexpected rule %+v", rule)
+	}
+}
+
+func TestSecurityReportSARIFAlwaysHasALocation(t *testing.T) {
+	goRoot := t.TempDir()
+	gomod := "module example.com/app\n\ngo 1.22\n\nrequire golang.org/x/net v0.8.0\n"
+	if err := os.WriteFile(filepath.Join(goRoot, "go.mod"), []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pyRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(pyRoot, "pyproject.toml"), []byte("[project]\nname = \"app\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, root, language string
+		vuln                 Vulnerability
+		uri                  string
+		line                 int
+	}{
+		{"go stdlib", goRoot, "go", Vulnerability{ID: "GO-2024-2687", Package: "stdlib", Version: "go1.22.0"}, "go.mod", 3},
+		{"pyproject only", pyRoot, "python", Vulnerability{ID: "GHSA-xxxx", Package: "requests", Version: "2.0.0"}, "pyproject.toml", 0},
+		{"no manifest", t.TempDir(), "ruby", Vulnerability{ID: "GHSA-yyyy", Package: "rack", Version: "2.0.0"}, ".", 0},
+	}
+	for _, tt := range tests {
+		report := &SecurityReport{Language: tt.language, Vulnerabilities: []Vulnerability{tt.vuln}}
+		results := report.SARIF("dev", tt.root).Results()
+		if len(results) != 1 || len(results[0].Locations) != 1 {
+			t.Errorf("%s: expected one located result, got %+v", tt.name, results)
+			continue
+		}
+		loc := results[0].Locations[0].PhysicalLocation
+		if loc.ArtifactLocation.URI != tt.uri {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.uri, loc.ArtifactLocation.URI)
+		}
+		line := 0
+		if loc.Region != nil {
+			line = loc.Region.StartLine
+		}
+		if line != tt.line {
+			t.Errorf("%s: expected line %d, got %d", tt.name, tt.line, line)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package security
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -13,23 +14,42 @@ import (
 )
 
 type Vulnerability struct {
-	ID          string
-	Severity    string
-	Package     string
-	Version     string
-	File        string
-	Line        int
-	Description string
-	Fix         string
-	CVE         string
+	ID          string `json:"id,omitempty"`
+	Severity    string `json:"severity"`
+	Package     string `json:"package,omitempty"`
+	Version     string `json:"version,omitempty"`
+	File        string `json:"file,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Description string `json:"description,omitempty"`
+	Fix         string `json:"fix,omitempty"`
+	CVE         string `json:"cve,omitempty"`
+	// Fixed is set once --fix has fixed it in this run
+	Fixed bool `json:"fixed,omitempty"`
 }
 
 type SecurityReport struct {
-	Language        string
-	Vulnerabilities []Vulnerability
-	FixedCount      int
-	UpdatedFiles    []string
-	Errors          []error
+	Language        string          `json:"language"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	FixedCount      int             `json:"fixed_count"`
+	UpdatedFiles    []string        `json:"updated_files,omitempty"`
+	Errors          []error         `json:"-"`
+}
+
+// MarshalJSON writes the fix errors as messages
+func (r SecurityReport) MarshalJSON() ([]byte, error) {
+	type report SecurityReport
+	out := struct {
+		report
+		Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+		Errors          []string        `json:"errors,omitempty"`
+	}{report: report(r), Vulnerabilities: r.Vulnerabilities}
+	if out.Vulnerabilities == nil {
+		out.Vulnerabilities = []Vulnerability{}
+	}
+	for _, err := range r.Errors {
+		out.Errors = append(out.Errors, err.Error())
+	}
+	return json.Marshal(out)
 }
 
 type Scanner struct {
@@ -63,10 +83,11 @@ func (s *Scanner) ScanAndFix(ctx context.Context, autofix bool) (*SecurityReport
 		return report, nil
 	}
 
-	for _, vuln := range vulns {
+	for i, vuln := range vulns {
 		if err := s.fixVulnerability(ctx, vuln, lang); err != nil {
 			report.Errors = append(report.Errors, fmt.Errorf("failed to fix %s: %w", vuln.ID, err))
 		} else {
+			report.Vulnerabilities[i].Fixed = true
 			report.FixedCount++
 			if vuln.File != "" && !contains(report.UpdatedFiles, vuln.File) {
 				report.UpdatedFiles = append(report.UpdatedFiles, vuln.File)
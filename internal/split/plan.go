@@ -0,0 +1,244 @@
+package split
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gptcode/internal/graph"
+)
+
+// Change is a single changed path in the working tree
+type Change struct {
+	Path    string
+	OldPath string // set for renames
+	Status  string // A, M, D or R
+	// WhitespaceOnly is true when the diff disappears with whitespace ignored
+	WhitespaceOnly bool
+}
+
+// Paths returns every path touched by the change
+func (c Change) Paths() []string {
+	if c.OldPath != "" && c.OldPath != c.Path {
+		return []string{c.OldPath, c.Path}
+	}
+	return []string{c.Path}
+}
+
+// ClusterKind orders clusters that have no dependency between them
+type ClusterKind int
+
+const (
+	KindDependencies ClusterKind = iota
+	KindFormatting
+	KindCode
+	KindDocs
+)
+
+func (k ClusterKind) String() string {
+	switch k {
+	case KindDependencies:
+		return "dependencies"
+	case KindFormatting:
+		return "formatting"
+	case KindDocs:
+		return "docs"
+	default:
+		return "code"
+	}
+}
+
+// Cluster is one logical change that becomes one commit
+type Cluster struct {
+	Name    string
+	Kind    ClusterKind
+	Changes []Change
+}
+
+// Files returns the sorted paths touched by the cluster
+func (c *Cluster) Files() []string {
+	var files []string
+	for _, ch := range c.Changes {
+		files = append(files, ch.Paths()...)
+	}
+	sort.Strings(files)
+	return files
+}
+
+var manifestFiles = map[string]bool{
+	"go.mod": true, "go.sum": true,
+	"package.json": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"requirements.txt": true, "pyproject.toml": true, "poetry.lock": true,
+	"Gemfile": true, "Gemfile.lock": true,
+	"Cargo.toml": true, "Cargo.lock": true,
+	"mix.exs": true, "mix.lock": true,
+}
+
+var docExtensions = map[string]bool{".md": true, ".mdx": true, ".rst": true, ".txt": true}
+
+// Plan clusters changes into an ordered series of logical changes.
+//
+// Changes are grouped by unit of compilation (a Go package, or a source file
+// and its test elsewhere), clusters that depend on each other cyclically are
+// merged, and the result is ordered so that imported code lands before its
+// importers. Dependency manifests come first and docs last. g may be nil.
+func Plan(changes []Change, g *graph.Graph) []*Cluster {
+	var clusters []*Cluster
+	byKey := make(map[string]*Cluster)
+
+	add := func(key, name string, kind ClusterKind, ch Change) {
+		c, ok := byKey[key]
+		if !ok {
+			c = &Cluster{Name: name, Kind: kind}
+			byKey[key] = c
+			clusters = append(clusters, c)
+		}
+		c.Changes = append(c.Changes, ch)
+	}
+
+	for _, ch := range changes {
+		base := filepath.Base(ch.Path)
+		ext := filepath.Ext(ch.Path)
+		switch {
+		case manifestFiles[base]:
+			add("deps", "dependencies", KindDependencies, ch)
+		case ch.WhitespaceOnly:
+			add("fmt", "formatting", KindFormatting, ch)
+		case docExtensions[ext]:
+			add("docs", "docs", KindDocs, ch)
+		default:
+			key := unitKey(ch.Path)
+			add("code:"+key, key, KindCode, ch)
+		}
+	}
+
+	clusters = mergeCycles(clusters, g)
+	return order(clusters, g)
+}
+
+// unitKey maps a path to the unit that must be committed atomically
+func unitKey(path string) string {
+	dir := filepath.Dir(path)
+	if filepath.Ext(path) == ".go" {
+		// A Go package only builds as a whole
+		return dir
+	}
+	return filepath.Join(dir, stem(path))
+}
+
+// stem strips test markers so foo.ts, foo.test.ts and test_foo.py share a unit
+func stem(path string) string {
+	base := filepath.Base(path)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	for _, suffix := range []string{".test", ".spec", "_test", "_spec"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return strings.TrimPrefix(name, "test_")
+}
+
+// dependsOn reports whether any file in a imports a file in b
+func dependsOn(a, b *Cluster, g *graph.Graph) bool {
+	if g == nil {
+		return false
+	}
+	targets := make(map[string]bool)
+	for _, f := range b.Files() {
+		targets[f] = true
+	}
+	for _, f := range a.Files() {
+		id, ok := g.Paths[f]
+		if !ok {
+			continue
+		}
+		for _, to := range g.OutEdges[id] {
+			if node := g.Nodes[to]; node != nil && targets[node.Path] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mergeCycles merges code clusters that (transitively) import each other,
+// since neither half can build without the other.
+func mergeCycles(clusters []*Cluster, g *graph.Graph) []*Cluster {
+	n := len(clusters)
+	reach := make([][]bool, n)
+	for i := range reach {
+		reach[i] = make([]bool, n)
+		for j := range reach[i] {
+			reach[i][j] = i == j || (clusters[i].Kind == KindCode && clusters[j].Kind == KindCode && dependsOn(clusters[i], clusters[j], g))
+		}
+	}
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if reach[i][k] && reach[k][j] {
+					reach[i][j] = true
+				}
+			}
+		}
+	}
+
+	merged := make([]bool, n)
+	var result []*Cluster
+	for i := 0; i < n; i++ {
+		if merged[i] {
+			continue
+		}
+		c := clusters[i]
+		for j := i + 1; j < n; j++ {
+			if !merged[j] && reach[i][j] && reach[j][i] {
+				c.Changes = append(c.Changes, clusters[j].Changes...)
+				c.Name += ", " + clusters[j].Name
+				merged[j] = true
+			}
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// order sorts clusters by kind, then topologically by imports, then by name
+func order(clusters []*Cluster, g *graph.Graph) []*Cluster {
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if clusters[i].Kind != clusters[j].Kind {
+			return clusters[i].Kind < clusters[j].Kind
+		}
+		return clusters[i].Name < clusters[j].Name
+	})
+
+	var result []*Cluster
+	placed := make(map[*Cluster]bool)
+	for len(result) < len(clusters) {
+		progressed := false
+		for _, c := range clusters {
+			if placed[c] {
+				continue
+			}
+			ready := true
+			for _, other := range clusters {
+				if other != c && !placed[other] && other.Kind <= c.Kind && dependsOn(c, other, g) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				result = append(result, c)
+				placed[c] = true
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			// Cycles were merged already; keep remaining order as a fallback
+			for _, c := range clusters {
+				if !placed[c] {
+					result = append(result, c)
+					placed[c] = true
+				}
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,145 @@
+package split
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/graph"
+	"gptcode/internal/maestro"
+)
+
+func TestPlanGroupsAndOrders(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddEdge("cmd/app/main.go", "internal/store/store.go")
+	g.AddEdge("web/app.ts", "web/api.ts")
+
+	changes := []Change{
+		{Path: "README.md", Status: "M"},
+		{Path: "cmd/app/main.go", Status: "M"},
+		{Path: "internal/store/store.go", Status: "M"},
+		{Path: "internal/store/store_test.go", Status: "A"},
+		{Path: "go.mod", Status: "M"},
+		{Path: "web/app.ts", Status: "M"},
+		{Path: "web/api.ts", Status: "M"},
+		{Path: "web/api.test.ts", Status: "A"},
+		{Path: "internal/util/util.go", Status: "M", WhitespaceOnly: true},
+	}
+
+	clusters := Plan(changes, g)
+
+	var names []string
+	for _, c := range clusters {
+		names = append(names, c.Name)
+	}
+	want := []string{"dependencies", "formatting", "internal/store", "cmd/app", "web/api", "web/app", "docs"}
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected order:\n got  %v\n want %v", names, want)
+	}
+
+	if files := clusters[2].Files(); len(files) != 2 {
+		t.Errorf("expected package files and test together, got %v", files)
+	}
+	if files := clusters[4].Files(); len(files) != 2 || files[0] != "web/api.test.ts" {
+		t.Errorf("expected JS test paired with its source, got %v", files)
+	}
+}
+
+func TestPlanMergesImportCycles(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddEdge("a/a.go", "b/b.go")
+	g.AddEdge("b/b.go", "a/a.go")
+
+	clusters := Plan([]Change{{Path: "a/a.go"}, {Path: "b/b.go"}}, g)
+	if len(clusters) != 1 {
+		t.Fatalf("expected cyclic packages to merge, got %d clusters", len(clusters))
+	}
+}
+
+type stubVerifier struct{ ok bool }
+
+func (v stubVerifier) Verify(ctx context.Context) (*maestro.VerificationResult, error) {
+	return &maestro.VerificationResult{Success: v.ok, Output: "boom"}, nil
+}
+
+func TestApplyCreatesSeriesAndFoldsFailures(t *testing.T) {
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	git(t, dir, "config", "user.email", "test@example.com")
+	git(t, dir, "config", "user.name", "Test")
+	write(t, dir, "a/a.js", "a1\n")
+	write(t, dir, "b/b.js", "b1\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "init")
+
+	write(t, dir, "a/a.js", "a2\n")
+	write(t, dir, "b/b.js", "b2\n")
+	write(t, dir, "c/c.js", "c1\n")
+
+	s := NewSplitter(nil, "", dir)
+	s.Status = func(string) {}
+	// a/ can't pass alone, so it must be folded into b/
+	s.Verifiers = func(wt string) []maestro.Verifier {
+		a, _ := os.ReadFile(filepath.Join(wt, "a/a.js"))
+		b, _ := os.ReadFile(filepath.Join(wt, "b/b.js"))
+		broken := string(a) == "a2\n" && string(b) == "b1\n"
+		return []maestro.Verifier{stubVerifier{ok: !broken}}
+	}
+
+	clusters, err := s.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 3 {
+		t.Fatalf("expected 3 clusters, got %d", len(clusters))
+	}
+
+	commits, err := s.Apply(context.Background(), clusters)
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits after folding, got %d", len(commits))
+	}
+	if len(commits[0].Clusters) != 2 {
+		t.Errorf("expected first commit to contain a/ and b/, got %d clusters", len(commits[0].Clusters))
+	}
+
+	if status := git(t, dir, "status", "--porcelain"); strings.TrimSpace(status) != "" {
+		t.Errorf("expected clean working tree, got:\n%s", status)
+	}
+	if count := strings.TrimSpace(git(t, dir, "rev-list", "--count", "HEAD")); count != "3" {
+		t.Errorf("expected 3 commits on branch, got %s", count)
+	}
+}
+
+func TestStackBranch(t *testing.T) {
+	if got := StackBranch("split/feat", 0, "Add store: retries & backoff"); got != "split/feat/01-add-store-retries-backoff" {
+		t.Errorf("unexpected branch: %s", got)
+	}
+}
+
+func write(t *testing.T, dir, path, content string) {
+	t.Helper()
+	full := filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
@@ -0,0 +1,289 @@
+package split
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/autonomous"
+	"gptcode/internal/graph"
+	"gptcode/internal/llm"
+	"gptcode/internal/maestro"
+)
+
+// Commit is one commit of the resulting series
+type Commit struct {
+	SHA      string
+	Message  string
+	Clusters []*Cluster
+	Verified bool
+	Output   string // verifier output when Verified is false
+}
+
+// Subject returns the first line of the commit message
+func (c *Commit) Subject() string {
+	return strings.SplitN(c.Message, "\n", 2)[0]
+}
+
+// Splitter turns a dirty working tree into a series of commits
+type Splitter struct {
+	provider llm.Provider
+	model    string
+	workDir  string
+
+	// Verify runs build and test verifiers against every commit
+	Verify bool
+	// Verifiers returns the verifiers for a checkout; defaults to maestro
+	// build and test verifiers.
+	Verifiers func(dir string) []maestro.Verifier
+	Status    func(string)
+}
+
+// NewSplitter creates a splitter; provider may be nil to skip LLM messages
+func NewSplitter(provider llm.Provider, model, workDir string) *Splitter {
+	return &Splitter{
+		provider: provider,
+		model:    model,
+		workDir:  workDir,
+		Verify:   true,
+	}
+}
+
+// Changes lists the working-tree changes relative to HEAD
+func (s *Splitter) Changes() ([]Change, error) {
+	out, err := s.git(s.workDir, "status", "--porcelain", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		code := strings.TrimSpace(line[:2])
+		path := unquote(line[3:])
+		ch := Change{Path: path, Status: "M"}
+
+		switch {
+		case code == "??" || strings.Contains(code, "A"):
+			ch.Status = "A"
+		case strings.Contains(code, "D"):
+			ch.Status = "D"
+		case strings.Contains(code, "R"):
+			parts := strings.SplitN(path, " -> ", 2)
+			if len(parts) == 2 {
+				ch.OldPath, ch.Path = unquote(parts[0]), unquote(parts[1])
+			}
+			ch.Status = "R"
+		}
+
+		if ch.Status == "M" {
+			cmd := exec.Command("git", "diff", "HEAD", "--quiet", "-w", "--", ch.Path)
+			cmd.Dir = s.workDir
+			ch.WhitespaceOnly = cmd.Run() == nil
+		}
+		changes = append(changes, ch)
+	}
+	return changes, nil
+}
+
+// Plan clusters the current working-tree changes
+func (s *Splitter) Plan() ([]*Cluster, error) {
+	changes, err := s.Changes()
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	g, err := graph.NewBuilder(s.workDir).Build()
+	if err != nil {
+		s.status(fmt.Sprintf("⚠️  Import graph unavailable, ordering by path: %v", err))
+		g = nil
+	}
+	return Plan(changes, g), nil
+}
+
+// Apply commits clusters in order on the current branch.
+//
+// Commits are built in a temporary worktree starting from HEAD so each one
+// can be verified in isolation. A cluster that doesn't build on its own is
+// folded into the next one. Once the series is complete the current branch is
+// moved to it; the working tree is left untouched since its content matches.
+func (s *Splitter) Apply(ctx context.Context, clusters []*Cluster) ([]*Commit, error) {
+	if len(clusters) == 0 {
+		return nil, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "gptcode-split-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worktree dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	wt := filepath.Join(tmp, "tree")
+
+	if out, err := s.git(s.workDir, "worktree", "add", "--detach", wt, "HEAD"); err != nil {
+		return nil, fmt.Errorf("failed to create worktree: %w\nOutput: %s", err, out)
+	}
+	defer s.git(s.workDir, "worktree", "remove", "--force", wt)
+
+	var commits []*Commit
+	var pending []*Cluster
+	for i, c := range clusters {
+		pending = append(pending, c)
+		if err := s.stage(wt, c); err != nil {
+			return nil, err
+		}
+
+		last := i == len(clusters)-1
+		verified, output := true, ""
+		if s.Verify {
+			s.status(fmt.Sprintf("🔨 Verifying %s...", c.Name))
+			verified, output = s.verify(ctx, wt)
+			if !verified && !last {
+				s.status(fmt.Sprintf("   ↪ %s doesn't pass on its own, folding into the next change", c.Name))
+				continue
+			}
+		}
+
+		commit := &Commit{Clusters: pending, Verified: verified, Output: output}
+		commit.Message = s.message(ctx, wt, pending)
+		if out, err := s.git(wt, "commit", "-q", "-m", commit.Message); err != nil {
+			return nil, fmt.Errorf("failed to commit: %w\nOutput: %s", err, out)
+		}
+		sha, err := s.git(wt, "rev-parse", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		commit.SHA = strings.TrimSpace(sha)
+		s.status(fmt.Sprintf("✅ %s %s", commit.SHA[:7], commit.Subject()))
+
+		commits = append(commits, commit)
+		pending = nil
+	}
+
+	// The final commit's tree equals the working tree, so a mixed reset just
+	// moves the branch onto the series.
+	final := commits[len(commits)-1].SHA
+	if out, err := s.git(s.workDir, "reset", "-q", final); err != nil {
+		return nil, fmt.Errorf("failed to move branch to %s: %w\nOutput: %s", final, err, out)
+	}
+	return commits, nil
+}
+
+// stage copies a cluster's files from the working tree into the worktree
+func (s *Splitter) stage(wt string, c *Cluster) error {
+	files := c.Files()
+	for _, f := range files {
+		src := filepath.Join(s.workDir, f)
+		dst := filepath.Join(wt, f)
+
+		info, err := os.Stat(src)
+		if os.IsNotExist(err) {
+			os.Remove(dst)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", f, err)
+		}
+
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("failed to create dir for %s: %w", f, err)
+		}
+		if err := os.WriteFile(dst, data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f, err)
+		}
+	}
+
+	args := append([]string{"add", "-A", "--"}, files...)
+	if out, err := s.git(wt, args...); err != nil {
+		return fmt.Errorf("failed to stage %s: %w\nOutput: %s", c.Name, err, out)
+	}
+	return nil
+}
+
+func (s *Splitter) verify(ctx context.Context, dir string) (bool, string) {
+	verifiers := []maestro.Verifier{maestro.NewBuildVerifier(dir), maestro.NewTestVerifier(dir)}
+	if s.Verifiers != nil {
+		verifiers = s.Verifiers(dir)
+	}
+
+	for _, v := range verifiers {
+		result, err := v.Verify(ctx)
+		if err != nil {
+			return false, err.Error()
+		}
+		if !result.Success {
+			return false, result.Output
+		}
+	}
+	return true, ""
+}
+
+func (s *Splitter) message(ctx context.Context, wt string, clusters []*Cluster) string {
+	var files, names []string
+	for _, c := range clusters {
+		files = append(files, c.Files()...)
+		names = append(names, c.Name)
+	}
+	fallback := autonomous.NewGitManager(wt).GenerateCommitMessage("update "+strings.Join(names, ", "), files)
+
+	if s.provider == nil {
+		return fallback
+	}
+
+	diff, _ := s.git(wt, "diff", "--cached", "--stat", "--patch")
+	if len(diff) > 12000 {
+		diff = diff[:12000] + "\n... (truncated)"
+	}
+
+	resp, err := s.provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You write concise git commit messages.",
+		UserPrompt: fmt.Sprintf(`Write a commit message for this staged diff. It is one commit of a
+series that splits a larger change into reviewable steps.
+
+Format: an imperative subject line under 72 characters, a blank line, then
+at most three short lines explaining why. Reply with only the message.
+
+%s`, diff),
+		Model: s.model,
+	})
+	if err != nil || strings.TrimSpace(resp.Text) == "" {
+		return fallback
+	}
+	return strings.Trim(strings.TrimSpace(resp.Text), "`")
+}
+
+func (s *Splitter) git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+func (s *Splitter) status(msg string) {
+	if s.Status != nil {
+		s.Status(msg)
+		return
+	}
+	fmt.Println(msg)
+}
+
+func unquote(path string) string {
+	if strings.HasPrefix(path, `"`) && strings.HasSuffix(path, `"`) {
+		return strings.Trim(path, `"`)
+	}
+	return path
+}
@@ -0,0 +1,98 @@
+package split
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"gptcode/internal/github"
+)
+
+// StackClient is the subset of github.Client needed to open stacked PRs
+type StackClient interface {
+	PushBranch(branchName string) error
+	CreatePR(opts github.PRCreateOptions) (*github.PullRequest, error)
+}
+
+// StackOptions configures stacked pull requests
+type StackOptions struct {
+	Base   string // base of the first PR
+	Prefix string // branch name prefix, e.g. "split/my-feature"
+	Draft  bool
+}
+
+// StackBranch is the branch name for the i-th (0-based) commit of a stack
+func StackBranch(prefix string, i int, subject string) string {
+	return fmt.Sprintf("%s/%02d-%s", prefix, i+1, slug(subject))
+}
+
+// OpenStack creates one branch per commit and opens a PR for each, with every
+// PR based on the branch of the previous one.
+func OpenStack(client StackClient, workDir string, commits []*Commit, opts StackOptions) ([]*github.PullRequest, error) {
+	branches := make([]string, len(commits))
+	for i, c := range commits {
+		branches[i] = StackBranch(opts.Prefix, i, c.Subject())
+
+		cmd := exec.Command("git", "branch", "-f", branches[i], c.SHA)
+		cmd.Dir = workDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("failed to create branch %s: %w\nOutput: %s", branches[i], err, string(out))
+		}
+		if err := client.PushBranch(branches[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var prs []*github.PullRequest
+	base := opts.Base
+	for i, c := range commits {
+		pr, err := client.CreatePR(github.PRCreateOptions{
+			Title:      c.Subject(),
+			Body:       stackBody(commits, i),
+			BaseBranch: base,
+			HeadBranch: branches[i],
+			IsDraft:    opts.Draft,
+		})
+		if err != nil {
+			return prs, err
+		}
+		prs = append(prs, pr)
+		base = branches[i]
+	}
+	return prs, nil
+}
+
+func stackBody(commits []*Commit, current int) string {
+	var sb strings.Builder
+	if parts := strings.SplitN(commits[current].Message, "\n", 2); len(parts) == 2 && strings.TrimSpace(parts[1]) != "" {
+		sb.WriteString(strings.TrimSpace(parts[1]))
+		sb.WriteString("\n\n")
+	}
+
+	fmt.Fprintf(&sb, "Part %d of %d of a stacked change. Review and merge in order:\n\n", current+1, len(commits))
+	for i, c := range commits {
+		marker := " "
+		if i == current {
+			marker = "→"
+		}
+		fmt.Fprintf(&sb, "%s %d. %s\n", marker, i+1, c.Subject())
+	}
+	if !commits[current].Verified {
+		sb.WriteString("\n⚠️ This commit did not pass build/test verification on its own.\n")
+	}
+	return sb.String()
+}
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(s string) string {
+	s = strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 40 {
+		s = strings.TrimRight(s[:40], "-")
+	}
+	if s == "" {
+		s = "change"
+	}
+	return s
+}
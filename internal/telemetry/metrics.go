@@ -0,0 +1,141 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"gptcode/internal/events"
+)
+
+// MetricsPath is where long-running modes serve Prometheus metrics
+const MetricsPath = "/metrics"
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	llmRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gptcode_llm_requests_total",
+		Help: "LLM requests by backend, model and status (ok or error).",
+	}, []string{"backend", "model", "status"})
+
+	llmLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gptcode_llm_request_duration_seconds",
+		Help:    "Time from sending an LLM request to its last token.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"backend", "model"})
+
+	llmTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gptcode_llm_tokens_total",
+		Help: "Tokens reported by backends, by type (prompt, completion or cached).",
+	}, []string{"backend", "model", "type"})
+
+	llmCost = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gptcode_llm_cost_usd_total",
+		Help: "Estimated spend on LLM requests in US dollars.",
+	}, []string{"backend", "model"})
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gptcode_tool_calls_total",
+		Help: "Tool calls by tool and status (ok or error).",
+	}, []string{"tool", "status"})
+
+	toolLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gptcode_tool_call_duration_seconds",
+		Help:    "Time spent running tool calls.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 15, 60, 300},
+	}, []string{"tool"})
+
+	stepErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gptcode_errors_total",
+		Help: "Failed verifications by error type (syntax, build, test, lint, ...).",
+	}, []string{"type"})
+
+	verifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gptcode_verifications_total",
+		Help: "Verification runs by result (pass or fail).",
+	}, []string{"result"})
+
+	activeSessions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gptcode_active_sessions",
+		Help: "Agent runs in progress.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		llmRequests, llmLatency, llmTokens, llmCost,
+		toolCalls, toolLatency,
+		stepErrors, verifications, activeSessions,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// MetricsHandler serves gptcode's metrics in the Prometheus exposition format
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// ServeMetrics serves MetricsHandler at MetricsPath on addr, such as
+// ":9090", in the background. The returned function stops the server.
+func ServeMetrics(addr string) (func(context.Context) error, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, MetricsHandler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "[METRICS] Server stopped: %v\n", err)
+		}
+	}()
+	return server.Shutdown, nil
+}
+
+// ObserveLLMRequest counts an LLM request and how long it took
+func ObserveLLMRequest(backend, model string, elapsed time.Duration, err error) {
+	llmRequests.WithLabelValues(backend, model, status(err)).Inc()
+	llmLatency.WithLabelValues(backend, model).Observe(elapsed.Seconds())
+}
+
+// ObserveToolCall counts a tool call and how long it took
+func ObserveToolCall(tool string, elapsed time.Duration, err error) {
+	toolCalls.WithLabelValues(tool, status(err)).Inc()
+	toolLatency.WithLabelValues(tool).Observe(elapsed.Seconds())
+}
+
+// RecordVerification counts a verification run and reports it to headless
+// output; errorType classifies a failure, as maestro.ClassifyResult does
+func RecordVerification(passed bool, errorType string) {
+	events.PublishVerification(events.Verification{Passed: passed, ErrorType: errorType})
+	if passed {
+		verifications.WithLabelValues("pass").Inc()
+		return
+	}
+	verifications.WithLabelValues("fail").Inc()
+	stepErrors.WithLabelValues(errorType).Inc()
+}
+
+// TrackSession counts a run as active until the returned function is called
+func TrackSession() func() {
+	activeSessions.Inc()
+	return activeSessions.Dec
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
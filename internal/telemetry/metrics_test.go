@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", MetricsPath, nil))
+	if rec.Code != 200 {
+		t.Fatalf("expected 200 from %s, got %d", MetricsPath, rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMetricsExposition(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	ObserveLLMRequest("groq", "metrics-test-model", 1500*time.Millisecond, nil)
+	ObserveLLMRequest("groq", "metrics-test-model", 200*time.Millisecond, errors.New("rate limited"))
+	RecordLLMUsage(context.Background(), "groq", "metrics-test-model", 1000, 200, 300)
+	ObserveToolCall("metrics_test_tool", 30*time.Millisecond, nil)
+	ObserveToolCall("metrics_test_tool", 10*time.Millisecond, errors.New("not found"))
+	RecordVerification(true, "")
+	RecordVerification(false, "build")
+	done := TrackSession()
+
+	body := scrape(t)
+	for _, want := range []string{
+		`gptcode_llm_requests_total{backend="groq",model="metrics-test-model",status="ok"} 1`,
+		`gptcode_llm_requests_total{backend="groq",model="metrics-test-model",status="error"} 1`,
+		`gptcode_llm_request_duration_seconds_bucket{backend="groq",model="metrics-test-model",le="2"} 2`,
+		`gptcode_llm_request_duration_seconds_count{backend="groq",model="metrics-test-model"} 2`,
+		`gptcode_llm_tokens_total{backend="groq",model="metrics-test-model",type="prompt"} 1000`,
+		`gptcode_llm_tokens_total{backend="groq",model="metrics-test-model",type="completion"} 200`,
+		`gptcode_llm_tokens_total{backend="groq",model="metrics-test-model",type="cached"} 300`,
+		`gptcode_tool_calls_total{status="error",tool="metrics_test_tool"} 1`,
+		`gptcode_tool_call_duration_seconds_count{tool="metrics_test_tool"} 2`,
+		`gptcode_verifications_total{result="fail"}`,
+		`gptcode_errors_total{type="build"}`,
+		`gptcode_active_sessions 1`,
+		`# TYPE gptcode_llm_request_duration_seconds histogram`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in the exposition", want)
+		}
+	}
+
+	done()
+	if !strings.Contains(scrape(t), "gptcode_active_sessions 0") {
+		t.Error("expected the session to be counted out")
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	shutdown, err := ServeMetrics("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	busy := httptest.NewServer(nil)
+	defer busy.Close()
+	if _, err := ServeMetrics(strings.TrimPrefix(busy.URL, "http://")); err == nil {
+		t.Error("expected an address in use to fail up front")
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
-	"gptcode/internal/intelligence"
+	"gptcode/internal/ledger"
 )
 
 // Telemetry provides OpenTelemetry-based telemetry tracking
@@ -76,10 +76,10 @@ func (u *UsageTracker) RecordRequest(backend, model string, tokens int) {
 	u.requests[key]++
 	u.tokens[key] += tokens
 
-	// Calculate cost based on model's cost per 1M tokens
-	catalog := intelligence.NewModelCatalog()
-	modelInfo := catalog.GetModelInfo(backend, model)
-	cost := (float64(tokens) / 1000000.0) * modelInfo.CostPer1M
+	// Only the total is known, so price it between the prompt and
+	// completion rates
+	price, _ := ledger.DefaultPricer().PriceFor(backend, model)
+	cost := float64(tokens) / 1000000.0 * (price.Prompt + price.Completion) / 2
 	u.costs[key] += cost
 	u.totalCost += cost
 }
@@ -0,0 +1,217 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"gptcode/internal/config"
+	"gptcode/internal/events"
+	"gptcode/internal/ledger"
+	"gptcode/internal/redact"
+	"gptcode/internal/secrets"
+)
+
+// TracerName is the instrumentation scope of gptcode's spans
+const TracerName = "gptcode"
+
+// Attributes gptcode adds where the GenAI conventions have none
+const (
+	CostKey         = attribute.Key("gptcode.cost_usd")
+	CachedTokensKey = attribute.Key("gptcode.usage.cached_tokens")
+	CommandKey      = attribute.Key("gptcode.command")
+	StepIndexKey    = attribute.Key("gptcode.step.index")
+	StepTitleKey    = attribute.Key("gptcode.step.title")
+)
+
+func tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// StartTracing exports spans over OTLP/HTTP when a collector is set in the
+// tracing section of setup.yaml or in the standard OTEL_EXPORTER_OTLP_*
+// variables. The returned function flushes buffered spans and must run
+// before the process exits; without a collector it does nothing.
+func StartTracing(ctx context.Context, cfg config.TracingConfig, version string) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if !tracingEnabled(cfg) {
+		return noop, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(tracesURL(cfg.Endpoint)))
+	}
+	if len(cfg.Headers) > 0 {
+		headers := make(map[string]string, len(cfg.Headers))
+		for name, ref := range cfg.Headers {
+			value, err := secrets.Resolve(ref)
+			if err != nil {
+				return noop, fmt.Errorf("failed to resolve tracing header %s: %w", name, err)
+			}
+			headers[name] = value
+		}
+		opts = append(opts, otlptracehttp.WithHeaders(headers))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win over the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("gptcode"), semconv.ServiceVersion(version)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return noop, fmt.Errorf("failed to describe the tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	// An unreachable collector shouldn't clutter the terminal
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		if os.Getenv("GPTCODE_DEBUG") == "1" {
+			fmt.Fprintf(os.Stderr, "[TRACING] %v\n", err)
+		}
+	}))
+
+	return provider.Shutdown, nil
+}
+
+func tracingEnabled(cfg config.TracingConfig) bool {
+	if cfg.Disabled || strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return cfg.Endpoint != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// tracesURL turns a collector base URL into the traces endpoint, the way
+// OTEL_EXPORTER_OTLP_ENDPOINT is read
+func tracesURL(endpoint string) string {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return u.String()
+}
+
+// StartSession starts the root span of a run. Its ID is the ledger
+// session, so spans and spend can be matched up.
+func StartSession(ctx context.Context, id, command string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "session", trace.WithAttributes(
+		semconv.GenAIConversationID(id),
+		CommandKey.String(summary(command)),
+	))
+}
+
+// StartStep starts the span of a plan step, numbered from 1
+func StartStep(ctx context.Context, index int, title string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "plan_step", trace.WithAttributes(
+		StepIndexKey.Int(index),
+		StepTitleKey.String(summary(title)),
+	))
+}
+
+// StartAgent starts the span of an agent's turn
+func StartAgent(ctx context.Context, agent string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "invoke_agent "+agent, trace.WithAttributes(
+		semconv.GenAIOperationNameInvokeAgent,
+		semconv.GenAIAgentName(agent),
+	))
+}
+
+// StartLLMCall starts the span of a chat request to a backend
+func StartLLMCall(ctx context.Context, backend, model string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "chat "+model,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.GenAIOperationNameChat,
+			semconv.GenAIProviderNameKey.String(backend),
+			semconv.GenAIRequestModel(model),
+		))
+}
+
+// RecordLLMUsage counts the tokens a model used and their price, reports
+// them to headless output and adds them to the LLM span in ctx
+func RecordLLMUsage(ctx context.Context, backend, model string, prompt, completion, cached int) {
+	cost, _ := ledger.DefaultPricer().Cost(backend, model, prompt, completion, cached)
+	llmTokens.WithLabelValues(backend, model, "prompt").Add(float64(prompt))
+	llmTokens.WithLabelValues(backend, model, "completion").Add(float64(completion))
+	llmTokens.WithLabelValues(backend, model, "cached").Add(float64(cached))
+	llmCost.WithLabelValues(backend, model).Add(cost)
+	events.PublishCost(events.Cost{
+		Backend:          backend,
+		Model:            model,
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		CachedTokens:     cached,
+		CostUSD:          cost,
+	})
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		semconv.GenAIResponseModel(model),
+		semconv.GenAIUsageInputTokens(prompt),
+		semconv.GenAIUsageOutputTokens(completion),
+		CachedTokensKey.Int(cached),
+		CostKey.Float64(cost),
+	)
+}
+
+// StartToolCall starts the span of a tool the model asked for
+func StartToolCall(ctx context.Context, tool string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "execute_tool "+tool, trace.WithAttributes(
+		semconv.GenAIOperationNameExecuteTool,
+		semconv.GenAIToolName(tool),
+	))
+}
+
+// End marks a span failed when err is set, with secrets masked, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		msg := redact.Default().Redact(err.Error())
+		span.RecordError(errors.New(msg))
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
+
+// summary keeps the first line of free text, masked and cut to 120
+// characters, so plans and prompts don't end up in span attributes
+func summary(text string) string {
+	text, _, _ = strings.Cut(strings.TrimSpace(text), "\n")
+	if r := []rune(text); len(r) > 120 {
+		text = string(r[:120])
+	}
+	return redact.Default().Redact(text)
+}
@@ -0,0 +1,182 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"gptcode/internal/config"
+)
+
+// otlpReceiver is a minimal OTLP/HTTP collector that keeps what it gets
+type otlpReceiver struct {
+	mu      sync.Mutex
+	spans   map[string]*tracepb.Span
+	headers http.Header
+	path    string
+}
+
+func (r *otlpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	var export collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.headers = req.Header.Clone()
+	r.path = req.URL.Path
+	for _, rs := range export.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				r.spans[s.Name] = s
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+}
+
+func value(s *tracepb.Span, key string) *commonpb.AnyValue {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+func attr(s *tracepb.Span, key string) string {
+	return value(s, key).GetStringValue()
+}
+
+func TestStartTracingExportsSpanHierarchy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TEST_TEAM_HEADER", "platform")
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	receiver := &otlpReceiver{spans: map[string]*tracepb.Span{}}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	shutdown, err := StartTracing(context.Background(), config.TracingConfig{
+		Endpoint: srv.URL,
+		Headers:  map[string]string{"X-Team": "env:TEST_TEAM_HEADER"},
+	}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, session := StartSession(context.Background(), "session-1", "Add a health check\n\nDetails follow")
+	ctx, step := StartStep(ctx, 1, "Add the handler")
+	ctx, agent := StartAgent(ctx, "editor")
+	llmCtx, llmSpan := StartLLMCall(ctx, "openrouter", "openai/gpt-4o-mini")
+	RecordLLMUsage(llmCtx, "openrouter", "openai/gpt-4o-mini", 1200, 300, 0)
+	End(llmSpan, nil)
+	_, tool := StartToolCall(ctx, "write_file")
+	End(tool, errors.New("permission denied"))
+	agent.End()
+	step.End()
+	End(session, nil)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if receiver.path != "/v1/traces" {
+		t.Errorf("expected spans posted to /v1/traces, got %q", receiver.path)
+	}
+	if receiver.headers.Get("X-Team") != "platform" {
+		t.Errorf("expected the configured header, got %v", receiver.headers)
+	}
+
+	names := []string{"session", "plan_step", "invoke_agent editor", "chat openai/gpt-4o-mini", "execute_tool write_file"}
+	for _, name := range names {
+		if receiver.spans[name] == nil {
+			t.Fatalf("span %q not exported; got %d spans", name, len(receiver.spans))
+		}
+	}
+	parents := map[string]string{
+		"plan_step":               "session",
+		"invoke_agent editor":     "plan_step",
+		"chat openai/gpt-4o-mini": "invoke_agent editor",
+		"execute_tool write_file": "invoke_agent editor",
+	}
+	for child, parent := range parents {
+		if string(receiver.spans[child].ParentSpanId) != string(receiver.spans[parent].SpanId) {
+			t.Errorf("expected %q under %q", child, parent)
+		}
+	}
+
+	sessionSpan := receiver.spans["session"]
+	if attr(sessionSpan, "gen_ai.conversation.id") != "session-1" || attr(sessionSpan, "gptcode.command") != "Add a health check" {
+		t.Errorf("unexpected session attributes: %v", sessionSpan.Attributes)
+	}
+	llmSpanOut := receiver.spans["chat openai/gpt-4o-mini"]
+	if llmSpanOut.Kind != tracepb.Span_SPAN_KIND_CLIENT {
+		t.Errorf("expected a client span, got %v", llmSpanOut.Kind)
+	}
+	for key, want := range map[string]string{
+		"gen_ai.operation.name": "chat",
+		"gen_ai.provider.name":  "openrouter",
+		"gen_ai.request.model":  "openai/gpt-4o-mini",
+		"gen_ai.response.model": "openai/gpt-4o-mini",
+	} {
+		if got := attr(llmSpanOut, key); got != want {
+			t.Errorf("%s: expected %q, got %q", key, want, got)
+		}
+	}
+	if value(llmSpanOut, "gen_ai.usage.input_tokens").GetIntValue() != 1200 || value(llmSpanOut, "gen_ai.usage.output_tokens").GetIntValue() != 300 {
+		t.Errorf("expected token usage, got %v", llmSpanOut.Attributes)
+	}
+	if value(llmSpanOut, "gptcode.cost_usd") == nil {
+		t.Errorf("expected a cost, got %v", llmSpanOut.Attributes)
+	}
+	toolSpan := receiver.spans["execute_tool write_file"]
+	if toolSpan.Status.GetCode() != tracepb.Status_STATUS_CODE_ERROR || toolSpan.Status.GetMessage() != "permission denied" {
+		t.Errorf("expected the tool span to fail, got %v", toolSpan.Status)
+	}
+}
+
+func TestStartTracingOffWithoutCollector(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	if tracingEnabled(config.TracingConfig{}) {
+		t.Error("expected tracing off without an endpoint")
+	}
+	if tracingEnabled(config.TracingConfig{Endpoint: "http://localhost:4318", Disabled: true}) {
+		t.Error("expected disabled to win over an endpoint")
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	if !tracingEnabled(config.TracingConfig{}) {
+		t.Error("expected the standard variable to turn tracing on")
+	}
+}
+
+func TestTracesURL(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:4318":          "http://localhost:4318/v1/traces",
+		"https://otel.example.com/":      "https://otel.example.com/v1/traces",
+		"localhost:4318":                 "http://localhost:4318/v1/traces",
+		"https://api.example.com/otlp/t": "https://api.example.com/otlp/t",
+	}
+	for in, want := range tests {
+		if got := tracesURL(in); got != want {
+			t.Errorf("tracesURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package testgen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gptcode/internal/coverage"
+	"gptcode/internal/langdetect"
+	"gptcode/internal/llm"
+	"gptcode/internal/telemetry"
+	"gptcode/internal/testreport"
+)
+
+// CoverageOptions controls GenerateUntilCoverage
+type CoverageOptions struct {
+	// Target is the statement coverage to reach for the file, in percent
+	Target float64
+	// MaxCost stops the loop once the model calls cost this much (USD);
+	// zero means no limit
+	MaxCost float64
+	// Backend is used to price the model calls
+	Backend   string
+	MaxRounds int
+	// Runs is how many times each new test must pass to count as stable
+	Runs int
+	// Gaps is how many uncovered blocks each round targets
+	Gaps    int
+	OnRound func(round CoverageRound)
+}
+
+// CoverageRound records one round of targeted generation
+type CoverageRound struct {
+	Round   int
+	Before  float64
+	After   float64
+	File    string
+	Kept    []string
+	Dropped map[string]string // test name -> reason
+	Cost    float64
+}
+
+// CoverageResult summarizes a coverage-guided generation run
+type CoverageResult struct {
+	SourceFile string
+	Start      float64
+	Final      float64
+	Rounds     []CoverageRound
+	Cost       float64
+	StopReason string
+}
+
+// GenerateUntilCoverage generates tests aimed at the largest uncovered
+// blocks of sourceFile until its coverage reaches the target, the rounds
+// run out or the cost budget is spent. Each round's tests are kept only
+// if they pass opts.Runs times in a row and cover something new.
+func (tg *TestGenerator) GenerateUntilCoverage(ctx context.Context, sourceFile string, opts CoverageOptions) (*CoverageResult, error) {
+	if tg.language != langdetect.Go {
+		return nil, fmt.Errorf("coverage-guided generation currently only supports Go")
+	}
+	if opts.Runs <= 0 {
+		opts.Runs = 3
+	}
+	if opts.Gaps <= 0 {
+		opts.Gaps = 5
+	}
+	if opts.MaxRounds <= 0 {
+		opts.MaxRounds = 5
+	}
+
+	pkg := "./" + filepath.ToSlash(filepath.Dir(sourceFile))
+	profile, err := coverage.RunProfile(ctx, tg.workDir, pkg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure coverage: %w", err)
+	}
+
+	result := &CoverageResult{SourceFile: sourceFile, Start: profile.Percent(sourceFile)}
+	result.Final = result.Start
+	usage := telemetry.NewUsageTracker()
+
+	for round := 1; ; round++ {
+		switch {
+		case result.Final >= opts.Target:
+			result.StopReason = "target reached"
+		case round > opts.MaxRounds:
+			result.StopReason = "round limit reached"
+		case opts.MaxCost > 0 && usage.GetTotalCost() >= opts.MaxCost:
+			result.StopReason = "cost budget reached"
+		case ctx.Err() != nil:
+			result.StopReason = "timed out"
+		}
+		if result.StopReason != "" {
+			break
+		}
+
+		funcs, err := profile.Functions(tg.workDir, sourceFile)
+		if err != nil {
+			return result, err
+		}
+		gaps := coverage.LargestGaps(funcs, opts.Gaps)
+		if len(gaps) == 0 {
+			result.StopReason = "no uncovered blocks left"
+			break
+		}
+
+		costBefore := usage.GetTotalCost()
+		r := CoverageRound{Round: round, Before: result.Final, Dropped: map[string]string{}}
+		r.File, err = tg.generateGapTests(ctx, sourceFile, gaps, usage, opts.Backend)
+		if err == nil {
+			r.Kept = tg.keepUsefulTests(ctx, r.File, pkg, sourceFile, profile, opts.Runs, r.Dropped)
+		} else {
+			r.Dropped["*"] = err.Error()
+		}
+		if len(r.Kept) == 0 && r.File != "" {
+			_ = os.Remove(filepath.Join(tg.workDir, r.File))
+			r.File = ""
+		}
+
+		if len(r.Kept) > 0 {
+			if measured, err := coverage.RunProfile(ctx, tg.workDir, pkg); err == nil {
+				profile = measured
+				result.Final = profile.Percent(sourceFile)
+			}
+		}
+		r.After = result.Final
+		r.Cost = usage.GetTotalCost() - costBefore
+		result.Rounds = append(result.Rounds, r)
+		if opts.OnRound != nil {
+			opts.OnRound(r)
+		}
+	}
+
+	result.Cost = usage.GetTotalCost()
+	return result, nil
+}
+
+// generateGapTests asks the model for tests that execute the given blocks
+// and writes them to a new test file, returned relative to workDir
+func (tg *TestGenerator) generateGapTests(ctx context.Context, sourceFile string, gaps []coverage.UncoveredBlock, usage *telemetry.UsageTracker, backend string) (string, error) {
+	source, err := os.ReadFile(filepath.Join(tg.workDir, sourceFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to read source file: %w", err)
+	}
+	lines := strings.Split(string(source), "\n")
+
+	var targets strings.Builder
+	for _, g := range gaps {
+		fmt.Fprintf(&targets, "%s, lines %d-%d (%d statements):\n", g.Function, g.StartLine, g.EndLine, g.NumStmt)
+		for n := g.StartLine; n <= g.EndLine && n <= len(lines); n++ {
+			fmt.Fprintf(&targets, "%5d | %s\n", n, lines[n-1])
+		}
+		targets.WriteString("\n")
+	}
+
+	dir := filepath.Join(tg.workDir, filepath.Dir(sourceFile))
+	var existing []string
+	for name := range existingTestFuncs(dir, "") {
+		existing = append(existing, name)
+	}
+	sort.Strings(existing)
+
+	testFile := coverageTestFile(tg.workDir, sourceFile)
+	prompt := fmt.Sprintf(`Write Go tests that execute the code below which no existing test reaches.
+
+File: %s
+%s
+
+Uncovered code (line numbers from the file above):
+%s
+Requirements:
+1. Each test function must drive execution through one or more of the uncovered lines
+2. Assert the observable behavior of those paths, not just that they run
+3. Tests must be deterministic: no sleeps, no reliance on time, randomness or map order
+4. Do not reuse these existing names: %s
+5. Same package as the source file, with only the imports you use
+
+Generate ONLY the complete test file content, ready to save as %s.`,
+		sourceFile, source, targets.String(), strings.Join(existing, ", "), filepath.Base(testFile))
+
+	resp, err := tg.provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You are a Go testing expert who writes targeted tests for uncovered code paths.",
+		UserPrompt:   prompt,
+		Model:        tg.model,
+	})
+	if err != nil {
+		return "", fmt.Errorf("LLM failed to generate tests: %w", err)
+	}
+	tokens := (len(prompt) + len(resp.Text)) / 4
+	if resp.TokenUsage != nil {
+		tokens = resp.TokenUsage.TotalTokens
+	}
+	usage.RecordRequest(backend, tg.model, tokens)
+
+	code := tg.cleanTestCode(extractCode(resp.Text))
+	if err := os.WriteFile(filepath.Join(tg.workDir, testFile), []byte(code), 0644); err != nil {
+		return "", fmt.Errorf("failed to write test file: %w", err)
+	}
+	return testFile, nil
+}
+
+// coverageTestFile picks an unused <file>_coverageN_test.go name
+func coverageTestFile(workDir, sourceFile string) string {
+	base := strings.TrimSuffix(sourceFile, ".go")
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s_coverage%d_test.go", base, n)
+		if _, err := os.Stat(filepath.Join(workDir, name)); os.IsNotExist(err) {
+			return name
+		}
+	}
+}
+
+// keepUsefulTests runs the new tests opts.Runs times and measures each on
+// its own, then rewrites the file with only the stable tests that cover a
+// block the baseline profile misses. Reasons for dropping go to dropped.
+func (tg *TestGenerator) keepUsefulTests(ctx context.Context, testFile, pkg, sourceFile string, baseline *coverage.Profile, runs int, dropped map[string]string) []string {
+	path := filepath.Join(tg.workDir, testFile)
+	names, err := testFuncNames(path)
+	if err != nil || len(names) == 0 {
+		dropped["*"] = "no test functions parsed"
+		return nil
+	}
+	if out, err := compileTests(ctx, tg.workDir, sourceFile); err != nil {
+		dropped["*"] = "does not compile: " + truncate(out, 500)
+		return nil
+	}
+
+	report, _, _ := testreport.Run(ctx, tg.workDir, testreport.FormatGoJSON, "go", "test",
+		fmt.Sprintf("-count=%d", runs), "-run", "^("+strings.Join(names, "|")+")$", pkg)
+	failed := map[string]bool{}
+	if report != nil {
+		for _, tc := range report.Failures() {
+			failed[strings.SplitN(tc.Name, "/", 2)[0]] = true
+		}
+	} else {
+		for _, name := range names {
+			failed[name] = true
+		}
+	}
+
+	var kept []string
+	for _, name := range names {
+		if failed[name] {
+			dropped[name] = fmt.Sprintf("failed in %d runs", runs)
+			continue
+		}
+		profile, err := coverage.RunProfile(ctx, tg.workDir, pkg, "-run", "^"+name+"$")
+		if err != nil {
+			dropped[name] = "failed when run alone"
+			continue
+		}
+		if !profile.Covers(baseline, sourceFile) {
+			dropped[name] = "covers nothing new"
+			continue
+		}
+		kept = append(kept, name)
+	}
+
+	if len(kept) > 0 && len(kept) < len(names) {
+		if err := keepFuncs(path, kept); err != nil {
+			return nil
+		}
+		if _, err := compileTests(ctx, tg.workDir, sourceFile); err != nil {
+			return nil
+		}
+	}
+	return kept
+}
+
+func testFuncNames(path string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && strings.HasPrefix(fn.Name.Name, "Test") {
+			names = append(names, fn.Name.Name)
+		}
+	}
+	return names, nil
+}
+
+// keepFuncs removes the Test functions not in keep, and the imports that
+// were only used by them
+func keepFuncs(path string, keep []string) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	keepSet := map[string]bool{}
+	for _, name := range keep {
+		keepSet[name] = true
+	}
+
+	decls := f.Decls[:0]
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && strings.HasPrefix(fn.Name.Name, "Test") && !keepSet[fn.Name.Name] {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	f.Decls = decls
+	// Comments of removed functions would otherwise be left floating
+	f.Comments = nil
+	pruneImports(f)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// pruneImports drops imports whose package name is no longer referenced
+func pruneImports(f *ast.File) {
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				used[x.Name] = true
+			}
+		}
+		return true
+	})
+
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		specs := gen.Specs[:0]
+		for _, spec := range gen.Specs {
+			imp := spec.(*ast.ImportSpec)
+			path := strings.Trim(imp.Path.Value, `"`)
+			name := filepath.Base(path)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			if name == "_" || name == "." || used[name] || !guessablePackageName(path) {
+				specs = append(specs, spec)
+			}
+		}
+		gen.Specs = specs
+	}
+
+	decls := f.Decls[:0]
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT && len(gen.Specs) == 0 {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	f.Decls = decls
+}
+
+// guessablePackageName reports whether an import path's last element is
+// its package name, which isn't so for paths like gopkg.in/yaml.v3 or
+// github.com/x/y/v2
+func guessablePackageName(path string) bool {
+	base := filepath.Base(path)
+	if strings.ContainsAny(base, ".-") {
+		return false
+	}
+	if len(base) > 1 && base[0] == 'v' && strings.Trim(base[1:], "0123456789") == "" {
+		return false
+	}
+	return true
+}
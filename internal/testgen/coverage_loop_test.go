@@ -0,0 +1,103 @@
+package testgen
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/llm"
+)
+
+// cannedProvider returns the same reply to every request
+type cannedProvider struct {
+	reply   string
+	prompts []string
+}
+
+func (p *cannedProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.prompts = append(p.prompts, req.UserPrompt)
+	return &llm.ChatResponse{Text: p.reply, TokenUsage: &llm.TokenUsage{TotalTokens: 1000}}, nil
+}
+
+func TestGenerateUntilCoverageKeepsUsefulTests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module demo\n\ngo 1.21\n",
+		"calc/calc.go": `package calc
+
+func Classify(n int) string {
+	if n < 0 {
+		return "negative"
+	}
+	if n == 0 {
+		return "zero"
+	}
+	return "positive"
+}
+`,
+		"calc/calc_test.go": "package calc\n\nimport \"testing\"\n\nfunc TestPositive(t *testing.T) {\n\tif Classify(5) != \"positive\" {\n\t\tt.Fatal()\n\t}\n}\n",
+	})
+
+	provider := &cannedProvider{reply: "```go\n" + `package calc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNegative(t *testing.T) {
+	if Classify(-1) != "negative" {
+		t.Fatal("want negative")
+	}
+}
+
+func TestPositiveAgain(t *testing.T) {
+	if Classify(7) != "positive" {
+		t.Fatal("want positive")
+	}
+}
+
+func TestZeroWrong(t *testing.T) {
+	if !strings.HasPrefix(Classify(0), "nope") {
+		t.Fatal("wrong expectation")
+	}
+}
+` + "```"}
+
+	tg, err := NewTestGenerator(provider, "test-model", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tg.GenerateUntilCoverage(context.Background(), "calc/calc.go", CoverageOptions{Target: 100, MaxRounds: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(provider.prompts[0], `    5 | 		return "negative"`) {
+		t.Errorf("expected uncovered lines in the prompt:\n%s", provider.prompts[0])
+	}
+	if result.StopReason != "round limit reached" || result.Final <= result.Start {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	r := result.Rounds[0]
+	if len(r.Kept) != 1 || r.Kept[0] != "TestNegative" {
+		t.Errorf("expected only TestNegative to be kept, got %v (dropped %v)", r.Kept, r.Dropped)
+	}
+	if r.Dropped["TestPositiveAgain"] != "covers nothing new" || !strings.HasPrefix(r.Dropped["TestZeroWrong"], "failed") {
+		t.Errorf("unexpected drop reasons: %v", r.Dropped)
+	}
+
+	kept, err := os.ReadFile(filepath.Join(dir, r.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(kept), "TestZeroWrong") || strings.Contains(string(kept), `"strings"`) {
+		t.Errorf("expected dropped tests and their imports to be removed:\n%s", kept)
+	}
+}
@@ -0,0 +1,601 @@
+package testgen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// FuzzParam is a parameter of a fuzz candidate. FuzzType is the type the
+// fuzzing engine supplies; Type is the declared type when it differs, such
+// as a named type over a string.
+type FuzzParam struct {
+	Name     string
+	FuzzType string
+	Type     string
+	// Import is the path of the package declaring Type when it isn't the
+	// fuzzed package, such as "time" for time.Duration
+	Import string
+}
+
+// FuzzCandidate is a function whose parameters the fuzzing engine can
+// generate directly
+type FuzzCandidate struct {
+	Name    string
+	Target  string // name of the fuzz target, without the Fuzz prefix
+	Params  []FuzzParam
+	Results int
+	// Pure means the function only computes from its arguments, which
+	// makes it a candidate for property tests
+	Pure   bool
+	Source string
+	// Seeds are argument lists found in existing tests, as Go expressions
+	Seeds [][]string
+}
+
+// RoundTrip is an encode/decode pair where Decode(Encode(x)) should be x
+type RoundTrip struct {
+	Encode, Decode       *FuzzCandidate
+	EncodeErr, DecodeErr bool
+}
+
+// FuzzPlan lists what can be fuzzed in one source file
+type FuzzPlan struct {
+	Package    string
+	SourceFile string
+	Candidates []*FuzzCandidate
+	RoundTrips []*RoundTrip
+}
+
+// roundTripPrefixes pair the verbs of inverse functions
+var roundTripPrefixes = [][2]string{
+	{"Encode", "Decode"},
+	{"Marshal", "Unmarshal"},
+	{"Format", "Parse"},
+	{"Serialize", "Deserialize"},
+	{"Escape", "Unescape"},
+	{"Quote", "Unquote"},
+	{"Compress", "Decompress"},
+	{"Encrypt", "Decrypt"},
+	{"Pack", "Unpack"},
+}
+
+// ioPackages do I/O, run processes or exit; fuzzing must never reach them
+var ioPackages = map[string]bool{
+	"os": true, "os/exec": true, "os/signal": true, "net": true, "net/http": true,
+	"syscall": true, "io/ioutil": true, "log": true, "plugin": true,
+}
+
+// impurePackages make results depend on more than the arguments
+var impurePackages = map[string]bool{
+	"time": true, "math/rand": true, "math/rand/v2": true, "crypto/rand": true, "sync": true,
+}
+
+// FindFuzzCandidates type-checks the package containing sourceFile and
+// returns the functions declared in it that can be fuzzed, the round-trip
+// pairs among them, and seeds from the package's existing tests
+func FindFuzzCandidates(workDir, sourceFile string) (*FuzzPlan, error) {
+	dir := filepath.Join(workDir, filepath.Dir(sourceFile))
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package: %w", err)
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	var target *ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		files = append(files, f)
+		if name == filepath.Base(sourceFile) {
+			target = f
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("%s is not part of the package build", sourceFile)
+	}
+
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{
+		Importer: stdlibImporter{importer.ForCompiler(fset, "source", nil)},
+		// Imports outside the standard library aren't loaded; functions
+		// that mention them just don't become candidates
+		Error: func(error) {},
+	}
+	pkg, _ := conf.Check(bp.Name, fset, files, info)
+
+	src, err := os.ReadFile(filepath.Join(workDir, sourceFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+
+	fx := analyzeEffects(files, info, pkg)
+	plan := &FuzzPlan{Package: bp.Name, SourceFile: sourceFile}
+	taken := existingTestFuncs(dir, fuzzTestFile(sourceFile))
+	byName := map[string]*FuzzCandidate{}
+	signatures := map[string]*types.Signature{}
+
+	for _, decl := range target.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil || fn.Type.TypeParams != nil {
+			continue
+		}
+		obj, ok := info.Defs[fn.Name].(*types.Func)
+		if !ok || fn.Name.Name == "init" || fn.Name.Name == "main" || fx.io[obj] {
+			continue
+		}
+		sig := obj.Type().(*types.Signature)
+		params, ok := fuzzParams(sig, pkg)
+		if !ok || len(params) == 0 {
+			continue
+		}
+
+		c := &FuzzCandidate{
+			Name:    fn.Name.Name,
+			Target:  exportedName(fn.Name.Name),
+			Params:  params,
+			Results: sig.Results().Len(),
+			Pure:    !fx.impure[obj] && comparableResults(sig),
+			Source:  string(src[fset.Position(fn.Pos()).Offset:fset.Position(fn.End()).Offset]),
+		}
+		if taken["Fuzz"+c.Target] || byName[c.Target] != nil {
+			continue
+		}
+		taken["Fuzz"+c.Target] = true
+		byName[c.Target] = c
+		signatures[c.Name] = sig
+		plan.Candidates = append(plan.Candidates, c)
+	}
+
+	for _, c := range plan.Candidates {
+		c.Seeds = tableSeeds(dir, plan.Package, c)
+	}
+	plan.RoundTrips = findRoundTrips(plan.Candidates, signatures)
+	return plan, nil
+}
+
+// stdlibImporter only imports standard library packages
+type stdlibImporter struct{ types.Importer }
+
+func (i stdlibImporter) Import(path string) (*types.Package, error) {
+	if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") || !isStdlib(path) {
+		return nil, fmt.Errorf("not loaded: %s", path)
+	}
+	return i.Importer.Import(path)
+}
+
+var stdlibCache sync.Map
+
+func isStdlib(path string) bool {
+	if cached, ok := stdlibCache.Load(path); ok {
+		return cached.(bool)
+	}
+	p, err := build.Default.Import(path, "", build.FindOnly)
+	std := err == nil && p.Goroot
+	stdlibCache.Store(path, std)
+	return std
+}
+
+// fuzzParams maps a signature's parameters to types the fuzzing engine
+// supports: strings, []byte, bools and numbers, or named types over them
+func fuzzParams(sig *types.Signature, pkg *types.Package) ([]FuzzParam, bool) {
+	if sig.Variadic() {
+		return nil, false
+	}
+	qualifier := types.RelativeTo(pkg)
+	// Parameter names must not shadow the packages the test file imports
+	reserved := map[string]bool{"": true, "_": true, "t": true, "f": true, "testing": true, "bytes": true}
+	var params []FuzzParam
+	for i := 0; i < sig.Params().Len(); i++ {
+		v := sig.Params().At(i)
+		fuzzType, ok := fuzzType(v.Type())
+		if !ok {
+			return nil, false
+		}
+		p := FuzzParam{Name: v.Name(), FuzzType: fuzzType}
+		if declared := types.TypeString(v.Type(), qualifier); declared != fuzzType {
+			p.Type = declared
+		}
+		if named, ok := types.Unalias(v.Type()).(*types.Named); ok {
+			if from := named.Obj().Pkg(); from != nil && from != pkg {
+				p.Import = from.Path()
+				reserved[from.Name()] = true
+			}
+		}
+		params = append(params, p)
+	}
+	for i := range params {
+		if reserved[params[i].Name] {
+			params[i].Name = fmt.Sprintf("arg%d", i)
+		}
+	}
+	return params, true
+}
+
+func fuzzType(t types.Type) (string, bool) {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch u.Kind() {
+		case types.String, types.Bool, types.Int, types.Int8, types.Int16, types.Int32, types.Int64,
+			types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64, types.Float32, types.Float64:
+			return u.Name(), true
+		}
+	case *types.Slice:
+		if b, ok := u.Elem().(*types.Basic); ok && b.Kind() == types.Uint8 {
+			return "[]byte", true
+		}
+	}
+	return "", false
+}
+
+// comparableResults reports whether a function returns values a property
+// test can compare
+func comparableResults(sig *types.Signature) bool {
+	if sig.Results().Len() == 0 {
+		return false
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		t := sig.Results().At(i).Type()
+		if _, ok := fuzzType(t); !ok && t.String() != "error" {
+			return false
+		}
+	}
+	return true
+}
+
+// effects records what the package's functions do besides computing their
+// results, following calls between them
+type effects struct {
+	// io: I/O, processes, exiting, or calls into unloaded packages
+	io map[*types.Func]bool
+	// impure: io, package state, goroutines, channels, time or randomness
+	impure map[*types.Func]bool
+}
+
+func analyzeEffects(files []*ast.File, info *types.Info, pkg *types.Package) *effects {
+	fx := &effects{io: map[*types.Func]bool{}, impure: map[*types.Func]bool{}}
+	callees := map[*types.Func][]*types.Func{}
+
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			obj, ok := info.Defs[fn.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.GoStmt, *ast.SendStmt, *ast.SelectStmt:
+					fx.impure[obj] = true
+				case *ast.UnaryExpr:
+					if n.Op == token.ARROW {
+						fx.impure[obj] = true
+					}
+				case *ast.Ident:
+					switch use := info.Uses[n].(type) {
+					case *types.PkgName:
+						if path := use.Imported().Path(); ioPackages[path] || !isStdlib(path) {
+							fx.io[obj] = true
+						} else if impurePackages[path] {
+							fx.impure[obj] = true
+						}
+					case *types.Var:
+						if pkg != nil && use.Parent() == pkg.Scope() {
+							fx.impure[obj] = true
+						}
+					case *types.Func:
+						if use.Pkg() == nil {
+							break
+						}
+						if use.Pkg() == pkg {
+							callees[obj] = append(callees[obj], use)
+						} else if use.Pkg().Path() == "fmt" && strings.HasPrefix(use.Name(), "Print") {
+							fx.io[obj] = true
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for caller, calls := range callees {
+			for _, callee := range calls {
+				if fx.io[callee] && !fx.io[caller] {
+					fx.io[caller], changed = true, true
+				}
+				if (fx.impure[callee] || fx.io[callee]) && !fx.impure[caller] {
+					fx.impure[caller], changed = true, true
+				}
+			}
+		}
+	}
+	for f := range fx.io {
+		fx.impure[f] = true
+	}
+	return fx
+}
+
+func exportedName(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// fuzzTestFile is where the fuzz targets for a source file are written
+func fuzzTestFile(sourceFile string) string {
+	return strings.TrimSuffix(sourceFile, ".go") + "_fuzz_test.go"
+}
+
+// existingTestFuncs lists test function names already declared in the
+// package, ignoring the generated file that is about to be rewritten
+func existingTestFuncs(dir, generated string) map[string]bool {
+	names := map[string]bool{}
+	for _, f := range parseTestFiles(dir) {
+		if filepath.Base(f.path) == filepath.Base(generated) {
+			continue
+		}
+		for _, decl := range f.file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+				names[fn.Name.Name] = true
+			}
+		}
+	}
+	return names
+}
+
+type parsedTestFile struct {
+	path string
+	file *ast.File
+}
+
+func parseTestFiles(dir string) []parsedTestFile {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	var out []parsedTestFile
+	fset := token.NewFileSet()
+	for _, path := range matches {
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err == nil {
+			out = append(out, parsedTestFile{path: path, file: f})
+		}
+	}
+	return out
+}
+
+// tableSeeds collects literal arguments passed to the candidate in existing
+// tests: direct calls like Parse("1+2"), and table tests that call
+// Parse(tt.input) with cases written as {input: "1+2"}
+func tableSeeds(dir, pkgName string, c *FuzzCandidate) [][]string {
+	var seeds [][]string
+	seen := map[string]bool{}
+	add := func(args []ast.Expr) {
+		seed, ok := seedArgs(c.Params, args)
+		if key := strings.Join(seed, ","); ok && !seen[key] && len(seeds) < 20 {
+			seen[key] = true
+			seeds = append(seeds, seed)
+		}
+	}
+
+	for _, f := range parseTestFiles(dir) {
+		if strings.HasSuffix(f.path, "_fuzz_test.go") {
+			continue
+		}
+		for _, decl := range f.file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			var fields [][]string
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || !callsFunc(call, pkgName, c.Name) || len(call.Args) != len(c.Params) {
+					return true
+				}
+				add(call.Args)
+				if names, ok := tableFields(call.Args); ok {
+					fields = append(fields, names)
+				}
+				return true
+			})
+			if len(fields) == 0 {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				lit, ok := n.(*ast.CompositeLit)
+				if !ok {
+					return true
+				}
+				values := map[string]ast.Expr{}
+				for _, elt := range lit.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if key, ok := kv.Key.(*ast.Ident); ok {
+							values[key.Name] = kv.Value
+						}
+					}
+				}
+				for _, names := range fields {
+					args := make([]ast.Expr, 0, len(names))
+					for _, name := range names {
+						if v, ok := values[name]; ok {
+							args = append(args, v)
+						}
+					}
+					if len(args) == len(names) {
+						add(args)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return seeds
+}
+
+func callsFunc(call *ast.CallExpr, pkgName, name string) bool {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name == name
+	case *ast.SelectorExpr:
+		x, ok := fun.X.(*ast.Ident)
+		return ok && x.Name == pkgName && fun.Sel.Name == name
+	}
+	return false
+}
+
+// tableFields returns the field names when every argument is a field of
+// the same table case, as in Parse(tt.input, tt.base)
+func tableFields(args []ast.Expr) ([]string, bool) {
+	var names []string
+	row := ""
+	for _, arg := range args {
+		sel, ok := arg.(*ast.SelectorExpr)
+		if !ok {
+			return nil, false
+		}
+		x, ok := sel.X.(*ast.Ident)
+		if !ok || (row != "" && x.Name != row) {
+			return nil, false
+		}
+		row = x.Name
+		names = append(names, sel.Sel.Name)
+	}
+	return names, true
+}
+
+// seedArgs converts literal arguments to f.Add arguments of exactly the
+// fuzz parameter types
+func seedArgs(params []FuzzParam, args []ast.Expr) ([]string, bool) {
+	seed := make([]string, len(params))
+	for i, p := range params {
+		kind, text, ok := literal(args[i])
+		if !ok {
+			return nil, false
+		}
+		switch {
+		case p.FuzzType == "string" && kind == token.STRING:
+			seed[i] = text
+		case p.FuzzType == "[]byte" && kind == token.STRING:
+			seed[i] = "[]byte(" + text + ")"
+		case p.FuzzType == "bool" && kind == token.IDENT:
+			seed[i] = text
+		case strings.HasPrefix(p.FuzzType, "float") && (kind == token.INT || kind == token.FLOAT):
+			seed[i] = p.FuzzType + "(" + text + ")"
+		case (strings.HasPrefix(p.FuzzType, "int") || strings.HasPrefix(p.FuzzType, "uint")) && (kind == token.INT || kind == token.CHAR):
+			seed[i] = p.FuzzType + "(" + text + ")"
+		default:
+			return nil, false
+		}
+	}
+	return seed, true
+}
+
+// literal returns the source of a constant argument: a basic literal, a
+// negated number, true/false, or a []byte conversion of a string
+func literal(e ast.Expr) (token.Token, string, bool) {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		return e.Kind, e.Value, true
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.BasicLit); ok && e.Op == token.SUB && lit.Kind != token.STRING {
+			return lit.Kind, "-" + lit.Value, true
+		}
+	case *ast.Ident:
+		if e.Name == "true" || e.Name == "false" {
+			return token.IDENT, e.Name, true
+		}
+	case *ast.CallExpr:
+		if arr, ok := e.Fun.(*ast.ArrayType); ok && len(e.Args) == 1 {
+			if elt, ok := arr.Elt.(*ast.Ident); ok && elt.Name == "byte" {
+				if lit, ok := e.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					return token.STRING, lit.Value, true
+				}
+			}
+		}
+	}
+	return token.ILLEGAL, "", false
+}
+
+// findRoundTrips pairs candidates like EncodeX/DecodeX where the encoder
+// takes one value and the decoder maps the encoding back to that type
+func findRoundTrips(candidates []*FuzzCandidate, sigs map[string]*types.Signature) []*RoundTrip {
+	byName := map[string]*FuzzCandidate{}
+	for _, c := range candidates {
+		byName[c.Name] = c
+	}
+
+	var trips []*RoundTrip
+	for _, c := range candidates {
+		if len(c.Params) != 1 {
+			continue
+		}
+		for _, prefixes := range roundTripPrefixes {
+			decName, ok := inverseName(c.Name, prefixes)
+			if !ok {
+				continue
+			}
+			dec, ok := byName[decName]
+			if !ok {
+				continue
+			}
+			if trip := roundTrip(c, dec, sigs[c.Name], sigs[decName]); trip != nil {
+				trips = append(trips, trip)
+			}
+		}
+	}
+	return trips
+}
+
+// inverseName maps EncodeX to DecodeX (and encodeX to decodeX)
+func inverseName(name string, prefixes [2]string) (string, bool) {
+	for _, lower := range []bool{false, true} {
+		enc, dec := prefixes[0], prefixes[1]
+		if lower {
+			enc, dec = strings.ToLower(enc[:1])+enc[1:], strings.ToLower(dec[:1])+dec[1:]
+		}
+		if strings.HasPrefix(name, enc) {
+			return dec + strings.TrimPrefix(name, enc), true
+		}
+	}
+	return "", false
+}
+
+func roundTrip(enc, dec *FuzzCandidate, encSig, decSig *types.Signature) *RoundTrip {
+	encRes, decRes := encSig.Results(), decSig.Results()
+	if encRes.Len() == 0 || encRes.Len() > 2 || decSig.Params().Len() != 1 || decRes.Len() == 0 || decRes.Len() > 2 {
+		return nil
+	}
+	isErr := func(t types.Type) bool { return t.String() == "error" }
+	if (encRes.Len() == 2 && !isErr(encRes.At(1).Type())) || (decRes.Len() == 2 && !isErr(decRes.At(1).Type())) {
+		return nil
+	}
+	if !types.Identical(encRes.At(0).Type(), decSig.Params().At(0).Type()) ||
+		!types.Identical(decRes.At(0).Type(), encSig.Params().At(0).Type()) {
+		return nil
+	}
+	return &RoundTrip{
+		Encode:    enc,
+		Decode:    dec,
+		EncodeErr: encRes.Len() == 2,
+		DecodeErr: decRes.Len() == 2,
+	}
+}
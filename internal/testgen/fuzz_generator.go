@@ -0,0 +1,399 @@
+package testgen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gptcode/internal/llm"
+)
+
+// FuzzGenerator writes native Go fuzz targets and property tests for a
+// source file, runs the fuzzer, and turns crashers into regression tests
+type FuzzGenerator struct {
+	provider llm.Provider
+	model    string
+	workDir  string
+}
+
+// FuzzOptions controls FuzzGenerator.Generate
+type FuzzOptions struct {
+	// FuzzTime is how long each target is fuzzed; zero skips fuzzing
+	FuzzTime time.Duration
+	// Properties asks the model for testing/quick property tests of the
+	// pure functions
+	Properties bool
+	// OnTarget is called before each target is fuzzed
+	OnTarget func(target string)
+}
+
+// Crasher is an input that made a fuzz target fail
+type Crasher struct {
+	Target     string
+	CorpusFile string
+	Inputs     []string
+	Output     string
+}
+
+// FuzzResult describes the generated tests and what fuzzing found
+type FuzzResult struct {
+	TestFile     string
+	PropertyFile string
+	// PropertyKept is the existing property test file left alone instead
+	// of generating one
+	PropertyKept string
+	Targets      []string
+	Crashers     []Crasher
+	// PropertyFailure holds the output when the property tests fail, which
+	// is either a bug or a wrong property
+	PropertyFailure string
+	Valid           bool
+	Error           error
+}
+
+func NewFuzzGenerator(provider llm.Provider, model, workDir string) *FuzzGenerator {
+	return &FuzzGenerator{
+		provider: provider,
+		model:    model,
+		workDir:  workDir,
+	}
+}
+
+// Generate writes <file>_fuzz_test.go with a fuzz target per candidate and
+// a round-trip target per encode/decode pair, optionally property tests in
+// <file>_property_test.go, then fuzzes each target for opts.FuzzTime
+func (g *FuzzGenerator) Generate(ctx context.Context, sourceFile string, opts FuzzOptions) (*FuzzResult, error) {
+	plan, err := FindFuzzCandidates(g.workDir, sourceFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Candidates) == 0 {
+		return nil, fmt.Errorf("no functions in %s take only strings, []byte, bools or numbers", sourceFile)
+	}
+
+	result := &FuzzResult{TestFile: fuzzTestFile(sourceFile)}
+	pkgDir := filepath.Join(g.workDir, filepath.Dir(sourceFile))
+	testPath := filepath.Join(g.workDir, result.TestFile)
+
+	code, targets, err := renderFuzzFile(plan, pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	result.Targets = targets
+	previous, readErr := os.ReadFile(testPath)
+	if err := os.WriteFile(testPath, code, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write fuzz tests: %w", err)
+	}
+	if out, err := compileTests(ctx, g.workDir, sourceFile); err != nil {
+		// Leave the package building: put back the file this replaced
+		if readErr == nil {
+			_ = os.WriteFile(testPath, previous, 0644)
+		} else {
+			_ = os.Remove(testPath)
+		}
+		result.Error = fmt.Errorf("generated fuzz tests do not compile:\n%s", out)
+		return result, nil
+	}
+	result.Valid = true
+
+	if opts.Properties && g.provider != nil {
+		g.generateProperties(ctx, plan, result)
+	}
+
+	if opts.FuzzTime <= 0 {
+		return result, nil
+	}
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		if opts.OnTarget != nil {
+			opts.OnTarget(target)
+		}
+		if crasher := runFuzz(ctx, g.workDir, sourceFile, target, opts.FuzzTime); crasher != nil {
+			result.Crashers = append(result.Crashers, *crasher)
+		}
+	}
+
+	if len(result.Crashers) > 0 {
+		// Re-render so the new corpus entries become regression tests
+		code, _, err := renderFuzzFile(plan, pkgDir)
+		if err != nil {
+			return result, err
+		}
+		if err := os.WriteFile(testPath, code, 0644); err != nil {
+			return result, fmt.Errorf("failed to write fuzz tests: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// renderFuzzFile renders the fuzz test file. Each target delegates to a
+// fuzzCheck helper so regression tests can call it with a crasher's inputs.
+func renderFuzzFile(plan *FuzzPlan, pkgDir string) ([]byte, []string, error) {
+	var body strings.Builder
+	var targets []string
+	imports := map[string]bool{"testing": true}
+	for _, c := range plan.Candidates {
+		for _, p := range c.Params {
+			if p.Import != "" {
+				imports[p.Import] = true
+			}
+		}
+	}
+
+	for _, c := range plan.Candidates {
+		target := "Fuzz" + c.Target
+		targets = append(targets, target)
+
+		fmt.Fprintf(&body, "func %s(f *testing.F) {\n", target)
+		for _, seed := range c.Seeds {
+			fmt.Fprintf(&body, "\tf.Add(%s)\n", strings.Join(seed, ", "))
+		}
+		fmt.Fprintf(&body, "\tf.Fuzz(fuzzCheck%s)\n}\n\n", c.Target)
+
+		fmt.Fprintf(&body, "// fuzzCheck%s calls %s with one input; it must not panic\n", c.Target, c.Name)
+		fmt.Fprintf(&body, "func fuzzCheck%s(t *testing.T, %s) {\n", c.Target, paramList(c.Params))
+		call := fmt.Sprintf("%s(%s)", c.Name, argList(c.Params))
+		if c.Results == 0 {
+			fmt.Fprintf(&body, "\t%s\n}\n\n", call)
+		} else {
+			fmt.Fprintf(&body, "\t%s = %s\n}\n\n", blanks(c.Results), call)
+		}
+	}
+
+	for _, rt := range plan.RoundTrips {
+		name := rt.Encode.Target + rt.Decode.Target
+		target := "Fuzz" + name
+		targets = append(targets, target)
+		p := rt.Encode.Params[0]
+		value := argList(rt.Encode.Params)
+
+		fmt.Fprintf(&body, "func %s(f *testing.F) {\n", target)
+		for _, seed := range rt.Encode.Seeds {
+			fmt.Fprintf(&body, "\tf.Add(%s)\n", strings.Join(seed, ", "))
+		}
+		fmt.Fprintf(&body, "\tf.Fuzz(fuzzCheck%s)\n}\n\n", name)
+
+		fmt.Fprintf(&body, "// fuzzCheck%s checks that %s(%s(x)) returns x\n", name, rt.Decode.Name, rt.Encode.Name)
+		fmt.Fprintf(&body, "func fuzzCheck%s(t *testing.T, %s) {\n", name, paramList(rt.Encode.Params))
+		if strings.HasPrefix(p.FuzzType, "float") {
+			fmt.Fprintf(&body, "\tif %s != %s {\n\t\tt.Skip(\"NaN never equals itself\")\n\t}\n", p.Name, p.Name)
+		}
+		if rt.EncodeErr {
+			fmt.Fprintf(&body, "\tencoded, err := %s(%s)\n\tif err != nil {\n\t\tt.Skip()\n\t}\n", rt.Encode.Name, value)
+		} else {
+			fmt.Fprintf(&body, "\tencoded := %s(%s)\n", rt.Encode.Name, value)
+		}
+		if rt.DecodeErr {
+			fmt.Fprintf(&body, "\tdecoded, err := %s(encoded)\n\tif err != nil {\n\t\tt.Fatalf(\"%s(%s(%%v)) failed: %%v\", %s, err)\n\t}\n",
+				rt.Decode.Name, rt.Decode.Name, rt.Encode.Name, p.Name)
+		} else {
+			fmt.Fprintf(&body, "\tdecoded := %s(encoded)\n", rt.Decode.Name)
+		}
+		mismatch := fmt.Sprintf("decoded != %s", value)
+		if p.FuzzType == "[]byte" {
+			imports["bytes"] = true
+			mismatch = fmt.Sprintf("!bytes.Equal(decoded, %s)", value)
+		}
+		fmt.Fprintf(&body, "\tif %s {\n\t\tt.Fatalf(\"%s(%s(%%v)) = %%v\", %s, decoded)\n\t}\n}\n\n",
+			mismatch, rt.Decode.Name, rt.Encode.Name, p.Name)
+	}
+
+	for _, target := range targets {
+		crashers := corpusEntries(pkgDir, target)
+		if len(crashers) == 0 {
+			continue
+		}
+		fmt.Fprintf(&body, "// Test%sRegressions replays inputs the fuzzer found failing,\n// stored under testdata/fuzz/%s\n", target, target)
+		fmt.Fprintf(&body, "func Test%sRegressions(t *testing.T) {\n", target)
+		for _, c := range crashers {
+			fmt.Fprintf(&body, "\tt.Run(%q, func(t *testing.T) { fuzzCheck%s(t, %s) })\n",
+				filepath.Base(c.CorpusFile), strings.TrimPrefix(target, "Fuzz"), strings.Join(c.Inputs, ", "))
+		}
+		body.WriteString("}\n\n")
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by gptcode gen fuzz from %s; regenerating rewrites it.\n\n", filepath.Base(plan.SourceFile))
+	fmt.Fprintf(&file, "package %s\n\n", plan.Package)
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if len(paths) == 1 {
+		fmt.Fprintf(&file, "import %q\n\n", paths[0])
+	} else {
+		file.WriteString("import (\n")
+		for _, path := range paths {
+			fmt.Fprintf(&file, "\t%q\n", path)
+		}
+		file.WriteString(")\n\n")
+	}
+	file.WriteString(body.String())
+
+	formatted, err := format.Source(file.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to format fuzz tests: %w", err)
+	}
+	return formatted, targets, nil
+}
+
+func paramList(params []FuzzParam) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.Name + " " + p.FuzzType
+	}
+	return strings.Join(parts, ", ")
+}
+
+// argList converts fuzz values to the declared parameter types
+func argList(params []FuzzParam) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.Name
+		if p.Type != "" {
+			parts[i] = p.Type + "(" + p.Name + ")"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func blanks(n int) string {
+	return strings.TrimSuffix(strings.Repeat("_, ", n), ", ")
+}
+
+// corpusEntries reads the fuzzer's saved failing inputs for a target
+func corpusEntries(pkgDir, target string) []Crasher {
+	paths, _ := filepath.Glob(filepath.Join(pkgDir, "testdata", "fuzz", target, "*"))
+	sort.Strings(paths)
+	var out []Crasher
+	for _, path := range paths {
+		if inputs, ok := readCorpusFile(path); ok {
+			out = append(out, Crasher{Target: target, CorpusFile: path, Inputs: inputs})
+		}
+	}
+	return out
+}
+
+// readCorpusFile parses a corpus file. Each value is a Go expression such as
+// string("x") or int(3), so it can be pasted into a call as is.
+func readCorpusFile(path string) ([]string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "go test fuzz v1") {
+		return nil, false
+	}
+	return lines[1:], true
+}
+
+var failingInputPattern = regexp.MustCompile(`Failing input written to (testdata/fuzz/\S+)`)
+
+// runFuzz fuzzes one target for the given time and returns the crasher it
+// found, if any
+func runFuzz(ctx context.Context, workDir, sourceFile, target string, fuzzTime time.Duration) *Crasher {
+	pkg := "./" + filepath.ToSlash(filepath.Dir(sourceFile))
+	cmd := exec.CommandContext(ctx, "go", "test", "-run", "^$", "-fuzz", "^"+target+"$",
+		"-fuzztime", fuzzTime.String(), pkg)
+	cmd.Dir = workDir
+	out, err := cmd.CombinedOutput()
+	if err == nil || ctx.Err() != nil {
+		return nil
+	}
+
+	crasher := &Crasher{Target: target, Output: truncate(string(out), 3000)}
+	if m := failingInputPattern.FindStringSubmatch(string(out)); m != nil {
+		crasher.CorpusFile = filepath.Join(workDir, filepath.Dir(sourceFile), filepath.FromSlash(m[1]))
+		crasher.Inputs, _ = readCorpusFile(crasher.CorpusFile)
+	}
+	return crasher
+}
+
+// compileTests builds the package's tests without running any
+func compileTests(ctx context.Context, workDir, sourceFile string) (string, error) {
+	cmd := exec.CommandContext(ctx, "go", "test", "-count=1", "-run", "^$", "./"+filepath.ToSlash(filepath.Dir(sourceFile)))
+	cmd.Dir = workDir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n... (truncated)"
+}
+
+// generateProperties asks the model for testing/quick property tests of the
+// pure functions. Tests that don't compile are dropped; tests that fail are
+// kept and reported. An existing property test file is never replaced.
+func (g *FuzzGenerator) generateProperties(ctx context.Context, plan *FuzzPlan, result *FuzzResult) {
+	propertyFile := strings.TrimSuffix(plan.SourceFile, ".go") + "_property_test.go"
+	path := filepath.Join(g.workDir, propertyFile)
+	if _, err := os.Stat(path); err == nil {
+		result.PropertyKept = propertyFile
+		return
+	}
+
+	var sources []string
+	for _, c := range plan.Candidates {
+		if c.Pure {
+			sources = append(sources, c.Source)
+		}
+	}
+	if len(sources) == 0 {
+		return
+	}
+
+	prompt := fmt.Sprintf(`Write property-based tests with testing/quick for these pure Go functions
+from package %s (%s).
+
+%s
+
+Requirements:
+1. One TestXxxProperties function per function, using quick.Check with a func returning bool
+2. Test real invariants: round trips, idempotence, ordering, bounds, length relations,
+   agreement with a simpler reference implementation. Do NOT restate the implementation.
+3. Skip inputs outside the documented domain by returning true for them
+4. Package %s, with only the imports you use
+
+Generate ONLY the complete test file content, ready to save as %s.`,
+		plan.Package, filepath.Base(plan.SourceFile), strings.Join(sources, "\n\n"), plan.Package, filepath.Base(propertyFile))
+
+	resp, err := g.provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You are a testing expert who writes precise property-based tests.",
+		UserPrompt:   prompt,
+		Model:        g.model,
+	})
+	if err != nil {
+		return
+	}
+
+	code := extractCode(resp.Text)
+	if err := os.WriteFile(path, []byte(code), 0644); err != nil {
+		return
+	}
+	if _, err := compileTests(ctx, g.workDir, plan.SourceFile); err != nil {
+		_ = os.Remove(path)
+		return
+	}
+	result.PropertyFile = propertyFile
+
+	cmd := exec.CommandContext(ctx, "go", "test", "-count=1", "-run", "Properties$", "./"+filepath.ToSlash(filepath.Dir(plan.SourceFile)))
+	cmd.Dir = g.workDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		result.PropertyFailure = truncate(string(out), 3000)
+	}
+}
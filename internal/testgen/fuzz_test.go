@@ -0,0 +1,231 @@
+package testgen
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var codecFiles = map[string]string{
+	"go.mod": "module demo\n\ngo 1.21\n",
+	"codec/codec.go": `package codec
+
+import (
+	"os"
+	"strconv"
+)
+
+type Name string
+
+func Encode(n int) string { return strconv.Itoa(n) }
+
+func Decode(s string) (int, error) { return strconv.Atoi(s) }
+
+func Greet(name Name, loud bool) string {
+	if loud {
+		return "HELLO " + string(name)
+	}
+	return "hello " + string(name)
+}
+
+func First(s string) byte { return s[0] }
+
+func Save(path string) error { return os.WriteFile(path, nil, 0644) }
+
+func saveTwice(path string) error {
+	if err := Save(path); err != nil {
+		return err
+	}
+	return Save(path)
+}
+
+var calls int
+
+func Count(s string) int {
+	calls++
+	return len(s)
+}
+`,
+	"codec/codec_test.go": `package codec
+
+import "testing"
+
+func TestGreet(t *testing.T) {
+	tests := []struct {
+		in   Name
+		loud bool
+		want string
+	}{
+		{in: "ann", loud: true, want: "HELLO ann"},
+		{in: "bob", want: "hello bob"},
+	}
+	for _, tt := range tests {
+		if got := Greet(tt.in, tt.loud); got != tt.want {
+			t.Errorf("got %q", got)
+		}
+	}
+}
+
+func TestFirst(t *testing.T) {
+	if First("x") != 'x' {
+		t.Fail()
+	}
+}
+`,
+}
+
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFindFuzzCandidates(t *testing.T) {
+	dir := writeModule(t, codecFiles)
+	plan, err := FindFuzzCandidates(dir, "codec/codec.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range plan.Candidates {
+		got = append(got, fmt.Sprintf("%s pure=%v seeds=%v", c.Name, c.Pure, c.Seeds))
+	}
+	want := []string{
+		"Encode pure=true seeds=[]",
+		"Decode pure=true seeds=[]",
+		`Greet pure=true seeds=[["ann" true]]`,
+		`First pure=true seeds=[["x"]]`,
+		"Count pure=false seeds=[]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected candidates (I/O functions must be excluded):\n%s", strings.Join(got, "\n"))
+	}
+
+	greet := plan.Candidates[2]
+	if greet.Params[0].FuzzType != "string" || greet.Params[0].Type != "Name" {
+		t.Errorf("expected named type to fuzz as its underlying type, got %+v", greet.Params[0])
+	}
+
+	if len(plan.RoundTrips) != 1 || plan.RoundTrips[0].Encode.Name != "Encode" || !plan.RoundTrips[0].DecodeErr {
+		t.Errorf("expected Encode/Decode round trip, got %+v", plan.RoundTrips)
+	}
+}
+
+func TestFuzzGeneratorFindsCrashers(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	if testing.Short() {
+		t.Skip("fuzzing is slow")
+	}
+	dir := writeModule(t, codecFiles)
+	ctx := context.Background()
+
+	result, err := NewFuzzGenerator(nil, "", dir).Generate(ctx, "codec/codec.go", FuzzOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Fatalf("generated tests do not compile: %v", result.Error)
+	}
+	if fmt.Sprint(result.Targets) != "[FuzzEncode FuzzDecode FuzzGreet FuzzFirst FuzzCount FuzzEncodeDecode]" {
+		t.Errorf("unexpected targets: %v", result.Targets)
+	}
+
+	crasher := runFuzz(ctx, dir, "codec/codec.go", "FuzzFirst", 20*time.Second)
+	if crasher == nil || len(crasher.Inputs) != 1 {
+		t.Fatalf("expected First to crash on some input, got %+v", crasher)
+	}
+
+	plan, _ := FindFuzzCandidates(dir, "codec/codec.go")
+	code, _, err := renderFuzzFile(plan, filepath.Join(dir, "codec"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	regression := fmt.Sprintf("fuzzCheckFirst(t, %s)", crasher.Inputs[0])
+	if !strings.Contains(string(code), "func TestFuzzFirstRegressions(t *testing.T)") || !strings.Contains(string(code), regression) {
+		t.Errorf("expected regression test for the crasher:\n%s", code)
+	}
+}
+
+func TestFuzzGeneratorRemovesTestsThatDoNotCompile(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := writeModule(t, map[string]string{
+		"go.mod":         "module demo\n\ngo 1.21\n",
+		"codec/codec.go": "package codec\n\nfunc Upper(s string) string {\n\treturn missing(s)\n}\n",
+	})
+
+	result, err := NewFuzzGenerator(nil, "", dir).Generate(context.Background(), "codec/codec.go", FuzzOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid || result.Error == nil {
+		t.Fatalf("expected tests for a package that doesn't build to be rejected, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, result.TestFile)); !os.IsNotExist(err) {
+		t.Errorf("expected %s removed, got %v", result.TestFile, err)
+	}
+}
+
+func TestFuzzGeneratorImportsParameterTypes(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := writeModule(t, map[string]string{
+		"go.mod":       "module demo\n\ngo 1.21\n",
+		"wait/wait.go": "package wait\n\nimport \"time\"\n\nfunc Double(time time.Duration) int64 {\n\treturn int64(2 * time)\n}\n\nfunc Pad(s string) string {\n\treturn s + \" \"\n}\n",
+	})
+
+	result, err := NewFuzzGenerator(nil, "", dir).Generate(context.Background(), "wait/wait.go", FuzzOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Fatalf("expected tests taking a time.Duration to compile: %v", result.Error)
+	}
+	code, _ := os.ReadFile(filepath.Join(dir, result.TestFile))
+	if !strings.Contains(string(code), `"time"`) || !strings.Contains(string(code), "Double(time.Duration(arg0))") {
+		t.Errorf("expected time imported and the parameter renamed:\n%s", code)
+	}
+}
+
+func TestFuzzGeneratorKeepsExistingPropertyTests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	handWritten := "package codec\n\n// hand-written\n"
+	files := map[string]string{"codec/codec_property_test.go": handWritten}
+	for name, content := range codecFiles {
+		files[name] = content
+	}
+	dir := writeModule(t, files)
+	provider := &cannedProvider{reply: "package codec\n\nthis does not compile\n"}
+
+	result, err := NewFuzzGenerator(provider, "", dir).Generate(context.Background(), "codec/codec.go", FuzzOptions{Properties: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PropertyKept != "codec/codec_property_test.go" || len(provider.prompts) != 0 {
+		t.Errorf("expected the existing property tests kept without asking the model, got %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "codec", "codec_property_test.go")); string(data) != handWritten {
+		t.Errorf("expected the hand-written file untouched, got %q", data)
+	}
+}
@@ -26,6 +26,8 @@ type GenerateResult struct {
 	SourceFile  string
 	Valid       bool
 	Error       error
+	// Mutation is the latest mutation testing report, when one was run
+	Mutation *MutationReport
 }
 
 func NewTestGenerator(provider llm.Provider, model, workDir string) (*TestGenerator, error) {
@@ -191,3 +193,107 @@ func (tg *TestGenerator) cleanTestCode(code string) string {
 
 	return strings.Join(cleaned, "\n")
 }
+
+// MutationOptions controls GenerateWithMutationScore
+type MutationOptions struct {
+	// Threshold is the mutation score to reach, between 0 and 1
+	Threshold float64
+	// MaxRounds bounds how many times the tests are strengthened
+	MaxRounds int
+	// MaxMutants caps the mutants tried per round
+	MaxMutants int
+	// OnRound is called after each mutation run
+	OnRound func(round int, report *MutationReport)
+}
+
+// GenerateWithMutationScore generates tests, then feeds surviving mutants
+// back to the model until the tests kill enough of them or the round budget
+// runs out. Rounds whose tests don't compile or pass are discarded.
+func (tg *TestGenerator) GenerateWithMutationScore(ctx context.Context, sourceFile string, opts MutationOptions) (*GenerateResult, error) {
+	result, err := tg.GenerateUnitTests(ctx, sourceFile)
+	if err != nil || !result.Valid {
+		return result, err
+	}
+
+	tester, err := NewMutationTester(tg.workDir, tg.language)
+	if err != nil {
+		return result, err
+	}
+	if opts.MaxMutants > 0 {
+		tester.MaxMutants = opts.MaxMutants
+	}
+
+	testPath := filepath.Join(tg.workDir, result.TestFile)
+	for round := 0; ; round++ {
+		report, err := tester.Run(ctx, sourceFile)
+		if err != nil {
+			if round == 0 {
+				result.Error = fmt.Errorf("mutation testing failed: %w", err)
+				return result, nil
+			}
+			// The strengthened tests are worse; keep the last good ones
+			_ = os.WriteFile(testPath, []byte(result.TestContent), 0644)
+			return result, nil
+		}
+
+		if round > 0 {
+			content, _ := os.ReadFile(testPath)
+			result.TestContent = string(content)
+		}
+		result.Mutation = report
+		if opts.OnRound != nil {
+			opts.OnRound(round, report)
+		}
+
+		if report.Score() >= opts.Threshold || round >= opts.MaxRounds || ctx.Err() != nil {
+			return result, nil
+		}
+
+		if err := tg.strengthenTests(ctx, result, report.Survivors()); err != nil {
+			_ = os.WriteFile(testPath, []byte(result.TestContent), 0644)
+			return result, nil
+		}
+	}
+}
+
+// strengthenTests asks the model to rewrite the tests so they fail on the
+// surviving mutants, and writes the new version if it compiles
+func (tg *TestGenerator) strengthenTests(ctx context.Context, result *GenerateResult, survivors []Mutant) error {
+	source, err := os.ReadFile(filepath.Join(tg.workDir, result.SourceFile))
+	if err != nil {
+		return fmt.Errorf("failed to read source file: %w", err)
+	}
+
+	prompt := fmt.Sprintf(`These tests pass, but they also pass when the source is changed in ways that alter
+its behavior. Strengthen the assertions so every change below makes at least one test fail.
+
+Source file: %s
+%s
+
+Current tests (%s):
+%s
+
+Changes the tests do not detect:
+%s
+Keep all existing tests that are still correct. Assert exact return values, boundary cases
+and error results rather than only checking for no error.
+
+Generate ONLY the complete updated test file content.`,
+		result.SourceFile, source, result.TestFile, result.TestContent, describeSurvivors(survivors))
+
+	response, err := tg.queryAgent.Execute(ctx, []llm.ChatMessage{
+		{Role: "user", Content: prompt},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("LLM failed to strengthen tests: %w", err)
+	}
+
+	testCode := tg.cleanTestCode(extractCode(response))
+	if err := os.WriteFile(filepath.Join(tg.workDir, result.TestFile), []byte(testCode), 0644); err != nil {
+		return fmt.Errorf("failed to write test file: %w", err)
+	}
+	if !NewValidator(tg.workDir, tg.language).Validate(result.TestFile) {
+		return fmt.Errorf("strengthened test does not compile")
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package testgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gptcode/internal/langdetect"
+	"gptcode/internal/testreport"
+)
+
+// Mutant is a single small change to a source file. Tests that still pass
+// with it applied don't check the behavior it changes.
+type Mutant struct {
+	File        string
+	Line        int
+	Operator    string
+	Offset      int
+	End         int
+	Original    string
+	Replacement string
+}
+
+func (m Mutant) String() string {
+	return fmt.Sprintf("%s:%d %s: %s -> %s", m.File, m.Line, m.Operator, m.Original, m.Replacement)
+}
+
+// Apply returns src with the mutant's byte range replaced
+func (m Mutant) Apply(src []byte) ([]byte, error) {
+	if m.Offset < 0 || m.End > len(src) || m.Offset > m.End || string(src[m.Offset:m.End]) != m.Original {
+		return nil, fmt.Errorf("mutant %s does not match the source", m)
+	}
+	out := make([]byte, 0, len(src)-len(m.Original)+len(m.Replacement))
+	out = append(out, src[:m.Offset]...)
+	out = append(out, m.Replacement...)
+	return append(out, src[m.End:]...), nil
+}
+
+// Mutator generates mutants for one language and knows how to run the tests
+// that cover a source file. When mutantFile is set it holds the mutated
+// source, which the tests must see in place of sourceFile without the
+// working tree being written to.
+type Mutator interface {
+	Mutants(file string, src []byte) ([]Mutant, error)
+	TestCommand(sourceFile, mutantFile string) (format testreport.Format, name string, args []string, err error)
+}
+
+var (
+	mutatorsMu sync.RWMutex
+	mutators   = map[langdetect.Language]Mutator{
+		langdetect.Go: GoMutator{},
+	}
+)
+
+// RegisterMutator adds or replaces the mutator for a language
+func RegisterMutator(lang langdetect.Language, m Mutator) {
+	mutatorsMu.Lock()
+	defer mutatorsMu.Unlock()
+	mutators[lang] = m
+}
+
+// MutatorFor returns the mutator registered for a language
+func MutatorFor(lang langdetect.Language) (Mutator, bool) {
+	mutatorsMu.RLock()
+	defer mutatorsMu.RUnlock()
+	m, ok := mutators[lang]
+	return m, ok
+}
+
+// GoMutator mutates Go source using go/ast: arithmetic, comparison and
+// logical operators, if conditions, and returned values
+type GoMutator struct{}
+
+var goOperatorSwaps = map[token.Token]token.Token{
+	token.ADD:  token.SUB,
+	token.SUB:  token.ADD,
+	token.MUL:  token.QUO,
+	token.QUO:  token.MUL,
+	token.REM:  token.MUL,
+	token.LSS:  token.LEQ,
+	token.LEQ:  token.LSS,
+	token.GTR:  token.GEQ,
+	token.GEQ:  token.GTR,
+	token.EQL:  token.NEQ,
+	token.NEQ:  token.EQL,
+	token.LAND: token.LOR,
+	token.LOR:  token.LAND,
+}
+
+func (GoMutator) Mutants(file string, src []byte) ([]Mutant, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	var mutants []Mutant
+	add := func(operator string, pos, end token.Pos, replacement string) {
+		start, stop := fset.Position(pos).Offset, fset.Position(end).Offset
+		mutants = append(mutants, Mutant{
+			File:        file,
+			Line:        fset.Position(pos).Line,
+			Operator:    operator,
+			Offset:      start,
+			End:         stop,
+			Original:    string(src[start:stop]),
+			Replacement: replacement,
+		})
+	}
+
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BinaryExpr:
+				swap, ok := goOperatorSwaps[n.Op]
+				if !ok || (n.Op == token.ADD && (isStringLit(n.X) || isStringLit(n.Y))) {
+					return true
+				}
+				kind := "operator"
+				if n.Op == token.LAND || n.Op == token.LOR {
+					kind = "condition"
+				}
+				add(kind, n.OpPos, n.OpPos+token.Pos(len(n.Op.String())), swap.String())
+			case *ast.IfStmt:
+				cond := string(src[fset.Position(n.Cond.Pos()).Offset:fset.Position(n.Cond.End()).Offset])
+				add("condition", n.Cond.Pos(), n.Cond.End(), "!("+cond+")")
+			case *ast.ReturnStmt:
+				for _, result := range n.Results {
+					if replacement, ok := mutatedReturn(result); ok {
+						add("return", result.Pos(), result.End(), replacement)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	sort.Slice(mutants, func(i, j int) bool { return mutants[i].Offset < mutants[j].Offset })
+	return mutants, nil
+}
+
+func isStringLit(e ast.Expr) bool {
+	lit, ok := e.(*ast.BasicLit)
+	return ok && lit.Kind == token.STRING
+}
+
+// mutatedReturn picks a replacement for a returned value whose type is
+// clear from the syntax alone
+func mutatedReturn(e ast.Expr) (string, bool) {
+	switch e := e.(type) {
+	case *ast.Ident:
+		switch e.Name {
+		case "true":
+			return "false", true
+		case "false":
+			return "true", true
+		case "err":
+			return "nil", true
+		}
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.INT, token.FLOAT:
+			if e.Value == "0" {
+				return "1", true
+			}
+			return "0", true
+		case token.STRING:
+			if e.Value != `""` && e.Value != "``" {
+				return `""`, true
+			}
+		}
+	}
+	return "", false
+}
+
+// TestCommand swaps the mutant in with go test -overlay, so the real source
+// file is never touched
+func (GoMutator) TestCommand(sourceFile, mutantFile string) (testreport.Format, string, []string, error) {
+	args := []string{"test", "-count=1", "-failfast"}
+	if mutantFile != "" {
+		overlay, err := json.Marshal(map[string]map[string]string{
+			"Replace": {sourceFile: mutantFile},
+		})
+		if err != nil {
+			return "", "", nil, fmt.Errorf("failed to encode overlay: %w", err)
+		}
+		overlayFile := mutantFile + ".overlay.json"
+		if err := os.WriteFile(overlayFile, overlay, 0644); err != nil {
+			return "", "", nil, fmt.Errorf("failed to write overlay: %w", err)
+		}
+		args = append(args, "-overlay="+overlayFile)
+	}
+	args = append(args, "./"+filepath.ToSlash(filepath.Dir(sourceFile)))
+	return testreport.FormatGoJSON, "go", args, nil
+}
+
+// MutantStatus is the outcome of running the tests against a mutant
+type MutantStatus string
+
+const (
+	MutantKilled   MutantStatus = "killed"
+	MutantSurvived MutantStatus = "survived"
+	MutantTimeout  MutantStatus = "timeout"
+	// MutantInvalid means the mutant didn't compile; it doesn't count
+	MutantInvalid MutantStatus = "invalid"
+)
+
+// MutantResult pairs a mutant with its outcome
+type MutantResult struct {
+	Mutant Mutant
+	Status MutantStatus
+}
+
+// MutationReport summarizes a mutation testing run
+type MutationReport struct {
+	SourceFile string
+	Results    []MutantResult
+}
+
+func (r *MutationReport) count(status MutantStatus) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Killed counts mutants the tests detected, including by timing out
+func (r *MutationReport) Killed() int {
+	return r.count(MutantKilled) + r.count(MutantTimeout)
+}
+
+func (r *MutationReport) Survived() int { return r.count(MutantSurvived) }
+func (r *MutationReport) Invalid() int  { return r.count(MutantInvalid) }
+
+// Score is the fraction of valid mutants killed. With no valid mutants
+// there is nothing the tests could miss, so the score is 1.
+func (r *MutationReport) Score() float64 {
+	valid := r.Killed() + r.Survived()
+	if valid == 0 {
+		return 1
+	}
+	return float64(r.Killed()) / float64(valid)
+}
+
+// Survivors returns the mutants the tests didn't detect
+func (r *MutationReport) Survivors() []Mutant {
+	var out []Mutant
+	for _, res := range r.Results {
+		if res.Status == MutantSurvived {
+			out = append(out, res.Mutant)
+		}
+	}
+	return out
+}
+
+func (r *MutationReport) Summary() string {
+	s := fmt.Sprintf("%.0f%% (%d/%d killed", r.Score()*100, r.Killed(), r.Killed()+r.Survived())
+	if n := r.Invalid(); n > 0 {
+		s += fmt.Sprintf(", %d invalid", n)
+	}
+	return s + ")"
+}
+
+// ErrBaselineFailing means the tests fail before any mutation, so a score
+// would be meaningless
+var ErrBaselineFailing = errors.New("tests fail on the unmutated source")
+
+// MutationTester applies mutants to a source file one at a time and runs
+// its tests against each
+type MutationTester struct {
+	WorkDir    string
+	Mutator    Mutator
+	MaxMutants int
+	// Timeout bounds each test run; zero derives it from the baseline run
+	Timeout time.Duration
+}
+
+// NewMutationTester creates a tester for the project's language
+func NewMutationTester(workDir string, lang langdetect.Language) (*MutationTester, error) {
+	mutator, ok := MutatorFor(lang)
+	if !ok {
+		return nil, fmt.Errorf("mutation testing is not supported for %s", lang)
+	}
+	return &MutationTester{WorkDir: workDir, Mutator: mutator, MaxMutants: 50}, nil
+}
+
+// Run mutates sourceFile and reports which mutants its tests kill. Mutants
+// are written to a temporary directory, never over the source file, so an
+// interrupted run can't leave mutated code behind.
+func (mt *MutationTester) Run(ctx context.Context, sourceFile string) (*MutationReport, error) {
+	original, err := os.ReadFile(filepath.Join(mt.WorkDir, sourceFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+
+	mutants, err := mt.Mutator.Mutants(sourceFile, original)
+	if err != nil {
+		return nil, err
+	}
+	mutants = sampleMutants(mutants, mt.MaxMutants)
+
+	start := time.Now()
+	if status := mt.runTests(ctx, sourceFile, "", 0); status != MutantSurvived {
+		return nil, ErrBaselineFailing
+	}
+	timeout := mt.Timeout
+	if timeout == 0 {
+		timeout = 10*time.Second + 3*time.Since(start)
+	}
+
+	tmp, err := os.MkdirTemp("", "gptcode-mutant-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mutant directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	mutantFile := filepath.Join(tmp, filepath.Base(sourceFile))
+
+	report := &MutationReport{SourceFile: sourceFile}
+	for _, m := range mutants {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		mutated, err := m.Apply(original)
+		if err != nil {
+			return report, err
+		}
+		if err := os.WriteFile(mutantFile, mutated, 0644); err != nil {
+			return report, fmt.Errorf("failed to write mutant: %w", err)
+		}
+		report.Results = append(report.Results, MutantResult{Mutant: m, Status: mt.runTests(ctx, sourceFile, mutantFile, timeout)})
+	}
+	return report, nil
+}
+
+// runTests reports MutantSurvived when the tests pass
+func (mt *MutationTester) runTests(ctx context.Context, sourceFile, mutantFile string, timeout time.Duration) MutantStatus {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	format, name, args, err := mt.Mutator.TestCommand(sourceFile, mutantFile)
+	if err != nil {
+		return MutantInvalid
+	}
+	report, _, err := testreport.Run(ctx, mt.WorkDir, format, name, args...)
+	switch {
+	case err == nil:
+		return MutantSurvived
+	case ctx.Err() == context.DeadlineExceeded:
+		return MutantTimeout
+	case report == nil:
+		return MutantKilled
+	}
+	for _, tc := range report.Failures() {
+		if tc.Name != testreport.SetupFailure {
+			return MutantKilled
+		}
+	}
+	return MutantInvalid
+}
+
+// sampleMutants keeps at most max mutants, spread evenly over the file
+func sampleMutants(mutants []Mutant, max int) []Mutant {
+	if max <= 0 || len(mutants) <= max {
+		return mutants
+	}
+	out := make([]Mutant, 0, max)
+	for i := 0; i < max; i++ {
+		out = append(out, mutants[i*len(mutants)/max])
+	}
+	return out
+}
+
+// describeSurvivors formats surviving mutants for the generator prompt
+func describeSurvivors(survivors []Mutant) string {
+	var sb strings.Builder
+	for _, m := range survivors {
+		fmt.Fprintf(&sb, "- line %d (%s): `%s` changed to `%s`\n", m.Line, m.Operator, m.Original, m.Replacement)
+	}
+	return sb.String()
+}
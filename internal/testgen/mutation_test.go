@@ -0,0 +1,119 @@
+package testgen
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/langdetect"
+)
+
+const calcSource = `package calc
+
+func Clamp(x, max int) int {
+	if x > max {
+		return max
+	}
+	return x
+}
+
+func IsEven(n int) bool {
+	return n%2 == 0
+}
+
+func Greeting() string {
+	return "hello" + "!"
+}
+`
+
+func TestGoMutatorMutants(t *testing.T) {
+	mutants, err := GoMutator{}.Mutants("calc.go", []byte(calcSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, m := range mutants {
+		got = append(got, m.String())
+		if _, err := m.Apply([]byte(calcSource)); err != nil {
+			t.Errorf("mutant does not apply: %v", err)
+		}
+	}
+	want := []string{
+		"calc.go:4 condition: x > max -> !(x > max)",
+		"calc.go:4 operator: > -> >=",
+		"calc.go:11 operator: % -> *",
+		"calc.go:11 operator: == -> !=",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected mutants:\n%s", strings.Join(got, "\n"))
+	}
+
+	mutated, _ := mutants[0].Apply([]byte(calcSource))
+	if !strings.Contains(string(mutated), "if !(x > max) {") {
+		t.Errorf("unexpected mutated source:\n%s", mutated)
+	}
+}
+
+func TestMutatedReturn(t *testing.T) {
+	src := "package p\n\nfunc f() (int, bool, string, error) {\n\tvar err error\n\treturn 3, true, \"x\", err\n}\n"
+	mutants, err := GoMutator{}.Mutants("p.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range mutants {
+		got = append(got, m.Original+"->"+m.Replacement)
+	}
+	if strings.Join(got, " ") != `3->0 true->false "x"->"" err->nil` {
+		t.Errorf("unexpected return mutants: %v", got)
+	}
+}
+
+func TestMutationTesterScoresTests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module demo\n\ngo 1.21\n",
+		"calc.go": calcSource,
+		// Misses the Clamp boundary
+		"calc_test.go": "package calc\n\nimport \"testing\"\n\nfunc TestCalc(t *testing.T) {\n\tif Clamp(5, 3) != 3 || Clamp(1, 3) != 1 {\n\t\tt.Error(\"Clamp\")\n\t}\n\tif !IsEven(4) {\n\t\tt.Error(\"IsEven\")\n\t}\n}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before, _ := os.Stat(filepath.Join(dir, "calc.go"))
+
+	tester, err := NewMutationTester(dir, langdetect.Go)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := tester.Run(context.Background(), "calc.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Killed() != 3 || report.Survived() != 1 || report.Score() != 0.75 {
+		t.Fatalf("unexpected score %s", report.Summary())
+	}
+	if s := report.Survivors(); len(s) != 1 || s[0].Original != ">" || s[0].Replacement != ">=" {
+		t.Errorf("unexpected survivors: %v", s)
+	}
+
+	after, _ := os.Stat(filepath.Join(dir, "calc.go"))
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Error("expected the source file never to be written")
+	}
+	unchanged, _ := os.ReadFile(filepath.Join(dir, "calc.go"))
+	if string(unchanged) != calcSource {
+		t.Error("expected the source file unchanged")
+	}
+}
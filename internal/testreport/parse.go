@@ -0,0 +1,542 @@
+package testreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+var (
+	goLocationPattern = regexp.MustCompile(`(?m)^\s*([\w.\-/]+\.go):(\d+):`)
+	goNoisePrefixes   = []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "--- FAIL", "--- PASS", "--- SKIP"}
+)
+
+// ParseGoTestJSON parses `go test -json` output. It also returns the plain
+// text the run would have printed without -json, including any lines that
+// were not JSON events (build errors go to stderr).
+func ParseGoTestJSON(data []byte) (*TestReport, string) {
+	report := &TestReport{Runner: "go"}
+	var text strings.Builder
+	outputs := make(map[string]*strings.Builder)
+	failedPkgs := make(map[string]float64)
+	var pkgOrder []string
+	var stray strings.Builder
+
+	key := func(pkg, test string) string { return pkg + "\x00" + test }
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var ev goTestEvent
+		if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &ev) != nil {
+			text.Write(line)
+			text.WriteByte('\n')
+			stray.Write(line)
+			stray.WriteByte('\n')
+			continue
+		}
+
+		switch ev.Action {
+		case "output", "build-output":
+			text.WriteString(ev.Output)
+			if ev.Action == "build-output" {
+				stray.WriteString(ev.Output)
+				continue
+			}
+			k := key(ev.Package, ev.Test)
+			if outputs[k] == nil {
+				outputs[k] = &strings.Builder{}
+			}
+			outputs[k].WriteString(ev.Output)
+		case "pass", "fail", "skip":
+			if ev.Test == "" {
+				report.Duration += time.Duration(ev.Elapsed * float64(time.Second))
+				if ev.Action == "fail" {
+					failedPkgs[ev.Package] = ev.Elapsed
+					pkgOrder = append(pkgOrder, ev.Package)
+				}
+				continue
+			}
+			tc := TestCase{
+				Name:     ev.Test,
+				Suite:    ev.Package,
+				Status:   goStatus(ev.Action),
+				Duration: time.Duration(ev.Elapsed * float64(time.Second)),
+			}
+			if tc.Status == StatusFail {
+				if out := outputs[key(ev.Package, ev.Test)]; out != nil {
+					tc.Message = cleanGoOutput(out.String())
+					if m := goLocationPattern.FindStringSubmatch(tc.Message); m != nil {
+						tc.File = m[1]
+						tc.Line, _ = strconv.Atoi(m[2])
+					}
+				}
+			}
+			report.Tests = append(report.Tests, tc)
+		}
+	}
+
+	report.Tests = dropEmptyParents(report.Tests)
+
+	// A package that failed without a failing test didn't build or crashed
+	// outside a test; report it as a single failure carrying its output.
+	for _, pkg := range pkgOrder {
+		hasFailure := false
+		for _, tc := range report.Tests {
+			if tc.Suite == pkg && tc.Status == StatusFail {
+				hasFailure = true
+				break
+			}
+		}
+		if hasFailure {
+			continue
+		}
+		msg := stray.String()
+		if out := outputs[key(pkg, "")]; out != nil {
+			msg += out.String()
+		}
+		tc := TestCase{
+			Name:     SetupFailure,
+			Suite:    pkg,
+			Status:   StatusFail,
+			Duration: time.Duration(failedPkgs[pkg] * float64(time.Second)),
+			Message:  strings.TrimSpace(msg),
+		}
+		if m := goLocationPattern.FindStringSubmatch(tc.Message); m != nil {
+			tc.File = m[1]
+			tc.Line, _ = strconv.Atoi(m[2])
+		}
+		report.Tests = append(report.Tests, tc)
+	}
+
+	return report, text.String()
+}
+
+func goStatus(action string) Status {
+	switch action {
+	case "pass":
+		return StatusPass
+	case "skip":
+		return StatusSkip
+	default:
+		return StatusFail
+	}
+}
+
+// cleanGoOutput drops the === RUN / --- FAIL framing and the indentation
+// go test adds to t.Log output
+func cleanGoOutput(out string) string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		noise := trimmed == ""
+		for _, p := range goNoisePrefixes {
+			if strings.HasPrefix(trimmed, p) {
+				noise = true
+				break
+			}
+		}
+		if !noise {
+			lines = append(lines, line)
+		}
+	}
+	return dedent(lines)
+}
+
+func dedent(lines []string) string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[i] = line[indent:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dropEmptyParents removes failing parent tests that only failed because a
+// subtest did, so each failure is reported once, where it happened.
+func dropEmptyParents(tests []TestCase) []TestCase {
+	var kept []TestCase
+	for _, tc := range tests {
+		if tc.Status == StatusFail && strings.TrimSpace(tc.Message) == "" {
+			hasFailingChild := false
+			for _, other := range tests {
+				if other.Suite == tc.Suite && other.Status == StatusFail && strings.HasPrefix(other.Name, tc.Name+"/") {
+					hasFailingChild = true
+					break
+				}
+			}
+			if hasFailingChild {
+				continue
+			}
+		}
+		kept = append(kept, tc)
+	}
+	return kept
+}
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	File   string       `xml:"file,attr"`
+	Time   string       `xml:"time,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      string        `xml:"line,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *junitProblem `xml:"skipped"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+var tracebackLocationPattern = regexp.MustCompile(`(?m)^([\w.\-/]+\.\w+):(\d+):`)
+
+// ParseJUnitXML parses a JUnit XML report, as written by pytest --junitxml
+// and most other runners. The root may be <testsuites> or a single <testsuite>.
+func ParseJUnitXML(data []byte) (*TestReport, error) {
+	var root junitSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse JUnit XML: %w", err)
+	}
+
+	report := &TestReport{Runner: "junit"}
+	var walk func(s junitSuite)
+	walk = func(s junitSuite) {
+		for _, c := range s.Cases {
+			report.Tests = append(report.Tests, junitTestCase(s, c))
+		}
+		for _, child := range s.Suites {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	for _, tc := range report.Tests {
+		report.Duration += tc.Duration
+	}
+	return report, nil
+}
+
+func junitTestCase(s junitSuite, c junitCase) TestCase {
+	tc := TestCase{
+		Name:     c.Name,
+		Suite:    c.Classname,
+		File:     c.File,
+		Status:   StatusPass,
+		Duration: parseSeconds(c.Time),
+	}
+	if tc.Suite == "" {
+		tc.Suite = s.Name
+	}
+	if tc.File == "" {
+		tc.File = s.File
+	}
+	tc.Line, _ = strconv.Atoi(c.Line)
+
+	problem := c.Failure
+	if problem == nil {
+		problem = c.Error
+	}
+	switch {
+	case problem != nil:
+		tc.Status = StatusFail
+		tc.Message = strings.TrimSpace(strings.TrimSpace(problem.Message) + "\n" + strings.TrimSpace(problem.Text))
+		if file, line := lastLocation(problem.Text, tc.File); line > 0 {
+			tc.File, tc.Line = file, line
+		}
+	case c.Skipped != nil:
+		tc.Status = StatusSkip
+		tc.Message = strings.TrimSpace(c.Skipped.Message)
+	}
+	return tc
+}
+
+// lastLocation finds the innermost file:line in a traceback, preferring
+// frames in the test's own file when it is known
+func lastLocation(text, file string) (string, int) {
+	matches := tracebackLocationPattern.FindAllStringSubmatch(text, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		if file != "" && matches[i][1] != file {
+			continue
+		}
+		line, _ := strconv.Atoi(matches[i][2])
+		return matches[i][1], line
+	}
+	return file, 0
+}
+
+func parseSeconds(s string) time.Duration {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(f * float64(time.Second))
+}
+
+type jestOutput struct {
+	TestResults []struct {
+		Name             string `json:"name"`
+		Status           string `json:"status"`
+		Message          string `json:"message"`
+		StartTime        int64  `json:"startTime"`
+		EndTime          int64  `json:"endTime"`
+		AssertionResults []struct {
+			AncestorTitles  []string `json:"ancestorTitles"`
+			Title           string   `json:"title"`
+			Status          string   `json:"status"`
+			Duration        *float64 `json:"duration"`
+			FailureMessages []string `json:"failureMessages"`
+			Location        *struct {
+				Line int `json:"line"`
+			} `json:"location"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+var jsStackPattern = regexp.MustCompile(`\(?([^\s()]+\.[cm]?[jt]sx?):(\d+):\d+\)?`)
+
+// ParseJestJSON parses the report written by jest --json --outputFile
+func ParseJestJSON(data []byte) (*TestReport, error) {
+	var out jestOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse jest JSON: %w", err)
+	}
+
+	report := &TestReport{Runner: "jest"}
+	for _, file := range out.TestResults {
+		if file.EndTime > file.StartTime {
+			report.Duration += time.Duration(file.EndTime-file.StartTime) * time.Millisecond
+		}
+
+		// A file that failed to load has no assertions, only a message
+		if len(file.AssertionResults) == 0 && file.Status == "failed" {
+			report.Tests = append(report.Tests, TestCase{
+				Name:    SetupFailure,
+				File:    file.Name,
+				Status:  StatusFail,
+				Message: strings.TrimSpace(file.Message),
+			})
+			continue
+		}
+
+		for _, a := range file.AssertionResults {
+			tc := TestCase{
+				Name:  a.Title,
+				Suite: strings.Join(a.AncestorTitles, " › "),
+				File:  file.Name,
+			}
+			if a.Duration != nil {
+				tc.Duration = time.Duration(*a.Duration * float64(time.Millisecond))
+			}
+			if a.Location != nil {
+				tc.Line = a.Location.Line
+			}
+			switch a.Status {
+			case "passed":
+				tc.Status = StatusPass
+			case "failed":
+				tc.Status = StatusFail
+				tc.Message = strings.TrimSpace(strings.Join(a.FailureMessages, "\n"))
+				if tc.Line == 0 {
+					tc.Line = stackLine(tc.Message, file.Name)
+				}
+			default:
+				tc.Status = StatusSkip
+			}
+			report.Tests = append(report.Tests, tc)
+		}
+	}
+	return report, nil
+}
+
+// stackLine returns the first line number of a stack frame in file
+func stackLine(message, file string) int {
+	for _, m := range jsStackPattern.FindAllStringSubmatch(message, -1) {
+		if m[1] == file || strings.HasSuffix(file, "/"+strings.TrimPrefix(m[1], "./")) {
+			line, _ := strconv.Atoi(m[2])
+			return line
+		}
+	}
+	return 0
+}
+
+type rspecOutput struct {
+	Examples []struct {
+		Description     string  `json:"description"`
+		FullDescription string  `json:"full_description"`
+		Status          string  `json:"status"`
+		FilePath        string  `json:"file_path"`
+		LineNumber      int     `json:"line_number"`
+		RunTime         float64 `json:"run_time"`
+		PendingMessage  string  `json:"pending_message"`
+		Exception       *struct {
+			Class     string   `json:"class"`
+			Message   string   `json:"message"`
+			Backtrace []string `json:"backtrace"`
+		} `json:"exception"`
+	} `json:"examples"`
+	Summary struct {
+		Duration float64 `json:"duration"`
+	} `json:"summary"`
+}
+
+// ParseRSpecJSON parses the report written by rspec --format json
+func ParseRSpecJSON(data []byte) (*TestReport, error) {
+	var out rspecOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse rspec JSON: %w", err)
+	}
+
+	report := &TestReport{
+		Runner:   "rspec",
+		Duration: time.Duration(out.Summary.Duration * float64(time.Second)),
+	}
+	for _, ex := range out.Examples {
+		tc := TestCase{
+			Name:     ex.FullDescription,
+			File:     strings.TrimPrefix(ex.FilePath, "./"),
+			Line:     ex.LineNumber,
+			Duration: time.Duration(ex.RunTime * float64(time.Second)),
+		}
+		if tc.Name == "" {
+			tc.Name = ex.Description
+		}
+		switch ex.Status {
+		case "passed":
+			tc.Status = StatusPass
+		case "failed":
+			tc.Status = StatusFail
+			if ex.Exception != nil {
+				msg := ex.Exception.Class + ": " + ex.Exception.Message
+				if len(ex.Exception.Backtrace) > 0 {
+					msg += "\n" + strings.Join(ex.Exception.Backtrace[:min(len(ex.Exception.Backtrace), 5)], "\n")
+				}
+				tc.Message = msg
+			}
+		default:
+			tc.Status = StatusSkip
+			tc.Message = ex.PendingMessage
+		}
+		report.Tests = append(report.Tests, tc)
+	}
+	return report, nil
+}
+
+var (
+	exunitModulePattern  = regexp.MustCompile(`^(\S.*) \[(\S+\.exs)\]$`)
+	exunitTracePattern   = regexp.MustCompile(`^\s+\* (?:test|doctest|property|feature) (.+?) \((?:([\d.]+)(ms|s)|(excluded|skipped|invalid))\) \[L#(\d+)\]$`)
+	exunitFailurePattern = regexp.MustCompile(`^\s+\d+\) (?:test|doctest|property|feature) (.+) \((\S+)\)$`)
+	exunitLocPattern     = regexp.MustCompile(`^\s+(\S+\.exs?):(\d+)$`)
+	exunitFinishPattern  = regexp.MustCompile(`^Finished in ([\d.]+) seconds`)
+)
+
+// ParseExUnit parses `mix test --trace` output. ExUnit has no built-in
+// machine-readable formatter, but with --trace the CLI formatter prints one
+// line per test and a numbered block per failure, which is stable enough.
+func ParseExUnit(output string) *TestReport {
+	report := &TestReport{Runner: "exunit"}
+	index := make(map[string]int)
+	var suite, file string
+
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// --trace rewrites the running test's line in place
+		if idx := strings.LastIndex(line, "\r"); idx >= 0 {
+			line = line[idx+1:]
+		}
+
+		if m := exunitFinishPattern.FindStringSubmatch(line); m != nil {
+			report.Duration = parseSeconds(m[1])
+			continue
+		}
+		if m := exunitModulePattern.FindStringSubmatch(line); m != nil {
+			suite, file = m[1], m[2]
+			continue
+		}
+		if m := exunitTracePattern.FindStringSubmatch(line); m != nil {
+			tc := TestCase{Name: m[1], Suite: suite, File: file, Status: StatusPass}
+			tc.Line, _ = strconv.Atoi(m[5])
+			if m[4] != "" {
+				tc.Status = StatusSkip
+			} else {
+				d, _ := strconv.ParseFloat(m[2], 64)
+				if m[3] == "ms" {
+					tc.Duration = time.Duration(d * float64(time.Millisecond))
+				} else {
+					tc.Duration = time.Duration(d * float64(time.Second))
+				}
+			}
+			index[tc.Suite+"\x00"+tc.Name] = len(report.Tests)
+			report.Tests = append(report.Tests, tc)
+			continue
+		}
+
+		m := exunitFailurePattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		tc := TestCase{Name: m[1], Suite: m[2], Status: StatusFail}
+		var msg []string
+		for i+1 < len(lines) {
+			next := lines[i+1]
+			if strings.TrimSpace(next) != "" && (!strings.HasPrefix(next, "     ") || exunitFailurePattern.MatchString(next)) {
+				break
+			}
+			i++
+			if loc := exunitLocPattern.FindStringSubmatch(next); loc != nil && tc.File == "" {
+				tc.File = loc[1]
+				tc.Line, _ = strconv.Atoi(loc[2])
+				continue
+			}
+			msg = append(msg, next)
+		}
+		tc.Message = strings.TrimSpace(dedent(msg))
+
+		if j, ok := index[tc.Suite+"\x00"+tc.Name]; ok {
+			prev := report.Tests[j]
+			tc.Duration = prev.Duration
+			if tc.File == "" {
+				tc.File, tc.Line = prev.File, prev.Line
+			}
+			report.Tests[j] = tc
+			continue
+		}
+		report.Tests = append(report.Tests, tc)
+	}
+	return report
+}
@@ -0,0 +1,161 @@
+package testreport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a single test
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// SetupFailure is the name given to a failure that happened before any test
+// ran, such as a Go package that didn't compile or a jest file that didn't load
+const SetupFailure = "[setup]"
+
+// TestCase is one test as reported by the runner
+type TestCase struct {
+	Name     string        `json:"name"`
+	Suite    string        `json:"suite,omitempty"`
+	File     string        `json:"file,omitempty"`
+	Line     int           `json:"line,omitempty"`
+	Status   Status        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	Message  string        `json:"message,omitempty"`
+}
+
+// Location returns file:line, or just the file when the line is unknown
+func (tc TestCase) Location() string {
+	if tc.File == "" {
+		return ""
+	}
+	if tc.Line > 0 {
+		return fmt.Sprintf("%s:%d", tc.File, tc.Line)
+	}
+	return tc.File
+}
+
+// FullName qualifies the test name with its suite or package
+func (tc TestCase) FullName() string {
+	if tc.Suite == "" {
+		return tc.Name
+	}
+	return tc.Suite + " › " + tc.Name
+}
+
+// TestReport is the runner-independent result of a test run
+type TestReport struct {
+	Runner   string        `json:"runner"`
+	Tests    []TestCase    `json:"tests"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Count returns how many tests ended with the given status
+func (r *TestReport) Count(status Status) int {
+	if r == nil {
+		return 0
+	}
+	n := 0
+	for _, tc := range r.Tests {
+		if tc.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *TestReport) Passed() int  { return r.Count(StatusPass) }
+func (r *TestReport) Failed() int  { return r.Count(StatusFail) }
+func (r *TestReport) Skipped() int { return r.Count(StatusSkip) }
+
+// Failures returns the failing tests in report order
+func (r *TestReport) Failures() []TestCase {
+	if r == nil {
+		return nil
+	}
+	var failed []TestCase
+	for _, tc := range r.Tests {
+		if tc.Status == StatusFail {
+			failed = append(failed, tc)
+		}
+	}
+	return failed
+}
+
+// Slowest returns up to n tests ordered by duration, longest first
+func (r *TestReport) Slowest(n int) []TestCase {
+	if r == nil {
+		return nil
+	}
+	tests := append([]TestCase(nil), r.Tests...)
+	sort.SliceStable(tests, func(i, j int) bool { return tests[i].Duration > tests[j].Duration })
+	if len(tests) > n {
+		tests = tests[:n]
+	}
+	return tests
+}
+
+// Merge appends the tests of other into r
+func (r *TestReport) Merge(other *TestReport) {
+	if other == nil {
+		return
+	}
+	if r.Runner == "" {
+		r.Runner = other.Runner
+	}
+	r.Tests = append(r.Tests, other.Tests...)
+	r.Duration += other.Duration
+}
+
+// Summary returns a one-line count such as "12 passed, 1 failed, 2 skipped"
+func (r *TestReport) Summary() string {
+	parts := []string{fmt.Sprintf("%d passed", r.Passed()), fmt.Sprintf("%d failed", r.Failed())}
+	if s := r.Skipped(); s > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", s))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// maxMessageLines caps each failure message in FailureText
+const maxMessageLines = 40
+
+// FailureText renders only the failing tests with their location and
+// message, which is what recovery prompts need instead of the full log.
+func (r *TestReport) FailureText() string {
+	failures := r.Failures()
+	if len(failures) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d failing test(s) (%s):\n", len(failures), r.Summary())
+	for _, tc := range failures {
+		sb.WriteString("\n--- ")
+		sb.WriteString(tc.FullName())
+		if loc := tc.Location(); loc != "" {
+			fmt.Fprintf(&sb, " (%s)", loc)
+		}
+		sb.WriteString("\n")
+
+		lines := strings.Split(strings.TrimRight(tc.Message, "\n"), "\n")
+		if len(lines) > maxMessageLines {
+			lines = append(lines[:maxMessageLines], fmt.Sprintf("... (%d more lines)", len(lines)-maxMessageLines))
+		}
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			sb.WriteString("    ")
+			sb.WriteString(strings.TrimRight(line, " \t\r"))
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,141 @@
+package testreport
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Format is the machine-readable output mode a test runner is asked for
+type Format string
+
+const (
+	FormatGoJSON Format = "go-json"
+	FormatJUnit  Format = "junit"
+	FormatJest   Format = "jest"
+	FormatRSpec  Format = "rspec"
+	FormatExUnit Format = "exunit"
+)
+
+// Run runs a test command in dir with the extra flags that make it produce
+// the given format, and parses the result. The returned output is what the
+// runner printed (for go test -json, the equivalent plain text) and err is
+// the command's own error. report is nil when nothing could be parsed, for
+// example when the runner crashed before writing its report.
+func Run(ctx context.Context, dir string, format Format, name string, args ...string) (*TestReport, string, error) {
+	reportPath := ""
+	if format == FormatJUnit || format == FormatJest || format == FormatRSpec {
+		tmp, err := os.MkdirTemp("", "gptcode-testreport-")
+		if err == nil {
+			defer os.RemoveAll(tmp)
+			reportPath = filepath.Join(tmp, "report")
+		}
+	}
+
+	args = append(args, formatArgs(format, reportPath)...)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	raw, runErr := cmd.CombinedOutput()
+	output := string(raw)
+
+	var report *TestReport
+	switch format {
+	case FormatGoJSON:
+		report, output = ParseGoTestJSON(raw)
+	case FormatExUnit:
+		report = ParseExUnit(output)
+	default:
+		data, err := os.ReadFile(reportPath)
+		if err != nil || len(data) == 0 {
+			return nil, output, runErr
+		}
+		switch format {
+		case FormatJUnit:
+			report, err = ParseJUnitXML(data)
+		case FormatJest:
+			report, err = ParseJestJSON(data)
+		case FormatRSpec:
+			report, err = ParseRSpecJSON(data)
+		}
+		if err != nil {
+			return nil, output, runErr
+		}
+	}
+
+	if len(report.Tests) == 0 && runErr != nil {
+		// The runner failed without reporting any test; the raw output is
+		// more useful than an empty report.
+		return nil, output, runErr
+	}
+	report.relativize(dir)
+	return report, output, runErr
+}
+
+func formatArgs(format Format, reportPath string) []string {
+	switch format {
+	case FormatGoJSON:
+		return []string{"-json"}
+	case FormatJUnit:
+		if reportPath != "" {
+			return []string{"--junitxml=" + reportPath}
+		}
+	case FormatJest:
+		if reportPath != "" {
+			return []string{"--json", "--outputFile=" + reportPath, "--testLocationInResults"}
+		}
+	case FormatRSpec:
+		if reportPath != "" {
+			return []string{"--format", "progress", "--format", "json", "--out", reportPath}
+		}
+	case FormatExUnit:
+		return []string{"--trace"}
+	}
+	return nil
+}
+
+// relativize makes absolute test file paths relative to dir
+func (r *TestReport) relativize(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	for i, tc := range r.Tests {
+		if !filepath.IsAbs(tc.File) {
+			continue
+		}
+		if rel, err := filepath.Rel(abs, tc.File); err == nil && !strings.HasPrefix(rel, "..") {
+			r.Tests[i].File = rel
+		}
+	}
+}
+
+// jestCommand matches jest as a command in a test script, not a name
+// that merely contains it
+var jestCommand = regexp.MustCompile(`(^|[\s/&;|])jest($|\s)`)
+
+// UsesJest reports whether a package.json runs its tests with jest: its
+// test script calls jest or it depends on jest
+func UsesJest(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return false
+	}
+	var pkg struct {
+		Scripts         map[string]string `json:"scripts"`
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return false
+	}
+	if jestCommand.MatchString(pkg.Scripts["test"]) {
+		return true
+	}
+	_, dep := pkg.Dependencies["jest"]
+	_, devDep := pkg.DevDependencies["jest"]
+	return dep || devDep
+}
@@ -0,0 +1,234 @@
+package testreport
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const goJSON = `{"Action":"start","Package":"demo/calc"}
+{"Action":"run","Package":"demo/calc","Test":"TestAdd"}
+{"Action":"output","Package":"demo/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"output","Package":"demo/calc","Test":"TestAdd","Output":"--- PASS: TestAdd (0.00s)\n"}
+{"Action":"pass","Package":"demo/calc","Test":"TestAdd","Elapsed":0.01}
+{"Action":"run","Package":"demo/calc","Test":"TestDiv"}
+{"Action":"run","Package":"demo/calc","Test":"TestDiv/by_zero"}
+{"Action":"output","Package":"demo/calc","Test":"TestDiv/by_zero","Output":"    calc_test.go:21: Div(1, 0) = 1, want error\n"}
+{"Action":"output","Package":"demo/calc","Test":"TestDiv/by_zero","Output":"--- FAIL: TestDiv/by_zero (0.00s)\n"}
+{"Action":"fail","Package":"demo/calc","Test":"TestDiv/by_zero","Elapsed":0}
+{"Action":"output","Package":"demo/calc","Test":"TestDiv","Output":"--- FAIL: TestDiv (0.00s)\n"}
+{"Action":"fail","Package":"demo/calc","Test":"TestDiv","Elapsed":0}
+{"Action":"skip","Package":"demo/calc","Test":"TestSlow","Elapsed":0}
+{"Action":"fail","Package":"demo/calc","Elapsed":0.2}
+# demo/broken
+broken/broken.go:3:9: undefined: nope
+{"Action":"output","Package":"demo/broken","Output":"FAIL\tdemo/broken [build failed]\n"}
+{"Action":"fail","Package":"demo/broken","Elapsed":0}
+`
+
+func TestParseGoTestJSON(t *testing.T) {
+	report, text := ParseGoTestJSON([]byte(goJSON))
+
+	if report.Passed() != 1 || report.Failed() != 2 || report.Skipped() != 1 {
+		t.Fatalf("unexpected counts: %s", report.Summary())
+	}
+
+	failures := report.Failures()
+	div := failures[0]
+	if div.Name != "TestDiv/by_zero" || div.Location() != "calc_test.go:21" {
+		t.Errorf("expected subtest failure with location, got %+v", div)
+	}
+	if div.Message != "calc_test.go:21: Div(1, 0) = 1, want error" {
+		t.Errorf("unexpected message %q", div.Message)
+	}
+
+	build := failures[1]
+	if build.Name != SetupFailure || build.Suite != "demo/broken" || build.Location() != "broken/broken.go:3" {
+		t.Errorf("expected build failure for demo/broken, got %+v", build)
+	}
+
+	if !strings.Contains(text, "--- FAIL: TestDiv/by_zero") || !strings.Contains(text, "undefined: nope") {
+		t.Errorf("expected plain text output, got:\n%s", text)
+	}
+}
+
+func TestParseJUnitXML(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" tests="3">
+  <testcase classname="tests.test_calc" name="test_add" time="0.001"/>
+  <testcase classname="tests.test_calc" name="test_div" time="0.002">
+    <failure message="assert 1 == 2">def test_div():
+&gt;       assert div(2, 1) == 2
+E       assert 1 == 2
+
+tests/test_calc.py:9: AssertionError</failure>
+  </testcase>
+  <testcase classname="tests.test_calc" name="test_later" time="0"><skipped message="not yet"/></testcase>
+</testsuite></testsuites>`
+
+	report, err := ParseJUnitXML([]byte(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary() != "1 passed, 1 failed, 1 skipped" {
+		t.Fatalf("unexpected summary: %s", report.Summary())
+	}
+	f := report.Failures()[0]
+	if f.FullName() != "tests.test_calc › test_div" || f.Location() != "tests/test_calc.py:9" {
+		t.Errorf("unexpected failure: %+v", f)
+	}
+	if !strings.HasPrefix(f.Message, "assert 1 == 2") {
+		t.Errorf("unexpected message %q", f.Message)
+	}
+}
+
+func TestParseJestJSON(t *testing.T) {
+	data := `{"testResults":[
+  {"name":"/app/src/sum.test.js","status":"failed","startTime":1000,"endTime":1250,"assertionResults":[
+    {"ancestorTitles":["sum"],"title":"adds","status":"passed","duration":3},
+    {"ancestorTitles":["sum"],"title":"handles negatives","status":"failed","duration":5,
+     "failureMessages":["Error: expect(received).toBe(expected)\n\nExpected: -1\nReceived: 1\n    at Object.<anonymous> (/app/src/sum.test.js:12:19)"]},
+    {"ancestorTitles":["sum"],"title":"later","status":"pending"}
+  ]},
+  {"name":"/app/src/broken.test.js","status":"failed","message":"SyntaxError: Unexpected token","assertionResults":[]}
+]}`
+
+	report, err := ParseJestJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.relativize("/app")
+
+	if report.Summary() != "1 passed, 2 failed, 1 skipped" {
+		t.Fatalf("unexpected summary: %s", report.Summary())
+	}
+	f := report.Failures()
+	if f[0].FullName() != "sum › handles negatives" || f[0].Location() != "src/sum.test.js:12" {
+		t.Errorf("unexpected failure: %+v", f[0])
+	}
+	if f[1].Name != SetupFailure || f[1].File != "src/broken.test.js" {
+		t.Errorf("expected suite load failure, got %+v", f[1])
+	}
+}
+
+func TestParseRSpecJSON(t *testing.T) {
+	data := `{"examples":[
+  {"full_description":"Calc adds","status":"passed","file_path":"./spec/calc_spec.rb","line_number":4,"run_time":0.001},
+  {"full_description":"Calc divides","status":"failed","file_path":"./spec/calc_spec.rb","line_number":8,"run_time":0.002,
+   "exception":{"class":"RSpec::Expectations::ExpectationNotMetError","message":"expected 2\n     got 1","backtrace":["./spec/calc_spec.rb:9"]}}
+],"summary":{"duration":0.01}}`
+
+	report, err := ParseRSpecJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := report.Failures()
+	if len(f) != 1 || f[0].Location() != "spec/calc_spec.rb:8" || !strings.Contains(f[0].Message, "expected 2") {
+		t.Fatalf("unexpected failures: %+v", f)
+	}
+}
+
+func TestParseExUnit(t *testing.T) {
+	output := `MathTest [test/math_test.exs]
+  * test adds (0.01ms) [L#4]
+  * test divides (1.2ms) [L#8]
+
+  1) test divides (MathTest)
+     test/math_test.exs:8
+     Assertion with == failed
+     code:  assert Math.div(2, 1) == 1
+     left:  2
+     right: 1
+     stacktrace:
+       test/math_test.exs:9: (test)
+
+  * test later (excluded) [L#12]
+
+Finished in 0.05 seconds (0.00s async, 0.05s sync)
+3 tests, 1 failure, 1 excluded
+`
+
+	report := ParseExUnit(output)
+	if report.Summary() != "1 passed, 1 failed, 1 skipped" {
+		t.Fatalf("unexpected summary: %s", report.Summary())
+	}
+	f := report.Failures()[0]
+	if f.FullName() != "MathTest › divides" || f.Location() != "test/math_test.exs:8" {
+		t.Errorf("unexpected failure: %+v", f)
+	}
+	if !strings.HasPrefix(f.Message, "Assertion with == failed") || !strings.Contains(f.Message, "right: 1") {
+		t.Errorf("unexpected message %q", f.Message)
+	}
+}
+
+func TestFailureTextOnlyListsFailures(t *testing.T) {
+	report, _ := ParseGoTestJSON([]byte(goJSON))
+	text := report.FailureText()
+	if strings.Contains(text, "TestAdd") || !strings.Contains(text, "TestDiv/by_zero (calc_test.go:21)") {
+		t.Errorf("unexpected failure text:\n%s", text)
+	}
+	if (&TestReport{}).FailureText() != "" {
+		t.Error("expected no text for a passing report")
+	}
+}
+
+func TestRunGoTest(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module demo\n\ngo 1.21\n",
+		"calc.go":      "package demo\n\nfunc Add(a, b int) int { return a - b }\n",
+		"calc_test.go": "package demo\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Errorf(\"Add(1, 2) = %d\", Add(1, 2))\n\t}\n}\n\nfunc TestOK(t *testing.T) {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, output, err := Run(context.Background(), dir, FormatGoJSON, "go", "test", "./...")
+	if err == nil {
+		t.Fatal("expected failing run")
+	}
+	if report == nil || report.Passed() != 1 || report.Failed() != 1 {
+		t.Fatalf("unexpected report: %+v\n%s", report, output)
+	}
+	if f := report.Failures()[0]; f.Location() != "calc_test.go:7" {
+		t.Errorf("unexpected location %q", f.Location())
+	}
+	if strings.Contains(output, `"Action"`) {
+		t.Errorf("expected plain text output, got:\n%s", output)
+	}
+}
+
+func TestUsesJest(t *testing.T) {
+	tests := []struct {
+		name string
+		pkg  string
+		want bool
+	}{
+		{"test script", `{"scripts": {"test": "jest --coverage"}}`, true},
+		{"npx", `{"scripts": {"test": "npx jest"}}`, true},
+		{"dev dependency", `{"scripts": {"test": "npm run unit"}, "devDependencies": {"jest": "^29.0.0"}}`, true},
+		{"only a jest plugin", `{"scripts": {"test": "mocha"}, "devDependencies": {"eslint-plugin-jest": "^27.0.0"}}`, false},
+		{"name", `{"name": "jester", "scripts": {"test": "vitest run"}}`, false},
+		{"not json", `jest`, false},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(tt.pkg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := UsesJest(dir); got != tt.want {
+			t.Errorf("%s: UsesJest = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if UsesJest(t.TempDir()) {
+		t.Error("expected no package.json to mean no jest")
+	}
+}
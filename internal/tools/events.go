@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"gptcode/internal/events"
+	"gptcode/internal/redact"
+)
+
+// maxEventArgument caps each argument of a streamed tool call, so whole
+// files written by the model don't end up in every event
+const maxEventArgument = 500
+
+// fileBefore is a file as it was before a tool changed it
+type fileBefore struct {
+	path    string
+	content string
+	existed bool
+}
+
+// snapshot reads the file a writing tool is about to change, for the diff
+// reported in headless output
+func snapshot(call ToolCall, workdir string) *fileBefore {
+	if call.Name != "write_file" && call.Name != "apply_patch" {
+		return nil
+	}
+	path, ok := call.Arguments["path"].(string)
+	if !ok {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(workdir, path))
+	return &fileBefore{path: path, content: string(data), existed: err == nil}
+}
+
+// publishToolCall reports a finished tool call, and the files it changed,
+// to headless output
+func publishToolCall(call ToolCall, result ToolResult, workdir string, before *fileBefore, elapsed time.Duration) {
+	r := redact.Default()
+	args := make(map[string]string, len(call.Arguments))
+	for k, v := range call.Arguments {
+		s, ok := v.(string)
+		if !ok {
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		args[k] = truncateArgument(r.Redact(s))
+	}
+	events.PublishToolCall(events.ToolCall{
+		Name:       call.Name,
+		Arguments:  args,
+		Error:      result.Error,
+		DurationMs: elapsed.Milliseconds(),
+	})
+
+	for _, path := range result.ModifiedFiles {
+		d := events.Diff{Path: path, Operation: "modify"}
+		old := ""
+		if before != nil && before.path == path {
+			old = before.content
+			if !before.existed {
+				d.Operation = "create"
+			}
+		}
+		data, _ := os.ReadFile(filepath.Join(workdir, path))
+		patch, added, removed := unifiedDiff(path, old, string(data), d.Operation == "create")
+		d.Added, d.Removed = added, removed
+		if events.Streaming() {
+			d.Patch = r.Redact(patch)
+		}
+		events.PublishDiff(d)
+	}
+}
+
+// unifiedDiff diffs two versions of a file and counts changed lines
+func unifiedDiff(path, before, after string, created bool) (string, int, int) {
+	from := "a/" + path
+	if created {
+		from = "/dev/null"
+	}
+	patch, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(before),
+		B:        splitLines(after),
+		FromFile: from,
+		ToFile:   "b/" + path,
+		Context:  3,
+	})
+	// Only the two file header lines are skipped: a removed "-- comment"
+	// or an added "++i" line starts the same way
+	lines := strings.Split(patch, "\n")
+	if len(lines) > 2 {
+		lines = lines[2:]
+	}
+	added, removed := 0, 0
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return patch, added, removed
+}
+
+// splitLines splits text into lines that keep their newline, unlike
+// difflib.SplitLines, which adds an empty last line
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func truncateArgument(s string) string {
+	if r := []rune(s); len(r) > maxEventArgument {
+		return string(r[:maxEventArgument]) + "…"
+	}
+	return s
+}
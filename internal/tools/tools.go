@@ -1,7 +1,9 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,8 +13,11 @@ import (
 	"strings"
 	"time"
 
-	"gopkg.in/yaml.v3"
+	"gptcode/internal/config"
+	"gptcode/internal/events"
 	"gptcode/internal/observability"
+	"gptcode/internal/redact"
+	"gptcode/internal/telemetry"
 )
 
 type Tool struct {
@@ -240,6 +245,55 @@ func GetAvailableTools() []map[string]interface{} {
 }
 
 func ExecuteTool(call ToolCall, workdir string) ToolResult {
+	return ExecuteToolContext(context.Background(), call, workdir)
+}
+
+// ExecuteToolContext runs a tool in a span under the one in ctx, counts it
+// in the tool metrics and reports it to headless output
+func ExecuteToolContext(ctx context.Context, call ToolCall, workdir string) ToolResult {
+	_, span := telemetry.StartToolCall(ctx, call.Name)
+	var before *fileBefore
+	if events.Headless() {
+		before = snapshot(call, workdir)
+	}
+	start := time.Now()
+	result := executeTool(call, workdir)
+	var err error
+	if result.Error != "" {
+		err = errors.New(result.Error)
+	}
+	telemetry.ObserveToolCall(call.Name, time.Since(start), err)
+	telemetry.End(span, err)
+	if events.Headless() {
+		publishToolCall(call, result, workdir, before, time.Since(start))
+	}
+	return result
+}
+
+func executeTool(call ToolCall, workdir string) ToolResult {
+	if result, blocked := checkPolicy(call, workdir); blocked {
+		return result
+	}
+
+	// Results go back to the model, so secrets in them are masked; the
+	// placeholders it writes back are swapped for the real values
+	r := redact.Default()
+	for k, v := range call.Arguments {
+		if s, ok := v.(string); ok {
+			call.Arguments[k] = r.Restore(s)
+		}
+	}
+	result := dispatchTool(call, workdir)
+	if path, ok := call.Arguments["path"].(string); ok && call.Name == "read_file" {
+		result.Result = r.RedactFile(path, result.Result)
+	} else {
+		result.Result = r.Redact(result.Result)
+	}
+	result.Error = r.Redact(result.Error)
+	return result
+}
+
+func dispatchTool(call ToolCall, workdir string) ToolResult {
 	switch call.Name {
 	case "read_file":
 		return readFile(call, workdir)
@@ -269,6 +323,40 @@ func ExecuteTool(call ToolCall, workdir string) ToolResult {
 	}
 }
 
+// checkPolicy refuses writes to paths the project config protects
+func checkPolicy(call ToolCall, workdir string) (ToolResult, bool) {
+	if call.Name != "write_file" && call.Name != "apply_patch" {
+		return ToolResult{}, false
+	}
+	path, ok := call.Arguments["path"].(string)
+	if !ok {
+		return ToolResult{}, false
+	}
+	// A project config that doesn't parse can't say what is protected, so
+	// nothing is written until it is fixed
+	if configPath, ok := config.FindProjectConfig(workdir); ok {
+		if _, err := config.LoadProjectConfig(configPath); err != nil {
+			return ToolResult{
+				Tool:  call.Name,
+				Error: fmt.Sprintf("refusing to modify %s: the project policy can't be read: %v", path, err),
+			}, true
+		}
+	}
+	// A missing user setup still leaves the project policy to enforce
+	setup, _ := config.LoadSetupAt(workdir)
+	fullPath := path
+	if !filepath.IsAbs(fullPath) {
+		fullPath = filepath.Join(workdir, path)
+	}
+	if !setup.IsProtected(fullPath) {
+		return ToolResult{}, false
+	}
+	return ToolResult{
+		Tool:  call.Name,
+		Error: fmt.Sprintf("%s is protected by the project policy and cannot be modified", path),
+	}, true
+}
+
 type LLMToolCall struct {
 	ID        string
 	Name      string
@@ -276,6 +364,12 @@ type LLMToolCall struct {
 }
 
 func ExecuteToolFromLLM(call LLMToolCall, workdir string) ToolResult {
+	return ExecuteToolFromLLMContext(context.Background(), call, workdir)
+}
+
+// ExecuteToolFromLLMContext is ExecuteToolFromLLM with the tool's span
+// under the one in ctx
+func ExecuteToolFromLLMContext(ctx context.Context, call LLMToolCall, workdir string) ToolResult {
 	var argsMap map[string]interface{}
 	if err := json.Unmarshal([]byte(call.Arguments), &argsMap); err != nil {
 		return ToolResult{
@@ -289,7 +383,7 @@ func ExecuteToolFromLLM(call LLMToolCall, workdir string) ToolResult {
 		Arguments: argsMap,
 	}
 
-	return ExecuteTool(toolCall, workdir)
+	return ExecuteToolContext(ctx, toolCall, workdir)
 }
 
 func readFile(call ToolCall, workdir string) ToolResult {
@@ -486,11 +580,11 @@ func writeFile(call ToolCall, workdir string) ToolResult {
 }
 
 // ExecuteToolWithObserver wraps ExecuteToolFromLLM and emits events to the observer
-func ExecuteToolWithObserver(call LLMToolCall, workdir string, observer observability.Observer) ToolResult {
+func ExecuteToolWithObserver(ctx context.Context, call LLMToolCall, workdir string, observer observability.Observer) ToolResult {
 	start := time.Now()
 
 	// Execute the tool
-	result := ExecuteToolFromLLM(call, workdir)
+	result := ExecuteToolFromLLMContext(ctx, call, workdir)
 
 	// Emit events if observer is provided
 	if observer != nil {
@@ -562,7 +656,7 @@ func WebSearch(call ToolCall) ToolResult {
 
 func searchWeb(query string, numResults int) (string, error) {
 	// Check for Tavily first (more popular for AI agents)
-	// Check env var first, then keys.yaml
+	// Check env var first, then the configured keys
 	tavilyKey := os.Getenv("TAVILY_API_KEY")
 	if tavilyKey == "" {
 		tavilyKey = loadSearchKey("TAVILY_API_KEY")
@@ -604,34 +698,12 @@ func loadSearchKey(envVar string) string {
 	return ""
 }
 
+// getConfigKey resolves a key through setup.yaml references, the secret
+// store and the legacy keys.yaml
 func getConfigKey(key string) (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-
-	keysPath := filepath.Join(home, ".gptcode", "keys.yaml")
-	data, err := os.ReadFile(keysPath)
-	if err != nil {
-		return "", err
-	}
-
-	var keys map[string]string
-	if err := yaml.Unmarshal(data, &keys); err != nil {
-		return "", err
-	}
-
-	// Try exact match
-	if val, ok := keys[key]; ok {
+	if val := config.LoadAPIKey(key); val != "" {
 		return val, nil
 	}
-
-	// Try without _API_KEY suffix
-	cleanKey := strings.TrimSuffix(key, "_API_KEY")
-	if val, ok := keys[cleanKey]; ok {
-		return val, nil
-	}
-
 	return "", fmt.Errorf("key not found")
 }
 
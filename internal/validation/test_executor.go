@@ -2,11 +2,14 @@ package validation
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"gptcode/internal/langdetect"
+	"gptcode/internal/testreport"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type TestResult struct {
@@ -17,6 +20,9 @@ type TestResult struct {
 	Skipped      int
 	Duration     string
 	ErrorMessage string
+	// Report holds per-test results when the runner supports a
+	// machine-readable mode
+	Report *testreport.TestReport
 }
 
 type TestExecutor struct {
@@ -47,28 +53,7 @@ func (te *TestExecutor) RunTests() (*TestResult, error) {
 }
 
 func (te *TestExecutor) runGoTests() (*TestResult, error) {
-	cmd := exec.Command("go", "test", "./...", "-v")
-	cmd.Dir = te.workDir
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-	output := stdout.String() + stderr.String()
-
-	result := &TestResult{
-		Success: err == nil,
-		Output:  output,
-	}
-
-	result.parseGoOutput(output)
-
-	if err != nil {
-		result.ErrorMessage = err.Error()
-	}
-
-	return result, nil
+	return te.run(testreport.FormatGoJSON, (*TestResult).parseGoOutput, "go", "test", "./...", "-v")
 }
 
 func (te *TestExecutor) runNodeTests() (*TestResult, error) {
@@ -81,113 +66,72 @@ func (te *TestExecutor) runNodeTests() (*TestResult, error) {
 		testCmd = "pnpm"
 	}
 
-	cmd := exec.Command(testCmd, args...)
-	cmd.Dir = te.workDir
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-	output := stdout.String() + stderr.String()
-
-	result := &TestResult{
-		Success: err == nil,
-		Output:  output,
-	}
-
-	result.parseJestOutput(output)
-
-	if err != nil {
-		result.ErrorMessage = err.Error()
+	var format testreport.Format
+	if testreport.UsesJest(te.workDir) {
+		format = testreport.FormatJest
+		if testCmd == "npm" {
+			args = append(args, "--")
+		}
 	}
 
-	return result, nil
+	return te.run(format, (*TestResult).parseJestOutput, testCmd, args...)
 }
 
 func (te *TestExecutor) runPythonTests() (*TestResult, error) {
-	testCmd := "pytest"
-	args := []string{"-v"}
-
 	if fileExists(filepath.Join(te.workDir, "manage.py")) {
-		testCmd = "python"
-		args = []string{"manage.py", "test"}
+		return te.run("", (*TestResult).parsePytestOutput, "python", "manage.py", "test")
 	}
-
-	cmd := exec.Command(testCmd, args...)
-	cmd.Dir = te.workDir
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-	output := stdout.String() + stderr.String()
-
-	result := &TestResult{
-		Success: err == nil,
-		Output:  output,
-	}
-
-	result.parsePytestOutput(output)
-
-	if err != nil {
-		result.ErrorMessage = err.Error()
-	}
-
-	return result, nil
+	return te.run(testreport.FormatJUnit, (*TestResult).parsePytestOutput, "pytest", "-v")
 }
 
 func (te *TestExecutor) runElixirTests() (*TestResult, error) {
-	cmd := exec.Command("mix", "test")
-	cmd.Dir = te.workDir
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-	output := stdout.String() + stderr.String()
-
-	result := &TestResult{
-		Success: err == nil,
-		Output:  output,
-	}
-
-	result.parseElixirOutput(output)
-
-	if err != nil {
-		result.ErrorMessage = err.Error()
-	}
-
-	return result, nil
+	return te.run(testreport.FormatExUnit, (*TestResult).parseElixirOutput, "mix", "test")
 }
 
 func (te *TestExecutor) runRubyTests() (*TestResult, error) {
-	testCmd := "rake"
-	args := []string{"test"}
-
 	if fileExists(filepath.Join(te.workDir, "spec")) {
-		testCmd = "rspec"
-		args = []string{}
+		return te.run(testreport.FormatRSpec, (*TestResult).parseRSpecOutput, "rspec")
 	}
+	return te.run("", (*TestResult).parseRSpecOutput, "rake", "test")
+}
 
-	cmd := exec.Command(testCmd, args...)
-	cmd.Dir = te.workDir
+// run executes a test command, in machine-readable mode when format is set.
+// Counts come from the structured report, or from parseText on the plain
+// output when the runner has no such mode or didn't produce a report.
+func (te *TestExecutor) run(format testreport.Format, parseText func(*TestResult, string), name string, args ...string) (*TestResult, error) {
+	start := time.Now()
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	var report *testreport.TestReport
+	var output string
+	var err error
+	if format != "" {
+		report, output, err = testreport.Run(context.Background(), te.workDir, format, name, args...)
+	} else {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = te.workDir
 
-	err := cmd.Run()
-	output := stdout.String() + stderr.String()
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		err = cmd.Run()
+		output = stdout.String() + stderr.String()
+	}
 
 	result := &TestResult{
-		Success: err == nil,
-		Output:  output,
+		Success:  err == nil,
+		Output:   output,
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+		Report:   report,
 	}
 
-	result.parseRSpecOutput(output)
+	if report != nil {
+		result.Passed = report.Passed()
+		result.Failed = report.Failed()
+		result.Skipped = report.Skipped()
+	} else {
+		parseText(result, output)
+	}
 
 	if err != nil {
 		result.ErrorMessage = err.Error()
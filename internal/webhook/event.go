@@ -0,0 +1,450 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Provider identifies the forge a webhook came from
+type Provider string
+
+const (
+	ProviderGitHub Provider = "github"
+	ProviderGitLab Provider = "gitlab"
+)
+
+// JobKind is the type of work a webhook delivery turned into
+type JobKind string
+
+const (
+	JobCheckFailure  JobKind = "check_failure"
+	JobIssue         JobKind = "issue"
+	JobReviewComment JobKind = "review_comment"
+)
+
+// DefaultTriggerLabel is the issue label that opts an issue into autofix
+const DefaultTriggerLabel = "gptcode"
+
+// FixBranchPrefix starts the branches the runner pushes proposed fixes to.
+// Failures on them are the runner's own and never start another job.
+const FixBranchPrefix = "gptcode/"
+
+// trustedAssociations are the GitHub author associations whose review
+// comments may drive the agent. Anyone can comment on a public repository,
+// and the agent runs commands in a clone that holds a push token.
+var trustedAssociations = map[string]bool{
+	"OWNER":        true,
+	"MEMBER":       true,
+	"COLLABORATOR": true,
+}
+
+// Job is a normalized unit of autofix work, independent of the forge that sent it
+type Job struct {
+	ID       string   `json:"id"`
+	Provider Provider `json:"provider"`
+	Kind     JobKind  `json:"kind"`
+
+	Repo      string `json:"repo"`       // owner/repo (GitHub) or group/project path (GitLab)
+	ProjectID int    `json:"project_id"` // GitLab numeric project ID
+	CloneURL  string `json:"clone_url"`
+	APIURL    string `json:"api_url,omitempty"` // GitLab instance API base
+	Branch    string `json:"branch"`
+	SHA       string `json:"sha"`
+
+	IssueNumber int `json:"issue_number,omitempty"`
+	PRNumber    int `json:"pr_number,omitempty"`
+
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	CheckName string `json:"check_name,omitempty"`
+	DetailURL string `json:"detail_url,omitempty"`
+
+	CommentID string `json:"comment_id,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Author    string `json:"author,omitempty"`
+
+	// Sender is the account whose action triggered the event, such as the
+	// one who added the label; SenderID is its GitLab user ID
+	Sender   string `json:"sender,omitempty"`
+	SenderID int    `json:"sender_id,omitempty"`
+
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Key returns a deduplication key so that redelivered or repeated events for
+// the same target don't queue duplicate work.
+func (j *Job) Key() string {
+	switch j.Kind {
+	case JobCheckFailure:
+		return fmt.Sprintf("%s:%s:check:%s:%s", j.Provider, j.Repo, j.SHA, j.CheckName)
+	case JobIssue:
+		return fmt.Sprintf("%s:%s:issue:%d", j.Provider, j.Repo, j.IssueNumber)
+	case JobReviewComment:
+		return fmt.Sprintf("%s:%s:comment:%s", j.Provider, j.Repo, j.CommentID)
+	}
+	return j.ID
+}
+
+// ParseGitHubEvent converts a GitHub delivery into a Job. It returns (nil, nil)
+// for events that are valid but not actionable (e.g. a successful check run).
+func ParseGitHubEvent(eventType string, body []byte, triggerLabel string) (*Job, error) {
+	if triggerLabel == "" {
+		triggerLabel = DefaultTriggerLabel
+	}
+
+	var repoPayload struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+			CloneURL string `json:"clone_url"`
+		} `json:"repository"`
+		Sender struct {
+			Login string `json:"login"`
+		} `json:"sender"`
+	}
+	if err := json.Unmarshal(body, &repoPayload); err != nil {
+		return nil, fmt.Errorf("failed to parse github payload: %w", err)
+	}
+
+	job := &Job{
+		Provider:   ProviderGitHub,
+		Repo:       repoPayload.Repository.FullName,
+		CloneURL:   repoPayload.Repository.CloneURL,
+		Sender:     repoPayload.Sender.Login,
+		ReceivedAt: time.Now(),
+	}
+
+	switch eventType {
+	case "check_run":
+		var p struct {
+			Action   string `json:"action"`
+			CheckRun struct {
+				Name       string `json:"name"`
+				HeadSHA    string `json:"head_sha"`
+				Conclusion string `json:"conclusion"`
+				DetailsURL string `json:"details_url"`
+				Output     struct {
+					Title   string `json:"title"`
+					Summary string `json:"summary"`
+					Text    string `json:"text"`
+				} `json:"output"`
+				CheckSuite struct {
+					HeadBranch string `json:"head_branch"`
+				} `json:"check_suite"`
+				PullRequests []struct {
+					Number int `json:"number"`
+				} `json:"pull_requests"`
+			} `json:"check_run"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse check_run payload: %w", err)
+		}
+		if p.Action != "completed" {
+			return nil, nil
+		}
+		if p.CheckRun.Conclusion != "failure" && p.CheckRun.Conclusion != "timed_out" {
+			return nil, nil
+		}
+		// Don't react to our own status reports or to failures of our own fixes
+		if strings.HasPrefix(p.CheckRun.Name, StatusContext) || strings.HasPrefix(p.CheckRun.CheckSuite.HeadBranch, FixBranchPrefix) {
+			return nil, nil
+		}
+
+		job.Kind = JobCheckFailure
+		job.SHA = p.CheckRun.HeadSHA
+		job.Branch = p.CheckRun.CheckSuite.HeadBranch
+		job.CheckName = p.CheckRun.Name
+		job.DetailURL = p.CheckRun.DetailsURL
+		job.Title = p.CheckRun.Output.Title
+		if job.Title == "" {
+			job.Title = fmt.Sprintf("Check %q failed", p.CheckRun.Name)
+		}
+		job.Body = strings.TrimSpace(p.CheckRun.Output.Summary + "\n\n" + p.CheckRun.Output.Text)
+		if len(p.CheckRun.PullRequests) > 0 {
+			job.PRNumber = p.CheckRun.PullRequests[0].Number
+		}
+
+	case "issues":
+		var p struct {
+			Action string `json:"action"`
+			Label  struct {
+				Name string `json:"name"`
+			} `json:"label"`
+			Issue struct {
+				Number int    `json:"number"`
+				Title  string `json:"title"`
+				Body   string `json:"body"`
+				User   struct {
+					Login string `json:"login"`
+				} `json:"user"`
+				PullRequest *json.RawMessage `json:"pull_request"`
+			} `json:"issue"`
+			Repository struct {
+				DefaultBranch string `json:"default_branch"`
+			} `json:"repository"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse issues payload: %w", err)
+		}
+		if p.Action != "labeled" || !strings.EqualFold(p.Label.Name, triggerLabel) {
+			return nil, nil
+		}
+		if p.Issue.PullRequest != nil {
+			return nil, nil
+		}
+
+		job.Kind = JobIssue
+		job.IssueNumber = p.Issue.Number
+		job.Title = p.Issue.Title
+		job.Body = p.Issue.Body
+		job.Author = p.Issue.User.Login
+		job.Branch = p.Repository.DefaultBranch
+
+	case "pull_request_review_comment":
+		var p struct {
+			Action  string `json:"action"`
+			Comment struct {
+				ID       int64  `json:"id"`
+				Body     string `json:"body"`
+				Path     string `json:"path"`
+				Line     int    `json:"line"`
+				CommitID string `json:"commit_id"`
+				// AuthorAssociation is the commenter's relation to the repository
+				AuthorAssociation string `json:"author_association"`
+				User              struct {
+					Login string `json:"login"`
+					Type  string `json:"type"`
+				} `json:"user"`
+			} `json:"comment"`
+			PullRequest struct {
+				Number int    `json:"number"`
+				Title  string `json:"title"`
+				Head   struct {
+					Ref string `json:"ref"`
+					SHA string `json:"sha"`
+				} `json:"head"`
+			} `json:"pull_request"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse review comment payload: %w", err)
+		}
+		if p.Action != "created" || p.Comment.User.Type == "Bot" {
+			return nil, nil
+		}
+		if !trustedAssociations[p.Comment.AuthorAssociation] {
+			return nil, nil
+		}
+
+		job.Kind = JobReviewComment
+		job.PRNumber = p.PullRequest.Number
+		job.Title = p.PullRequest.Title
+		job.Body = p.Comment.Body
+		job.CommentID = fmt.Sprintf("%d", p.Comment.ID)
+		job.Path = p.Comment.Path
+		job.Line = p.Comment.Line
+		job.Author = p.Comment.User.Login
+		job.Branch = p.PullRequest.Head.Ref
+		job.SHA = p.PullRequest.Head.SHA
+
+	default:
+		return nil, nil
+	}
+
+	job.ID = newJobID(job)
+	return job, nil
+}
+
+// ParseGitLabEvent converts a GitLab delivery (X-Gitlab-Event header) into a
+// Job. Failed pipelines map to check failures, labeled issues to issue jobs and
+// diff notes on merge requests to review comments.
+func ParseGitLabEvent(eventType string, body []byte, triggerLabel string) (*Job, error) {
+	if triggerLabel == "" {
+		triggerLabel = DefaultTriggerLabel
+	}
+
+	var base struct {
+		Project struct {
+			ID                int    `json:"id"`
+			PathWithNamespace string `json:"path_with_namespace"`
+			GitHTTPURL        string `json:"git_http_url"`
+			WebURL            string `json:"web_url"`
+			DefaultBranch     string `json:"default_branch"`
+		} `json:"project"`
+		User struct {
+			ID       int    `json:"id"`
+			Username string `json:"username"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(body, &base); err != nil {
+		return nil, fmt.Errorf("failed to parse gitlab payload: %w", err)
+	}
+
+	job := &Job{
+		Provider:   ProviderGitLab,
+		Repo:       base.Project.PathWithNamespace,
+		ProjectID:  base.Project.ID,
+		CloneURL:   base.Project.GitHTTPURL,
+		APIURL:     gitlabAPIURL(base.Project.WebURL, base.Project.PathWithNamespace),
+		Author:     base.User.Username,
+		Sender:     base.User.Username,
+		SenderID:   base.User.ID,
+		ReceivedAt: time.Now(),
+	}
+
+	switch eventType {
+	case "Pipeline Hook":
+		var p struct {
+			ObjectAttributes struct {
+				ID     int    `json:"id"`
+				Ref    string `json:"ref"`
+				SHA    string `json:"sha"`
+				Status string `json:"status"`
+				URL    string `json:"url"`
+			} `json:"object_attributes"`
+			MergeRequest *struct {
+				IID int `json:"iid"`
+			} `json:"merge_request"`
+			Builds []struct {
+				Name   string `json:"name"`
+				Stage  string `json:"stage"`
+				Status string `json:"status"`
+			} `json:"builds"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse pipeline payload: %w", err)
+		}
+		if p.ObjectAttributes.Status != "failed" || strings.HasPrefix(p.ObjectAttributes.Ref, FixBranchPrefix) {
+			return nil, nil
+		}
+
+		var failedJobs []string
+		for _, b := range p.Builds {
+			if b.Status == "failed" {
+				failedJobs = append(failedJobs, fmt.Sprintf("%s (%s)", b.Name, b.Stage))
+			}
+		}
+
+		job.Kind = JobCheckFailure
+		job.SHA = p.ObjectAttributes.SHA
+		job.Branch = p.ObjectAttributes.Ref
+		job.CheckName = fmt.Sprintf("pipeline #%d", p.ObjectAttributes.ID)
+		job.DetailURL = p.ObjectAttributes.URL
+		job.Title = fmt.Sprintf("Pipeline #%d failed", p.ObjectAttributes.ID)
+		if len(failedJobs) > 0 {
+			job.Body = "Failed jobs: " + strings.Join(failedJobs, ", ")
+		}
+		if p.MergeRequest != nil {
+			job.PRNumber = p.MergeRequest.IID
+		}
+
+	case "Issue Hook":
+		var p struct {
+			ObjectAttributes struct {
+				IID         int    `json:"iid"`
+				Title       string `json:"title"`
+				Description string `json:"description"`
+				Action      string `json:"action"`
+			} `json:"object_attributes"`
+			Changes struct {
+				Labels *struct {
+					Previous []struct {
+						Title string `json:"title"`
+					} `json:"previous"`
+					Current []struct {
+						Title string `json:"title"`
+					} `json:"current"`
+				} `json:"labels"`
+			} `json:"changes"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse issue payload: %w", err)
+		}
+		// Only react when the trigger label was just added
+		if p.Changes.Labels == nil {
+			return nil, nil
+		}
+		hadLabel, hasLabel := false, false
+		for _, l := range p.Changes.Labels.Previous {
+			if strings.EqualFold(l.Title, triggerLabel) {
+				hadLabel = true
+			}
+		}
+		for _, l := range p.Changes.Labels.Current {
+			if strings.EqualFold(l.Title, triggerLabel) {
+				hasLabel = true
+			}
+		}
+		if hadLabel || !hasLabel {
+			return nil, nil
+		}
+
+		job.Kind = JobIssue
+		job.IssueNumber = p.ObjectAttributes.IID
+		job.Title = p.ObjectAttributes.Title
+		job.Body = p.ObjectAttributes.Description
+		job.Branch = base.Project.DefaultBranch
+
+	case "Note Hook":
+		var p struct {
+			ObjectAttributes struct {
+				ID           int    `json:"id"`
+				Note         string `json:"note"`
+				NoteableType string `json:"noteable_type"`
+				Position     *struct {
+					NewPath string `json:"new_path"`
+					NewLine int    `json:"new_line"`
+				} `json:"position"`
+				DiscussionID string `json:"discussion_id"`
+			} `json:"object_attributes"`
+			MergeRequest struct {
+				IID          int    `json:"iid"`
+				Title        string `json:"title"`
+				SourceBranch string `json:"source_branch"`
+				LastCommit   struct {
+					ID string `json:"id"`
+				} `json:"last_commit"`
+			} `json:"merge_request"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse note payload: %w", err)
+		}
+		if p.ObjectAttributes.NoteableType != "MergeRequest" || p.ObjectAttributes.Position == nil {
+			return nil, nil
+		}
+
+		job.Kind = JobReviewComment
+		job.PRNumber = p.MergeRequest.IID
+		job.Title = p.MergeRequest.Title
+		job.Body = p.ObjectAttributes.Note
+		job.CommentID = p.ObjectAttributes.DiscussionID
+		if job.CommentID == "" {
+			job.CommentID = fmt.Sprintf("%d", p.ObjectAttributes.ID)
+		}
+		job.Path = p.ObjectAttributes.Position.NewPath
+		job.Line = p.ObjectAttributes.Position.NewLine
+		job.Branch = p.MergeRequest.SourceBranch
+		job.SHA = p.MergeRequest.LastCommit.ID
+
+	default:
+		return nil, nil
+	}
+
+	job.ID = newJobID(job)
+	return job, nil
+}
+
+// gitlabAPIURL derives the instance API base from a project web URL, so that
+// self-managed GitLab instances work without extra configuration.
+func gitlabAPIURL(webURL, path string) string {
+	if webURL == "" {
+		return "https://gitlab.com/api/v4"
+	}
+	instance := strings.TrimSuffix(strings.TrimSuffix(webURL, "/"), "/"+path)
+	return instance + "/api/v4"
+}
+
+func newJobID(j *Job) string {
+	return fmt.Sprintf("%s-%s-%d", j.Provider, j.Kind, j.ReceivedAt.UnixNano())
+}
@@ -0,0 +1,239 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gptcode/internal/github"
+)
+
+// StatusContext is the commit status context used for autofix reports
+const StatusContext = "gptcode/autofix"
+
+// Status is a forge-neutral commit status
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusSuccess Status = "success"
+	StatusFailure Status = "failure"
+)
+
+// Forge reports results back to the hosting service
+type Forge interface {
+	// SetStatus sets the autofix commit status on the job's SHA
+	SetStatus(ctx context.Context, job *Job, status Status, description string) error
+	// Comment posts on the job's issue, pull request or review thread
+	Comment(ctx context.Context, job *Job, body string) error
+	// OpenChangeRequest opens a PR/MR from head into base and returns its URL
+	OpenChangeRequest(ctx context.Context, job *Job, head, base, title, body string) (string, error)
+}
+
+// GitHubForge reports through the gh CLI, authenticated via GH_TOKEN
+type GitHubForge struct{}
+
+func (GitHubForge) client(job *Job) *github.Client {
+	return github.NewClient(job.Repo)
+}
+
+// Self returns the login the gh CLI is authenticated as
+func (GitHubForge) Self(ctx context.Context, job *Job) (string, error) {
+	return github.CurrentUser()
+}
+
+func (g GitHubForge) SetStatus(ctx context.Context, job *Job, status Status, description string) error {
+	if job.SHA == "" {
+		return nil
+	}
+	return g.client(job).SetCommitStatus(job.SHA, string(status), description, StatusContext, "")
+}
+
+func (g GitHubForge) Comment(ctx context.Context, job *Job, body string) error {
+	client := g.client(job)
+	switch {
+	case job.Kind == JobReviewComment && job.CommentID != "":
+		return client.ReplyToReviewComment(job.PRNumber, job.CommentID, body)
+	case job.IssueNumber > 0:
+		return client.AddIssueComment(job.IssueNumber, body)
+	case job.PRNumber > 0:
+		return client.AddIssueComment(job.PRNumber, body)
+	}
+	return nil
+}
+
+func (g GitHubForge) OpenChangeRequest(ctx context.Context, job *Job, head, base, title, body string) (string, error) {
+	pr, err := g.client(job).CreatePR(github.PRCreateOptions{
+		Title:      title,
+		Body:       body,
+		HeadBranch: head,
+		BaseBranch: base,
+	})
+	if err != nil {
+		return "", err
+	}
+	return pr.URL, nil
+}
+
+// GitLabForge reports through the GitLab REST API using a personal or project access token
+type GitLabForge struct {
+	Token  string
+	Client *http.Client
+}
+
+// NewGitLabForge creates a GitLab reporter
+func NewGitLabForge(token string) *GitLabForge {
+	return &GitLabForge{
+		Token:  token,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (g *GitLabForge) SetStatus(ctx context.Context, job *Job, status Status, description string) error {
+	if job.SHA == "" {
+		return nil
+	}
+
+	state := string(status)
+	switch status {
+	case StatusPending:
+		state = "running"
+	case StatusFailure:
+		state = "failed"
+	}
+
+	form := url.Values{}
+	form.Set("state", state)
+	form.Set("name", StatusContext)
+	form.Set("description", description)
+	if job.Branch != "" {
+		form.Set("ref", job.Branch)
+	}
+
+	path := fmt.Sprintf("/projects/%d/statuses/%s?%s", job.ProjectID, job.SHA, form.Encode())
+	return g.post(ctx, job, path, nil, nil)
+}
+
+func (g *GitLabForge) Comment(ctx context.Context, job *Job, body string) error {
+	payload := map[string]string{"body": body}
+
+	switch {
+	case job.Kind == JobReviewComment && job.CommentID != "":
+		path := fmt.Sprintf("/projects/%d/merge_requests/%d/discussions/%s/notes", job.ProjectID, job.PRNumber, job.CommentID)
+		return g.post(ctx, job, path, payload, nil)
+	case job.IssueNumber > 0:
+		path := fmt.Sprintf("/projects/%d/issues/%d/notes", job.ProjectID, job.IssueNumber)
+		return g.post(ctx, job, path, payload, nil)
+	case job.PRNumber > 0:
+		path := fmt.Sprintf("/projects/%d/merge_requests/%d/notes", job.ProjectID, job.PRNumber)
+		return g.post(ctx, job, path, payload, nil)
+	}
+	return nil
+}
+
+func (g *GitLabForge) OpenChangeRequest(ctx context.Context, job *Job, head, base, title, body string) (string, error) {
+	payload := map[string]string{
+		"source_branch": head,
+		"target_branch": base,
+		"title":         title,
+		"description":   body,
+	}
+
+	var result struct {
+		WebURL string `json:"web_url"`
+	}
+	path := fmt.Sprintf("/projects/%d/merge_requests", job.ProjectID)
+	if err := g.post(ctx, job, path, payload, &result); err != nil {
+		return "", err
+	}
+	return result.WebURL, nil
+}
+
+// Self returns the username the token belongs to
+func (g *GitLabForge) Self(ctx context.Context, job *Job) (string, error) {
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := g.request(ctx, http.MethodGet, job, "/user", nil, &user); err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
+
+// AccessLevel returns the sender's access level in the project, including
+// levels inherited from groups, or 0 when they aren't a member
+func (g *GitLabForge) AccessLevel(ctx context.Context, job *Job) (int, error) {
+	if job.SenderID == 0 {
+		return 0, nil
+	}
+	var member struct {
+		AccessLevel int `json:"access_level"`
+	}
+	path := fmt.Sprintf("/projects/%d/members/all/%d", job.ProjectID, job.SenderID)
+	err := g.request(ctx, http.MethodGet, job, path, nil, &member)
+	var statusErr *gitlabStatusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return member.AccessLevel, nil
+}
+
+// gitlabStatusError is a GitLab API response with an error status
+type gitlabStatusError struct {
+	path string
+	code int
+	body string
+}
+
+func (e *gitlabStatusError) Error() string {
+	return fmt.Sprintf("gitlab %s returned %d: %s", e.path, e.code, e.body)
+}
+
+func (g *GitLabForge) post(ctx context.Context, job *Job, path string, payload interface{}, out interface{}) error {
+	return g.request(ctx, http.MethodPost, job, path, payload, out)
+}
+
+func (g *GitLabForge) request(ctx context.Context, method string, job *Job, path string, payload interface{}, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, job.APIURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.Token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("gitlab request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &gitlabStatusError{path: path, code: resp.StatusCode, body: string(msg)}
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when the backlog is at capacity
+var ErrQueueFull = errors.New("webhook queue is full")
+
+// ErrDuplicateJob is returned when an equivalent job is queued, running or
+// finished within the DedupeWindow
+var ErrDuplicateJob = errors.New("job already queued")
+
+// DedupeWindow is how long a finished job keeps blocking equivalent ones,
+// so that redelivered events don't run it again
+const DedupeWindow = 24 * time.Hour
+
+// HandlerFunc processes a single job. Handlers run concurrently, up to the
+// queue's concurrency limit.
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// Queue is a bounded work queue that runs jobs with limited concurrency and
+// drops duplicates of jobs that are pending, in flight or recently finished.
+type Queue struct {
+	jobs        chan *Job
+	handler     HandlerFunc
+	concurrency int
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+	finished map[string]time.Time
+
+	wg sync.WaitGroup
+}
+
+// NewQueue creates a queue with the given worker count and backlog capacity
+func NewQueue(concurrency, capacity int, handler HandlerFunc) *Queue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if capacity < 1 {
+		capacity = 100
+	}
+	return &Queue{
+		jobs:        make(chan *Job, capacity),
+		handler:     handler,
+		concurrency: concurrency,
+		inFlight:    make(map[string]bool),
+		finished:    make(map[string]time.Time),
+	}
+}
+
+// Enqueue adds a job without blocking
+func (q *Queue) Enqueue(job *Job) error {
+	key := job.Key()
+
+	q.mu.Lock()
+	for k, at := range q.finished {
+		if time.Since(at) > DedupeWindow {
+			delete(q.finished, k)
+		}
+	}
+	if _, done := q.finished[key]; done || q.inFlight[key] {
+		q.mu.Unlock()
+		return ErrDuplicateJob
+	}
+	q.inFlight[key] = true
+	q.mu.Unlock()
+
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		q.release(key)
+		return ErrQueueFull
+	}
+}
+
+// Start launches the workers. They stop once ctx is cancelled; call Wait to
+// block until running jobs finish.
+func (q *Queue) Start(ctx context.Context) {
+	for i := 0; i < q.concurrency; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+}
+
+// Wait blocks until all workers have exited
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+// Pending returns the number of jobs waiting for a worker
+func (q *Queue) Pending() int {
+	return len(q.jobs)
+}
+
+func (q *Queue) worker(ctx context.Context) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-q.jobs:
+			q.run(ctx, job)
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, job *Job) {
+	defer q.finish(job.Key())
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "[WEBHOOK] job %s panicked: %v\n", job.ID, r)
+		}
+	}()
+
+	if err := q.handler(ctx, job); err != nil {
+		fmt.Fprintf(os.Stderr, "[WEBHOOK] job %s failed: %v\n", job.ID, err)
+	}
+}
+
+func (q *Queue) release(key string) {
+	q.mu.Lock()
+	delete(q.inFlight, key)
+	q.mu.Unlock()
+}
+
+// finish releases a job that ran, keeping its key to drop redeliveries
+func (q *Queue) finish(key string) {
+	q.mu.Lock()
+	delete(q.inFlight, key)
+	q.finished[key] = time.Now()
+	q.mu.Unlock()
+}
@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ExecuteFunc runs the agent for a job inside workDir and returns a short
+// summary of what it did.
+type ExecuteFunc func(ctx context.Context, job *Job, workDir string) (string, error)
+
+// GitLabDeveloper is the GitLab access level of developers, the lowest
+// that can push to a project
+const GitLabDeveloper = 30
+
+// Identifier is implemented by forges that can tell which account the
+// runner's token acts as
+type Identifier interface {
+	Self(ctx context.Context, job *Job) (string, error)
+}
+
+// AccessChecker is implemented by forges whose webhooks don't say what the
+// sender may do in the project, such as GitLab
+type AccessChecker interface {
+	AccessLevel(ctx context.Context, job *Job) (int, error)
+}
+
+// Runner turns queued jobs into agent runs: it prepares an isolated
+// workspace, executes the agent, pushes the result and reports back.
+type Runner struct {
+	Forges        map[Provider]Forge
+	Tokens        map[Provider]string
+	Execute       ExecuteFunc
+	WorkspaceRoot string
+	Timeout       time.Duration
+
+	// BotLogins are accounts the runner posts and pushes as, besides the
+	// one its forge token belongs to. Their events never start jobs.
+	BotLogins []string
+	// MinAccessLevel is the GitLab access level a sender needs, by default
+	// GitLabDeveloper
+	MinAccessLevel int
+}
+
+// Handle implements HandlerFunc
+func (r *Runner) Handle(ctx context.Context, job *Job) error {
+	forge, ok := r.Forges[job.Provider]
+	if !ok {
+		return fmt.Errorf("no forge configured for %s", job.Provider)
+	}
+
+	// Refused jobs get no status or comment, which would only feed a loop
+	// or answer whoever sent them
+	if err := r.authorize(ctx, forge, job); err != nil {
+		fmt.Fprintf(os.Stderr, "[WEBHOOK] ignoring %s %s: %v\n", job.Kind, job.ID, err)
+		return nil
+	}
+
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = 25 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r.report(ctx, forge, job, StatusPending, "Autofix running")
+
+	ws, err := PrepareWorkspace(ctx, job, r.WorkspaceRoot, r.Tokens[job.Provider])
+	if err != nil {
+		r.fail(ctx, forge, job, err)
+		return err
+	}
+	defer ws.Cleanup()
+
+	fmt.Fprintf(os.Stderr, "[WEBHOOK] running %s %s in %s\n", job.Kind, job.ID, ws.Dir)
+
+	summary, err := r.Execute(ctx, job, ws.Dir)
+	if err != nil {
+		r.fail(ctx, forge, job, err)
+		return err
+	}
+
+	if !ws.HasChanges(ctx) {
+		r.report(ctx, forge, job, StatusSuccess, "Autofix finished without changes")
+		r.comment(ctx, forge, job, fmt.Sprintf("🤖 GPTCode looked into this but made no changes.\n\n%s", summary))
+		return nil
+	}
+
+	message := CommitMessage(job)
+	switch job.Kind {
+	case JobReviewComment:
+		// Review fixes go straight onto the PR branch
+		if err := ws.CommitAndPush(ctx, job.Branch, message); err != nil {
+			r.fail(ctx, forge, job, err)
+			return err
+		}
+		r.report(ctx, forge, job, StatusSuccess, "Review comment addressed")
+		r.comment(ctx, forge, job, fmt.Sprintf("🤖 Addressed in the latest push.\n\n%s", summary))
+
+	default:
+		branch := FixBranch(job)
+		if err := ws.CommitAndPush(ctx, branch, message); err != nil {
+			r.fail(ctx, forge, job, err)
+			return err
+		}
+
+		base := job.Branch
+		body := fmt.Sprintf("%s\n\n%s", describeJob(job), summary)
+		url, err := forge.OpenChangeRequest(ctx, job, branch, base, message, body)
+		if err != nil {
+			r.fail(ctx, forge, job, err)
+			return err
+		}
+
+		r.report(ctx, forge, job, StatusSuccess, "Fix proposed")
+		r.comment(ctx, forge, job, fmt.Sprintf("🤖 GPTCode proposed a fix: %s\n\n%s", url, summary))
+	}
+
+	return nil
+}
+
+// BuildPrompt turns a job into the task description handed to the agent
+func BuildPrompt(job *Job) string {
+	switch job.Kind {
+	case JobCheckFailure:
+		return fmt.Sprintf(`The CI check %q failed on branch '%s' at commit %s.
+
+%s
+
+Details: %s
+
+Discover what failed by examining the code, tests and CI configuration,
+reproduce the failure locally if possible and apply a minimal fix.
+Do not commit, push or open pull requests; that is handled for you.`,
+			job.CheckName, job.Branch, job.SHA, job.Body, job.DetailURL)
+
+	case JobIssue:
+		return fmt.Sprintf(`Fix issue #%d: %s
+
+%s
+
+Implement the change with tests where appropriate.
+Do not commit, push or open pull requests; that is handled for you.`,
+			job.IssueNumber, job.Title, job.Body)
+
+	case JobReviewComment:
+		return fmt.Sprintf(`Address review comment on pull request #%d (%s):
+File: %s (line %d)
+Comment from @%s: %s
+
+Read the file, understand the context and implement the requested change.
+Do not commit, push or reply to the comment; that is handled for you.`,
+			job.PRNumber, job.Title, job.Path, job.Line, job.Author, job.Body)
+	}
+	return job.Title + "\n\n" + job.Body
+}
+
+// FixBranch returns the branch name used for proposed fixes
+func FixBranch(job *Job) string {
+	switch job.Kind {
+	case JobIssue:
+		return fmt.Sprintf("%sissue-%d", FixBranchPrefix, job.IssueNumber)
+	case JobCheckFailure:
+		sha := job.SHA
+		if len(sha) > 8 {
+			sha = sha[:8]
+		}
+		return fmt.Sprintf("%sfix-%s", FixBranchPrefix, sha)
+	}
+	return FixBranchPrefix + job.ID
+}
+
+// CommitMessage returns the commit (and PR title) for a job's changes
+func CommitMessage(job *Job) string {
+	switch job.Kind {
+	case JobIssue:
+		return fmt.Sprintf("fix: %s (#%d)", job.Title, job.IssueNumber)
+	case JobCheckFailure:
+		return fmt.Sprintf("fix: %s", job.CheckName)
+	case JobReviewComment:
+		return fmt.Sprintf("Address review comment on %s", job.Path)
+	}
+	return "fix: automated change"
+}
+
+func describeJob(job *Job) string {
+	switch job.Kind {
+	case JobIssue:
+		return fmt.Sprintf("Closes #%d", job.IssueNumber)
+	case JobCheckFailure:
+		return fmt.Sprintf("Automated fix for failing check %q at %s.", job.CheckName, job.SHA)
+	}
+	return ""
+}
+
+// authorize refuses jobs the runner's own account triggered, so its
+// comments and pushes can't start further jobs, and on forges that need a
+// lookup, jobs from senders who couldn't push themselves
+func (r *Runner) authorize(ctx context.Context, forge Forge, job *Job) error {
+	self := append([]string{}, r.BotLogins...)
+	if id, ok := forge.(Identifier); ok {
+		login, err := id.Self(ctx, job)
+		if err != nil {
+			return fmt.Errorf("failed to look up the runner's account: %w", err)
+		}
+		self = append(self, login)
+	}
+	for _, login := range self {
+		if login != "" && strings.EqualFold(login, job.Sender) {
+			return fmt.Errorf("triggered by the runner's own account %s", login)
+		}
+	}
+
+	if checker, ok := forge.(AccessChecker); ok {
+		required := r.MinAccessLevel
+		if required == 0 {
+			required = GitLabDeveloper
+		}
+		level, err := checker.AccessLevel(ctx, job)
+		if err != nil {
+			return fmt.Errorf("failed to check the access of %s: %w", job.Sender, err)
+		}
+		if level < required {
+			return fmt.Errorf("%s has access level %d, %d required", job.Sender, level, required)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) fail(ctx context.Context, forge Forge, job *Job, err error) {
+	r.report(ctx, forge, job, StatusFailure, "Autofix failed")
+	r.comment(ctx, forge, job, fmt.Sprintf("🤖 GPTCode could not complete this automatically:\n\n```\n%v\n```", redact(err.Error(), r.Tokens[job.Provider])))
+}
+
+// report and comment are best-effort: a reporting failure must not abort the job
+func (r *Runner) report(ctx context.Context, forge Forge, job *Job, status Status, description string) {
+	if err := forge.SetStatus(ctx, job, status, description); err != nil {
+		fmt.Fprintf(os.Stderr, "[WEBHOOK] status update failed for %s: %v\n", job.ID, err)
+	}
+}
+
+func (r *Runner) comment(ctx context.Context, forge Forge, job *Job, body string) {
+	if err := forge.Comment(ctx, job, body); err != nil {
+		fmt.Fprintf(os.Stderr, "[WEBHOOK] comment failed for %s: %v\n", job.ID, err)
+	}
+}
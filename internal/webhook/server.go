@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// maxPayloadSize caps webhook bodies; GitHub documents 25MB as its upper bound
+const maxPayloadSize = 25 << 20
+
+// ServerConfig configures the webhook HTTP server
+type ServerConfig struct {
+	Port         int
+	GitHubSecret string
+	GitLabSecret string
+	TriggerLabel string
+}
+
+// Server receives forge webhooks, verifies them and feeds jobs into a Queue.
+//
+// Endpoints:
+//   - POST /webhooks/github — GitHub deliveries (X-Hub-Signature-256)
+//   - POST /webhooks/gitlab — GitLab deliveries (X-Gitlab-Token)
+//   - GET  /health          — health check
+type Server struct {
+	cfg        ServerConfig
+	queue      *Queue
+	httpServer *http.Server
+}
+
+// NewServer creates a webhook server backed by queue
+func NewServer(cfg ServerConfig, queue *Queue) *Server {
+	if cfg.TriggerLabel == "" {
+		cfg.TriggerLabel = DefaultTriggerLabel
+	}
+	return &Server{cfg: cfg, queue: queue}
+}
+
+// Handler returns the HTTP handler, mainly for tests
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhooks/github", s.handleGitHub)
+	mux.HandleFunc("/webhooks/gitlab", s.handleGitLab)
+	mux.HandleFunc("/health", s.handleHealth)
+	return mux
+}
+
+// ListenAndServe starts the HTTP server. Blocks until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	s.httpServer = &http.Server{
+		Addr:         fmt.Sprintf(":%d", s.cfg.Port),
+		Handler:      s.Handler(),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.httpServer.Shutdown(shutdownCtx)
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
+
+func (s *Server) handleGitHub(w http.ResponseWriter, r *http.Request) {
+	body, ok := readPayload(w, r)
+	if !ok {
+		return
+	}
+
+	if err := VerifyGitHubSignature(s.cfg.GitHubSecret, body, r.Header.Get("X-Hub-Signature-256")); err != nil {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	eventType := r.Header.Get("X-GitHub-Event")
+	if eventType == "ping" {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "pong"})
+		return
+	}
+
+	job, err := ParseGitHubEvent(eventType, body, s.cfg.TriggerLabel)
+	s.accept(w, eventType, job, err)
+}
+
+func (s *Server) handleGitLab(w http.ResponseWriter, r *http.Request) {
+	body, ok := readPayload(w, r)
+	if !ok {
+		return
+	}
+
+	if err := VerifyGitLabToken(s.cfg.GitLabSecret, r.Header.Get("X-Gitlab-Token")); err != nil {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	eventType := r.Header.Get("X-Gitlab-Event")
+	job, err := ParseGitLabEvent(eventType, body, s.cfg.TriggerLabel)
+	s.accept(w, eventType, job, err)
+}
+
+// accept enqueues a parsed job and writes the response. Forges retry on 5xx,
+// so a full queue is reported as 503 while ignored events get a 202.
+func (s *Server) accept(w http.ResponseWriter, eventType string, job *Job, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if job == nil {
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "ignored", "event": eventType})
+		return
+	}
+
+	switch err := s.queue.Enqueue(job); {
+	case errors.Is(err, ErrDuplicateJob):
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "duplicate", "job_id": job.ID})
+	case errors.Is(err, ErrQueueFull):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		fmt.Fprintf(os.Stderr, "[WEBHOOK] queued %s %s for %s\n", job.Kind, job.ID, job.Repo)
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued", "job_id": job.ID})
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "ok",
+		"pending": s.queue.Pending(),
+	})
+}
+
+func readPayload(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// VerifyGitHubSignature checks the X-Hub-Signature-256 header against an
+// HMAC-SHA256 of the raw request body keyed by the webhook secret.
+func VerifyGitHubSignature(secret string, body []byte, header string) error {
+	if secret == "" {
+		return fmt.Errorf("github webhook secret not configured")
+	}
+	if !strings.HasPrefix(header, "sha256=") {
+		return fmt.Errorf("missing or malformed X-Hub-Signature-256 header")
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// VerifyGitLabToken checks the X-Gitlab-Token header. GitLab sends the
+// configured secret verbatim, so a constant-time comparison is all we need.
+func VerifyGitLabToken(secret string, header string) error {
+	if secret == "" {
+		return fmt.Errorf("gitlab webhook secret not configured")
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(header)) != 1 {
+		return fmt.Errorf("token mismatch")
+	}
+	return nil
+}
+
+// SignGitHubPayload returns the X-Hub-Signature-256 value for body. Useful for
+// tests and for replaying captured deliveries against a local server.
+func SignGitHubPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Workspace is an isolated checkout for a single job
+type Workspace struct {
+	Dir string
+}
+
+// PrepareWorkspace clones the job's repository into a fresh temp directory
+// under root (os.TempDir() when empty). Credentials are embedded in the
+// remote URL of the clone only, never in global git config.
+func PrepareWorkspace(ctx context.Context, job *Job, root, token string) (*Workspace, error) {
+	dir, err := os.MkdirTemp(root, "gptcode-job-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	ws := &Workspace{Dir: dir}
+
+	cloneURL, err := authenticatedURL(job, token)
+	if err != nil {
+		ws.Cleanup()
+		return nil, err
+	}
+
+	args := []string{"clone", "--depth", "50"}
+	if job.Branch != "" {
+		args = append(args, "--branch", job.Branch)
+	}
+	args = append(args, cloneURL, dir)
+
+	if out, err := exec.CommandContext(ctx, "git", args...).CombinedOutput(); err != nil {
+		ws.Cleanup()
+		return nil, fmt.Errorf("failed to clone %s: %w\nOutput: %s", job.Repo, err, redact(string(out), token))
+	}
+
+	// Pin to the reported commit when it is within the shallow history
+	if job.SHA != "" && job.Kind == JobCheckFailure {
+		ws.git(ctx, "checkout", "-q", job.SHA)
+	}
+
+	ws.git(ctx, "config", "user.email", "gptcode-agent@users.noreply.github.com")
+	ws.git(ctx, "config", "user.name", "GPTCode Agent")
+
+	return ws, nil
+}
+
+// HasChanges reports whether the agent left uncommitted changes
+func (w *Workspace) HasChanges(ctx context.Context) bool {
+	out, err := w.git(ctx, "status", "--porcelain")
+	return err == nil && strings.TrimSpace(out) != ""
+}
+
+// CommitAndPush commits all changes onto branch and pushes it to origin
+func (w *Workspace) CommitAndPush(ctx context.Context, branch, message string) error {
+	if _, err := w.git(ctx, "checkout", "-B", branch); err != nil {
+		return err
+	}
+	if _, err := w.git(ctx, "add", "-A"); err != nil {
+		return err
+	}
+	if _, err := w.git(ctx, "commit", "-m", message); err != nil {
+		return err
+	}
+	if _, err := w.git(ctx, "push", "origin", "HEAD:refs/heads/"+branch); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Cleanup removes the workspace directory
+func (w *Workspace) Cleanup() {
+	os.RemoveAll(w.Dir)
+}
+
+func (w *Workspace) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = w.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("git %s failed: %w\nOutput: %s", args[0], err, string(out))
+	}
+	return string(out), nil
+}
+
+func authenticatedURL(job *Job, token string) (string, error) {
+	raw := job.CloneURL
+	if raw == "" && job.Provider == ProviderGitHub {
+		raw = fmt.Sprintf("https://github.com/%s.git", job.Repo)
+	}
+	if raw == "" {
+		return "", fmt.Errorf("no clone URL for %s", job.Repo)
+	}
+	if token == "" {
+		return raw, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid clone URL %q: %w", raw, err)
+	}
+	switch job.Provider {
+	case ProviderGitLab:
+		u.User = url.UserPassword("oauth2", token)
+	default:
+		u.User = url.UserPassword("x-access-token", token)
+	}
+	return u.String(), nil
+}
+
+func redact(s, token string) string {
+	if token == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, token, "***")
+}